@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// План на день: утром в заданное в настройках время учитель получает список
+// сегодняшних занятий (время, ученик, направление, контакт, заметки), свободные
+// слоты и ожидающие действия. Группы с событием «сводка занятий» получают
+// сокращенный план без контактов и заметок. До конца дня сообщение
+// редактируется на месте, когда меняется расписание или проходят занятия.
+
+const (
+	agendaNoteLength = 100              // Сколько символов заметки показывать в плане
+	agendaSendWindow = 30 * time.Minute // Сколько после заданного времени план еще отправляется
+)
+
+// Текущее время в часовом поясе расписания (как у слотов: настенное время с пометкой UTC)
+func scheduleNow() time.Time {
+	return toScheduleTime(time.Now(), config.Location())
+}
+
+// Текст плана учителя на день now; full — с контактами, заметками и ожидающими действиями
+func buildAgenda(lang string, teacherID int64, now time.Time, full bool) (string, error) {
+	schedules, err := store.GetTeacherSchedule(teacherID)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "agenda.title", formatDate(lang, now)))
+
+	var lessons int
+	var free []string
+	for _, s := range schedules {
+		start, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil || !sameDay(start, now) {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, s.EndTime)
+		if err != nil {
+			continue
+		}
+		if s.Status != "booked" || !s.StudentID.Valid {
+			if start.After(now) {
+				free = append(free, clockOf(s.StartTime)+"–"+clockOf(s.EndTime))
+			}
+			continue
+		}
+
+		if lessons == 0 {
+			builder.WriteString(T(lang, "agenda.lessons"))
+		}
+		lessons++
+		icon := "🕒"
+		switch {
+		case !end.After(now):
+			icon = "✔️"
+		case !start.After(now):
+			icon = "▶️"
+		}
+		builder.WriteString(htmlf("%s %s–%s — %s", icon, clockOf(s.StartTime), clockOf(s.EndTime), studentName(s.StudentID.Int64)))
+		if s.Direction.Valid && s.Direction.String != "" {
+			builder.WriteString(htmlf(" (%s)", s.Direction.String))
+		}
+		builder.WriteString("\n")
+		if full {
+			builder.WriteString(agendaLessonDetails(lang, s))
+		}
+	}
+	if lessons == 0 {
+		builder.WriteString(T(lang, "agenda.no_lessons"))
+	}
+
+	if len(free) == 0 {
+		builder.WriteString(T(lang, "agenda.no_free"))
+	} else {
+		builder.WriteString(T(lang, "agenda.free", strings.Join(free, ", ")))
+	}
+
+	if full {
+		pending, err := agendaPending(lang, teacherID)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(pending)
+	}
+	return builder.String(), nil
+}
+
+// Контакт ученика и заметки к занятию
+func agendaLessonDetails(lang string, s Schedule) string {
+	var builder strings.Builder
+	if contact := studentContact(s.StudentID.Int64); contact != "" {
+		builder.WriteString(htmlf("    📞 %s\n", contact))
+	}
+	notes, err := store.GetLessonNotes(int64(s.ID))
+	if err != nil {
+		fmt.Println("Ошибка получения заметок:", err, "slotID:", s.ID)
+		return builder.String()
+	}
+	for _, n := range notes {
+		text := n.Text
+		if text == "" {
+			text = T(lang, "notes.kind."+n.Kind)
+		}
+		if utf8.RuneCountInString(text) > agendaNoteLength {
+			text = string([]rune(text)[:agendaNoteLength]) + "…"
+		}
+		builder.WriteString(htmlf("    %s %s\n", noteIcon(n.Kind), text))
+	}
+	return builder.String()
+}
+
+// Телефон и @username ученика
+func studentContact(studentID int64) string {
+	user, err := store.GetUser(studentID)
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	if p, err := store.GetStudentProfile(studentID); err == nil && p != nil && p.Phone.Valid && p.Phone.String != "" {
+		parts = append(parts, p.Phone.String)
+	} else if user.Contact.Valid && user.Contact.String != "" {
+		parts = append(parts, user.Contact.String)
+	}
+	if user.Username.Valid && user.Username.String != "" {
+		parts = append(parts, "@"+user.Username.String)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Ожидающие действия учителя: ответы на проверку и непрочитанные уведомления
+func agendaPending(lang string, teacherID int64) (string, error) {
+	homework, err := store.GetTeacherHomework(teacherID)
+	if err != nil {
+		return "", err
+	}
+	toReview := 0
+	for _, hw := range homework {
+		if hw.Status == homeworkSubmitted {
+			toReview++
+		}
+	}
+	unread, err := store.CountUnreadNotifications(teacherID)
+	if err != nil {
+		return "", err
+	}
+	if toReview == 0 && unread == 0 {
+		return T(lang, "agenda.no_pending"), nil
+	}
+	text := T(lang, "agenda.pending")
+	if toReview > 0 {
+		text += T(lang, "agenda.pending_homework", toReview)
+	}
+	if unread > 0 {
+		text += T(lang, "agenda.pending_notifications", unread)
+	}
+	return text, nil
+}
+
+// План на сегодня по запросу (/agenda)
+func showAgenda(chatID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "план на день"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	text, err := buildAgenda(lang, chatID, scheduleNow(), true)
+	if err != nil {
+		fmt.Println("Ошибка составления плана на день:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "settings.open"), cbNotifySettings),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, text, &keyboard)
+}
+
+// Отправка планов в заданное время и обновление уже отправленных
+func dailyAgendas(ctx context.Context) {
+	for sleepContext(ctx, 1*time.Minute) {
+		refreshAgendas(scheduleNow())
+	}
+}
+
+// Ключ отправленного плана
+type agendaKey struct {
+	chatID    int64
+	teacherID int64
+}
+
+func refreshAgendas(now time.Time) {
+	day := now.Format("2006-01-02")
+	agendas, err := store.GetDailyAgendas(day)
+	if err != nil {
+		fmt.Println("Ошибка получения планов на день:", err)
+		return
+	}
+	sent := make(map[agendaKey]DailyAgenda, len(agendas))
+	for _, a := range agendas {
+		sent[agendaKey{a.ChatID, a.TeacherID}] = a
+	}
+
+	teachers, err := store.GetAllTeachers()
+	if err != nil {
+		fmt.Println("Ошибка получения учителей:", err)
+		return
+	}
+	for _, t := range teachers {
+		settings := notificationSettings(t.TelegramID)
+		due := agendaDue(now, settings.AgendaTime)
+
+		if settings.EnableAgenda {
+			a, ok := sent[agendaKey{t.TelegramID, t.TelegramID}]
+			if ok || due {
+				updateAgenda(t.TelegramID, t.TelegramID, day, now, true, settings.PinAgenda, a)
+			}
+		}
+
+		groups, err := store.GetGroupsForEvent(t.TelegramID, groupEventAgenda)
+		if err != nil {
+			fmt.Println("Ошибка получения групп:", err, "teacherID:", t.TelegramID)
+			continue
+		}
+		for _, g := range groups {
+			a, ok := sent[agendaKey{g.ChatID, t.TelegramID}]
+			if ok || due {
+				updateAgenda(g.ChatID, t.TelegramID, day, now, false, g.PinAgenda, a)
+			}
+		}
+	}
+
+	if err := store.DeleteDailyAgendasBefore(day); err != nil {
+		fmt.Println("Ошибка удаления старых планов на день:", err)
+	}
+}
+
+// Пора ли отправить план: в течение agendaSendWindow после времени из настроек.
+// Если бот был выключен или план включили позже, сегодняшний план пропускается.
+func agendaDue(now time.Time, agendaTime string) bool {
+	clock, err := time.Parse("15:04", agendaTime)
+	if err != nil {
+		return false
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	return !now.Before(at) && now.Sub(at) < agendaSendWindow
+}
+
+// Отправка плана в чат или его изменение, если расписание поменялось.
+// prev — отправленный сегодня план (MessageID == 0, если его еще не было).
+func updateAgenda(chatID, teacherID int64, day string, now time.Time, full, pin bool, prev DailyAgenda) {
+	text, err := buildAgenda(userLang(chatID), teacherID, now, full)
+	if err != nil {
+		fmt.Println("Ошибка составления плана на день:", err, "teacherID:", teacherID)
+		return
+	}
+	if prev.MessageID != 0 {
+		if prev.Text == text {
+			return
+		}
+		// Сохраняем текст и при ошибке, чтобы не повторять ее каждую минуту
+		editMessage(chatID, prev.MessageID, text)
+		prev.Text = text
+		if err := store.SaveDailyAgenda(prev); err != nil {
+			fmt.Println("Ошибка сохранения плана на день:", err)
+		}
+		return
+	}
+
+	// Сообщение не связано с меню и не удаляется при следующем ответе бота
+	var msg tgbotapi.Message
+	if chatID < 0 {
+		msg, err = sendGroupMessage(chatID, text, nil, PriorityNormal)
+	} else {
+		plain := tgbotapi.NewMessage(chatID, text)
+		plain.ParseMode = parseMode
+		msg, err = messenger.Send(plain)
+		if err != nil {
+			fmt.Println("Ошибка отправки плана на день:", err, "chatID:", chatID)
+		}
+	}
+	if err != nil {
+		return
+	}
+	if pin {
+		pinMessage(chatID, msg.MessageID)
+	}
+	err = store.SaveDailyAgenda(DailyAgenda{ChatID: chatID, TeacherID: teacherID, Day: day, MessageID: msg.MessageID, Text: text})
+	if err != nil {
+		fmt.Println("Ошибка сохранения плана на день:", err)
+	}
+}
+
+// Закрепление сообщения без уведомления участников
+func pinMessage(chatID int64, messageID int) {
+	_, err := messenger.PinChatMessage(tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: messageID, DisableNotification: true})
+	if err != nil {
+		fmt.Println("Ошибка закрепления сообщения:", err, "chatID:", chatID)
+	}
+}
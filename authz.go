@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// ErrForbidden — у пользователя нет прав на действие
+var ErrForbidden = errors.New("недостаточно прав")
+
+// Отказ в доступе с записью в лог
+func deny(chatID int64, action, reason string) error {
+	fmt.Println("Отказано в доступе:", action, "chatID:", chatID, "причина:", reason)
+	return ErrForbidden
+}
+
+// Проверка роли пользователя
+func authorizeRole(chatID int64, role, action string) (*User, error) {
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		return nil, deny(chatID, action, "пользователь не зарегистрирован")
+	}
+	if user.Role != role {
+		return nil, deny(chatID, action, "роль "+user.Role)
+	}
+	return user, nil
+}
+
+// Действие доступно только учителю
+func authorizeTeacher(chatID int64, action string) error {
+	_, err := authorizeRole(chatID, "teacher", action)
+	return err
+}
+
+// Изменение слота: учитель и владелец слота
+func authorizeSlotOwner(chatID, slotID int64, action string) (*Schedule, error) {
+	if err := authorizeTeacher(chatID, action); err != nil {
+		return nil, err
+	}
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if slot.TeacherID != chatID {
+		return nil, deny(chatID, action, fmt.Sprintf("слот %d принадлежит учителю %d", slotID, slot.TeacherID))
+	}
+	return slot, nil
+}
+
+// Запись на слот: только ученик, не заблокированный учителем этого слота
+func authorizeBooking(chatID, slotID int64) (*Schedule, error) {
+	const action = "запись на слот"
+	if _, err := authorizeRole(chatID, "student", action); err != nil {
+		return nil, err
+	}
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := store.IsStudentBlocked(slot.TeacherID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, deny(chatID, action, fmt.Sprintf("ученик заблокирован учителем %d", slot.TeacherID))
+	}
+	return slot, nil
+}
+
+// Отмена записи: ученик, который записан на слот
+func authorizeCancel(chatID, slotID int64) (*Schedule, error) {
+	const action = "отмена записи"
+	if _, err := authorizeRole(chatID, "student", action); err != nil {
+		return nil, err
+	}
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if slot.Status != "booked" || !slot.StudentID.Valid || slot.StudentID.Int64 != chatID {
+		return nil, deny(chatID, action, fmt.Sprintf("слот %d не принадлежит ученику", slotID))
+	}
+	return slot, nil
+}
+
+// Изменение уведомления: учитель, которому оно адресовано
+func authorizeNotification(chatID int64, notificationID int) error {
+	const action = "изменение уведомления"
+	if err := authorizeTeacher(chatID, action); err != nil {
+		return err
+	}
+	n, err := store.GetNotificationByID(notificationID)
+	if err != nil {
+		return err
+	}
+	if n.TeacherID != chatID {
+		return deny(chatID, action, fmt.Sprintf("уведомление %d адресовано %d", notificationID, n.TeacherID))
+	}
+	return nil
+}
+
+// Домашнее задание: учитель, который его выдал, или ученик, получивший его
+func authorizeHomework(chatID, homeworkID int64, action string) (*Homework, error) {
+	hw, err := store.GetHomework(homeworkID)
+	if err != nil {
+		return nil, err
+	}
+	if hw.TeacherID == chatID {
+		return hw, nil
+	}
+	if hw.StudentID == chatID && hw.SentAt.Valid {
+		return hw, nil
+	}
+	return nil, deny(chatID, action, fmt.Sprintf("задание %d не принадлежит пользователю", homeworkID))
+}
+
+// Группа: учитель, который ее привязал
+func authorizeGroup(chatID, groupChatID int64, action string) (*GroupChat, error) {
+	if err := authorizeTeacher(chatID, action); err != nil {
+		return nil, err
+	}
+	g, err := store.GetGroupChat(groupChatID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, deny(chatID, action, fmt.Sprintf("группа %d не привязана учителем", groupChatID))
+	}
+	return g, nil
+}
+
+// Привязка чата из личного чата: пользователь — создатель или администратор этого чата
+func authorizeChatAdmin(chatID, targetChatID int64, action string) error {
+	member, err := messenger.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: targetChatID, UserID: int(chatID)})
+	if err != nil {
+		return fmt.Errorf("ошибка проверки администратора чата %d: %v", targetChatID, err)
+	}
+	if !member.IsCreator() && !member.IsAdministrator() {
+		return deny(chatID, action, fmt.Sprintf("статус %q в чате %d", member.Status, targetChatID))
+	}
+	return nil
+}
+
+// Сообщение пользователю об ошибке проверки прав
+func sendAuthError(chatID int64, err error) {
+	if errors.Is(err, ErrForbidden) {
+		sendMessage(chatID, tr(chatID, "auth.forbidden"))
+		return
+	}
+	fmt.Println("Ошибка проверки прав:", err, "chatID:", chatID)
+	sendMessage(chatID, tr(chatID, "auth.failed"))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Доска свободных слотов: закрепленное сообщение учителя в группе или канале
+// со списком ближайших свободных слотов и кнопками «Записаться» (ссылки
+// t.me/<бот>?start=slot_<id>). Любое изменение слотов через Store отмечает
+// доски учителя, и фоновая задача редактирует их на месте.
+
+const (
+	boardSize            = 20              // Сколько слотов показывать на доске
+	boardRefreshInterval = 5 * time.Second // Как часто применять изменения слотов
+	boardSlotPayload     = "slot_"         // Префикс параметра /start для записи на слот
+)
+
+// BoardTracker — Store, который после добавления, удаления слота или смены его
+// статуса (запись, отмена) отмечает доски учителя для обновления
+type BoardTracker struct {
+	Store
+}
+
+func NewBoardTracker(next Store) *BoardTracker {
+	return &BoardTracker{Store: next}
+}
+
+func (t *BoardTracker) AddScheduleSlot(teacherID int64, startTime, endTime string) error {
+	err := t.Store.AddScheduleSlot(teacherID, startTime, endTime)
+	if err == nil {
+		changedBoards.Mark(teacherID)
+	}
+	return err
+}
+
+func (t *BoardTracker) UpdateScheduleStatus(scheduleID int64, status string, studentID int64, direction string) error {
+	err := t.Store.UpdateScheduleStatus(scheduleID, status, studentID, direction)
+	if err == nil {
+		if slot, getErr := t.Store.GetScheduleByID(scheduleID); getErr == nil {
+			changedBoards.Mark(slot.TeacherID)
+		}
+	}
+	return err
+}
+
+func (t *BoardTracker) BookSlot(scheduleID, studentID int64, direction string) error {
+	err := t.Store.BookSlot(scheduleID, studentID, direction)
+	if err == nil {
+		if slot, getErr := t.Store.GetScheduleByID(scheduleID); getErr == nil {
+			changedBoards.Mark(slot.TeacherID)
+		}
+	}
+	return err
+}
+
+func (t *BoardTracker) DeleteScheduleSlot(scheduleID int64) error {
+	slot, getErr := t.Store.GetScheduleByID(scheduleID)
+	err := t.Store.DeleteScheduleSlot(scheduleID)
+	if err == nil && getErr == nil {
+		changedBoards.Mark(slot.TeacherID)
+	}
+	return err
+}
+
+// Учителя, у которых менялись слоты с прошлого обновления досок
+type boardChanges struct {
+	mu       sync.Mutex
+	teachers map[int64]bool
+}
+
+var changedBoards boardChanges
+
+func (c *boardChanges) Mark(teacherID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.teachers == nil {
+		c.teachers = make(map[int64]bool)
+	}
+	c.teachers[teacherID] = true
+}
+
+func (c *boardChanges) Take() map[int64]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	teachers := c.teachers
+	c.teachers = nil
+	return teachers
+}
+
+// Обновление досок: после изменений слотов и раз в минуту, чтобы убрать начавшиеся слоты
+func slotBoards(ctx context.Context) {
+	var lastFull time.Time
+	for sleepContext(ctx, boardRefreshInterval) {
+		teachers := changedBoards.Take()
+		if time.Since(lastFull) >= time.Minute {
+			all, err := store.GetBoardTeachers()
+			if err != nil {
+				fmt.Println("Ошибка получения досок слотов:", err)
+			}
+			if teachers == nil {
+				teachers = make(map[int64]bool)
+			}
+			for _, id := range all {
+				teachers[id] = true
+			}
+			lastFull = time.Now()
+		}
+		for teacherID := range teachers {
+			refreshSlotBoards(teacherID)
+		}
+	}
+}
+
+// Ссылка на бота с параметром /start; пустая строка — имя бота неизвестно
+func botLink(payload string) string {
+	name := messenger.Self().UserName
+	if name == "" {
+		return ""
+	}
+	link := "https://t.me/" + name
+	if payload != "" {
+		link += "?start=" + payload
+	}
+	return link
+}
+
+// Текст и кнопки доски учителя
+func buildSlotBoard(lang string, teacherID int64, now time.Time) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	keyboard := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	schedules, err := store.GetTeacherSchedule(teacherID)
+	if err != nil {
+		return "", keyboard, err
+	}
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "board.title"))
+	shown, more := 0, 0
+	for _, s := range schedules {
+		start, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil || s.Status != "free" || !start.After(now) {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, s.EndTime)
+		if err != nil {
+			continue
+		}
+		if shown == boardSize {
+			more++
+			continue
+		}
+		shown++
+		slot := formatSlotRange(lang, ProposedSlot{Start: start, End: end})
+		builder.WriteString("• " + slot + "\n")
+		if link := botLink(boardSlotPayload + cbID(int64(s.ID))); link != "" {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL(T(lang, "board.book", slot), link),
+			))
+		}
+	}
+	if shown == 0 {
+		builder.WriteString(T(lang, "board.empty"))
+	}
+	if more > 0 {
+		builder.WriteString(T(lang, "board.more", more))
+	}
+	return builder.String(), keyboard, nil
+}
+
+// Обновление всех досок учителя
+func refreshSlotBoards(teacherID int64) {
+	groups, err := store.GetGroupsForEvent(teacherID, groupEventBoard)
+	if err != nil {
+		fmt.Println("Ошибка получения групп:", err, "teacherID:", teacherID)
+		return
+	}
+	if len(groups) == 0 {
+		return
+	}
+	text, keyboard, err := buildSlotBoard(defaultLang, teacherID, scheduleNow())
+	if err != nil {
+		fmt.Println("Ошибка составления доски слотов:", err, "teacherID:", teacherID)
+		return
+	}
+	for _, g := range groups {
+		updateSlotBoard(g.ChatID, teacherID, text, keyboard)
+	}
+}
+
+// Изменение доски на месте; если ее еще нет или она удалена из чата — новая доска с закреплением
+func updateSlotBoard(chatID, teacherID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	b, err := store.GetSlotBoard(chatID, teacherID)
+	if err != nil {
+		fmt.Println("Ошибка получения доски слотов:", err)
+		return
+	}
+	if b != nil {
+		if b.Text == text {
+			return
+		}
+		edit := tgbotapi.NewEditMessageText(chatID, b.MessageID, text)
+		edit.ParseMode = parseMode
+		edit.DisableWebPagePreview = true
+		edit.ReplyMarkup = &keyboard
+		_, err := messenger.Send(edit)
+		if err == nil || strings.Contains(err.Error(), "message is not modified") {
+			b.Text = text
+			if err := store.SaveSlotBoard(*b); err != nil {
+				fmt.Println("Ошибка сохранения доски слотов:", err)
+			}
+			return
+		}
+		fmt.Println("Ошибка изменения доски слотов:", err, "chatID:", chatID)
+		// Новая доска — только если старой больше нет; при других ошибках
+		// (лимит запросов, сеть) повторяем изменение при следующем обновлении
+		if !boardMessageGone(err) {
+			changedBoards.Mark(teacherID)
+			return
+		}
+	}
+
+	msg, err := sendGroupMessage(chatID, text, &keyboard, PriorityNormal)
+	if err != nil {
+		return
+	}
+	pinMessage(chatID, msg.MessageID)
+	err = store.SaveSlotBoard(SlotBoard{ChatID: chatID, TeacherID: teacherID, MessageID: msg.MessageID, Text: text})
+	if err != nil {
+		fmt.Println("Ошибка сохранения доски слотов:", err)
+	}
+}
+
+// Сообщение доски удалено из чата или больше не может быть изменено
+func boardMessageGone(err error) bool {
+	text := err.Error()
+	return strings.Contains(text, "message to edit not found") || strings.Contains(text, "message can't be edited")
+}
+
+// Удаление доски из чата (событие отключено или группа отвязана)
+func removeSlotBoard(chatID, teacherID int64) {
+	b, err := store.GetSlotBoard(chatID, teacherID)
+	if err != nil {
+		fmt.Println("Ошибка получения доски слотов:", err)
+		return
+	}
+	if b == nil {
+		return
+	}
+	deleteMessage(chatID, b.MessageID)
+	if err := store.DeleteSlotBoard(chatID, teacherID); err != nil {
+		fmt.Println("Ошибка удаления доски слотов:", err)
+	}
+}
+
+// ID слота из параметра /start ("slot_<id>")
+func slotFromStartPayload(payload string) (int64, bool) {
+	if !strings.HasPrefix(payload, boardSlotPayload) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(payload, boardSlotPayload), 36, 64)
+	return id, err == nil
+}
+
+// Предложение записаться на слот, выбранный на доске; notice — текст перед предложением
+func showSlotOffer(chatID, slotID int64, notice string) {
+	lang := userLang(chatID)
+	var text string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	slot, err := store.GetScheduleByID(slotID)
+	var start, end time.Time
+	if err == nil {
+		start, err = time.Parse(time.RFC3339, slot.StartTime)
+	}
+	if err == nil {
+		end, err = time.Parse(time.RFC3339, slot.EndTime)
+	}
+	if err != nil || slot.Status != "free" || !start.After(scheduleNow()) {
+		text = T(lang, "board.slot_gone")
+	} else {
+		text = T(lang, "board.offer", formatSlotRange(lang, ProposedSlot{Start: start, End: end}))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "board.confirm"), cbBook, cbID(slotID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "board.other_time"), cbBookCalendar),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sendMessageWithKeyboard(chatID, notice+text, &keyboard)
+}
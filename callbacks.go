@@ -0,0 +1,848 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Формат данных кнопки: <версия>:<код маршрута>[:<аргумент>...].
+// При несовместимом изменении маршрутов версия увеличивается, и кнопки
+// в старых сообщениях просто возвращают пользователя в меню.
+const (
+	callbackVersion   = "1"
+	callbackSeparator = ":"
+	callbackDataLimit = 64 // Ограничение Telegram на callback_data в байтах
+)
+
+// Коды маршрутов кнопок
+const (
+	cbMenu = "m"  // Главное меню
+	cbNoop = "x"  // Некликабельная кнопка (заголовки календаря, прошедшие дни)
+	cbICal = "il" // Ссылка на подписку на календарь
+	// Язык интерфейса
+	cbLanguage    = "lg" // Выбор языка
+	cbSetLanguage = "ls" // Смена языка: ru, en или auto
+	// Диалоги
+	cbDialogCancel     = "dx" // Отмена ожидания ввода
+	cbCancelReasonSkip = "cr" // Отмена записи без причины
+	// Учитель
+	cbTeacherSchedule    = "ts" // Просмотр расписания
+	cbTeacherStudents    = "st" // Список учеников
+	cbSlotCalendar       = "sc" // Календарь добавления слотов [месяц]
+	cbSlotDay            = "sd" // Часы выбранного дня: дата
+	cbSlotAdd            = "sa" // Добавление слота: дата, время
+	cbSlotCustomTime     = "so" // Ввод своего времени слота: дата
+	cbSlotDeleteList     = "dl" // Список слотов для удаления
+	cbSlotDeleteSelect   = "ds" // Удаление слота из списка: id
+	cbSlotDelete         = "dd" // Удаление занятого слота: id
+	cbNotifications      = "n"  // Уведомления
+	cbNotificationRead   = "nr" // Отметить уведомление прочитанным: id
+	cbNotificationsClear = "nc" // Очистить уведомления
+	cbICalRotate         = "ir" // Смена ссылки на календарь
+	cbExtCalendars       = "ec" // Внешние календари
+	cbExtCalendarDelete  = "ed" // Удаление внешнего календаря: id
+	cbImportConfirm      = "ic" // Подтверждение импорта CSV
+	cbImportCancel       = "ix" // Отмена импорта CSV
+	cbSlotTextConfirm    = "tc" // Добавление слотов, введенных текстом
+	cbSlotTextCancel     = "tx" // Отмена слотов, введенных текстом
+	// Ученик
+	cbBookCalendar = "bc" // Календарь записи [месяц]
+	cbBookDay      = "bd" // Свободные слоты дня: дата
+	cbBook         = "b"  // Запись на слот: id
+	cbMyBookings   = "mb" // Мои записи
+	cbCancelList   = "cl" // Список записей для отмены
+	cbCancel       = "c"  // Отмена записи: id
+	cbReminderOK   = "ro" // Подтверждение напоминания: id
+
+	cbProfile          = "pf" // Анкета ученика
+	cbProfileEdit      = "pe" // Заполнение анкеты заново
+	cbProfileLevel     = "pv" // Уровень в анкете: код уровня
+	cbProfileSkipGoals = "pg" // Пропуск целей в анкете
+	cbProfileDirection = "pd" // Направление в анкете: код направления
+	cbStudentCard      = "su" // Карточка ученика (для учителя): id
+
+	cbStudentsPage    = "sp" // Страница справочника учеников: номер
+	cbStudentsSearch  = "sf" // Поиск в справочнике учеников
+	cbStudentMessage  = "sm" // Сообщение ученику: id
+	cbStudentNote     = "sn" // Заметка об ученике: id
+	cbStudentBookList = "sb" // Выбор слота для записи ученика: id
+	cbStudentBookSlot = "sk" // Запись ученика на слот: id ученика, id слота
+	cbStudentBlock    = "sx" // Блокировка ученика: id, 1 или 0
+	cbStudentTimeline = "sh" // История ученика: id, страница
+
+	cbLesson     = "lv" // Занятие с заметками: id слота
+	cbLessonNote = "ln" // Заметка к занятию: id слота
+	cbNoteShow   = "nv" // Голосовое или фото из заметки: id заметки
+
+	cbLessonHomework    = "hl" // Домашнее задание к занятию: id слота
+	cbHomework          = "hw" // Домашнее задание: id
+	cbHomeworkList      = "hs" // Список домашних заданий
+	cbHomeworkDue       = "hd" // Выбор срока сдачи: id
+	cbHomeworkSetDue    = "ht" // Срок сдачи: id, unix-время
+	cbHomeworkFiles     = "hf" // Файлы задания или ответа: id, teacher или student
+	cbHomeworkDelete    = "hx" // Удаление задания: id
+	cbHomeworkSubmit    = "ha" // Ответ на задание: id
+	cbHomeworkSend      = "hu" // Отправка ответа на проверку: id
+	cbHomeworkReview    = "hr" // Проверка ответа: id
+	cbHomeworkGrade     = "hg" // Оценка ответа: id, оценка
+	cbHomeworkNoComment = "hn" // Оценка без комментария: id, оценка
+
+	cbFeedbackRate  = "fr" // Оценка занятия учеником: id слота, оценка
+	cbFeedbackSkip  = "fs" // Оценка без комментария: id слота
+	cbLessonSummary = "fm" // Итог занятия от учителя: id слота
+	cbTeacherStats  = "tt" // Статистика учителя
+
+	cbGroups      = "gl" // Группы учителя
+	cbGroup       = "gs" // Настройки группы: id группы
+	cbGroupToggle = "ge" // Включение события в группе: id группы, событие
+	cbGroupUnlink = "gu" // Отвязка группы: id группы
+
+	cbNotifySettings = "ns" // Настройки уведомлений
+	cbSettingToggle  = "nt" // Переключение настройки: имя настройки
+	cbAgendaTime     = "at" // Выбор времени плана на день
+	cbAgendaSetTime  = "aa" // Время плана на день: ЧЧММ
+	cbAgenda         = "ag" // План на сегодня
+)
+
+// Маршрут кнопки
+type CallbackRoute struct {
+	Code        string
+	Role        string // Требуемая роль; пустая строка — любой зарегистрированный пользователь
+	KeepMessage bool   // Не удалять у учителя сообщение с нажатой кнопкой
+	Handle      func(c *CallbackContext) error
+}
+
+// Контекст нажатия кнопки
+type CallbackContext struct {
+	Query     *tgbotapi.CallbackQuery
+	ChatID    int64
+	MessageID int
+	User      *User
+	Args      []string
+}
+
+var callbackRoutes = map[string]CallbackRoute{}
+
+// Регистрация маршрута кнопки
+func registerCallback(route CallbackRoute) {
+	if _, exists := callbackRoutes[route.Code]; exists {
+		panic("маршрут кнопки уже зарегистрирован: " + route.Code)
+	}
+	callbackRoutes[route.Code] = route
+}
+
+// Данные кнопки для маршрута с аргументами
+func callbackData(code string, args ...string) string {
+	parts := append([]string{callbackVersion, code}, args...)
+	data := strings.Join(parts, callbackSeparator)
+	if len(data) > callbackDataLimit {
+		fmt.Println("Данные кнопки превышают лимит Telegram:", data)
+		return callbackData(cbNoop)
+	}
+	return data
+}
+
+// Кнопка с данными маршрута
+func callbackButton(text, code string, args ...string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, callbackData(code, args...))
+}
+
+// Кодирование аргументов кнопок
+func cbID(id int64) string { return strconv.FormatInt(id, 36) }
+
+func cbDate(t time.Time) string { return t.Format("20060102") }
+
+func cbMonth(year int, month time.Month) string {
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Format("200601")
+}
+
+func cbClock(hour, minute int) string { return fmt.Sprintf("%02d%02d", hour, minute) }
+
+// Разбор данных кнопки: код маршрута и аргументы
+func parseCallbackData(data string) (string, []string, error) {
+	parts := strings.Split(data, callbackSeparator)
+	if len(parts) < 2 || parts[0] != callbackVersion {
+		return "", nil, fmt.Errorf("устаревший формат данных кнопки: %q", data)
+	}
+	return parts[1], parts[2:], nil
+}
+
+func (c *CallbackContext) arg(i int) (string, error) {
+	if i >= len(c.Args) {
+		return "", fmt.Errorf("нет аргумента %d", i)
+	}
+	return c.Args[i], nil
+}
+
+// Идентификатор из аргумента i
+func (c *CallbackContext) ID(i int) (int64, error) {
+	s, err := c.arg(i)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 36, 64)
+}
+
+// Дата из аргумента i
+func (c *CallbackContext) Date(i int) (time.Time, error) {
+	s, err := c.arg(i)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse("20060102", s)
+}
+
+// Месяц из аргумента i; без аргумента — текущий месяц по времени расписания
+func (c *CallbackContext) Month(i int) (int, time.Month, error) {
+	if i >= len(c.Args) {
+		now := scheduleNow()
+		return now.Year(), now.Month(), nil
+	}
+	t, err := time.Parse("200601", c.Args[i])
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Year(), t.Month(), nil
+}
+
+// Время суток (часы и минуты) из аргумента i
+func (c *CallbackContext) Clock(i int) (int, int, error) {
+	s, err := c.arg(i)
+	if err != nil {
+		return 0, 0, err
+	}
+	t, err := time.Parse("1504", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Единая точка обработки нажатий: разбор данных, проверка роли, вызов
+// маршрута и ответ на callback-запрос
+func handleCallback(query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		answerCallback(query, "")
+		return
+	}
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		answerCallback(query, "")
+		sendMessage(chatID, tr(chatID, "error.user"))
+		return
+	}
+
+	code, args, err := parseCallbackData(query.Data)
+	route, ok := callbackRoutes[code]
+	if err != nil || !ok {
+		fmt.Println("Неизвестная кнопка:", query.Data, "chatID:", chatID)
+		answerCallback(query, tr(chatID, "callback.stale"))
+		showMenu(chatID, user)
+		return
+	}
+
+	if route.Role != "" && user.Role != route.Role {
+		fmt.Println("Отказано в доступе к кнопке:", query.Data, "chatID:", chatID, "роль:", user.Role)
+		answerCallback(query, tr(chatID, "callback.denied"))
+		return
+	}
+
+	if user.Role == "teacher" && !route.KeepMessage {
+		deleteMessage(chatID, messageID)
+	}
+
+	c := &CallbackContext{
+		Query:     query,
+		ChatID:    chatID,
+		MessageID: messageID,
+		User:      user,
+		Args:      args,
+	}
+
+	if err := route.Handle(c); err != nil {
+		fmt.Println("Ошибка обработки кнопки:", err, "data:", query.Data)
+		answerCallback(query, tr(chatID, "callback.bad_data"))
+		return
+	}
+	answerCallback(query, "")
+}
+
+func answerCallback(query *tgbotapi.CallbackQuery, text string) {
+	if _, err := messenger.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, text)); err != nil {
+		fmt.Println("Ошибка ответа на callback:", err)
+	}
+}
+
+// Главное меню по роли пользователя
+func showMenu(chatID int64, user *User) {
+	if user.Role == "teacher" {
+		showTeacherMenu(chatID)
+	} else {
+		showStudentMenu(chatID)
+	}
+}
+
+// Прошедшие дни (по времени расписания) в календаре не открываются
+func isPastDate(date time.Time) bool {
+	return date.Before(scheduleNow().Truncate(24 * time.Hour))
+}
+
+func init() {
+	registerCallback(CallbackRoute{Code: cbMenu, Handle: func(c *CallbackContext) error {
+		showMenu(c.ChatID, c.User)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbNoop, KeepMessage: true, Handle: func(c *CallbackContext) error {
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbICal, Handle: func(c *CallbackContext) error {
+		handleCalendarLink(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbICalRotate, Handle: func(c *CallbackContext) error {
+		handleCalendarRotate(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbDialogCancel, Handle: func(c *CallbackContext) error {
+		cancelDialog(c.ChatID)
+		showMenu(c.ChatID, c.User)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbCancelReasonSkip, Role: "student", Handle: func(c *CallbackContext) error {
+		cancelDialog(c.ChatID)
+		showMenu(c.ChatID, c.User)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbLanguage, Handle: func(c *CallbackContext) error {
+		showLanguageSettings(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSetLanguage, Handle: func(c *CallbackContext) error {
+		lang, err := c.arg(0)
+		if err != nil {
+			return err
+		}
+		if lang == languageAuto {
+			lang = ""
+		} else if _, ok := catalog[lang]; !ok {
+			return fmt.Errorf("неизвестный язык: %s", lang)
+		}
+		handleSetLanguage(c.ChatID, lang)
+		return nil
+	}})
+
+	// Учитель
+	registerCallback(CallbackRoute{Code: cbTeacherSchedule, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleTeacherSchedule(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbTeacherStudents, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleTeacherStudents(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotCalendar, Role: "teacher", Handle: func(c *CallbackContext) error {
+		year, month, err := c.Month(0)
+		if err != nil {
+			return err
+		}
+		showMonthCalendar(c.ChatID, year, month)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotDay, Role: "teacher", Handle: func(c *CallbackContext) error {
+		date, err := c.Date(0)
+		if err != nil {
+			return err
+		}
+		if isPastDate(date) {
+			return nil
+		}
+		showTimeSlots(c.ChatID, date.Format("2006-01-02"))
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotAdd, Role: "teacher", Handle: func(c *CallbackContext) error {
+		date, err := c.Date(0)
+		if err != nil {
+			return err
+		}
+		hour, minute, err := c.Clock(1)
+		if err != nil {
+			return err
+		}
+		handleAddSlot(c.ChatID, time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.UTC), defaultSlotLength)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotCustomTime, Role: "teacher", Handle: func(c *CallbackContext) error {
+		date, err := c.Date(0)
+		if err != nil {
+			return err
+		}
+		if isPastDate(date) {
+			return nil
+		}
+		return askSlotTime(c.ChatID, date)
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotDeleteList, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		handleDeleteSchedule(c.ChatID, c.MessageID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotDeleteSelect, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleSelectDeleteSlot(c.ChatID, c.MessageID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotDelete, Role: "teacher", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleDeleteSlot(c.ChatID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbNotifications, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		showNotifications(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbNotificationRead, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		notificationID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleMarkNotificationRead(c.ChatID, int(notificationID))
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbNotificationsClear, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		handleClearNotifications(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbExtCalendars, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleExternalCalendars(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbExtCalendarDelete, Role: "teacher", Handle: func(c *CallbackContext) error {
+		calendarID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleDeleteExternalCalendar(c.ChatID, calendarID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotTextConfirm, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleSlotTextConfirm(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSlotTextCancel, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleSlotTextCancel(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbImportConfirm, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleImportConfirm(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbImportCancel, Role: "teacher", Handle: func(c *CallbackContext) error {
+		handleImportCancel(c.ChatID)
+		return nil
+	}})
+
+	// Ученик
+	registerCallback(CallbackRoute{Code: cbBookCalendar, Role: "student", Handle: func(c *CallbackContext) error {
+		year, month, err := c.Month(0)
+		if err != nil {
+			return err
+		}
+		showStudentMonthCalendar(c.ChatID, year, month)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbBookDay, Role: "student", Handle: func(c *CallbackContext) error {
+		date, err := c.Date(0)
+		if err != nil {
+			return err
+		}
+		if isPastDate(date) {
+			return nil
+		}
+		showStudentTimeSlots(c.ChatID, date.Format("2006-01-02"))
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbBook, Role: "student", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleBooking(c.ChatID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbMyBookings, Role: "student", Handle: func(c *CallbackContext) error {
+		handleStudentBookings(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbCancelList, Role: "student", Handle: func(c *CallbackContext) error {
+		handleStudentCancel(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbCancel, Role: "student", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		handleCancelBooking(c.ChatID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbReminderOK, Role: "student", Handle: func(c *CallbackContext) error {
+		if lastID, exists := lastMessageID.Get(c.ChatID); exists {
+			deleteMessage(c.ChatID, lastID)
+		}
+		showStudentMenu(c.ChatID)
+		return nil
+	}})
+
+	// Анкета и карточка ученика
+	registerCallback(CallbackRoute{Code: cbProfile, Role: "student", Handle: func(c *CallbackContext) error {
+		showProfile(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbProfileEdit, Role: "student", Handle: func(c *CallbackContext) error {
+		return startProfile(c.ChatID, false, "")
+	}})
+	// Кнопки ответов снимают ожидание текстового ввода того же шага и передают дальше параметр /start
+	registerCallback(CallbackRoute{Code: cbProfileLevel, Role: "student", Handle: func(c *CallbackContext) error {
+		level, err := c.arg(0)
+		if err != nil {
+			return err
+		}
+		if levelName(defaultLang, level) == level {
+			return fmt.Errorf("неизвестный уровень: %s", level)
+		}
+		return setProfileLevel(c.ChatID, level, takeProfilePayload(c.ChatID))
+	}})
+	registerCallback(CallbackRoute{Code: cbProfileSkipGoals, Role: "student", Handle: func(c *CallbackContext) error {
+		return setProfileGoals(c.ChatID, "", takeProfilePayload(c.ChatID))
+	}})
+	registerCallback(CallbackRoute{Code: cbProfileDirection, Role: "student", Handle: func(c *CallbackContext) error {
+		direction, err := c.arg(0)
+		if err != nil {
+			return err
+		}
+		if directionName(defaultLang, direction) == direction {
+			return fmt.Errorf("неизвестное направление: %s", direction)
+		}
+		return setProfileDirection(c.ChatID, direction, takeProfilePayload(c.ChatID))
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentCard, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showStudentCard(c.ChatID, studentID)
+		return nil
+	}})
+
+	// Справочник учеников
+	registerCallback(CallbackRoute{Code: cbStudentsPage, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		page, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showStudentDirectory(c.ChatID, int(page))
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentsSearch, Role: "teacher", Handle: func(c *CallbackContext) error {
+		return askInput(c.ChatID, dialogStudentSearch, "", tr(c.ChatID, "students.search_prompt"))
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentMessage, Role: "teacher", Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		prompt := htmlf(tr(c.ChatID, "students.message_prompt"), studentName(studentID))
+		return askInput(c.ChatID, dialogStudentMessage, strconv.FormatInt(studentID, 10), prompt)
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentNote, Role: "teacher", Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return askStudentNote(c.ChatID, studentID)
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentBookList, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showBookForStudent(c.ChatID, studentID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentBookSlot, Role: "teacher", Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		slotID, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		handleBookForStudent(c.ChatID, studentID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentBlock, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		blocked, err := c.arg(1)
+		if err != nil {
+			return err
+		}
+		handleBlockStudent(c.ChatID, studentID, blocked == "1")
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbStudentTimeline, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		studentID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		page, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		showStudentTimeline(c.ChatID, studentID, int(page))
+		return nil
+	}})
+
+	// Занятия и заметки
+	registerCallback(CallbackRoute{Code: cbLesson, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showLesson(c.ChatID, slotID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbLessonNote, Role: "teacher", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return askLessonNote(c.ChatID, slotID)
+	}})
+	registerCallback(CallbackRoute{Code: cbNoteShow, Role: "teacher", KeepMessage: true, Handle: func(c *CallbackContext) error {
+		noteID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showNoteMedia(c.ChatID, noteID)
+		return nil
+	}})
+
+	// Домашние задания
+	registerCallback(CallbackRoute{Code: cbLessonHomework, Role: "teacher", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return openLessonHomework(c.ChatID, slotID)
+	}})
+	registerCallback(CallbackRoute{Code: cbHomework, KeepMessage: true, Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showHomework(c.ChatID, homeworkID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkList, Handle: func(c *CallbackContext) error {
+		showHomeworkList(c.ChatID, c.User)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkDue, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return askHomeworkDue(c.ChatID, homeworkID, "")
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkSetDue, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		due, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		cancelDialog(c.ChatID)
+		return setHomeworkDue(c.ChatID, homeworkID, time.Unix(due, 0).UTC())
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkFiles, KeepMessage: true, Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		owner, err := c.arg(1)
+		if err != nil {
+			return err
+		}
+		if owner != "teacher" && owner != "student" {
+			return fmt.Errorf("неизвестный владелец файлов: %s", owner)
+		}
+		sendHomeworkFiles(c.ChatID, homeworkID, owner)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkDelete, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		cancelDialog(c.ChatID)
+		return deleteHomework(c.ChatID, homeworkID)
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkSubmit, Role: "student", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		hw, err := studentAssignment(c.ChatID, homeworkID, "сдача домашнего задания")
+		if err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		return askHomeworkAnswer(c.ChatID, hw, "")
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkSend, Role: "student", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		cancelDialog(c.ChatID)
+		return submitHomework(c.ChatID, homeworkID)
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkReview, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showHomeworkGrades(c.ChatID, homeworkID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkGrade, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		grade, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		return askHomeworkComment(c.ChatID, homeworkID, int(grade), "")
+	}})
+	registerCallback(CallbackRoute{Code: cbHomeworkNoComment, Role: "teacher", Handle: func(c *CallbackContext) error {
+		homeworkID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		grade, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		cancelDialog(c.ChatID)
+		return reviewHomework(c.ChatID, homeworkID, int(grade), "")
+	}})
+
+	// Отзывы после занятий и статистика
+	registerCallback(CallbackRoute{Code: cbFeedbackRate, Role: "student", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		rating, err := c.ID(1)
+		if err != nil {
+			return err
+		}
+		return rateLesson(c.ChatID, slotID, int(rating))
+	}})
+	registerCallback(CallbackRoute{Code: cbFeedbackSkip, Role: "student", Handle: func(c *CallbackContext) error {
+		cancelDialog(c.ChatID)
+		showFeedbackThanks(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbLessonSummary, Role: "teacher", Handle: func(c *CallbackContext) error {
+		slotID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return askLessonSummary(c.ChatID, slotID)
+	}})
+	registerCallback(CallbackRoute{Code: cbTeacherStats, Role: "teacher", Handle: func(c *CallbackContext) error {
+		showTeacherStats(c.ChatID)
+		return nil
+	}})
+
+	// Группы
+	registerCallback(CallbackRoute{Code: cbGroups, Role: "teacher", Handle: func(c *CallbackContext) error {
+		showGroups(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbGroup, Role: "teacher", Handle: func(c *CallbackContext) error {
+		groupChatID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		showGroupSettings(c.ChatID, groupChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbGroupToggle, Role: "teacher", Handle: func(c *CallbackContext) error {
+		groupChatID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		event, err := c.arg(1)
+		if err != nil {
+			return err
+		}
+		return toggleGroupEvent(c.ChatID, groupChatID, event)
+	}})
+	registerCallback(CallbackRoute{Code: cbGroupUnlink, Role: "teacher", Handle: func(c *CallbackContext) error {
+		groupChatID, err := c.ID(0)
+		if err != nil {
+			return err
+		}
+		return unlinkGroup(c.ChatID, groupChatID)
+	}})
+
+	// Настройки уведомлений и план на день
+	registerCallback(CallbackRoute{Code: cbNotifySettings, Role: "teacher", Handle: func(c *CallbackContext) error {
+		showNotificationSettings(c.ChatID)
+		return nil
+	}})
+	registerCallback(CallbackRoute{Code: cbSettingToggle, Role: "teacher", Handle: func(c *CallbackContext) error {
+		setting, err := c.arg(0)
+		if err != nil {
+			return err
+		}
+		return toggleNotificationSetting(c.ChatID, setting)
+	}})
+	registerCallback(CallbackRoute{Code: cbAgendaTime, Role: "teacher", Handle: func(c *CallbackContext) error {
+		return askAgendaTime(c.ChatID, "")
+	}})
+	registerCallback(CallbackRoute{Code: cbAgendaSetTime, Role: "teacher", Handle: func(c *CallbackContext) error {
+		hour, minute, err := c.Clock(0)
+		if err != nil {
+			return err
+		}
+		cancelDialog(c.ChatID)
+		return setAgendaTime(c.ChatID, hour, minute)
+	}})
+	registerCallback(CallbackRoute{Code: cbAgenda, Role: "teacher", Handle: func(c *CallbackContext) error {
+		showAgenda(c.ChatID)
+		return nil
+	}})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Календарь считает дни по часовому поясу расписания, а не по часам сервера
+func TestCalendarDates(t *testing.T) {
+	config.Timezone = "Pacific/Kiritimati" // UTC+14: дата почти всегда впереди UTC
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	today := scheduleNow()
+	day := func(offset int) time.Time {
+		return time.Date(today.Year(), today.Month(), today.Day()+offset, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name string
+		date time.Time
+		past bool
+	}{
+		{"вчера", day(-1), true},
+		{"сегодня", day(0), false},
+		{"завтра", day(1), false},
+	}
+	for _, c := range cases {
+		if got := isPastDate(c.date); got != c.past {
+			t.Errorf("%s (%s): isPastDate = %v", c.name, c.date.Format("2006-01-02"), got)
+		}
+	}
+
+	c := &CallbackContext{}
+	year, month, err := c.Month(0)
+	if err != nil || year != today.Year() || month != today.Month() {
+		t.Errorf("месяц по умолчанию: %d-%02d, %v; ожидалось %s", year, month, err, today.Format("2006-01"))
+	}
+	c.Args = []string{"209902"}
+	if year, month, err := c.Month(0); err != nil || year != 2099 || month != time.February {
+		t.Errorf("месяц из аргумента: %d-%02d, %v", year, month, err)
+	}
+}
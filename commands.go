@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Команды с аргументами (/addslot, /free, /book, /delslot, /today) и списки
+// команд, которые Telegram показывает в подсказках
+
+// Команда в списке подсказок: имя и ключ описания в каталоге
+type botCommand struct {
+	Name string
+	Key  string
+}
+
+var studentCommands = []botCommand{
+	{"start", "cmd.start"},
+	{"book", "cmd.book"},
+	{"free", "cmd.free"},
+	{"today", "cmd.today"},
+	{"mybookings", "cmd.mybookings"},
+	{"cancel", "cmd.cancel"},
+	{"homework", "cmd.homework"},
+	{"profile", "cmd.profile"},
+	{"calendar_link", "cmd.calendar_link"},
+	{"language", "cmd.language"},
+}
+
+var teacherCommands = []botCommand{
+	{"start", "cmd.start"},
+	{"today", "cmd.today"},
+	{"agenda", "cmd.agenda"},
+	{"schedule", "cmd.schedule"},
+	{"addslot", "cmd.addslot"},
+	{"free", "cmd.free_teacher"},
+	{"delslot", "cmd.delslot"},
+	{"students", "cmd.students"},
+	{"homework", "cmd.homework_teacher"},
+	{"stats", "cmd.stats"},
+	{"groups", "cmd.groups"},
+	{"calendars", "cmd.calendars"},
+	{"add_calendar", "cmd.add_calendar"},
+	{"export", "cmd.export"},
+	{"export_students", "cmd.export_students"},
+	{"calendar_link", "cmd.calendar_link"},
+	{"language", "cmd.language"},
+	{"cancel", "cmd.cancel_input"},
+}
+
+// Команды в группах: привязка группы к учителю
+var groupCommands = []botCommand{
+	{"linkgroup", "cmd.linkgroup"},
+	{"unlinkgroup", "cmd.unlinkgroup"},
+}
+
+// language_code из Telegram, для которых подсказки на русском (см. langFromCode);
+// остальным достаются английские
+var russianLanguageCodes = []string{"ru", "uk", "be", "kk"}
+
+var reLength = regexp.MustCompile(`^(\d+)(?:h|ч)$`)
+
+// Регистрация подсказок команд: общий список для учеников и отдельный
+// список в чате каждого учителя
+func registerBotCommands() {
+	if err := setBotCommands(studentCommands, langEN, "", map[string]interface{}{"type": "default"}); err != nil {
+		fmt.Println("Ошибка регистрации команд:", err)
+	}
+	for _, code := range russianLanguageCodes {
+		if err := setBotCommands(studentCommands, langRU, code, map[string]interface{}{"type": "default"}); err != nil {
+			fmt.Println("Ошибка регистрации команд:", err, "язык:", code)
+		}
+	}
+
+	groupScope := map[string]interface{}{"type": "all_group_chats"}
+	if err := setBotCommands(groupCommands, langEN, "", groupScope); err != nil {
+		fmt.Println("Ошибка регистрации команд групп:", err)
+	}
+	for _, code := range russianLanguageCodes {
+		if err := setBotCommands(groupCommands, langRU, code, groupScope); err != nil {
+			fmt.Println("Ошибка регистрации команд групп:", err, "язык:", code)
+		}
+	}
+
+	teacherIDs := map[int64]bool{config.TeacherID: true}
+	teachers, err := store.GetAllTeachers()
+	if err != nil {
+		fmt.Println("Ошибка получения учителей для команд:", err)
+	}
+	for _, t := range teachers {
+		teacherIDs[t.TelegramID] = true
+	}
+	for id := range teacherIDs {
+		if id != 0 {
+			updateChatCommands(id)
+		}
+	}
+}
+
+// Подсказки команд в чате пользователя: учителю — команды учителя, ученику с
+// выбранным в настройках языком — команды ученика на этом языке
+func updateChatCommands(chatID int64) {
+	scope := map[string]interface{}{"type": "chat", "chat_id": chatID}
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		// Учитель из конфигурации еще не запускал бота
+		if chatID == config.TeacherID {
+			err = setBotCommands(teacherCommands, defaultLang, "", scope)
+		}
+	} else if user.Role == "teacher" {
+		err = setBotCommands(teacherCommands, user.Lang(), "", scope)
+	} else if user.Language.Valid {
+		err = setBotCommands(studentCommands, user.Lang(), "", scope)
+	} else {
+		err = deleteBotCommands(scope)
+	}
+	if err != nil {
+		fmt.Println("Ошибка обновления команд чата:", err, "chatID:", chatID)
+	}
+}
+
+func setBotCommands(commands []botCommand, lang, languageCode string, scope map[string]interface{}) error {
+	list := make([]map[string]string, 0, len(commands))
+	for _, c := range commands {
+		list = append(list, map[string]string{"command": c.Name, "description": T(lang, c.Key)})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования команд: %v", err)
+	}
+	params := url.Values{}
+	params.Set("commands", string(data))
+	return botCommandsRequest("setMyCommands", params, languageCode, scope)
+}
+
+func deleteBotCommands(scope map[string]interface{}) error {
+	return botCommandsRequest("deleteMyCommands", url.Values{}, "", scope)
+}
+
+// В используемой версии библиотеки нет методов для команд — вызываем API напрямую
+func botCommandsRequest(method string, params url.Values, languageCode string, scope map[string]interface{}) error {
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования области команд: %v", err)
+	}
+	params.Set("scope", string(data))
+	if languageCode != "" {
+		params.Set("language_code", languageCode)
+	}
+	if _, err := messenger.MakeRequest(method, params); err != nil {
+		return fmt.Errorf("ошибка %s: %v", method, err)
+	}
+	return nil
+}
+
+// Ошибка в аргументах команды и подсказка по использованию
+func sendUsage(chatID int64, usageKey, errKey, arg string) {
+	lang := userLang(chatID)
+	text := T(lang, usageKey)
+	if errKey != "" {
+		text = htmlf(T(lang, errKey), arg) + "\n\n" + text
+	}
+	sendMessage(chatID, text)
+}
+
+// День из аргумента команды: "2025-03-10", "10.03", "завтра", "пн"
+func parseCommandDay(tok string, now time.Time) (time.Time, error) {
+	tok = strings.ToLower(strings.TrimSpace(tok))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case hasKey(slotTextDays, tok):
+		return today.AddDate(0, 0, slotTextDays[tok]), nil
+	case isWeekdayToken(tok):
+		return today.AddDate(0, 0, (int(slotTextWeekdays[tok])-int(today.Weekday())+7)%7), nil
+	case reDayMonth.MatchString(tok):
+		return parseDayMonth(tok, today)
+	case reISODate.MatchString(tok):
+		if d, err := time.Parse("2006-01-02", tok); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата: %s", tok)
+}
+
+// Длительность слота: "90m", "90", "45мин", "2h", "1h30m"
+func parseSlotLength(tok string) (time.Duration, error) {
+	tok = strings.ToLower(strings.TrimSpace(tok))
+	var length time.Duration
+	if m := reMinutes.FindStringSubmatch(tok); m != nil {
+		minutes, _ := strconv.Atoi(m[1])
+		length = time.Duration(minutes) * time.Minute
+	} else if m := reLength.FindStringSubmatch(tok); m != nil {
+		hours, _ := strconv.Atoi(m[1])
+		length = time.Duration(hours) * time.Hour
+	} else if d, err := time.ParseDuration(tok); err == nil {
+		length = d
+	}
+	if length < 5*time.Minute || length > 24*time.Hour || length%time.Minute != 0 {
+		return 0, fmt.Errorf("неверная длительность: %s", tok)
+	}
+	return length, nil
+}
+
+// /addslot 2025-03-10 18:00 [90m]
+func handleAddSlotCommand(chatID int64, args string) {
+	if err := authorizeTeacher(chatID, "добавление слота"); err != nil {
+		sendMessage(chatID, tr(chatID, "teacher.only"))
+		return
+	}
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 3 {
+		sendUsage(chatID, "usage.addslot", "", "")
+		return
+	}
+	day, err := parseCommandDay(fields[0], scheduleNow())
+	if err != nil {
+		sendUsage(chatID, "usage.addslot", "command.bad_date", fields[0])
+		return
+	}
+	hour, minute, err := parseClock(fields[1])
+	if err != nil {
+		sendUsage(chatID, "usage.addslot", "command.bad_time", fields[1])
+		return
+	}
+	length := defaultSlotLength
+	if len(fields) == 3 {
+		if length, err = parseSlotLength(fields[2]); err != nil {
+			sendUsage(chatID, "usage.addslot", "command.bad_length", fields[2])
+			return
+		}
+	}
+	handleAddSlot(chatID, day.Add(time.Duration(hour)*time.Hour+time.Duration(minute)*time.Minute), length)
+}
+
+// /free [день] — свободное время на день (по умолчанию сегодня)
+func handleFreeCommand(chatID int64, args string) {
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "error.user"))
+		return
+	}
+	fields := strings.Fields(args)
+	if len(fields) > 1 {
+		sendUsage(chatID, "usage.free", "", "")
+		return
+	}
+	day := scheduleNow()
+	if len(fields) == 1 {
+		if day, err = parseCommandDay(fields[0], scheduleNow()); err != nil {
+			sendUsage(chatID, "usage.free", "command.bad_date", fields[0])
+			return
+		}
+	}
+
+	if user.Role == "teacher" {
+		showTimeSlots(chatID, day.Format("2006-01-02"))
+	} else {
+		showStudentTimeSlots(chatID, day.Format("2006-01-02"))
+	}
+}
+
+// /book [день время [@учитель]] — без аргументов открывает календарь записи
+func handleBookCommand(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		handleStudentBook(chatID)
+		return
+	}
+	if len(fields) != 2 && len(fields) != 3 {
+		sendUsage(chatID, "usage.book", "", "")
+		return
+	}
+	day, err := parseCommandDay(fields[0], scheduleNow())
+	if err != nil {
+		sendUsage(chatID, "usage.book", "command.bad_date", fields[0])
+		return
+	}
+	hour, minute, err := parseClock(fields[1])
+	if err != nil {
+		sendUsage(chatID, "usage.book", "command.bad_time", fields[1])
+		return
+	}
+	start := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+
+	slots, err := store.GetAvailableSlotsForDate(day)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "slots.error"))
+		return
+	}
+	teacher := ""
+	if len(fields) == 3 {
+		teacher = strings.TrimPrefix(fields[2], "@")
+	}
+	var matches []Schedule
+	for _, s := range slots {
+		t, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil || !t.Equal(start) {
+			continue
+		}
+		if teacher != "" && !strings.EqualFold(getUsername(s.TeacherID), teacher) {
+			continue
+		}
+		matches = append(matches, s)
+	}
+
+	lang := userLang(chatID)
+	switch len(matches) {
+	case 1:
+		handleBooking(chatID, int64(matches[0].ID))
+		return
+	case 0:
+		sendMessage(chatID, htmlf(T(lang, "book.no_slot"), formatTime(lang, start.Format(time.RFC3339))))
+	default:
+		// В это время свободны несколько учителей: без имени учителя не угадываем
+		names := make([]string, len(matches))
+		for i, s := range matches {
+			names[i] = "@" + getUsername(s.TeacherID)
+		}
+		sendMessage(chatID, htmlf(T(lang, "book.ambiguous"), formatTime(lang, start.Format(time.RFC3339)),
+			strings.Join(names, ", "), fields[0], fields[1]))
+	}
+	showStudentTimeSlots(chatID, day.Format("2006-01-02"))
+}
+
+// /delslot <id>
+func handleDeleteSlotCommand(chatID int64, args string) {
+	if err := authorizeTeacher(chatID, "удаление слота"); err != nil {
+		sendMessage(chatID, tr(chatID, "teacher.only"))
+		return
+	}
+	fields := strings.Fields(args)
+	if len(fields) != 1 {
+		sendUsage(chatID, "usage.delslot", "", "")
+		return
+	}
+	slotID, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "#"), 10, 64)
+	if err != nil || slotID <= 0 {
+		sendUsage(chatID, "usage.delslot", "command.bad_id", fields[0])
+		return
+	}
+	handleDeleteSlot(chatID, slotID)
+}
+
+// /today — занятия на сегодня
+func handleToday(chatID int64) {
+	lang := userLang(chatID)
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, T(lang, "error.user"))
+		return
+	}
+	now := scheduleNow()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var builder strings.Builder
+	if user.Role == "teacher" {
+		slots, err := store.GetSlotsForDate(chatID, today)
+		if err != nil {
+			sendMessage(chatID, T(lang, "schedule.error"))
+			return
+		}
+		for _, s := range slots {
+			builder.WriteString(T(lang, "today.slot", s.ID, clockOf(s.StartTime), clockOf(s.EndTime), statusText(lang, s.Status)))
+		}
+	} else {
+		bookings, err := store.GetStudentBookings(chatID)
+		if err != nil {
+			sendMessage(chatID, T(lang, "bookings.error"))
+			return
+		}
+		for _, b := range bookings {
+			if t, err := time.Parse(time.RFC3339, b.StartTime); err != nil || !sameDay(t, today) {
+				continue
+			}
+			builder.WriteString(htmlf("🕒 %s - %s (%s)\n", clockOf(b.StartTime), clockOf(b.EndTime), b.Direction.String))
+		}
+	}
+
+	if builder.Len() == 0 {
+		sendMessage(chatID, htmlf(T(lang, "today.empty"), formatDate(lang, today)))
+		return
+	}
+	sendMessage(chatID, htmlf(T(lang, "today.title"), formatDate(lang, today))+builder.String())
+}
+
+// Часы и минуты из времени RFC3339
+func clockOf(timeStr string) string {
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return timeStr
+	}
+	return t.Format("15:04")
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
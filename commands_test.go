@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseCommandDay(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 25, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		tok  string
+		now  time.Time
+		want string // Пусто — ошибка
+	}{
+		{"сегодня", monday, "2026-10-19"},
+		{" Завтра ", monday, "2026-10-20"},
+		{"послезавтра", monday, "2026-10-21"},
+		// День недели — ближайший, включая сегодня
+		{"пн", monday, "2026-10-19"},
+		{"sat", monday, "2026-10-24"},
+		{"вс", monday, "2026-10-25"},
+		// Переход через воскресенье на следующую неделю
+		{"пн", sunday, "2026-10-26"},
+		{"сб", sunday, "2026-10-31"},
+		{"вс", sunday, "2026-10-25"},
+		{"21.10", monday, "2026-10-21"},
+		{"18.10", monday, "2027-10-18"},
+		{"2026-11-05", monday, "2026-11-05"},
+		{"2026-02-30", monday, ""},
+		{"вчера", monday, ""},
+		{"", monday, ""},
+	}
+	for _, c := range cases {
+		got, err := parseCommandDay(c.tok, c.now)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("parseCommandDay(%q) = %v, ожидалась ошибка", c.tok, got)
+			}
+			continue
+		}
+		if err != nil || got.Format("2006-01-02") != c.want {
+			t.Errorf("parseCommandDay(%q, %s) = %v, %v; ожидалось %s", c.tok, c.now.Weekday(), got, err, c.want)
+		}
+	}
+}
+
+func TestParseSlotLength(t *testing.T) {
+	cases := []struct {
+		tok  string
+		want time.Duration // 0 — ошибка
+	}{
+		{"4m", 0},
+		{"5m", 5 * time.Minute},
+		{"90", 90 * time.Minute},
+		{"45мин", 45 * time.Minute},
+		{"2h", 2 * time.Hour},
+		{"2ч", 2 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"24h", 24 * time.Hour},
+		{"25h", 0},
+		{"1h30s", 0},
+		{"0", 0},
+		{"-1h", 0},
+		{"долго", 0},
+	}
+	for _, c := range cases {
+		got, err := parseSlotLength(c.tok)
+		if (err == nil) != (c.want != 0) || got != c.want {
+			t.Errorf("parseSlotLength(%q) = %v, %v; ожидалось %v", c.tok, got, err, c.want)
+		}
+	}
+}
+
+// Подсказки команд обновляются через Messenger: в тестах запросы видны в FakeMessenger
+func TestScenarioChatCommands(t *testing.T) {
+	fm := newScenario(t)
+	// Ученик выбрал язык в настройках; новый ученик еще нет
+	if err := store.SetUserLanguage(scenarioStudent, langEN); err != nil {
+		t.Fatal(err)
+	}
+	const noLanguage int64 = 5
+	if err := store.RegisterUser(noLanguage, "student", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		chatID  int64
+		method  string
+		command string // Команда, которая должна быть в списке
+	}{
+		{scenarioTeacher, "setMyCommands", "agenda"},
+		{scenarioStudent, "setMyCommands", "book"},
+		{noLanguage, "deleteMyCommands", ""},
+	}
+	for _, c := range cases {
+		fm.Reset()
+		updateChatCommands(c.chatID)
+		if len(fm.Requests) != 1 || fm.Requests[0].Method != c.method {
+			t.Fatalf("чат %d: запросы %+v, ожидался %s", c.chatID, fm.Requests, c.method)
+		}
+		params := fm.Requests[0].Params
+
+		var scope struct {
+			Type   string `json:"type"`
+			ChatID int64  `json:"chat_id"`
+		}
+		if err := json.Unmarshal([]byte(params.Get("scope")), &scope); err != nil || scope.Type != "chat" || scope.ChatID != c.chatID {
+			t.Errorf("чат %d: область %q", c.chatID, params.Get("scope"))
+		}
+		if c.command == "" {
+			continue
+		}
+		var commands []struct{ Command, Description string }
+		if err := json.Unmarshal([]byte(params.Get("commands")), &commands); err != nil {
+			t.Fatalf("чат %d: команды %q: %v", c.chatID, params.Get("commands"), err)
+		}
+		found := false
+		for _, cmd := range commands {
+			found = found || cmd.Command == c.command && cmd.Description != ""
+		}
+		if !found {
+			t.Errorf("чат %d: нет команды %s: %+v", c.chatID, c.command, commands)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config содержит настройки бота, считываемые из переменных окружения
+type Config struct {
+	BotToken    string // Токен Telegram-бота (TELEGRAM_BOT_TOKEN)
+	TeacherID   int64  // Telegram ID учителя (TEACHER_ID)
+	HTTPAddr    string // Адрес встроенного HTTP-сервера (HTTP_ADDR)
+	PublicURL   string // Внешний адрес HTTP-сервера для ссылок (PUBLIC_URL)
+	DatabaseDSN string // Путь к файлу базы данных (DATABASE_PATH)
+	Timezone    string // Часовой пояс расписания (TIMEZONE)
+	GroupChatID int64  // Группа, привязываемая к учителю при запуске (GROUP_CHAT_ID)
+
+	UpdateMode    string // Способ получения обновлений: polling или webhook (UPDATE_MODE)
+	WebhookURL    string // Внешний адрес для вебхука, например за обратным прокси (WEBHOOK_URL)
+	WebhookSecret string // Секрет вебхука: часть пути и X-Telegram-Bot-Api-Secret-Token (WEBHOOK_SECRET)
+	TLSCertFile   string // Сертификат для HTTPS-сервера (TLS_CERT_FILE)
+	TLSKeyFile    string // Ключ для HTTPS-сервера (TLS_KEY_FILE)
+
+	Workers int // Число параллельных обработчиков обновлений (WORKERS)
+}
+
+var config Config
+
+// Загрузка настроек из переменных окружения
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		BotToken:    os.Getenv("TELEGRAM_BOT_TOKEN"),
+		HTTPAddr:    getEnv("HTTP_ADDR", ":8080"),
+		PublicURL:   getEnv("PUBLIC_URL", "http://localhost:8080"),
+		DatabaseDSN: getEnv("DATABASE_PATH", "./schedule.db"),
+		Timezone:    getEnv("TIMEZONE", "Europe/Moscow"),
+
+		UpdateMode:    getEnv("UPDATE_MODE", updateModePolling),
+		WebhookURL:    getEnv("WEBHOOK_URL", os.Getenv("PUBLIC_URL")),
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+		TLSCertFile:   os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:    os.Getenv("TLS_KEY_FILE"),
+
+		Workers: 8,
+	}
+
+	if teacherID := os.Getenv("TEACHER_ID"); teacherID != "" {
+		id, err := strconv.ParseInt(teacherID, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("неверный TEACHER_ID %q: %v", teacherID, err)
+		}
+		cfg.TeacherID = id
+	}
+
+	if groupChatID := os.Getenv("GROUP_CHAT_ID"); groupChatID != "" {
+		id, err := strconv.ParseInt(groupChatID, 10, 64)
+		if err != nil || id >= 0 {
+			return cfg, fmt.Errorf("неверный GROUP_CHAT_ID %q: ожидается отрицательный ID группы", groupChatID)
+		}
+		cfg.GroupChatID = id
+	}
+
+	if workers := os.Getenv("WORKERS"); workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("неверный WORKERS %q: ожидается целое число больше 0", workers)
+		}
+		cfg.Workers = n
+	}
+
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return cfg, fmt.Errorf("неверный TIMEZONE %q: %v", cfg.Timezone, err)
+	}
+
+	if cfg.UpdateMode != updateModePolling && cfg.UpdateMode != updateModeWebhook {
+		return cfg, fmt.Errorf("неверный UPDATE_MODE %q: ожидается polling или webhook", cfg.UpdateMode)
+	}
+	if cfg.WebhookSecret != "" && !isValidWebhookSecret(cfg.WebhookSecret) {
+		return cfg, fmt.Errorf("WEBHOOK_SECRET может содержать только A-Z, a-z, 0-9, _ и - (до 256 символов)")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return cfg, fmt.Errorf("для HTTPS нужно указать и TLS_CERT_FILE, и TLS_KEY_FILE")
+	}
+
+	return cfg, nil
+}
+
+// Секрет вебхука по требованиям Telegram: 1-256 символов A-Z, a-z, 0-9, _ и -
+func isValidWebhookSecret(secret string) bool {
+	if len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Часовой пояс, в котором учитель задает время слотов
+func (c Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Значение переменной окружения или значение по умолчанию
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const maxImportSize = 1 << 20 // Ограничение размера загружаемого CSV
+
+// Выгрузка расписания в CSV
+func exportSchedulesCSV(w io.Writer, filter ScheduleFilter) error {
+	rows, err := store.GetSchedulesForExport(filter)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "teacher_id", "start_time", "end_time", "status", "student_id", "student_username", "student_contact", "direction"})
+	for _, r := range rows {
+		studentID := ""
+		if r.StudentID.Valid {
+			studentID = strconv.FormatInt(r.StudentID.Int64, 10)
+		}
+		cw.Write([]string{
+			strconv.Itoa(r.ID),
+			strconv.FormatInt(r.TeacherID, 10),
+			r.StartTime,
+			r.EndTime,
+			r.Status,
+			studentID,
+			r.StudentUsername.String,
+			r.StudentContact.String,
+			r.Direction.String,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Выгрузка учеников в CSV
+func exportStudentsCSV(w io.Writer) error {
+	students, err := store.GetAllStudents()
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"telegram_id", "username", "contact", "active_bookings"})
+	for _, s := range students {
+		cw.Write([]string{
+			strconv.FormatInt(s.TelegramID, 10),
+			s.Username.String,
+			s.Contact.String,
+			strconv.Itoa(s.Bookings),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Импорт слотов из CSV (start_time,end_time) с той же проверкой, что и addScheduleSlot.
+// При dryRun база не изменяется, а отчет показывает, какие строки будут отклонены.
+func importSlotsCSV(r io.Reader, teacherID int64, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	seen := make(map[string]bool)
+	now := scheduleNow()
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("ошибка чтения CSV: %v", err)
+		}
+		// Номер строки файла, где начинается запись (поля в кавычках бывают многострочными)
+		line, _ := cr.FieldPos(0)
+		if first && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "start_time") {
+			continue
+		}
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+
+		report.Total++
+		reject := func(reason string) {
+			report.Rejected = append(report.Rejected, ImportRejected{Line: line, Reason: reason})
+		}
+
+		if len(record) < 2 {
+			reject("ожидается два столбца: start_time, end_time")
+			continue
+		}
+		start, err := parseCSVTime(record[0])
+		if err != nil {
+			reject("неверное время начала: " + record[0])
+			continue
+		}
+		end, err := parseCSVTime(record[1])
+		if err != nil {
+			reject("неверное время окончания: " + record[1])
+			continue
+		}
+		if start.Before(now) {
+			reject("время начала уже прошло")
+			continue
+		}
+
+		startStr, endStr := start.Format(time.RFC3339), end.Format(time.RFC3339)
+		if seen[startStr] {
+			reject("слот повторяется в файле")
+			continue
+		}
+		seen[startStr] = true
+
+		if dryRun {
+			err = store.ValidateScheduleSlot(teacherID, startStr, endStr)
+		} else {
+			err = store.AddScheduleSlot(teacherID, startStr, endStr)
+		}
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+		report.Accepted++
+	}
+	return report, nil
+}
+
+// Разбор времени из CSV: RFC3339 или настенное время "2006-01-02 15:04".
+// Время с суффиксом Z совпадает с форматом хранения (и выгрузки) и берется как есть,
+// время с другим смещением переводится в часовой пояс расписания.
+func parseCSVTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if _, offset := t.Zone(); offset != 0 {
+			return toScheduleTime(t, config.Location()), nil
+		}
+		return t.UTC(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "02.01.2006 15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверный формат времени: %s", value)
+}
+
+// Текст отчета об импорте
+func formatImportReport(lang string, report ImportReport) string {
+	var builder strings.Builder
+	if report.DryRun {
+		builder.WriteString(T(lang, "import.dry_run"))
+	} else {
+		builder.WriteString(T(lang, "import.done"))
+	}
+	builder.WriteString(T(lang, "import.summary", report.Total, report.Accepted, len(report.Rejected)))
+
+	const maxShown = 20
+	for i, r := range report.Rejected {
+		if i == maxShown {
+			builder.WriteString(T(lang, "import.more", len(report.Rejected)-maxShown))
+			break
+		}
+		builder.WriteString(T(lang, "import.row", r.Line, r.Reason))
+	}
+	return builder.String()
+}
+
+// Разбор периода "2006-01-02 2006-01-02" в границы выгрузки
+func parseExportRange(from, to string) (string, string, error) {
+	var fromStr, toStr string
+	if from != "" {
+		d, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return "", "", fmt.Errorf("неверная дата начала: %s", from)
+		}
+		fromStr = d.Format(time.RFC3339)
+	}
+	if to != "" {
+		d, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return "", "", fmt.Errorf("неверная дата окончания: %s", to)
+		}
+		toStr = d.AddDate(0, 0, 1).Format(time.RFC3339)
+	}
+	return fromStr, toStr, nil
+}
+
+// Команда /export [с] [по] [free|booked]: выгрузка расписания учителя файлом
+func handleExportSchedules(chatID int64, args string) {
+	if err := authorizeTeacher(chatID, "выгрузка расписания"); err != nil {
+		sendMessage(chatID, tr(chatID, "teacher.only"))
+		return
+	}
+
+	filter := ScheduleFilter{TeacherID: chatID}
+	var dates []string
+	for _, arg := range strings.Fields(args) {
+		if arg == "free" || arg == "booked" {
+			filter.Status = arg
+		} else {
+			dates = append(dates, arg)
+		}
+	}
+	if len(dates) > 2 {
+		sendMessage(chatID, tr(chatID, "export.usage"))
+		return
+	}
+	dates = append(dates, "", "")
+	from, to, err := parseExportRange(dates[0], dates[1])
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "export.usage"))
+		return
+	}
+	filter.From, filter.To = from, to
+
+	var buf bytes.Buffer
+	if err := exportSchedulesCSV(&buf, filter); err != nil {
+		fmt.Println("Ошибка выгрузки расписания:", err)
+		sendMessage(chatID, tr(chatID, "export.schedule_error"))
+		return
+	}
+	sendCSVDocument(chatID, "schedule.csv", buf.Bytes())
+}
+
+// Команда /export_students: выгрузка учеников файлом
+func handleExportStudents(chatID int64) {
+	if err := authorizeTeacher(chatID, "выгрузка учеников"); err != nil {
+		sendMessage(chatID, tr(chatID, "teacher.only"))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exportStudentsCSV(&buf); err != nil {
+		fmt.Println("Ошибка выгрузки учеников:", err)
+		sendMessage(chatID, tr(chatID, "export.students_error"))
+		return
+	}
+	sendCSVDocument(chatID, "students.csv", buf.Bytes())
+}
+
+func sendCSVDocument(chatID int64, name string, data []byte) {
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	if _, err := messenger.Send(doc); err != nil {
+		fmt.Println("Ошибка отправки файла:", err)
+		sendMessage(chatID, tr(chatID, "export.send_error"))
+	}
+}
+
+// Загрузка CSV-файла учителем: пробный запуск и запрос подтверждения
+func handleDocument(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if err := authorizeTeacher(chatID, "импорт слотов"); err != nil {
+		return
+	}
+	if !strings.HasSuffix(strings.ToLower(msg.Document.FileName), ".csv") {
+		sendMessage(chatID, tr(chatID, "import.need_csv"))
+		return
+	}
+	if msg.Document.FileSize > maxImportSize {
+		sendMessage(chatID, tr(chatID, "import.too_large"))
+		return
+	}
+
+	data, err := downloadTelegramFile(msg.Document.FileID)
+	if err != nil {
+		fmt.Println("Ошибка загрузки файла:", err)
+		sendMessage(chatID, tr(chatID, "import.download_error"))
+		return
+	}
+
+	report, err := importSlotsCSV(bytes.NewReader(data), chatID, true)
+	if err != nil {
+		sendPlainMessageWithKeyboard(chatID, tr(chatID, "import.read_error", err), nil)
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if report.Accepted > 0 {
+		// До подтверждения хранится только file_id: файл скачивается заново
+		if err := waitInput(chatID, dialogImportConfirm, msg.Document.FileID); err != nil {
+			fmt.Println("Ошибка сохранения импорта:", err, "chatID:", chatID)
+			sendMessage(chatID, tr(chatID, "error.generic"))
+			return
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "import.confirm", report.Accepted), cbImportConfirm),
+			callbackButton(tr(chatID, "btn.cancel"), cbImportCancel),
+		))
+	} else {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendPlainMessageWithKeyboard(chatID, formatImportReport(userLang(chatID), report), &keyboard)
+}
+
+// Подтверждение импорта после пробного запуска
+func handleImportConfirm(chatID int64) {
+	if err := authorizeTeacher(chatID, "импорт слотов"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+
+	fileID, ok := takeDialogData(chatID, dialogImportConfirm)
+	if !ok {
+		sendMessage(chatID, tr(chatID, "import.nothing"))
+		return
+	}
+	data, err := downloadTelegramFile(fileID)
+	if err != nil {
+		fmt.Println("Ошибка загрузки файла:", err)
+		sendMessage(chatID, tr(chatID, "import.download_error"))
+		return
+	}
+
+	report, err := importSlotsCSV(bytes.NewReader(data), chatID, false)
+	if err != nil {
+		sendPlainMessageWithKeyboard(chatID, tr(chatID, "import.error", err), nil)
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendPlainMessageWithKeyboard(chatID, formatImportReport(userLang(chatID), report), &keyboard)
+}
+
+func handleImportCancel(chatID int64) {
+	takeDialogData(chatID, dialogImportConfirm)
+	showTeacherMenu(chatID)
+}
+
+// Скачивание файла, загруженного в Telegram
+func downloadTelegramFile(fileID string) ([]byte, error) {
+	fileURL, err := messenger.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("telegram вернул статус %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+}
+
+// Подкоманды командной строки:
+//
+//	export-schedules [-teacher ID] [-from ГГГГ-ММ-ДД] [-to ГГГГ-ММ-ДД] [-status free|booked]
+//	export-students
+//	import-slots -teacher ID [-dry-run] [файл.csv]
+func runCLI(args []string, stdin io.Reader, stdout io.Writer) error {
+	switch args[0] {
+	case "export-schedules":
+		fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+		teacher := fs.Int64("teacher", 0, "ID учителя (0 — все)")
+		from := fs.String("from", "", "начало периода ГГГГ-ММ-ДД")
+		to := fs.String("to", "", "конец периода ГГГГ-ММ-ДД включительно")
+		status := fs.String("status", "", "статус слота: free или booked")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *status != "" && *status != "free" && *status != "booked" {
+			return fmt.Errorf("неверный статус: %s", *status)
+		}
+		fromStr, toStr, err := parseExportRange(*from, *to)
+		if err != nil {
+			return err
+		}
+		return exportSchedulesCSV(stdout, ScheduleFilter{TeacherID: *teacher, From: fromStr, To: toStr, Status: *status})
+
+	case "export-students":
+		return exportStudentsCSV(stdout)
+
+	case "import-slots":
+		fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+		teacher := fs.Int64("teacher", config.TeacherID, "ID учителя")
+		dryRun := fs.Bool("dry-run", false, "только проверить файл")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *teacher == 0 {
+			return errors.New("не указан ID учителя (-teacher или TEACHER_ID)")
+		}
+
+		input := stdin
+		if fs.NArg() > 0 {
+			f, err := os.Open(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			input = f
+		}
+
+		report, err := importSlotsCSV(input, *teacher, *dryRun)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, formatImportReport(defaultLang, report))
+		return nil
+
+	default:
+		return fmt.Errorf("неизвестная команда %q (доступны: export-schedules, export-students, import-slots)", args[0])
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVTime(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Moscow"); err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	config.Timezone = "Europe/Moscow"
+	cases := []struct {
+		name, value, want string
+	}{
+		{"формат хранения", "2099-01-10T12:00:00Z", "2099-01-10T12:00:00Z"},
+		{"смещение переводится в пояс расписания", "2099-01-10T12:00:00+05:00", "2099-01-10T10:00:00Z"},
+		{"настенное время", "2099-01-10 12:00", "2099-01-10T12:00:00Z"},
+		{"настенное время с T", " 2099-01-10T12:00 ", "2099-01-10T12:00:00Z"},
+		{"русский формат", "10.01.2099 12:00", "2099-01-10T12:00:00Z"},
+		{"только дата", "2099-01-10", ""},
+		{"мусор", "завтра", ""},
+		{"пусто", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseCSVTime(c.value)
+			if c.want == "" {
+				if err == nil {
+					t.Errorf("parseCSVTime(%q) = %v, ожидалась ошибка", c.value, got)
+				}
+				return
+			}
+			if err != nil || got.Format(time.RFC3339) != c.want {
+				t.Errorf("parseCSVTime(%q) = %v, %v; ожидалось %s", c.value, got, err, c.want)
+			}
+		})
+	}
+}
+
+func TestImportSlotsCSV(t *testing.T) {
+	st, _ := seedTestStore(t)
+	store = st
+	config.Timezone = "Europe/Moscow"
+
+	// Час назад по времени расписания: по часам сервера в UTC это еще будущее
+	past := scheduleNow().Add(-time.Hour)
+	pastRow := past.Format("2006-01-02 15:04") + "," + past.Add(time.Hour).Format("2006-01-02 15:04")
+	file := strings.Join([]string{
+		"start_time,end_time",                       // 1: заголовок
+		"2099-02-01 10:00,2099-02-01 11:00",         // 2
+		"когда-нибудь,2099-02-01 11:00",             // 3: неверное начало
+		"2099-02-02 10:00,потом",                    // 4: неверное окончание
+		pastRow,                                     // 5: прошло
+		"2099-02-01T10:00:00Z,2099-02-01T11:00:00Z", // 6: повтор строки 2
+		"2099-02-03 10:00",                          // 7: один столбец
+		"",                                          // 8: пустая строка
+		`2099-02-04 10:00,2099-02-04 11:00,"заметка`, // 9: поле в кавычках
+		`на двух строках"`,                           // 10
+		"2099-01-10T10:00:00Z,2099-01-10T11:00:00Z",  // 11: слот уже есть в базе
+		"2099-02-05 12:00,2099-02-05 11:00",          // 12: конец раньше начала
+	}, "\n")
+
+	wantLines := []int{3, 4, 5, 6, 7, 11, 12}
+	for _, dryRun := range []bool{true, false} {
+		report, err := importSlotsCSV(strings.NewReader(file), testTeacher, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []int
+		for _, r := range report.Rejected {
+			lines = append(lines, r.Line)
+		}
+		if report.DryRun != dryRun || report.Total != 9 || report.Accepted != 2 || !reflect.DeepEqual(lines, wantLines) {
+			t.Errorf("dryRun=%v: отчет %+v, ожидались отклоненные строки %v", dryRun, report, wantLines)
+		}
+		_, err = st.GetSlotIDByStart(testTeacher, "2099-02-04T10:00:00Z")
+		if added := err == nil; added == dryRun {
+			t.Errorf("dryRun=%v: слот из строки 9 добавлен: %v", dryRun, added)
+		}
+	}
+
+	if _, err := importSlotsCSV(strings.NewReader("\"незакрытая кавычка\n"), testTeacher, true); err == nil {
+		t.Error("ожидалась ошибка чтения CSV")
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	st, s := seedTestStore(t)
+	store = st
+	mustExec(t, st.SetUserContact(testStudent, "+70000000000"))
+
+	var buf bytes.Buffer
+	mustExec(t, exportSchedulesCSV(&buf, ScheduleFilter{TeacherID: testTeacher, From: "2099-01-10T11:00:00Z", To: "2099-01-11T00:00:00Z"}))
+	records, err := csv.NewReader(&buf).ReadAll()
+	mustExec(t, err)
+	want := [][]string{
+		{"id", "teacher_id", "start_time", "end_time", "status", "student_id", "student_username", "student_contact", "direction"},
+		{strconv.FormatInt(s.Booked, 10), "10", "2099-01-10T12:00:00Z", "2099-01-10T13:00:00Z", "booked", "20", "student", "+70000000000", "Grammar"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("выгрузка расписания:\n%v\nожидалось:\n%v", records, want)
+	}
+
+	// Выгруженный файл загружается обратно: формат времени совпадает с форматом хранения
+	buf.Reset()
+	mustExec(t, exportSchedulesCSV(&buf, ScheduleFilter{TeacherID: testTeacher, Status: "free", From: "2099-01-01T00:00:00Z"}))
+	var back bytes.Buffer
+	records, err = csv.NewReader(&buf).ReadAll()
+	mustExec(t, err)
+	w := csv.NewWriter(&back)
+	for _, r := range records[1:] {
+		w.Write([]string{r[2], r[3]})
+	}
+	w.Flush()
+	report, err := importSlotsCSV(&back, testTeacher+2, true)
+	mustExec(t, err)
+	if report.Total != 1 || report.Accepted != 1 {
+		t.Errorf("повторный импорт выгрузки: %+v", report)
+	}
+
+	buf.Reset()
+	mustExec(t, exportStudentsCSV(&buf))
+	records, err = csv.NewReader(&buf).ReadAll()
+	mustExec(t, err)
+	if len(records) != 3 || records[0][0] != "telegram_id" {
+		t.Fatalf("выгрузка учеников: %v", records)
+	}
+	for _, r := range records[1:] {
+		if r[0] == "20" && (r[1] != "student" || r[2] != "+70000000000" || r[3] != "2") {
+			t.Errorf("ученик в выгрузке: %v", r)
+		}
+	}
+}
@@ -2,19 +2,54 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrSlotExternallyBusy возвращается, если слот пересекается с занятостью во внешнем календаре
+var ErrSlotExternallyBusy = errors.New("время занято во внешнем календаре")
+
+// ErrSlotExists возвращается, если у учителя уже есть слот с таким началом
+var ErrSlotExists = errors.New("слот уже существует")
+
+// ErrSlotTaken возвращается, если слот заняли раньше, чем прошла запись
+var ErrSlotTaken = errors.New("слот уже занят")
+
+// SQLiteStore — реализация Store поверх SQLite
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// Открытие хранилища: путь к файлу или ":memory:" для базы в памяти
+func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
+	db, err := InitDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Закрытие соединения с базой
+func (st *SQLiteStore) Close() error {
+	return st.db.Close()
+}
+
 // Инициализация базы данных
-func InitDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./schedule.db")
+func InitDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка открытия базы данных: %v", err)
 	}
 
+	// У каждого соединения своя база в памяти, поэтому держим одно соединение
+	if strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory") {
+		db.SetMaxOpenConns(1)
+	}
+
 	// Проверка соединения
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("ошибка подключения к базе данных: %v", err)
@@ -57,6 +92,160 @@ func createTables(db *sql.DB) error {
             created_at TEXT,
             is_read BOOLEAN DEFAULT 0,
             FOREIGN KEY(teacher_id) REFERENCES users(telegram_id)
+        )`,
+		// Секретные токены для подписки на календарь
+		`CREATE TABLE IF NOT EXISTS calendar_tokens (
+            telegram_id INTEGER PRIMARY KEY,
+            token TEXT UNIQUE NOT NULL,
+            created_at TEXT,
+            FOREIGN KEY(telegram_id) REFERENCES users(telegram_id)
+        )`,
+		// Внешние календари учителей (ICS-ссылки)
+		`CREATE TABLE IF NOT EXISTS external_calendars (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            teacher_id INTEGER,
+            url TEXT,
+            last_synced_at TEXT,
+            last_error TEXT,
+            UNIQUE(teacher_id, url),
+            FOREIGN KEY(teacher_id) REFERENCES users(telegram_id)
+        )`,
+		// Занятое время, загруженное из внешних календарей
+		`CREATE TABLE IF NOT EXISTS external_busy (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            calendar_id INTEGER,
+            teacher_id INTEGER,
+            start_time TEXT,
+            end_time TEXT,
+            FOREIGN KEY(calendar_id) REFERENCES external_calendars(id) ON DELETE CASCADE
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_external_busy_teacher ON external_busy(teacher_id, start_time)`,
+		// Ожидание текстового ответа в чате (один шаг диалога на чат)
+		`CREATE TABLE IF NOT EXISTS dialog_states (
+            chat_id INTEGER PRIMARY KEY,
+            step TEXT NOT NULL,
+            data TEXT,
+            expires_at TEXT NOT NULL
+        )`,
+		// Анкеты учеников
+		`CREATE TABLE IF NOT EXISTS student_profiles (
+            telegram_id INTEGER PRIMARY KEY,
+            name TEXT,
+            phone TEXT,
+            level TEXT,
+            goals TEXT,
+            direction TEXT,
+            completed_at TEXT,
+            FOREIGN KEY(telegram_id) REFERENCES users(telegram_id)
+        )`,
+		// Отмены записей учениками (в schedules после отмены ученик не хранится)
+		`CREATE TABLE IF NOT EXISTS cancellations (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            slot_id INTEGER,
+            teacher_id INTEGER,
+            student_id INTEGER,
+            start_time TEXT,
+            end_time TEXT,
+            cancelled_at TEXT
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_cancellations_student ON cancellations(teacher_id, student_id)`,
+		// Ученики, которым учитель запретил записываться к нему
+		`CREATE TABLE IF NOT EXISTS student_blocks (
+            teacher_id INTEGER NOT NULL,
+            student_id INTEGER NOT NULL,
+            blocked_at TEXT,
+            PRIMARY KEY (teacher_id, student_id)
+        )`,
+		// Заметки учителя об учениках
+		`CREATE TABLE IF NOT EXISTS student_notes (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            teacher_id INTEGER,
+            student_id INTEGER,
+            text TEXT,
+            created_at TEXT
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_student_notes_student ON student_notes(teacher_id, student_id)`,
+		// Домашние задания: одно на занятие
+		`CREATE TABLE IF NOT EXISTS homework (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            slot_id INTEGER UNIQUE,
+            teacher_id INTEGER,
+            student_id INTEGER,
+            status TEXT NOT NULL DEFAULT 'draft',
+            text TEXT,
+            due_at TEXT,
+            created_at TEXT,
+            sent_at TEXT,
+            reminded_at TEXT,
+            answer TEXT,
+            submitted_at TEXT,
+            grade INTEGER,
+            comment TEXT,
+            reviewed_at TEXT
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_homework_student ON homework(student_id)`,
+		// Файлы заданий и ответов (хранятся в Telegram, здесь только file_id)
+		`CREATE TABLE IF NOT EXISTS homework_files (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            homework_id INTEGER,
+            owner TEXT CHECK(owner IN ('teacher', 'student')),
+            kind TEXT,
+            file_id TEXT,
+            file_name TEXT,
+            FOREIGN KEY(homework_id) REFERENCES homework(id) ON DELETE CASCADE
+        )`,
+		// Опрос после занятия: оценка ученика и итог учителя
+		`CREATE TABLE IF NOT EXISTS lesson_feedback (
+            slot_id INTEGER PRIMARY KEY,
+            teacher_id INTEGER,
+            student_id INTEGER,
+            direction TEXT,
+            rating INTEGER CHECK(rating BETWEEN 1 AND 5),
+            comment TEXT,
+            rated_at TEXT,
+            summary TEXT,
+            summarized_at TEXT,
+            created_at TEXT,
+            FOREIGN KEY(slot_id) REFERENCES schedules(id)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_lesson_feedback_teacher ON lesson_feedback(teacher_id)`,
+		// Группы, привязанные учителями, и события, которые туда публикуются
+		`CREATE TABLE IF NOT EXISTS group_chats (
+            chat_id INTEGER,
+            teacher_id INTEGER,
+            title TEXT,
+            reminders BOOLEAN NOT NULL DEFAULT 1,
+            agenda BOOLEAN NOT NULL DEFAULT 0,
+            free_slots BOOLEAN NOT NULL DEFAULT 0,
+            linked_at TEXT,
+            PRIMARY KEY(chat_id, teacher_id)
+        )`,
+		// Настройки уведомлений пользователя (нет строки — значения по умолчанию)
+		`CREATE TABLE IF NOT EXISTS notification_settings (
+            user_id INTEGER PRIMARY KEY,
+            enable_reminders BOOLEAN NOT NULL DEFAULT 1,
+            enable_new_bookings BOOLEAN NOT NULL DEFAULT 1,
+            enable_cancellations BOOLEAN NOT NULL DEFAULT 1,
+            enable_agenda BOOLEAN NOT NULL DEFAULT 1,
+            agenda_time TEXT NOT NULL DEFAULT '08:00',
+            pin_agenda BOOLEAN NOT NULL DEFAULT 0
+        )`,
+		// Отправленные планы на день: сообщение, которое обновляется в течение дня
+		`CREATE TABLE IF NOT EXISTS daily_agendas (
+            chat_id INTEGER,
+            teacher_id INTEGER,
+            day TEXT,
+            message_id INTEGER,
+            text TEXT,
+            PRIMARY KEY(chat_id, teacher_id, day)
+        )`,
+		// Закрепленные доски свободных слотов учителей в группах и каналах
+		`CREATE TABLE IF NOT EXISTS slot_boards (
+            chat_id INTEGER,
+            teacher_id INTEGER,
+            message_id INTEGER,
+            text TEXT,
+            PRIMARY KEY(chat_id, teacher_id)
         )`,
 	}
 
@@ -65,34 +254,67 @@ func createTables(db *sql.DB) error {
 			return fmt.Errorf("ошибка выполнения запроса %q: %v", query, err)
 		}
 	}
+
+	// Столбцы, добавленные после создания таблиц
+	columns := []struct{ table, column, definition string }{
+		{"users", "delivery_status", "TEXT NOT NULL DEFAULT 'active'"},
+		{"users", "delivery_error", "TEXT"},
+		{"users", "delivery_updated_at", "TEXT"},
+		{"users", "language", "TEXT"},
+		{"users", "language_code", "TEXT"},
+		{"student_notes", "slot_id", "INTEGER"},
+		{"student_notes", "kind", "TEXT NOT NULL DEFAULT 'text'"},
+		{"student_notes", "file_id", "TEXT"},
+		{"group_chats", "board", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"group_chats", "pin_agenda", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"cancellations", "reason", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Получение списка учеников для учителя
-func getTeacherStudents(teacherID int64) ([]BookingNotification, error) {
-	query := `SELECT s.student_id, u.username, s.start_time, s.direction 
-		FROM schedules s
-		JOIN users u ON s.student_id = u.telegram_id
-		WHERE s.teacher_id = ? AND s.status = 'booked'`
-	rows, err := db.Query(query, teacherID)
+// Добавление столбца в существующую таблицу, если его еще нет
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("ошибка чтения структуры таблицы %s: %v", table, err)
 	}
 	defer rows.Close()
 
-	var students []BookingNotification
 	for rows.Next() {
-		var s BookingNotification
-		rows.Scan(&s.StudentID, &s.StudentUsername, &s.StartTime, &s.Direction)
-		students = append(students, s)
+		var (
+			cid        int
+			name, typ  string
+			notNull    bool
+			dflt       sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &primaryKey); err != nil {
+			return fmt.Errorf("ошибка чтения структуры таблицы %s: %v", table, err)
+		}
+		if name == column {
+			return nil
+		}
 	}
-	return students, nil
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения структуры таблицы %s: %v", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("ошибка добавления столбца %s.%s: %v", table, column, err)
+	}
+	return nil
 }
 
 // Регистрация нового пользователя
-func registerUser(telegramID int64, role, username string) error {
+func (st *SQLiteStore) RegisterUser(telegramID int64, role, username string) error {
 	query := `INSERT INTO users (telegram_id, role, username) VALUES (?, ?, ?)`
-	_, err := db.Exec(query, telegramID, role, username)
+	_, err := st.db.Exec(query, telegramID, role, username)
 	if err != nil {
 		return fmt.Errorf("ошибка регистрации пользователя: %v", err)
 	}
@@ -100,10 +322,10 @@ func registerUser(telegramID int64, role, username string) error {
 }
 
 // Проверка существования пользователя
-func userExists(telegramID int64) (bool, error) {
+func (st *SQLiteStore) UserExists(telegramID int64) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE telegram_id = ?)`
-	err := db.QueryRow(query, telegramID).Scan(&exists)
+	err := st.db.QueryRow(query, telegramID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("ошибка проверки пользователя: %v", err)
 	}
@@ -111,27 +333,115 @@ func userExists(telegramID int64) (bool, error) {
 }
 
 // Получение пользователя по Telegram ID
-func getUser(telegramID int64) (*User, error) {
+func (st *SQLiteStore) GetUser(telegramID int64) (*User, error) {
 	var user User
-	query := `SELECT id, telegram_id, role, username, contact 
+	query := `SELECT id, telegram_id, role, username, contact, delivery_status, language, language_code
 		FROM users WHERE telegram_id = ?`
-	err := db.QueryRow(query, telegramID).Scan(
+	err := st.db.QueryRow(query, telegramID).Scan(
 		&user.ID,
 		&user.TelegramID,
 		&user.Role,
 		&user.Username,
-		&user.Contact)
+		&user.Contact,
+		&user.DeliveryStatus,
+		&user.Language,
+		&user.LanguageCode)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения пользователя: %v", err)
 	}
 	return &user, nil
 }
 
+// Сохранение статуса доставки сообщений пользователю
+func (st *SQLiteStore) SetDeliveryStatus(telegramID int64, status, reason string) error {
+	_, err := st.db.Exec(
+		`UPDATE users SET delivery_status = ?, delivery_error = ?, delivery_updated_at = ? WHERE telegram_id = ?`,
+		status, reason, time.Now().UTC().Format(time.RFC3339), telegramID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения статуса доставки: %v", err)
+	}
+	return nil
+}
+
+// Сохранение языка, выбранного пользователем (пустая строка — как в Telegram)
+func (st *SQLiteStore) SetUserLanguage(telegramID int64, lang string) error {
+	var value sql.NullString
+	if lang != "" {
+		value = sql.NullString{String: lang, Valid: true}
+	}
+	_, err := st.db.Exec(`UPDATE users SET language = ? WHERE telegram_id = ?`, value, telegramID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения языка: %v", err)
+	}
+	return nil
+}
+
+// Сохранение language_code из Telegram
+func (st *SQLiteStore) SetUserLanguageCode(telegramID int64, code string) error {
+	_, err := st.db.Exec(`UPDATE users SET language_code = ? WHERE telegram_id = ?`, code, telegramID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения кода языка: %v", err)
+	}
+	return nil
+}
+
+// Сохранение контакта пользователя (телефона)
+func (st *SQLiteStore) SetUserContact(telegramID int64, contact string) error {
+	_, err := st.db.Exec(`UPDATE users SET contact = ? WHERE telegram_id = ?`, contact, telegramID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения контакта: %v", err)
+	}
+	return nil
+}
+
+// Пользователи, которым сообщения не доставляются: telegram_id -> статус
+func (st *SQLiteStore) GetUnreachableUsers() (map[int64]string, error) {
+	rows, err := st.db.Query(`SELECT telegram_id, delivery_status FROM users WHERE delivery_status != 'active'`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения недоступных пользователей: %v", err)
+	}
+	defer rows.Close()
+
+	users := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, fmt.Errorf("ошибка чтения недоступных пользователей: %v", err)
+		}
+		users[id] = status
+	}
+	return users, rows.Err()
+}
+
 // Добавление нового слота в расписание
-func addScheduleSlot(teacherID int64, startTime, endTime string) error {
+func (st *SQLiteStore) AddScheduleSlot(teacherID int64, startTime, endTime string) error {
+	if err := st.ValidateScheduleSlot(teacherID, startTime, endTime); err != nil {
+		return err
+	}
+
+	// Добавление нового слота
+	_, err := st.db.Exec(
+		`INSERT INTO schedules 
+        (teacher_id, start_time, end_time, status) 
+        VALUES (?, ?, ?, 'free')`,
+		teacherID, startTime, endTime)
+
+	if err != nil {
+		return fmt.Errorf("ошибка добавления слота: %v", err)
+	}
+	return nil
+}
+
+// Проверка слота перед добавлением: формат, порядок времени, дубликаты и внешняя занятость
+func (st *SQLiteStore) ValidateScheduleSlot(teacherID int64, startTime, endTime string) error {
+	if !IsTimeRangeValid(startTime, endTime) {
+		return fmt.Errorf("неверный интервал времени")
+	}
+
 	// Проверка существования слота
 	var exists bool
-	err := db.QueryRow(
+	err := st.db.QueryRow(
 		`SELECT EXISTS(
             SELECT 1 FROM schedules 
             WHERE teacher_id = ? 
@@ -143,23 +453,22 @@ func addScheduleSlot(teacherID int64, startTime, endTime string) error {
 	}
 
 	if exists {
-		return fmt.Errorf("слот уже существует")
+		return ErrSlotExists
 	}
 
-	// Добавление нового слота
-	_, err = db.Exec(
-		`INSERT INTO schedules 
-        (teacher_id, start_time, end_time, status) 
-        VALUES (?, ?, ?, 'free')`,
-		teacherID, startTime, endTime)
-
+	// Проверка пересечения с занятостью во внешних календарях
+	busy, err := st.IsExternallyBusy(teacherID, startTime, endTime)
 	if err != nil {
-		return fmt.Errorf("ошибка добавления слота: %v", err)
+		return fmt.Errorf("ошибка проверки внешних календарей: %v", err)
+	}
+	if busy {
+		return ErrSlotExternallyBusy
 	}
 	return nil
 }
 
-func getSlotsForDate(date time.Time) ([]Schedule, error) {
+// Получение слотов учителя на дату
+func (st *SQLiteStore) GetSlotsForDate(teacherID int64, date time.Time) ([]Schedule, error) {
 	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
 	endOfDay := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC).Format(time.RFC3339)
 
@@ -168,7 +477,7 @@ func getSlotsForDate(date time.Time) ([]Schedule, error) {
         WHERE teacher_id = ? 
         AND start_time BETWEEN ? AND ? 
         ORDER BY start_time`
-	rows, err := db.Query(query, teacherID, startOfDay, endOfDay)
+	rows, err := st.db.Query(query, teacherID, startOfDay, endOfDay)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса слотов: %v", err)
 	}
@@ -185,42 +494,42 @@ func getSlotsForDate(date time.Time) ([]Schedule, error) {
 	return slots, nil
 }
 
-func setUserLastMessage(chatID int64, msgID int) {
-	_, err := db.Exec(
-		`UPDATE users 
-        SET last_message_id = ? 
-        WHERE telegram_id = ?`,
-		msgID, chatID)
+// Новая функция для получения доступных слотов по дате
+func (st *SQLiteStore) GetAvailableSlotsForDate(date time.Time) ([]Schedule, error) {
+	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	endOfDay := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC).Format(time.RFC3339)
 
+	query := `SELECT id, teacher_id, start_time, end_time, status 
+        FROM schedules 
+        WHERE status = 'free' 
+        AND start_time BETWEEN ? AND ? 
+        ORDER BY start_time`
+	rows, err := st.db.Query(query, startOfDay, endOfDay)
 	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса свободных слотов: %v", err)
 	}
-}
-
-func getUserLastMessage(chatID int64) (int, bool) {
-	var msgID int
-	err := db.QueryRow(
-		`SELECT last_message_id 
-        FROM users 
-        WHERE telegram_id = ?`,
-		chatID).Scan(&msgID)
+	defer rows.Close()
 
-	if err != nil {
-		if err != sql.ErrNoRows {
+	var slots []Schedule
+	for rows.Next() {
+		var s Schedule
+		if err := rows.Scan(&s.ID, &s.TeacherID, &s.StartTime, &s.EndTime, &s.Status); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования слотов: %v", err)
 		}
-		return 0, false
+		slots = append(slots, s)
 	}
-	return msgID, true
+	return slots, nil
 }
 
 // Получение расписания учителя
-func getTeacherSchedule(teacherID int64) ([]Schedule, error) {
+func (st *SQLiteStore) GetTeacherSchedule(teacherID int64) ([]Schedule, error) {
 
-	query := `SELECT id, start_time, end_time, status 
+	query := `SELECT id, teacher_id, start_time, end_time, status, student_id, direction 
         FROM schedules 
         WHERE teacher_id = ? 
         ORDER BY start_time`
 
-	rows, err := db.Query(query, teacherID)
+	rows, err := st.db.Query(query, teacherID)
 	if err != nil {
 		return nil, fmt.Errorf("database error: %v", err)
 	}
@@ -229,7 +538,7 @@ func getTeacherSchedule(teacherID int64) ([]Schedule, error) {
 	var schedules []Schedule
 	for rows.Next() {
 		var s Schedule
-		if err := rows.Scan(&s.ID, &s.StartTime, &s.EndTime, &s.Status); err != nil {
+		if err := rows.Scan(&s.ID, &s.TeacherID, &s.StartTime, &s.EndTime, &s.Status, &s.StudentID, &s.Direction); err != nil {
 			continue
 		}
 		schedules = append(schedules, s)
@@ -239,8 +548,8 @@ func getTeacherSchedule(teacherID int64) ([]Schedule, error) {
 }
 
 // Получение записей ученика
-func getStudentBookings(studentID int64) ([]Schedule, error) {
-	rows, err := db.Query(`SELECT id, start_time, end_time, direction 
+func (st *SQLiteStore) GetStudentBookings(studentID int64) ([]Schedule, error) {
+	rows, err := st.db.Query(`SELECT id, start_time, end_time, direction 
                            FROM schedules 
                            WHERE student_id = ? AND status = 'booked' 
                            ORDER BY start_time`, studentID)
@@ -261,7 +570,7 @@ func getStudentBookings(studentID int64) ([]Schedule, error) {
 }
 
 // Обновление статуса слота (запись или отмена)
-func updateScheduleStatus(scheduleID int64, status string, studentID int64, direction string) error {
+func (st *SQLiteStore) UpdateScheduleStatus(scheduleID int64, status string, studentID int64, direction string) error {
 	var studentIDValue interface{}
 	if studentID == 0 {
 		studentIDValue = nil // Устанавливаем NULL для свободного слота
@@ -279,30 +588,54 @@ func updateScheduleStatus(scheduleID int64, status string, studentID int64, dire
 	query := `UPDATE schedules 
               SET status = ?, student_id = ?, direction = ?
               WHERE id = ?`
-	_, err := db.Exec(query, status, studentIDValue, directionValue, scheduleID)
+	_, err := st.db.Exec(query, status, studentIDValue, directionValue, scheduleID)
 	if err != nil {
 		return fmt.Errorf("ошибка обновления статуса: %v", err)
 	}
 	return nil
 }
 
+// Запись ученика на слот: проверка и запись одним запросом, чтобы двое
+// учеников не заняли один слот; ErrSlotTaken, если слот уже не свободен
+func (st *SQLiteStore) BookSlot(scheduleID, studentID int64, direction string) error {
+	var directionValue interface{}
+	if direction != "" {
+		directionValue = direction
+	}
+	query := `UPDATE schedules
+              SET status = 'booked', student_id = ?, direction = ?
+              WHERE id = ? AND status = 'free'`
+	res, err := st.db.Exec(query, studentID, directionValue, scheduleID)
+	if err != nil {
+		return fmt.Errorf("ошибка записи на слот: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка записи на слот: %v", err)
+	}
+	if n != 1 {
+		return ErrSlotTaken
+	}
+	return nil
+}
+
 // Удаление слота из расписания
-func DeleteScheduleSlot(scheduleID int64) error {
+func (st *SQLiteStore) DeleteScheduleSlot(scheduleID int64) error {
 	query := `DELETE FROM schedules WHERE id = ?`
-	_, err := db.Exec(query, scheduleID)
+	_, err := st.db.Exec(query, scheduleID)
 	if err != nil {
 		return fmt.Errorf("ошибка удаления слота: %v", err)
 	}
 	return nil
 }
 
-// Получение свободных слотов для записи
-func getAvailableSlots() ([]Schedule, error) {
+// Получение свободных слотов для записи, которые начинаются после now (время расписания)
+func (st *SQLiteStore) GetAvailableSlots(now time.Time) ([]Schedule, error) {
 	query := `SELECT id, start_time 
 		FROM schedules 
 		WHERE status = 'free' AND start_time > ? 
 		ORDER BY start_time`
-	rows, err := db.Query(query, time.Now().Format(time.RFC3339))
+	rows, err := st.db.Query(query, now.Format(time.RFC3339))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса свободных слотов: %v", err)
 	}
@@ -320,11 +653,11 @@ func getAvailableSlots() ([]Schedule, error) {
 }
 
 // Получение информации о слоте
-func getScheduleByID(scheduleID int64) (*Schedule, error) {
+func (st *SQLiteStore) GetScheduleByID(scheduleID int64) (*Schedule, error) {
 	var s Schedule
 	query := `SELECT id, teacher_id, start_time, end_time, status, student_id, direction 
         FROM schedules WHERE id = ?`
-	err := db.QueryRow(query, scheduleID).Scan(
+	err := st.db.QueryRow(query, scheduleID).Scan(
 		&s.ID, &s.TeacherID, &s.StartTime, &s.EndTime, &s.Status, &s.StudentID, &s.Direction)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("слот не найден")
@@ -334,3 +667,1306 @@ func getScheduleByID(scheduleID int64) (*Schedule, error) {
 	}
 	return &s, nil
 }
+
+// Поиск слота учителя по времени начала
+func (st *SQLiteStore) GetSlotIDByStart(teacherID int64, startTime string) (int64, error) {
+	var slotID int64
+	err := st.db.QueryRow(
+		`SELECT id FROM schedules WHERE teacher_id = ? AND start_time = ?`,
+		teacherID, startTime).Scan(&slotID)
+	return slotID, err
+}
+
+// Получение будущих занятий для напоминаний
+func (st *SQLiteStore) GetUpcomingBookings(after time.Time) ([]BookingNotification, error) {
+	query := `SELECT s.id, s.teacher_id, s.student_id, s.start_time, s.end_time, COALESCE(s.direction, ''), COALESCE(u.username, '') 
+        FROM schedules s
+        JOIN users u ON s.student_id = u.telegram_id
+        WHERE s.status = 'booked' AND s.start_time > ?`
+	rows, err := st.db.Query(query, after.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса расписания: %v", err)
+	}
+	defer rows.Close()
+
+	var bookings []BookingNotification
+	for rows.Next() {
+		var b BookingNotification
+		if err := rows.Scan(&b.ID, &b.TeacherID, &b.StudentID, &b.StartTime, &b.EndTime, &b.Direction, &b.StudentUsername); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования записи: %v", err)
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
+
+// Получение токена календарной ленты пользователя (создается при первом обращении)
+func (st *SQLiteStore) GetCalendarToken(telegramID int64) (string, error) {
+	var token string
+	err := st.db.QueryRow(`SELECT token FROM calendar_tokens WHERE telegram_id = ?`, telegramID).Scan(&token)
+	if err == nil {
+		return token, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("ошибка получения токена календаря: %v", err)
+	}
+	return st.RotateCalendarToken(telegramID)
+}
+
+// Выпуск нового токена календарной ленты, старая ссылка перестает работать
+func (st *SQLiteStore) RotateCalendarToken(telegramID int64) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации токена календаря: %v", err)
+	}
+	_, err = st.db.Exec(
+		`INSERT INTO calendar_tokens (telegram_id, token, created_at) VALUES (?, ?, ?)
+        ON CONFLICT(telegram_id) DO UPDATE SET token = excluded.token, created_at = excluded.created_at`,
+		telegramID, token, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return "", fmt.Errorf("ошибка сохранения токена календаря: %v", err)
+	}
+	return token, nil
+}
+
+// Получение пользователя по токену календарной ленты
+func (st *SQLiteStore) GetUserByCalendarToken(token string) (*User, error) {
+	var telegramID int64
+	err := st.db.QueryRow(`SELECT telegram_id FROM calendar_tokens WHERE token = ?`, token).Scan(&telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска токена календаря: %v", err)
+	}
+	return st.GetUser(telegramID)
+}
+
+// Получение занятий для календарной ленты: слоты учителя и записи ученика
+func (st *SQLiteStore) GetCalendarEvents(telegramID int64) ([]CalendarEvent, error) {
+	query := `SELECT s.id, s.teacher_id, s.start_time, s.end_time, s.status, s.direction, u.username
+        FROM schedules s
+        LEFT JOIN users u ON s.student_id = u.telegram_id
+        WHERE s.teacher_id = ? OR (s.student_id = ? AND s.status = 'booked')
+        ORDER BY s.start_time`
+	rows, err := st.db.Query(query, telegramID, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса занятий для календаря: %v", err)
+	}
+	defer rows.Close()
+
+	var events []CalendarEvent
+	for rows.Next() {
+		var e CalendarEvent
+		if err := rows.Scan(&e.ID, &e.TeacherID, &e.StartTime, &e.EndTime, &e.Status, &e.Direction, &e.StudentUsername); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования занятий для календаря: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Добавление внешнего календаря учителя
+func (st *SQLiteStore) AddExternalCalendar(teacherID int64, url string) (int64, error) {
+	res, err := st.db.Exec(`INSERT INTO external_calendars (teacher_id, url) VALUES (?, ?)`, teacherID, url)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления календаря: %v", err)
+	}
+	return res.LastInsertId()
+}
+
+// Получение внешних календарей учителя (teacherID = 0 — всех учителей)
+func (st *SQLiteStore) GetExternalCalendars(teacherID int64) ([]ExternalCalendar, error) {
+	query := `SELECT id, teacher_id, url, last_synced_at, last_error
+        FROM external_calendars
+        WHERE ? = 0 OR teacher_id = ?
+        ORDER BY id`
+	rows, err := st.db.Query(query, teacherID, teacherID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса календарей: %v", err)
+	}
+	defer rows.Close()
+
+	var calendars []ExternalCalendar
+	for rows.Next() {
+		var c ExternalCalendar
+		if err := rows.Scan(&c.ID, &c.TeacherID, &c.URL, &c.LastSyncedAt, &c.LastError); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования календарей: %v", err)
+		}
+		calendars = append(calendars, c)
+	}
+	return calendars, rows.Err()
+}
+
+// Удаление внешнего календаря вместе с загруженной занятостью
+func (st *SQLiteStore) DeleteExternalCalendar(teacherID, calendarID int64) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка удаления календаря: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM external_busy WHERE calendar_id = ? AND teacher_id = ?`, calendarID, teacherID); err != nil {
+		return fmt.Errorf("ошибка удаления занятости: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM external_calendars WHERE id = ? AND teacher_id = ?`, calendarID, teacherID); err != nil {
+		return fmt.Errorf("ошибка удаления календаря: %v", err)
+	}
+	return tx.Commit()
+}
+
+// Замена занятости календаря на свежезагруженную
+func (st *SQLiteStore) ReplaceExternalBusy(calendar ExternalCalendar, intervals []BusyInterval) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения занятости: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM external_busy WHERE calendar_id = ?`, calendar.ID); err != nil {
+		return fmt.Errorf("ошибка очистки занятости: %v", err)
+	}
+	for _, iv := range intervals {
+		_, err := tx.Exec(
+			`INSERT INTO external_busy (calendar_id, teacher_id, start_time, end_time) VALUES (?, ?, ?, ?)`,
+			calendar.ID, calendar.TeacherID, iv.Start.Format(time.RFC3339), iv.End.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения занятости: %v", err)
+		}
+	}
+	if _, err := tx.Exec(
+		`UPDATE external_calendars SET last_synced_at = ?, last_error = NULL WHERE id = ?`,
+		time.Now().Format(time.RFC3339), calendar.ID); err != nil {
+		return fmt.Errorf("ошибка обновления календаря: %v", err)
+	}
+	return tx.Commit()
+}
+
+// Сохранение ошибки синхронизации календаря
+func (st *SQLiteStore) SetExternalCalendarError(calendarID int64, syncErr error) error {
+	_, err := st.db.Exec(`UPDATE external_calendars SET last_error = ? WHERE id = ?`, syncErr.Error(), calendarID)
+	return err
+}
+
+// Занятость учителя во внешних календарях в интервале [from, to)
+func (st *SQLiteStore) GetExternalBusy(teacherID int64, from, to string) ([]BusyInterval, error) {
+	rows, err := st.db.Query(
+		`SELECT start_time, end_time FROM external_busy
+        WHERE teacher_id = ? AND start_time < ? AND end_time > ?
+        ORDER BY start_time`, teacherID, to, from)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса занятости: %v", err)
+	}
+	defer rows.Close()
+
+	var intervals []BusyInterval
+	for rows.Next() {
+		var start, end string
+		if err := rows.Scan(&start, &end); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования занятости: %v", err)
+		}
+		s, err1 := time.Parse(time.RFC3339, start)
+		e, err2 := time.Parse(time.RFC3339, end)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		intervals = append(intervals, BusyInterval{Start: s, End: e})
+	}
+	return intervals, rows.Err()
+}
+
+// Проверка пересечения интервала с занятостью во внешних календарях
+func (st *SQLiteStore) IsExternallyBusy(teacherID int64, startTime, endTime string) (bool, error) {
+	var exists bool
+	err := st.db.QueryRow(
+		`SELECT EXISTS(
+            SELECT 1 FROM external_busy
+            WHERE teacher_id = ? AND start_time < ? AND end_time > ?
+        )`, teacherID, endTime, startTime).Scan(&exists)
+	return exists, err
+}
+
+// Выгрузка слотов с данными учеников по фильтру
+func (st *SQLiteStore) GetSchedulesForExport(filter ScheduleFilter) ([]ScheduleExportRow, error) {
+	query := `SELECT s.id, s.teacher_id, s.start_time, s.end_time, s.status, s.student_id, u.username, u.contact, s.direction
+        FROM schedules s
+        LEFT JOIN users u ON s.student_id = u.telegram_id
+        WHERE 1 = 1`
+	var args []interface{}
+	if filter.TeacherID != 0 {
+		query += ` AND s.teacher_id = ?`
+		args = append(args, filter.TeacherID)
+	}
+	if filter.From != "" {
+		query += ` AND s.start_time >= ?`
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		query += ` AND s.start_time < ?`
+		args = append(args, filter.To)
+	}
+	if filter.Status != "" {
+		query += ` AND s.status = ?`
+		args = append(args, filter.Status)
+	}
+	query += ` ORDER BY s.start_time`
+
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса расписания для выгрузки: %v", err)
+	}
+	defer rows.Close()
+
+	var result []ScheduleExportRow
+	for rows.Next() {
+		var r ScheduleExportRow
+		if err := rows.Scan(&r.ID, &r.TeacherID, &r.StartTime, &r.EndTime, &r.Status,
+			&r.StudentID, &r.StudentUsername, &r.StudentContact, &r.Direction); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования расписания для выгрузки: %v", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// Получение всех учеников с количеством занятий
+func (st *SQLiteStore) GetAllStudents() ([]StudentExportRow, error) {
+	query := `SELECT u.telegram_id, u.username, u.contact,
+            (SELECT COUNT(*) FROM schedules s WHERE s.student_id = u.telegram_id AND s.status = 'booked')
+        FROM users u
+        WHERE u.role = 'student'
+        ORDER BY u.id`
+	rows, err := st.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса учеников: %v", err)
+	}
+	defer rows.Close()
+
+	var students []StudentExportRow
+	for rows.Next() {
+		var s StudentExportRow
+		if err := rows.Scan(&s.TelegramID, &s.Username, &s.Contact, &s.Bookings); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования учеников: %v", err)
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// Получение всех учителей
+func (st *SQLiteStore) GetAllTeachers() ([]User, error) {
+	query := `SELECT id, telegram_id, username FROM users WHERE role = 'teacher'`
+	rows, err := st.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teachers []User
+	for rows.Next() {
+		var t User
+		if err := rows.Scan(&t.ID, &t.TelegramID, &t.Username); err != nil {
+			return nil, err
+		}
+		teachers = append(teachers, t)
+	}
+	return teachers, nil
+}
+
+// Получение новых записей
+func (st *SQLiteStore) GetNewBookings() ([]BookingNotification, error) {
+	query := `SELECT s.id, s.teacher_id, s.student_id, s.start_time, s.end_time, COALESCE(s.direction, ''), COALESCE(u.username, '') 
+		FROM schedules s
+		JOIN users u ON s.student_id = u.telegram_id
+		WHERE s.status = 'booked' AND s.notified = 0`
+	rows, err := st.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []BookingNotification
+	for rows.Next() {
+		var b BookingNotification
+		if err := rows.Scan(&b.ID, &b.TeacherID, &b.StudentID, &b.StartTime, &b.EndTime, &b.Direction, &b.StudentUsername); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, nil
+}
+
+// Получение новых отмен
+func (st *SQLiteStore) GetNewCancellations() ([]CancellationNotification, error) {
+	query := `SELECT s.id, s.teacher_id, s.student_id, s.start_time, s.end_time, COALESCE(u.username, '') 
+              FROM schedules s
+              JOIN users u ON s.student_id = u.telegram_id
+              WHERE s.status = 'free' AND s.notified = 1`
+	rows, err := st.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cancellations []CancellationNotification
+	for rows.Next() {
+		var c CancellationNotification
+		if err := rows.Scan(&c.ID, &c.TeacherID, &c.StudentID, &c.StartTime, &c.EndTime, &c.StudentUsername); err != nil {
+			return nil, err
+		}
+		cancellations = append(cancellations, c)
+	}
+	return cancellations, nil
+}
+
+// Пометить запись как уведомленную
+func (st *SQLiteStore) MarkBookingAsNotified(bookingID int) error {
+	query := `UPDATE schedules SET notified = 1 WHERE id = ?`
+	_, err := st.db.Exec(query, bookingID)
+	return err
+}
+
+// Пометить отмену как уведомленную
+func (st *SQLiteStore) MarkCancellationAsNotified(cancellationID int) error {
+	query := `UPDATE schedules SET notified = 0 WHERE id = ?`
+	_, err := st.db.Exec(query, cancellationID)
+	return err
+}
+
+func (st *SQLiteStore) AddNotification(teacherID int64, message string) error {
+	query := `INSERT INTO notifications (teacher_id, message, created_at) VALUES (?, ?, ?)`
+	_, err := st.db.Exec(query, teacherID, message, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("ошибка добавления уведомления: %v", err)
+	}
+	return nil
+}
+
+// Получение всех уведомлений для учителя
+func (st *SQLiteStore) GetTeacherNotifications(chatID int64) ([]Notification, error) {
+	rows, err := st.db.Query("SELECT id, message, is_read, created_at FROM notifications WHERE teacher_id = ? ORDER BY created_at DESC", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Message, &n.IsRead, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// Подсчет непрочитанных уведомлений
+func (st *SQLiteStore) CountUnreadNotifications(teacherID int64) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE teacher_id = ? AND is_read = 0`
+	err := st.db.QueryRow(query, teacherID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета уведомлений: %v", err)
+	}
+	return count, nil
+}
+
+// Отметить уведомление как прочитанное
+func (st *SQLiteStore) MarkNotificationAsRead(notificationID int) error {
+	query := `UPDATE notifications SET is_read = 1 WHERE id = ?`
+	_, err := st.db.Exec(query, notificationID)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки уведомления как прочитанного: %v", err)
+	}
+	return nil
+}
+
+// Получение уведомления по ID
+func (st *SQLiteStore) GetNotificationByID(notificationID int) (*Notification, error) {
+	var n Notification
+	err := st.db.QueryRow(
+		`SELECT id, teacher_id, message, is_read, created_at FROM notifications WHERE id = ?`,
+		notificationID).Scan(&n.ID, &n.TeacherID, &n.Message, &n.IsRead, &n.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("уведомление не найдено")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения уведомления: %v", err)
+	}
+	return &n, nil
+}
+
+// Очистить все уведомления учителя
+func (st *SQLiteStore) ClearTeacherNotifications(teacherID int64) error {
+	query := `DELETE FROM notifications WHERE teacher_id = ?`
+	_, err := st.db.Exec(query, teacherID)
+	if err != nil {
+		return fmt.Errorf("ошибка очистки уведомлений: %v", err)
+	}
+	return nil
+}
+
+// Сохранение шага диалога (заменяет предыдущий шаг чата)
+func (st *SQLiteStore) SetDialogState(state DialogState) error {
+	_, err := st.db.Exec(
+		`INSERT OR REPLACE INTO dialog_states (chat_id, step, data, expires_at) VALUES (?, ?, ?, ?)`,
+		state.ChatID, state.Step, state.Data, state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения состояния диалога: %v", err)
+	}
+	return nil
+}
+
+// Получение и удаление шага диалога; nil — чат ничего не ждет
+func (st *SQLiteStore) TakeDialogState(chatID int64) (*DialogState, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения состояния диалога: %v", err)
+	}
+	defer tx.Rollback()
+
+	state := DialogState{ChatID: chatID}
+	err = tx.QueryRow(`SELECT step, COALESCE(data, ''), expires_at FROM dialog_states WHERE chat_id = ?`, chatID).
+		Scan(&state.Step, &state.Data, &state.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения состояния диалога: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM dialog_states WHERE chat_id = ?`, chatID); err != nil {
+		return nil, fmt.Errorf("ошибка удаления состояния диалога: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка удаления состояния диалога: %v", err)
+	}
+	return &state, nil
+}
+
+// Получение и удаление шагов диалога, время ответа на которые истекло
+func (st *SQLiteStore) TakeExpiredDialogStates(now time.Time) ([]DialogState, error) {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истекших диалогов: %v", err)
+	}
+	defer tx.Rollback()
+
+	deadline := now.UTC().Format(time.RFC3339)
+	rows, err := tx.Query(
+		`SELECT chat_id, step, COALESCE(data, ''), expires_at FROM dialog_states WHERE expires_at <= ?`, deadline)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истекших диалогов: %v", err)
+	}
+	var states []DialogState
+	for rows.Next() {
+		var s DialogState
+		if err := rows.Scan(&s.ChatID, &s.Step, &s.Data, &s.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка чтения истекших диалогов: %v", err)
+		}
+		states = append(states, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения истекших диалогов: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM dialog_states WHERE expires_at <= ?`, deadline); err != nil {
+		return nil, fmt.Errorf("ошибка удаления истекших диалогов: %v", err)
+	}
+	return states, tx.Commit()
+}
+
+// Анкета ученика; nil — ученик еще не начинал ее заполнять
+func (st *SQLiteStore) GetStudentProfile(telegramID int64) (*StudentProfile, error) {
+	p := StudentProfile{TelegramID: telegramID}
+	err := st.db.QueryRow(
+		`SELECT name, phone, level, goals, direction, completed_at FROM student_profiles WHERE telegram_id = ?`, telegramID).
+		Scan(&p.Name, &p.Phone, &p.Level, &p.Goals, &p.Direction, &p.CompletedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения анкеты: %v", err)
+	}
+	return &p, nil
+}
+
+// Сохранение анкеты ученика целиком
+func (st *SQLiteStore) SaveStudentProfile(p StudentProfile) error {
+	_, err := st.db.Exec(
+		`INSERT OR REPLACE INTO student_profiles (telegram_id, name, phone, level, goals, direction, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		p.TelegramID, p.Name, p.Phone, p.Level, p.Goals, p.Direction, p.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения анкеты: %v", err)
+	}
+	return nil
+}
+
+// Сохранение отмены записи учеником
+func (st *SQLiteStore) RecordCancellation(c Cancellation) error {
+	_, err := st.db.Exec(
+		`INSERT INTO cancellations (slot_id, teacher_id, student_id, start_time, end_time, cancelled_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.SlotID, c.TeacherID, c.StudentID, c.StartTime, c.EndTime, c.CancelledAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения отмены: %v", err)
+	}
+	return nil
+}
+
+// Причина последней отмены учеником записи на слот
+func (st *SQLiteStore) SetCancellationReason(slotID, studentID int64, reason string) error {
+	_, err := st.db.Exec(`UPDATE cancellations SET reason = ?
+        WHERE id = (SELECT MAX(id) FROM cancellations WHERE slot_id = ? AND student_id = ?)`, reason, slotID, studentID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения причины отмены: %v", err)
+	}
+	return nil
+}
+
+// Справочник учеников: ученики, которые записывались к учителю (в том числе
+// отменившие запись или заблокированные им), с числом предстоящих и прошедших
+// к now (время расписания) занятий и отмен
+func (st *SQLiteStore) GetStudentSummaries(teacherID int64, now time.Time) ([]StudentSummary, error) {
+	rows, err := st.db.Query(`SELECT u.telegram_id, u.username, p.name, COALESCE(p.phone, u.contact), p.level,
+            EXISTS(SELECT 1 FROM student_blocks b WHERE b.teacher_id = ? AND b.student_id = u.telegram_id),
+            (SELECT COUNT(*) FROM schedules s
+                WHERE s.student_id = u.telegram_id AND s.teacher_id = ? AND s.status = 'booked' AND s.start_time >= ?),
+            (SELECT COUNT(*) FROM schedules s
+                WHERE s.student_id = u.telegram_id AND s.teacher_id = ? AND s.status = 'booked' AND s.start_time < ?),
+            (SELECT COUNT(*) FROM cancellations c WHERE c.student_id = u.telegram_id AND c.teacher_id = ?)
+        FROM users u
+        LEFT JOIN student_profiles p ON p.telegram_id = u.telegram_id
+        WHERE u.role = 'student' AND u.telegram_id IN (
+            SELECT student_id FROM schedules WHERE teacher_id = ? AND status = 'booked'
+            UNION SELECT student_id FROM cancellations WHERE teacher_id = ?
+            UNION SELECT student_id FROM student_blocks WHERE teacher_id = ?)
+        ORDER BY COALESCE(p.name, u.username, '') COLLATE NOCASE, u.telegram_id`,
+		teacherID, teacherID, now.Format(time.RFC3339), teacherID, now.Format(time.RFC3339), teacherID, teacherID, teacherID, teacherID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения учеников: %v", err)
+	}
+	defer rows.Close()
+
+	var students []StudentSummary
+	for rows.Next() {
+		var s StudentSummary
+		if err := rows.Scan(&s.TelegramID, &s.Username, &s.Name, &s.Phone, &s.Level, &s.Blocked,
+			&s.Upcoming, &s.Past, &s.Cancellations); err != nil {
+			return nil, fmt.Errorf("ошибка чтения учеников: %v", err)
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// Запрет ученику записываться к учителю или его снятие
+func (st *SQLiteStore) SetStudentBlocked(teacherID, studentID int64, blocked bool) error {
+	var err error
+	if blocked {
+		_, err = st.db.Exec(`INSERT OR IGNORE INTO student_blocks (teacher_id, student_id, blocked_at) VALUES (?, ?, ?)`,
+			teacherID, studentID, time.Now().Format(time.RFC3339))
+	} else {
+		_, err = st.db.Exec(`DELETE FROM student_blocks WHERE teacher_id = ? AND student_id = ?`, teacherID, studentID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки ученика: %v", err)
+	}
+	return nil
+}
+
+// Запрещено ли ученику записываться к учителю
+func (st *SQLiteStore) IsStudentBlocked(teacherID, studentID int64) (bool, error) {
+	var blocked bool
+	err := st.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM student_blocks WHERE teacher_id = ? AND student_id = ?)`,
+		teacherID, studentID).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки блокировки ученика: %v", err)
+	}
+	return blocked, nil
+}
+
+// Все занятия ученика у учителя по времени
+func (st *SQLiteStore) GetStudentLessons(teacherID, studentID int64) ([]Schedule, error) {
+	rows, err := st.db.Query(`SELECT id, teacher_id, start_time, end_time, status, student_id, direction
+        FROM schedules
+        WHERE teacher_id = ? AND student_id = ? AND status = 'booked'
+        ORDER BY start_time`, teacherID, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения занятий ученика: %v", err)
+	}
+	defer rows.Close()
+
+	var lessons []Schedule
+	for rows.Next() {
+		var s Schedule
+		if err := rows.Scan(&s.ID, &s.TeacherID, &s.StartTime, &s.EndTime, &s.Status, &s.StudentID, &s.Direction); err != nil {
+			return nil, fmt.Errorf("ошибка чтения занятий ученика: %v", err)
+		}
+		lessons = append(lessons, s)
+	}
+	return lessons, rows.Err()
+}
+
+// Отмены ученика у учителя, сначала последние
+func (st *SQLiteStore) GetStudentCancellations(teacherID, studentID int64) ([]Cancellation, error) {
+	rows, err := st.db.Query(`SELECT slot_id, teacher_id, student_id, start_time, end_time, cancelled_at, COALESCE(reason, '')
+        FROM cancellations
+        WHERE teacher_id = ? AND student_id = ?
+        ORDER BY cancelled_at DESC`, teacherID, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения отмен: %v", err)
+	}
+	defer rows.Close()
+
+	var cancellations []Cancellation
+	for rows.Next() {
+		var c Cancellation
+		if err := rows.Scan(&c.SlotID, &c.TeacherID, &c.StudentID, &c.StartTime, &c.EndTime, &c.CancelledAt, &c.Reason); err != nil {
+			return nil, fmt.Errorf("ошибка чтения отмен: %v", err)
+		}
+		cancellations = append(cancellations, c)
+	}
+	return cancellations, rows.Err()
+}
+
+// Добавление заметки об ученике или занятии
+func (st *SQLiteStore) AddStudentNote(note StudentNote) error {
+	_, err := st.db.Exec(
+		`INSERT INTO student_notes (teacher_id, student_id, slot_id, kind, text, file_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		note.TeacherID, note.StudentID, note.SlotID, note.Kind, note.Text, note.FileID, note.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения заметки: %v", err)
+	}
+	return nil
+}
+
+const studentNoteColumns = `n.id, n.teacher_id, n.student_id, n.slot_id, n.kind, COALESCE(n.text, ''), n.file_id, n.created_at, s.start_time`
+
+func scanStudentNotes(rows *sql.Rows) ([]StudentNote, error) {
+	defer rows.Close()
+	var notes []StudentNote
+	for rows.Next() {
+		var n StudentNote
+		if err := rows.Scan(&n.ID, &n.TeacherID, &n.StudentID, &n.SlotID, &n.Kind, &n.Text, &n.FileID, &n.CreatedAt, &n.LessonStart); err != nil {
+			return nil, fmt.Errorf("ошибка чтения заметок: %v", err)
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// Заметки учителя об ученике и его занятиях, сначала последние
+func (st *SQLiteStore) GetStudentNotes(teacherID, studentID int64) ([]StudentNote, error) {
+	rows, err := st.db.Query(`SELECT `+studentNoteColumns+`
+        FROM student_notes n
+        LEFT JOIN schedules s ON s.id = n.slot_id
+        WHERE n.teacher_id = ? AND n.student_id = ?
+        ORDER BY n.created_at DESC, n.id DESC`, teacherID, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заметок: %v", err)
+	}
+	return scanStudentNotes(rows)
+}
+
+// Заметки к занятию, сначала последние
+func (st *SQLiteStore) GetLessonNotes(slotID int64) ([]StudentNote, error) {
+	rows, err := st.db.Query(`SELECT `+studentNoteColumns+`
+        FROM student_notes n
+        LEFT JOIN schedules s ON s.id = n.slot_id
+        WHERE n.slot_id = ?
+        ORDER BY n.created_at DESC, n.id DESC`, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заметок занятия: %v", err)
+	}
+	return scanStudentNotes(rows)
+}
+
+// Заметка по ID
+func (st *SQLiteStore) GetStudentNote(noteID int64) (*StudentNote, error) {
+	rows, err := st.db.Query(`SELECT `+studentNoteColumns+`
+        FROM student_notes n
+        LEFT JOIN schedules s ON s.id = n.slot_id
+        WHERE n.id = ?`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заметки: %v", err)
+	}
+	notes, err := scanStudentNotes(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("заметка не найдена")
+	}
+	return &notes[0], nil
+}
+
+// Создание домашнего задания (черновика) к занятию
+func (st *SQLiteStore) CreateHomework(hw Homework) (int64, error) {
+	result, err := st.db.Exec(
+		`INSERT INTO homework (slot_id, teacher_id, student_id, status, text, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		hw.SlotID, hw.TeacherID, hw.StudentID, hw.Status, hw.Text, hw.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания домашнего задания: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// Сохранение изменяемых полей задания: статус, текст, срок, ответ и проверка
+func (st *SQLiteStore) UpdateHomework(hw Homework) error {
+	_, err := st.db.Exec(`UPDATE homework
+        SET status = ?, text = ?, due_at = ?, answer = ?, submitted_at = ?, grade = ?, comment = ?, reviewed_at = ?
+        WHERE id = ?`,
+		hw.Status, hw.Text, hw.DueAt, hw.Answer, hw.SubmittedAt, hw.Grade, hw.Comment, hw.ReviewedAt, hw.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления домашнего задания: %v", err)
+	}
+	return nil
+}
+
+// Удаление задания вместе с файлами
+func (st *SQLiteStore) DeleteHomework(homeworkID int64) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка удаления домашнего задания: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM homework_files WHERE homework_id = ?`, homeworkID); err != nil {
+		return fmt.Errorf("ошибка удаления файлов задания: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM homework WHERE id = ?`, homeworkID); err != nil {
+		return fmt.Errorf("ошибка удаления домашнего задания: %v", err)
+	}
+	return tx.Commit()
+}
+
+const homeworkColumns = `h.id, h.slot_id, h.teacher_id, h.student_id, h.status, COALESCE(h.text, ''), h.due_at,
+        h.created_at, h.sent_at, h.reminded_at, COALESCE(h.answer, ''), h.submitted_at, h.grade,
+        COALESCE(h.comment, ''), h.reviewed_at, s.start_time, s.end_time`
+
+func scanHomework(rows *sql.Rows) ([]Homework, error) {
+	defer rows.Close()
+	var list []Homework
+	for rows.Next() {
+		var h Homework
+		if err := rows.Scan(&h.ID, &h.SlotID, &h.TeacherID, &h.StudentID, &h.Status, &h.Text, &h.DueAt,
+			&h.CreatedAt, &h.SentAt, &h.RemindedAt, &h.Answer, &h.SubmittedAt, &h.Grade,
+			&h.Comment, &h.ReviewedAt, &h.LessonStart, &h.LessonEnd); err != nil {
+			return nil, fmt.Errorf("ошибка чтения домашних заданий: %v", err)
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// Одно задание по условию; nil, nil — заданий нет
+func (st *SQLiteStore) queryOneHomework(where string, args ...interface{}) (*Homework, error) {
+	rows, err := st.db.Query(`SELECT `+homeworkColumns+`
+        FROM homework h
+        LEFT JOIN schedules s ON s.id = h.slot_id
+        WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения домашнего задания: %v", err)
+	}
+	list, err := scanHomework(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Задание по ID
+func (st *SQLiteStore) GetHomework(homeworkID int64) (*Homework, error) {
+	hw, err := st.queryOneHomework(`h.id = ?`, homeworkID)
+	if err != nil {
+		return nil, err
+	}
+	if hw == nil {
+		return nil, fmt.Errorf("домашнее задание %d не найдено", homeworkID)
+	}
+	return hw, nil
+}
+
+// Задание к занятию; nil, nil — задания нет
+func (st *SQLiteStore) GetLessonHomework(slotID int64) (*Homework, error) {
+	return st.queryOneHomework(`h.slot_id = ?`, slotID)
+}
+
+// Выданные учителем задания (без черновиков), сначала последние занятия
+func (st *SQLiteStore) GetTeacherHomework(teacherID int64) ([]Homework, error) {
+	rows, err := st.db.Query(`SELECT `+homeworkColumns+`
+        FROM homework h
+        LEFT JOIN schedules s ON s.id = h.slot_id
+        WHERE h.teacher_id = ? AND h.status != 'draft'
+        ORDER BY s.start_time DESC, h.id DESC`, teacherID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения домашних заданий: %v", err)
+	}
+	return scanHomework(rows)
+}
+
+// Полученные учеником задания, сначала самые поздние сроки
+func (st *SQLiteStore) GetStudentHomework(studentID int64) ([]Homework, error) {
+	rows, err := st.db.Query(`SELECT `+homeworkColumns+`
+        FROM homework h
+        LEFT JOIN schedules s ON s.id = h.slot_id
+        WHERE h.student_id = ? AND h.status != 'draft' AND h.sent_at IS NOT NULL
+        ORDER BY h.due_at DESC, h.id DESC`, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения домашних заданий: %v", err)
+	}
+	return scanHomework(rows)
+}
+
+// Выданные задания, занятие которых закончилось к now (время расписания), но ученик их еще не получил
+// (если ученик отменил запись, задание не отправляется)
+func (st *SQLiteStore) GetHomeworkToSend(now time.Time) ([]Homework, error) {
+	rows, err := st.db.Query(`SELECT `+homeworkColumns+`
+        FROM homework h
+        JOIN schedules s ON s.id = h.slot_id
+        WHERE h.status = 'assigned' AND h.sent_at IS NULL AND s.end_time <= ?
+            AND s.status = 'booked' AND s.student_id = h.student_id`,
+		now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заданий для отправки: %v", err)
+	}
+	return scanHomework(rows)
+}
+
+// Несданные задания со сроком до dueBefore (время расписания), о которых еще не напоминали
+func (st *SQLiteStore) GetHomeworkToRemind(dueBefore time.Time) ([]Homework, error) {
+	rows, err := st.db.Query(`SELECT `+homeworkColumns+`
+        FROM homework h
+        LEFT JOIN schedules s ON s.id = h.slot_id
+        WHERE h.status = 'assigned' AND h.sent_at IS NOT NULL AND h.reminded_at IS NULL AND h.due_at <= ?`,
+		dueBefore.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заданий для напоминания: %v", err)
+	}
+	return scanHomework(rows)
+}
+
+// Отметка об отправке задания ученику
+func (st *SQLiteStore) MarkHomeworkSent(homeworkID int64) error {
+	_, err := st.db.Exec(`UPDATE homework SET sent_at = ? WHERE id = ?`, time.Now().Format(time.RFC3339), homeworkID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления задания: %v", err)
+	}
+	return nil
+}
+
+// Отметка о напоминании про срок
+func (st *SQLiteStore) MarkHomeworkReminded(homeworkID int64) error {
+	_, err := st.db.Exec(`UPDATE homework SET reminded_at = ? WHERE id = ?`, time.Now().Format(time.RFC3339), homeworkID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления задания: %v", err)
+	}
+	return nil
+}
+
+// Добавление файла к заданию или ответу
+func (st *SQLiteStore) AddHomeworkFile(file HomeworkFile) error {
+	_, err := st.db.Exec(
+		`INSERT INTO homework_files (homework_id, owner, kind, file_id, file_name) VALUES (?, ?, ?, ?, ?)`,
+		file.HomeworkID, file.Owner, file.Kind, file.FileID, file.FileName)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения файла задания: %v", err)
+	}
+	return nil
+}
+
+// Файлы задания и ответа в порядке добавления
+func (st *SQLiteStore) GetHomeworkFiles(homeworkID int64) ([]HomeworkFile, error) {
+	rows, err := st.db.Query(`SELECT id, homework_id, owner, kind, file_id, COALESCE(file_name, '')
+        FROM homework_files WHERE homework_id = ? ORDER BY id`, homeworkID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения файлов задания: %v", err)
+	}
+	defer rows.Close()
+
+	var files []HomeworkFile
+	for rows.Next() {
+		var f HomeworkFile
+		if err := rows.Scan(&f.ID, &f.HomeworkID, &f.Owner, &f.Kind, &f.FileID, &f.FileName); err != nil {
+			return nil, fmt.Errorf("ошибка чтения файлов задания: %v", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// Занятия, закончившиеся в промежутке (from, to] времени расписания, по которым еще не было опроса
+func (st *SQLiteStore) GetLessonsToSurvey(from, to time.Time) ([]BookingNotification, error) {
+	rows, err := st.db.Query(`SELECT s.id, s.teacher_id, s.student_id, s.start_time, s.end_time,
+            COALESCE(s.direction, ''), COALESCE(u.username, '')
+        FROM schedules s
+        LEFT JOIN users u ON u.telegram_id = s.student_id
+        LEFT JOIN lesson_feedback f ON f.slot_id = s.id
+        WHERE s.status = 'booked' AND s.student_id IS NOT NULL
+            AND s.end_time > ? AND s.end_time <= ? AND f.slot_id IS NULL`,
+		from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения прошедших занятий: %v", err)
+	}
+	defer rows.Close()
+
+	var lessons []BookingNotification
+	for rows.Next() {
+		var b BookingNotification
+		if err := rows.Scan(&b.ID, &b.TeacherID, &b.StudentID, &b.StartTime, &b.EndTime, &b.Direction, &b.StudentUsername); err != nil {
+			return nil, fmt.Errorf("ошибка чтения прошедших занятий: %v", err)
+		}
+		lessons = append(lessons, b)
+	}
+	return lessons, rows.Err()
+}
+
+// Создание опроса по занятию; false — опрос уже был
+func (st *SQLiteStore) CreateLessonFeedback(feedback LessonFeedback) (bool, error) {
+	result, err := st.db.Exec(`INSERT OR IGNORE INTO lesson_feedback (slot_id, teacher_id, student_id, direction, created_at)
+        VALUES (?, ?, ?, ?, ?)`,
+		feedback.SlotID, feedback.TeacherID, feedback.StudentID, feedback.Direction, feedback.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("ошибка создания опроса: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка создания опроса: %v", err)
+	}
+	return n > 0, nil
+}
+
+// Опрос по занятию; nil, nil — опроса не было
+func (st *SQLiteStore) GetLessonFeedback(slotID int64) (*LessonFeedback, error) {
+	var f LessonFeedback
+	err := st.db.QueryRow(`SELECT slot_id, teacher_id, student_id, COALESCE(direction, ''), rating, COALESCE(comment, ''),
+            rated_at, COALESCE(summary, ''), summarized_at, created_at
+        FROM lesson_feedback WHERE slot_id = ?`, slotID).Scan(
+		&f.SlotID, &f.TeacherID, &f.StudentID, &f.Direction, &f.Rating, &f.Comment,
+		&f.RatedAt, &f.Summary, &f.SummarizedAt, &f.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения опроса: %v", err)
+	}
+	return &f, nil
+}
+
+// Оценка ученика (повторная оценка заменяет прежнюю)
+func (st *SQLiteStore) SetLessonRating(slotID int64, rating int) error {
+	_, err := st.db.Exec(`UPDATE lesson_feedback SET rating = ?, rated_at = ? WHERE slot_id = ?`,
+		rating, time.Now().Format(time.RFC3339), slotID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения оценки: %v", err)
+	}
+	return nil
+}
+
+// Комментарий ученика к оценке
+func (st *SQLiteStore) SetLessonComment(slotID int64, comment string) error {
+	_, err := st.db.Exec(`UPDATE lesson_feedback SET comment = ? WHERE slot_id = ?`, comment, slotID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения комментария: %v", err)
+	}
+	return nil
+}
+
+// Итог занятия от учителя
+func (st *SQLiteStore) SetLessonSummary(slotID int64, summary string) error {
+	_, err := st.db.Exec(`UPDATE lesson_feedback SET summary = ?, summarized_at = ? WHERE slot_id = ?`,
+		summary, time.Now().Format(time.RFC3339), slotID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения итога занятия: %v", err)
+	}
+	return nil
+}
+
+// Статистика учителя: слоты, отмены и опросы после занятий
+func (st *SQLiteStore) GetTeacherStats(teacherID int64) (*TeacherStats, error) {
+	var stats TeacherStats
+	err := st.db.QueryRow(`SELECT COUNT(*),
+            COALESCE(SUM(CASE WHEN status = 'booked' THEN 1 ELSE 0 END), 0),
+            COALESCE(SUM(CASE WHEN status = 'free' THEN 1 ELSE 0 END), 0)
+        FROM schedules WHERE teacher_id = ?`, teacherID).Scan(&stats.TotalSlots, &stats.BookedSlots, &stats.FreeSlots)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчета слотов: %v", err)
+	}
+	err = st.db.QueryRow(`SELECT COUNT(*) FROM cancellations WHERE teacher_id = ?`, teacherID).Scan(&stats.Cancellations)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчета отмен: %v", err)
+	}
+	err = st.db.QueryRow(`SELECT COUNT(*), COUNT(rating), COALESCE(AVG(rating), 0), COUNT(summarized_at)
+        FROM lesson_feedback WHERE teacher_id = ?`, teacherID).Scan(&stats.Surveyed, &stats.Rated, &stats.AverageRating, &stats.Summaries)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчета оценок: %v", err)
+	}
+	return &stats, nil
+}
+
+// Средние оценки учителя по направлениям, сначала лучшие
+func (st *SQLiteStore) GetRatingStats(teacherID int64) ([]RatingStat, error) {
+	rows, err := st.db.Query(`SELECT COALESCE(direction, ''), COUNT(rating), AVG(rating)
+        FROM lesson_feedback
+        WHERE teacher_id = ? AND rating IS NOT NULL
+        GROUP BY COALESCE(direction, '')
+        ORDER BY AVG(rating) DESC, COUNT(rating) DESC`, teacherID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения оценок по направлениям: %v", err)
+	}
+	defer rows.Close()
+
+	var stats []RatingStat
+	for rows.Next() {
+		var r RatingStat
+		if err := rows.Scan(&r.Direction, &r.Count, &r.Average); err != nil {
+			return nil, fmt.Errorf("ошибка чтения оценок по направлениям: %v", err)
+		}
+		stats = append(stats, r)
+	}
+	return stats, rows.Err()
+}
+
+// Столбцы group_chats с флагами событий
+var groupEventColumns = map[string]bool{
+	groupEventReminders: true,
+	groupEventAgenda:    true,
+	groupEventFreeSlots: true,
+	groupEventBoard:     true,
+	groupEventPinAgenda: true,
+}
+
+// Привязка группы к учителю; false — группа уже была привязана (обновляется только название)
+func (st *SQLiteStore) LinkGroupChat(g GroupChat) (bool, error) {
+	result, err := st.db.Exec(`INSERT OR IGNORE INTO group_chats (chat_id, teacher_id, title, reminders, agenda, free_slots, board, pin_agenda, linked_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ChatID, g.TeacherID, g.Title, g.Reminders, g.Agenda, g.FreeSlots, g.Board, g.PinAgenda, g.LinkedAt)
+	if err != nil {
+		return false, fmt.Errorf("ошибка привязки группы: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка привязки группы: %v", err)
+	}
+	if n > 0 {
+		return true, nil
+	}
+	if g.Title != "" {
+		_, err = st.db.Exec(`UPDATE group_chats SET title = ? WHERE chat_id = ? AND teacher_id = ?`, g.Title, g.ChatID, g.TeacherID)
+		if err != nil {
+			return false, fmt.Errorf("ошибка обновления названия группы: %v", err)
+		}
+	}
+	return false, nil
+}
+
+// Отвязка группы от учителя вместе с его доской свободных слотов
+func (st *SQLiteStore) UnlinkGroupChat(chatID, teacherID int64) error {
+	for _, table := range []string{"group_chats", "slot_boards"} {
+		_, err := st.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE chat_id = ? AND teacher_id = ?`, table), chatID, teacherID)
+		if err != nil {
+			return fmt.Errorf("ошибка отвязки группы: %v", err)
+		}
+	}
+	return nil
+}
+
+// Удаление группы у всех учителей (бота удалили из группы)
+func (st *SQLiteStore) RemoveGroupChat(chatID int64) error {
+	for _, table := range []string{"group_chats", "slot_boards"} {
+		_, err := st.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE chat_id = ?`, table), chatID)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления группы: %v", err)
+		}
+	}
+	return nil
+}
+
+// Новый ID группы после ее преобразования в супергруппу
+func (st *SQLiteStore) MigrateGroupChat(oldChatID, newChatID int64) error {
+	_, err := st.db.Exec(`UPDATE OR REPLACE group_chats SET chat_id = ? WHERE chat_id = ?`, newChatID, oldChatID)
+	if err != nil {
+		return fmt.Errorf("ошибка переноса группы: %v", err)
+	}
+	// Сообщения старой группы в супергруппе недоступны — доски будут отправлены заново
+	_, err = st.db.Exec(`DELETE FROM slot_boards WHERE chat_id = ?`, oldChatID)
+	if err != nil {
+		return fmt.Errorf("ошибка переноса группы: %v", err)
+	}
+	return nil
+}
+
+// Группа учителя; nil, nil — группа не привязана
+func (st *SQLiteStore) GetGroupChat(chatID, teacherID int64) (*GroupChat, error) {
+	var g GroupChat
+	err := st.db.QueryRow(`SELECT chat_id, teacher_id, COALESCE(title, ''), reminders, agenda, free_slots, board, pin_agenda, COALESCE(linked_at, '')
+        FROM group_chats WHERE chat_id = ? AND teacher_id = ?`, chatID, teacherID).Scan(
+		&g.ChatID, &g.TeacherID, &g.Title, &g.Reminders, &g.Agenda, &g.FreeSlots, &g.Board, &g.PinAgenda, &g.LinkedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения группы: %v", err)
+	}
+	return &g, nil
+}
+
+// Группы учителя в порядке привязки
+func (st *SQLiteStore) GetTeacherGroups(teacherID int64) ([]GroupChat, error) {
+	return st.queryGroupChats(`SELECT chat_id, teacher_id, COALESCE(title, ''), reminders, agenda, free_slots, board, pin_agenda, COALESCE(linked_at, '')
+        FROM group_chats WHERE teacher_id = ? ORDER BY linked_at, chat_id`, teacherID)
+}
+
+// Группы учителя, в которые публикуется событие event
+func (st *SQLiteStore) GetGroupsForEvent(teacherID int64, event string) ([]GroupChat, error) {
+	if !groupEventColumns[event] {
+		return nil, fmt.Errorf("неизвестное событие группы: %s", event)
+	}
+	return st.queryGroupChats(fmt.Sprintf(`SELECT chat_id, teacher_id, COALESCE(title, ''), reminders, agenda, free_slots, board, pin_agenda, COALESCE(linked_at, '')
+        FROM group_chats WHERE teacher_id = ? AND %s ORDER BY linked_at, chat_id`, event), teacherID)
+}
+
+func (st *SQLiteStore) queryGroupChats(query string, args ...interface{}) ([]GroupChat, error) {
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения групп: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []GroupChat
+	for rows.Next() {
+		var g GroupChat
+		if err := rows.Scan(&g.ChatID, &g.TeacherID, &g.Title, &g.Reminders, &g.Agenda, &g.FreeSlots, &g.Board, &g.PinAgenda, &g.LinkedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения групп: %v", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Включение или отключение публикации события в группу
+func (st *SQLiteStore) SetGroupEvent(chatID, teacherID int64, event string, enabled bool) error {
+	if !groupEventColumns[event] {
+		return fmt.Errorf("неизвестное событие группы: %s", event)
+	}
+	_, err := st.db.Exec(fmt.Sprintf(`UPDATE group_chats SET %s = ? WHERE chat_id = ? AND teacher_id = ?`, event), enabled, chatID, teacherID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настроек группы: %v", err)
+	}
+	return nil
+}
+
+// Настройки уведомлений; без сохраненных настроек — значения по умолчанию
+func (st *SQLiteStore) GetNotificationSettings(userID int64) (*NotificationSettings, error) {
+	s := defaultNotificationSettings(userID)
+	err := st.db.QueryRow(`SELECT enable_reminders, enable_new_bookings, enable_cancellations, enable_agenda, agenda_time, pin_agenda
+        FROM notification_settings WHERE user_id = ?`, userID).Scan(
+		&s.EnableReminders, &s.EnableNewBookings, &s.EnableCancellations, &s.EnableAgenda, &s.AgendaTime, &s.PinAgenda)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("ошибка получения настроек уведомлений: %v", err)
+	}
+	return &s, nil
+}
+
+// Сохранение настроек уведомлений
+func (st *SQLiteStore) SaveNotificationSettings(s NotificationSettings) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO notification_settings
+            (user_id, enable_reminders, enable_new_bookings, enable_cancellations, enable_agenda, agenda_time, pin_agenda)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.UserID, s.EnableReminders, s.EnableNewBookings, s.EnableCancellations, s.EnableAgenda, s.AgendaTime, s.PinAgenda)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настроек уведомлений: %v", err)
+	}
+	return nil
+}
+
+// Планы на день, отправленные в день day (YYYY-MM-DD)
+func (st *SQLiteStore) GetDailyAgendas(day string) ([]DailyAgenda, error) {
+	rows, err := st.db.Query(`SELECT chat_id, teacher_id, day, message_id, COALESCE(text, '')
+        FROM daily_agendas WHERE day = ?`, day)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения планов на день: %v", err)
+	}
+	defer rows.Close()
+
+	var agendas []DailyAgenda
+	for rows.Next() {
+		var a DailyAgenda
+		if err := rows.Scan(&a.ChatID, &a.TeacherID, &a.Day, &a.MessageID, &a.Text); err != nil {
+			return nil, fmt.Errorf("ошибка чтения планов на день: %v", err)
+		}
+		agendas = append(agendas, a)
+	}
+	return agendas, rows.Err()
+}
+
+// Сохранение отправленного или измененного плана на день
+func (st *SQLiteStore) SaveDailyAgenda(a DailyAgenda) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO daily_agendas (chat_id, teacher_id, day, message_id, text)
+        VALUES (?, ?, ?, ?, ?)`, a.ChatID, a.TeacherID, a.Day, a.MessageID, a.Text)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения плана на день: %v", err)
+	}
+	return nil
+}
+
+// Удаление планов за прошедшие дни
+func (st *SQLiteStore) DeleteDailyAgendasBefore(day string) error {
+	_, err := st.db.Exec(`DELETE FROM daily_agendas WHERE day < ?`, day)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления старых планов на день: %v", err)
+	}
+	return nil
+}
+
+// Доска свободных слотов учителя в чате; nil, nil — доски еще нет
+func (st *SQLiteStore) GetSlotBoard(chatID, teacherID int64) (*SlotBoard, error) {
+	var b SlotBoard
+	err := st.db.QueryRow(`SELECT chat_id, teacher_id, message_id, COALESCE(text, '')
+        FROM slot_boards WHERE chat_id = ? AND teacher_id = ?`, chatID, teacherID).Scan(
+		&b.ChatID, &b.TeacherID, &b.MessageID, &b.Text)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения доски слотов: %v", err)
+	}
+	return &b, nil
+}
+
+// Сохранение отправленной или измененной доски
+func (st *SQLiteStore) SaveSlotBoard(b SlotBoard) error {
+	_, err := st.db.Exec(`INSERT OR REPLACE INTO slot_boards (chat_id, teacher_id, message_id, text)
+        VALUES (?, ?, ?, ?)`, b.ChatID, b.TeacherID, b.MessageID, b.Text)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения доски слотов: %v", err)
+	}
+	return nil
+}
+
+// Удаление доски
+func (st *SQLiteStore) DeleteSlotBoard(chatID, teacherID int64) error {
+	_, err := st.db.Exec(`DELETE FROM slot_boards WHERE chat_id = ? AND teacher_id = ?`, chatID, teacherID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления доски слотов: %v", err)
+	}
+	return nil
+}
+
+// Учителя, у которых есть доски свободных слотов
+func (st *SQLiteStore) GetBoardTeachers() ([]int64, error) {
+	rows, err := st.db.Query(`SELECT DISTINCT teacher_id FROM group_chats WHERE board`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения учителей с досками: %v", err)
+	}
+	defer rows.Close()
+
+	var teachers []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения учителей с досками: %v", err)
+		}
+		teachers = append(teachers, id)
+	}
+	return teachers, rows.Err()
+}
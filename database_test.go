@@ -0,0 +1,751 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Проверки запросов SQLiteStore на базе в памяти. Слоты лежат далеко в
+// будущем (и один в прошлом), чтобы запросы относительно текущего времени
+// давали одинаковый результат при любом запуске.
+
+const (
+	testTeacher   int64 = 10
+	testStudent   int64 = 20
+	testAnonymous int64 = 30 // Ученик без имени пользователя (username NULL)
+)
+
+// Слоты тестовой базы
+type testSlots struct {
+	Free      int64 // Свободный: student_id и direction NULL
+	Booked    int64 // Записан testStudent с направлением
+	NoDir     int64 // Записан testStudent без направления (direction NULL)
+	Anonymous int64 // Записан testAnonymous
+	Past      int64 // Свободный слот в прошлом
+	Other     int64 // Свободный слот другого учителя
+}
+
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	st, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func mustExec(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// База с учителем, двумя учениками и слотами во всех состояниях
+func seedTestStore(t *testing.T) (*SQLiteStore, testSlots) {
+	t.Helper()
+	st := newTestStore(t)
+	mustExec(t, st.RegisterUser(testTeacher, "teacher", "teacher"))
+	mustExec(t, st.RegisterUser(testTeacher+1, "teacher", "other"))
+	mustExec(t, st.RegisterUser(testStudent, "student", "student"))
+	_, err := st.db.Exec(`INSERT INTO users (telegram_id, role, username) VALUES (?, 'student', NULL)`, testAnonymous)
+	mustExec(t, err)
+
+	add := func(teacherID int64, start string) int64 {
+		t.Helper()
+		end := mustParseTime(t, start).Add(time.Hour).Format(time.RFC3339)
+		mustExec(t, st.AddScheduleSlot(teacherID, start, end))
+		id, err := st.GetSlotIDByStart(teacherID, start)
+		mustExec(t, err)
+		return id
+	}
+	var s testSlots
+	s.Free = add(testTeacher, "2099-01-10T10:00:00Z")
+	s.Booked = add(testTeacher, "2099-01-10T12:00:00Z")
+	s.NoDir = add(testTeacher, "2099-01-11T09:00:00Z")
+	s.Anonymous = add(testTeacher, "2099-01-12T09:00:00Z")
+	s.Past = add(testTeacher, "2000-01-01T10:00:00Z")
+	s.Other = add(testTeacher+1, "2099-01-10T10:00:00Z")
+	mustExec(t, st.UpdateScheduleStatus(s.Booked, "booked", testStudent, "Grammar"))
+	mustExec(t, st.UpdateScheduleStatus(s.NoDir, "booked", testStudent, ""))
+	mustExec(t, st.UpdateScheduleStatus(s.Anonymous, "booked", testAnonymous, ""))
+	return st, s
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func scheduleIDs(list []Schedule) []int64 {
+	var ids []int64
+	for _, s := range list {
+		ids = append(ids, int64(s.ID))
+	}
+	return ids
+}
+
+func TestSlotQueries(t *testing.T) {
+	st, s := seedTestStore(t)
+	day := time.Date(2099, 1, 10, 15, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name  string
+		query func() ([]Schedule, error)
+		want  []int64
+	}{
+		{"GetTeacherSchedule", func() ([]Schedule, error) { return st.GetTeacherSchedule(testTeacher) },
+			[]int64{s.Past, s.Free, s.Booked, s.NoDir, s.Anonymous}},
+		{"GetTeacherSchedule без слотов", func() ([]Schedule, error) { return st.GetTeacherSchedule(testStudent) }, nil},
+		{"GetSlotsForDate", func() ([]Schedule, error) { return st.GetSlotsForDate(testTeacher, day) },
+			[]int64{s.Free, s.Booked}},
+		{"GetSlotsForDate пустой день", func() ([]Schedule, error) { return st.GetSlotsForDate(testTeacher, day.AddDate(0, 0, 5)) }, nil},
+		{"GetAvailableSlotsForDate", func() ([]Schedule, error) { return st.GetAvailableSlotsForDate(day) },
+			[]int64{s.Free, s.Other}},
+		{"GetAvailableSlots без прошедших", func() ([]Schedule, error) { return st.GetAvailableSlots(scheduleNow()) },
+			[]int64{s.Free, s.Other}},
+		{"GetAvailableSlots с начала слота", func() ([]Schedule, error) { return st.GetAvailableSlots(mustParseTime(t, "2099-01-10T10:00:00Z")) }, nil},
+		{"GetAvailableSlots до прошедшего", func() ([]Schedule, error) { return st.GetAvailableSlots(mustParseTime(t, "1999-12-31T23:00:00Z")) },
+			[]int64{s.Past, s.Free, s.Other}},
+		{"GetStudentBookings", func() ([]Schedule, error) { return st.GetStudentBookings(testStudent) },
+			[]int64{s.Booked, s.NoDir}},
+		{"GetStudentBookings без записей", func() ([]Schedule, error) { return st.GetStudentBookings(testTeacher) }, nil},
+		{"GetStudentLessons", func() ([]Schedule, error) { return st.GetStudentLessons(testTeacher, testStudent) },
+			[]int64{s.Booked, s.NoDir}},
+		{"GetStudentLessons у другого учителя", func() ([]Schedule, error) { return st.GetStudentLessons(testTeacher+1, testStudent) }, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := c.query()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Слоты одного времени у разных учителей идут в порядке добавления
+			if got := scheduleIDs(list); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNullColumns(t *testing.T) {
+	st, s := seedTestStore(t)
+	schedule, err := st.GetTeacherSchedule(testTeacher)
+	mustExec(t, err)
+	byID := make(map[int64]Schedule)
+	for _, slot := range schedule {
+		byID[int64(slot.ID)] = slot
+	}
+	bookings, err := st.GetStudentBookings(testStudent)
+	mustExec(t, err)
+	bookingDirections := make(map[int64]sql.NullString)
+	for _, b := range bookings {
+		bookingDirections[int64(b.ID)] = b.Direction
+	}
+
+	cases := []struct {
+		name      string
+		slotID    int64
+		status    string
+		student   sql.NullInt64
+		direction sql.NullString
+	}{
+		{"свободный", s.Free, "free", sql.NullInt64{}, sql.NullString{}},
+		{"с направлением", s.Booked, "booked", sql.NullInt64{Int64: testStudent, Valid: true}, sql.NullString{String: "Grammar", Valid: true}},
+		{"без направления", s.NoDir, "booked", sql.NullInt64{Int64: testStudent, Valid: true}, sql.NullString{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			slot, err := st.GetScheduleByID(c.slotID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed, ok := byID[c.slotID]
+			if !ok {
+				t.Fatal("слота нет в расписании учителя")
+			}
+			for _, got := range []Schedule{*slot, listed} {
+				if got.Status != c.status || got.StudentID != c.student || got.Direction != c.direction {
+					t.Errorf("получено %s %+v %+v, ожидалось %s %+v %+v",
+						got.Status, got.StudentID, got.Direction, c.status, c.student, c.direction)
+				}
+			}
+			if dir, ok := bookingDirections[c.slotID]; ok && dir != c.direction {
+				t.Errorf("GetStudentBookings: направление %+v, ожидалось %+v", dir, c.direction)
+			}
+		})
+	}
+
+	if _, err := st.GetScheduleByID(-1); err == nil {
+		t.Error("GetScheduleByID: ожидалась ошибка для несуществующего слота")
+	}
+
+	// Отмена записи возвращает NULL в student_id и direction
+	mustExec(t, st.UpdateScheduleStatus(s.Booked, "free", 0, ""))
+	slot, err := st.GetScheduleByID(s.Booked)
+	mustExec(t, err)
+	if slot.Status != "free" || slot.StudentID.Valid || slot.Direction.Valid {
+		t.Errorf("после отмены: %+v", slot)
+	}
+}
+
+func TestValidateScheduleSlot(t *testing.T) {
+	st, _ := seedTestStore(t)
+	calendarID, err := st.AddExternalCalendar(testTeacher, "https://example.com/busy.ics")
+	mustExec(t, err)
+	busy := []BusyInterval{{
+		Start: mustParseTime(t, "2099-02-01T10:00:00Z"),
+		End:   mustParseTime(t, "2099-02-01T11:00:00Z"),
+	}}
+	mustExec(t, st.ReplaceExternalBusy(ExternalCalendar{ID: calendarID, TeacherID: testTeacher}, busy))
+
+	cases := []struct {
+		name       string
+		teacherID  int64
+		start, end string
+		want       error
+		wantErr    bool
+	}{
+		{"свободное время", testTeacher, "2099-02-01T12:00:00Z", "2099-02-01T13:00:00Z", nil, false},
+		{"конец раньше начала", testTeacher, "2099-02-01T13:00:00Z", "2099-02-01T12:00:00Z", nil, true},
+		{"неверный формат", testTeacher, "2099-02-01 12:00", "2099-02-01T13:00:00Z", nil, true},
+		{"дубликат", testTeacher, "2099-01-10T10:00:00Z", "2099-01-10T11:00:00Z", ErrSlotExists, true},
+		{"дубликат у другого учителя", testTeacher + 1, "2099-01-10T12:00:00Z", "2099-01-10T13:00:00Z", nil, false},
+		{"занято во внешнем календаре", testTeacher, "2099-02-01T10:30:00Z", "2099-02-01T11:30:00Z", ErrSlotExternallyBusy, true},
+		{"стык с внешней занятостью", testTeacher, "2099-02-01T11:00:00Z", "2099-02-01T12:00:00Z", nil, false},
+		{"внешняя занятость другого учителя", testTeacher + 1, "2099-02-01T10:00:00Z", "2099-02-01T11:00:00Z", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := st.ValidateScheduleSlot(c.teacherID, c.start, c.end)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, c.wantErr)
+			}
+			if c.want != nil && err != c.want {
+				t.Errorf("ошибка %v, ожидалась %v", err, c.want)
+			}
+		})
+	}
+}
+
+func TestScheduleExportQueries(t *testing.T) {
+	st, s := seedTestStore(t)
+	mustExec(t, st.SetUserContact(testStudent, "+70000000000"))
+
+	cases := []struct {
+		name   string
+		filter ScheduleFilter
+		want   []int64
+	}{
+		{"все слоты", ScheduleFilter{}, []int64{s.Past, s.Free, s.Other, s.Booked, s.NoDir, s.Anonymous}},
+		{"учитель", ScheduleFilter{TeacherID: testTeacher + 1}, []int64{s.Other}},
+		{"период", ScheduleFilter{TeacherID: testTeacher, From: "2099-01-10T11:00:00Z", To: "2099-01-12T00:00:00Z"}, []int64{s.Booked, s.NoDir}},
+		{"статус", ScheduleFilter{TeacherID: testTeacher, Status: "free"}, []int64{s.Past, s.Free}},
+		{"пустой результат", ScheduleFilter{TeacherID: testStudent}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows, err := st.GetSchedulesForExport(c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, r := range rows {
+				got = append(got, int64(r.ID))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+
+	rows, err := st.GetSchedulesForExport(ScheduleFilter{TeacherID: testTeacher})
+	mustExec(t, err)
+	for _, r := range rows {
+		switch int64(r.ID) {
+		case s.Free:
+			if r.StudentID.Valid || r.StudentUsername.Valid || r.StudentContact.Valid || r.Direction.Valid {
+				t.Errorf("свободный слот с данными ученика: %+v", r)
+			}
+		case s.Booked:
+			if r.StudentUsername.String != "student" || r.StudentContact.String != "+70000000000" || r.Direction.String != "Grammar" {
+				t.Errorf("запись без данных ученика: %+v", r)
+			}
+		case s.Anonymous:
+			if !r.StudentID.Valid || r.StudentUsername.Valid || r.StudentContact.Valid {
+				t.Errorf("ученик без имени: %+v", r)
+			}
+		}
+	}
+}
+
+func TestCalendarEvents(t *testing.T) {
+	st, s := seedTestStore(t)
+
+	cases := []struct {
+		name string
+		user int64
+		want []int64
+	}{
+		{"учитель видит все свои слоты", testTeacher, []int64{s.Past, s.Free, s.Booked, s.NoDir, s.Anonymous}},
+		{"ученик видит только свои записи", testStudent, []int64{s.Booked, s.NoDir}},
+		{"без занятий", testAnonymous + 1, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			events, err := st.GetCalendarEvents(c.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, e := range events {
+				got = append(got, int64(e.ID))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+
+	events, err := st.GetCalendarEvents(testTeacher)
+	mustExec(t, err)
+	for _, e := range events {
+		switch int64(e.ID) {
+		case s.Free:
+			if e.Direction.Valid || e.StudentUsername.Valid {
+				t.Errorf("свободный слот: %+v", e)
+			}
+		case s.NoDir:
+			if e.Direction.Valid || e.StudentUsername.String != "student" {
+				t.Errorf("запись без направления: %+v", e)
+			}
+		case s.Anonymous:
+			if e.StudentUsername.Valid {
+				t.Errorf("ученик без имени: %+v", e)
+			}
+		}
+	}
+
+	token, err := st.GetCalendarToken(testStudent)
+	mustExec(t, err)
+	if again, _ := st.GetCalendarToken(testStudent); again != token {
+		t.Errorf("токен изменился при повторном запросе: %q → %q", token, again)
+	}
+	rotated, err := st.RotateCalendarToken(testStudent)
+	mustExec(t, err)
+	if rotated == token {
+		t.Error("токен не изменился после перевыпуска")
+	}
+	if _, err := st.GetUserByCalendarToken(token); err == nil {
+		t.Error("старый токен по-прежнему действует")
+	}
+	if u, err := st.GetUserByCalendarToken(rotated); err != nil || u.TelegramID != testStudent {
+		t.Errorf("пользователь по токену: %+v, %v", u, err)
+	}
+}
+
+func TestExternalCalendarQueries(t *testing.T) {
+	st, _ := seedTestStore(t)
+	first, err := st.AddExternalCalendar(testTeacher, "https://example.com/a.ics")
+	mustExec(t, err)
+	_, err = st.AddExternalCalendar(testTeacher+1, "https://example.com/b.ics")
+	mustExec(t, err)
+
+	for _, c := range []struct {
+		teacherID int64
+		want      int
+	}{{testTeacher, 1}, {testTeacher + 1, 1}, {0, 2}, {testStudent, 0}} {
+		calendars, err := st.GetExternalCalendars(c.teacherID)
+		mustExec(t, err)
+		if len(calendars) != c.want {
+			t.Errorf("GetExternalCalendars(%d): %d календарей, ожидалось %d", c.teacherID, len(calendars), c.want)
+		}
+	}
+
+	interval := BusyInterval{Start: mustParseTime(t, "2099-03-01T10:00:00Z"), End: mustParseTime(t, "2099-03-01T11:00:00Z")}
+	mustExec(t, st.ReplaceExternalBusy(ExternalCalendar{ID: first, TeacherID: testTeacher}, []BusyInterval{interval}))
+	calendars, err := st.GetExternalCalendars(testTeacher)
+	mustExec(t, err)
+	if !calendars[0].LastSyncedAt.Valid || calendars[0].LastError.Valid {
+		t.Errorf("после синхронизации: %+v", calendars[0])
+	}
+
+	cases := []struct {
+		name     string
+		from, to string
+		want     int
+	}{
+		{"внутри", "2099-03-01T10:15:00Z", "2099-03-01T10:45:00Z", 1},
+		{"стык до", "2099-03-01T09:00:00Z", "2099-03-01T10:00:00Z", 0},
+		{"стык после", "2099-03-01T11:00:00Z", "2099-03-01T12:00:00Z", 0},
+		{"весь день", "2099-03-01T00:00:00Z", "2099-03-02T00:00:00Z", 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := st.GetExternalBusy(testTeacher, c.from, c.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			busy, err := st.IsExternallyBusy(testTeacher, c.from, c.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != c.want || busy != (c.want > 0) {
+				t.Errorf("интервалов %d, занято %v; ожидалось %d", len(list), busy, c.want)
+			}
+		})
+	}
+
+	mustExec(t, st.SetExternalCalendarError(first, errors.New("timeout")))
+	calendars, err = st.GetExternalCalendars(testTeacher)
+	mustExec(t, err)
+	if calendars[0].LastError.String != "timeout" {
+		t.Errorf("ошибка синхронизации не сохранена: %+v", calendars[0])
+	}
+
+	mustExec(t, st.DeleteExternalCalendar(testTeacher+1, first))
+	if calendars, _ := st.GetExternalCalendars(testTeacher); len(calendars) != 1 {
+		t.Error("календарь удален чужим учителем")
+	}
+	mustExec(t, st.DeleteExternalCalendar(testTeacher, first))
+	if busy, _ := st.IsExternallyBusy(testTeacher, interval.Start.Format(time.RFC3339), interval.End.Format(time.RFC3339)); busy {
+		t.Error("занятость осталась после удаления календаря")
+	}
+}
+
+func TestUserQueries(t *testing.T) {
+	st, s := seedTestStore(t)
+
+	for _, c := range []struct {
+		id   int64
+		want bool
+	}{{testTeacher, true}, {testAnonymous, true}, {99, false}} {
+		if exists, err := st.UserExists(c.id); err != nil || exists != c.want {
+			t.Errorf("UserExists(%d) = %v, %v", c.id, exists, err)
+		}
+	}
+	if err := st.RegisterUser(testStudent, "student", "again"); err == nil {
+		t.Error("повторная регистрация не отклонена")
+	}
+	if _, err := st.GetUser(99); err == nil {
+		t.Error("GetUser: ожидалась ошибка для неизвестного пользователя")
+	}
+
+	anonymous, err := st.GetUser(testAnonymous)
+	mustExec(t, err)
+	if anonymous.Username.Valid || anonymous.Contact.Valid || anonymous.Language.Valid {
+		t.Errorf("новый пользователь без имени: %+v", anonymous)
+	}
+
+	mustExec(t, st.SetUserLanguage(testStudent, "en"))
+	mustExec(t, st.SetUserLanguageCode(testStudent, "de"))
+	mustExec(t, st.SetUserContact(testStudent, "+70000000000"))
+	u, err := st.GetUser(testStudent)
+	mustExec(t, err)
+	if u.Language.String != "en" || u.LanguageCode.String != "de" || u.Contact.String != "+70000000000" {
+		t.Errorf("настройки пользователя не сохранены: %+v", u)
+	}
+	mustExec(t, st.SetUserLanguage(testStudent, ""))
+	if u, _ := st.GetUser(testStudent); u.Language.Valid {
+		t.Errorf("язык не сброшен: %+v", u.Language)
+	}
+
+	mustExec(t, st.SetDeliveryStatus(testAnonymous, "blocked", "Forbidden: bot was blocked by the user"))
+	unreachable, err := st.GetUnreachableUsers()
+	mustExec(t, err)
+	if !reflect.DeepEqual(unreachable, map[int64]string{testAnonymous: "blocked"}) {
+		t.Errorf("недоступные пользователи: %v", unreachable)
+	}
+
+	teachers, err := st.GetAllTeachers()
+	mustExec(t, err)
+	if len(teachers) != 2 || teachers[0].TelegramID != testTeacher {
+		t.Errorf("учителя: %+v", teachers)
+	}
+
+	students, err := st.GetAllStudents()
+	mustExec(t, err)
+	want := []StudentExportRow{
+		{TelegramID: testStudent, Username: sql.NullString{String: "student", Valid: true},
+			Contact: sql.NullString{String: "+70000000000", Valid: true}, Bookings: 2},
+		{TelegramID: testAnonymous, Bookings: 1},
+	}
+	if !reflect.DeepEqual(students, want) {
+		t.Errorf("ученики: %+v, ожидалось %+v", students, want)
+	}
+
+	// Отмена записи уменьшает число занятий ученика
+	mustExec(t, st.UpdateScheduleStatus(s.Anonymous, "free", 0, ""))
+	students, err = st.GetAllStudents()
+	mustExec(t, err)
+	if students[1].Bookings != 0 {
+		t.Errorf("после отмены: %+v", students[1])
+	}
+}
+
+func TestBookingQueries(t *testing.T) {
+	st, s := seedTestStore(t)
+
+	bookingIDs := func(list []BookingNotification) []int64 {
+		var ids []int64
+		for _, b := range list {
+			ids = append(ids, int64(b.ID))
+		}
+		return ids
+	}
+
+	upcoming, err := st.GetUpcomingBookings(mustParseTime(t, "2099-01-10T12:00:00Z"))
+	mustExec(t, err)
+	if got := bookingIDs(upcoming); !reflect.DeepEqual(got, []int64{s.NoDir, s.Anonymous}) {
+		t.Errorf("GetUpcomingBookings: %v", got)
+	}
+	for _, b := range upcoming {
+		if b.Direction != "" {
+			t.Errorf("направление NULL не превращено в пустую строку: %+v", b)
+		}
+		if int64(b.ID) == s.Anonymous && b.StudentUsername != "" {
+			t.Errorf("имя NULL не превращено в пустую строку: %+v", b)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		notify []int64
+		want   []int64
+	}{
+		{"все новые записи", nil, []int64{s.Booked, s.NoDir, s.Anonymous}},
+		{"после уведомления", []int64{s.Booked}, []int64{s.NoDir, s.Anonymous}},
+		{"все уведомлены", []int64{s.NoDir, s.Anonymous}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, id := range c.notify {
+				mustExec(t, st.MarkBookingAsNotified(int(id)))
+			}
+			bookings, err := st.GetNewBookings()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bookingIDs(bookings); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+
+	mustExec(t, st.RecordCancellation(Cancellation{SlotID: s.Booked, TeacherID: testTeacher, StudentID: testStudent,
+		StartTime: "2099-01-10T12:00:00Z", EndTime: "2099-01-10T13:00:00Z", CancelledAt: "2099-01-01T00:00:00Z"}))
+	cancellations, err := st.GetStudentCancellations(testTeacher, testStudent)
+	mustExec(t, err)
+	if len(cancellations) != 1 || cancellations[0].SlotID != s.Booked {
+		t.Errorf("отмены ученика: %+v", cancellations)
+	}
+
+	// Запись проходит только на свободный слот
+	if err := st.BookSlot(s.Free, testAnonymous, ""); err != nil {
+		t.Fatalf("BookSlot: %v", err)
+	}
+	slot, err := st.GetScheduleByID(s.Free)
+	mustExec(t, err)
+	if slot.Status != "booked" || slot.StudentID.Int64 != testAnonymous || slot.Direction.Valid {
+		t.Errorf("после BookSlot: %+v", slot)
+	}
+	for _, id := range []int64{s.Free, s.Booked, -1} {
+		if err := st.BookSlot(id, testStudent, "Grammar"); !errors.Is(err, ErrSlotTaken) {
+			t.Errorf("BookSlot(%d): %v, ожидалось ErrSlotTaken", id, err)
+		}
+	}
+	if slot, _ := st.GetScheduleByID(s.Free); slot.StudentID.Int64 != testAnonymous {
+		t.Errorf("занятый слот перезаписан: %+v", slot)
+	}
+
+	mustExec(t, st.DeleteScheduleSlot(s.Free))
+	if _, err := st.GetScheduleByID(s.Free); err == nil {
+		t.Error("слот не удален")
+	}
+}
+
+func TestNotificationQueries(t *testing.T) {
+	st, _ := seedTestStore(t)
+	mustExec(t, st.AddNotification(testTeacher, "первое"))
+	mustExec(t, st.AddNotification(testTeacher, "второе"))
+	mustExec(t, st.AddNotification(testTeacher+1, "чужое"))
+
+	list, err := st.GetTeacherNotifications(testTeacher)
+	mustExec(t, err)
+	if len(list) != 2 {
+		t.Fatalf("уведомления учителя: %+v", list)
+	}
+	n, err := st.GetNotificationByID(list[0].ID)
+	mustExec(t, err)
+	if n.TeacherID != testTeacher || n.IsRead {
+		t.Errorf("уведомление: %+v", n)
+	}
+	if _, err := st.GetNotificationByID(-1); err == nil {
+		t.Error("GetNotificationByID: ожидалась ошибка")
+	}
+
+	cases := []struct {
+		name   string
+		action func() error
+		want   int
+	}{
+		{"новые", func() error { return nil }, 2},
+		{"одно прочитано", func() error { return st.MarkNotificationAsRead(list[0].ID) }, 1},
+		{"очищены", func() error { return st.ClearTeacherNotifications(testTeacher) }, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.action(); err != nil {
+				t.Fatal(err)
+			}
+			count, err := st.CountUnreadNotifications(testTeacher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != c.want {
+				t.Errorf("непрочитанных %d, ожидалось %d", count, c.want)
+			}
+		})
+	}
+	if count, _ := st.CountUnreadNotifications(testTeacher + 1); count != 1 {
+		t.Errorf("очищены уведомления другого учителя: %d", count)
+	}
+}
+
+func TestDialogStateQueries(t *testing.T) {
+	st := newTestStore(t)
+	mustExec(t, st.SetDialogState(DialogState{ChatID: 1, Step: "first", ExpiresAt: "2099-01-01T00:00:00Z"}))
+	mustExec(t, st.SetDialogState(DialogState{ChatID: 1, Step: "second", Data: "x", ExpiresAt: "2099-01-01T00:00:00Z"}))
+	mustExec(t, st.SetDialogState(DialogState{ChatID: 2, Step: "old", ExpiresAt: "2000-01-01T00:00:00Z"}))
+
+	state, err := st.TakeDialogState(1)
+	mustExec(t, err)
+	if state == nil || state.Step != "second" || state.Data != "x" {
+		t.Errorf("шаг диалога не заменен: %+v", state)
+	}
+	if state, _ := st.TakeDialogState(1); state != nil {
+		t.Errorf("шаг диалога не удален: %+v", state)
+	}
+
+	expired, err := st.TakeExpiredDialogStates(mustParseTime(t, "2026-01-01T00:00:00Z"))
+	mustExec(t, err)
+	if len(expired) != 1 || expired[0].ChatID != 2 {
+		t.Errorf("истекшие шаги: %+v", expired)
+	}
+	if state, _ := st.TakeDialogState(2); state != nil {
+		t.Errorf("истекший шаг не удален: %+v", state)
+	}
+}
+
+func TestGroupChatQueries(t *testing.T) {
+	st := newTestStore(t)
+	created, err := st.LinkGroupChat(GroupChat{ChatID: -100, TeacherID: 10, Title: "Группа", Reminders: true, Agenda: true, LinkedAt: "2026-10-01T10:00:00Z"})
+	mustExec(t, err)
+	if !created {
+		t.Error("группа не привязана")
+	}
+	mustExec(t, st.SetGroupEvent(-100, 10, groupEventPinAgenda, true))
+	g, err := st.GetGroupChat(-100, 10)
+	mustExec(t, err)
+	if g == nil || !g.Agenda || !g.PinAgenda || g.Board {
+		t.Errorf("настройки группы: %+v", g)
+	}
+
+	// Закрепление — настройка конкретной группы
+	_, err = st.LinkGroupChat(GroupChat{ChatID: -200, TeacherID: 10, Agenda: true, LinkedAt: "2026-10-02T10:00:00Z"})
+	mustExec(t, err)
+	groups, err := st.GetGroupsForEvent(10, groupEventAgenda)
+	mustExec(t, err)
+	if len(groups) != 2 || !groups[0].PinAgenda || groups[1].PinAgenda {
+		t.Errorf("группы со сводкой: %+v", groups)
+	}
+	if _, err := st.GetGroupsForEvent(10, "title"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного события")
+	}
+}
+
+func TestStudentSummaries(t *testing.T) {
+	st, s := seedTestStore(t)
+	other := testTeacher + 1
+	mustExec(t, st.UpdateScheduleStatus(s.Other, "booked", testAnonymous, ""))
+	mustExec(t, st.RegisterUser(testAnonymous+1, "student", "never_booked"))
+	mustExec(t, st.RegisterUser(testAnonymous+2, "student", "cancelled"))
+	mustExec(t, st.RecordCancellation(Cancellation{SlotID: s.Free, TeacherID: testTeacher, StudentID: testAnonymous + 2,
+		StartTime: "2099-01-10T10:00:00Z", EndTime: "2099-01-10T11:00:00Z", CancelledAt: "2099-01-01T00:00:00Z"}))
+	mustExec(t, st.SetStudentBlocked(testTeacher, testAnonymous, true))
+
+	summaryIDs := func(teacherID int64) []int64 {
+		t.Helper()
+		list, err := st.GetStudentSummaries(teacherID, time.Now())
+		mustExec(t, err)
+		var ids []int64
+		for _, v := range list {
+			ids = append(ids, v.TelegramID)
+		}
+		return ids
+	}
+	cases := []struct {
+		name    string
+		teacher int64
+		want    []int64
+	}{
+		// Без имени сортируется первым; никогда не записывавшийся ученик не виден
+		{"свои ученики и отменившие запись", testTeacher, []int64{testAnonymous, testAnonymous + 2, testStudent}},
+		{"ученики другого учителя", other, []int64{testAnonymous}},
+		{"учитель без учеников", testStudent, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := summaryIDs(c.teacher); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+
+	// Предстоящие и прошедшие занятия считаются относительно now
+	list, err := st.GetStudentSummaries(testTeacher, mustParseTime(t, "2099-01-11T00:00:00Z"))
+	mustExec(t, err)
+	for _, v := range list {
+		if v.TelegramID == testStudent && (v.Upcoming != 1 || v.Past != 1) {
+			t.Errorf("занятия ученика: %+v", v)
+		}
+		if v.TelegramID == testAnonymous+2 && v.Cancellations != 1 {
+			t.Errorf("отмены ученика: %+v", v)
+		}
+	}
+
+	// Блокировка действует только у заблокировавшего учителя
+	for _, c := range []struct {
+		teacher int64
+		want    bool
+	}{{testTeacher, true}, {other, false}} {
+		blocked, err := st.IsStudentBlocked(c.teacher, testAnonymous)
+		mustExec(t, err)
+		list, err := st.GetStudentSummaries(c.teacher, time.Now())
+		mustExec(t, err)
+		if blocked != c.want || list[0].Blocked != c.want {
+			t.Errorf("учитель %d: заблокирован %v, в справочнике %v, ожидалось %v", c.teacher, blocked, list[0].Blocked, c.want)
+		}
+	}
+
+	// Снятие блокировки; заблокированный ученик остается в справочнике и после отмены всех записей
+	mustExec(t, st.UpdateScheduleStatus(s.Anonymous, "free", 0, ""))
+	if got := summaryIDs(testTeacher); len(got) != 3 {
+		t.Errorf("заблокированный ученик пропал из справочника: %v", got)
+	}
+	mustExec(t, st.SetStudentBlocked(testTeacher, testAnonymous, false))
+	if blocked, _ := st.IsStudentBlocked(testTeacher, testAnonymous); blocked {
+		t.Error("блокировка не снята")
+	}
+	if got := summaryIDs(testTeacher); !reflect.DeepEqual(got, []int64{testAnonymous + 2, testStudent}) {
+		t.Errorf("после снятия блокировки: %v", got)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Статусы доставки сообщений пользователю
+const (
+	deliveryActive      = "active"
+	deliveryBlocked     = "blocked"     // Пользователь заблокировал бота
+	deliveryDeactivated = "deactivated" // Аккаунт пользователя удален
+)
+
+// ErrUnreachable — пользователю нельзя отправить сообщение
+var ErrUnreachable = errors.New("пользователь недоступен")
+
+// DeliveryTracker — Messenger, который запоминает пользователей, недоступных
+// по ответу Telegram 403, и больше не отправляет им сообщения до их /start.
+type DeliveryTracker struct {
+	next Messenger
+
+	mu          sync.Mutex
+	unreachable map[int64]string
+}
+
+func NewDeliveryTracker(next Messenger) (*DeliveryTracker, error) {
+	unreachable, err := store.GetUnreachableUsers()
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryTracker{next: next, unreachable: unreachable}, nil
+}
+
+func (t *DeliveryTracker) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return t.deliver(c, t.next.Send)
+}
+
+func (t *DeliveryTracker) SendPriority(c tgbotapi.Chattable, p Priority) (tgbotapi.Message, error) {
+	if ps, ok := t.next.(PrioritySender); ok {
+		return t.deliver(c, func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+			return ps.SendPriority(c, p)
+		})
+	}
+	return t.Send(c)
+}
+
+func (t *DeliveryTracker) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return t.next.AnswerCallbackQuery(config)
+}
+
+func (t *DeliveryTracker) GetFileDirectURL(fileID string) (string, error) {
+	return t.next.GetFileDirectURL(fileID)
+}
+
+func (t *DeliveryTracker) MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error) {
+	return t.next.MakeRequest(endpoint, params)
+}
+
+func (t *DeliveryTracker) Self() tgbotapi.User {
+	return t.next.Self()
+}
+
+func (t *DeliveryTracker) GetChat(config tgbotapi.ChatConfig) (tgbotapi.Chat, error) {
+	return t.next.GetChat(config)
+}
+
+func (t *DeliveryTracker) PinChatMessage(config tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error) {
+	return t.next.PinChatMessage(config)
+}
+
+func (t *DeliveryTracker) GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	return t.next.GetChatMember(config)
+}
+
+func (t *DeliveryTracker) deliver(c tgbotapi.Chattable, send func(tgbotapi.Chattable) (tgbotapi.Message, error)) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+	// Статус отслеживается только для личных чатов
+	if chatID <= 0 {
+		return send(c)
+	}
+
+	t.mu.Lock()
+	status, blocked := t.unreachable[chatID]
+	t.mu.Unlock()
+	if blocked {
+		return tgbotapi.Message{}, fmt.Errorf("%w: %s", ErrUnreachable, status)
+	}
+
+	msg, err := send(c)
+	if err != nil {
+		if status := deliveryStatusFromError(err); status != "" {
+			t.markUnreachable(chatID, status, err)
+			return msg, fmt.Errorf("%w: %v", ErrUnreachable, err)
+		}
+	}
+	return msg, err
+}
+
+func (t *DeliveryTracker) markUnreachable(chatID int64, status string, reason error) {
+	t.mu.Lock()
+	t.unreachable[chatID] = status
+	t.mu.Unlock()
+
+	fmt.Println("Пользователь недоступен:", chatID, "статус:", status, "ошибка:", reason)
+	if err := store.SetDeliveryStatus(chatID, status, reason.Error()); err != nil {
+		fmt.Println("Ошибка сохранения статуса доставки:", err)
+	}
+}
+
+// Пользователь снова доступен (например, прислал /start)
+func (t *DeliveryTracker) MarkReachable(chatID int64) error {
+	t.mu.Lock()
+	_, wasUnreachable := t.unreachable[chatID]
+	delete(t.unreachable, chatID)
+	t.mu.Unlock()
+
+	if !wasUnreachable {
+		return nil
+	}
+	fmt.Println("Пользователь снова доступен:", chatID)
+	return store.SetDeliveryStatus(chatID, deliveryActive, "")
+}
+
+// Снятие отметки о недоступности после /start
+func markReachable(chatID int64) {
+	var err error
+	if t, ok := messenger.(*DeliveryTracker); ok {
+		err = t.MarkReachable(chatID)
+	} else if user, getErr := store.GetUser(chatID); getErr == nil && user.DeliveryStatus != deliveryActive {
+		err = store.SetDeliveryStatus(chatID, deliveryActive, "")
+	}
+	if err != nil {
+		fmt.Println("Ошибка сохранения статуса доставки:", err)
+	}
+}
+
+// Статус доставки по ошибке Telegram; пустая строка — ошибка не связана с доступностью
+func deliveryStatusFromError(err error) string {
+	var apiErr tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	msg := strings.ToLower(apiErr.Message)
+	if !strings.HasPrefix(msg, "forbidden") {
+		return ""
+	}
+	if strings.Contains(msg, "deactivated") {
+		return deliveryDeactivated
+	}
+	return deliveryBlocked
+}
+
+// Описание недоступности для учителя
+func deliveryStatusText(lang, status string) string {
+	switch status {
+	case deliveryBlocked:
+		return T(lang, "delivery.blocked")
+	case deliveryDeactivated:
+		return T(lang, "delivery.deactivated")
+	}
+	return ""
+}
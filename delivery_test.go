@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Ответы Telegram на отправку пользователю, которому нельзя писать
+var (
+	errBotBlocked      = tgbotapi.Error{Message: "Forbidden: bot was blocked by the user"}
+	errUserDeactivated = tgbotapi.Error{Message: "Forbidden: user is deactivated"}
+)
+
+// Отправки, дошедшие до FakeMessenger, по чатам; вызовы с ошибкой тоже считаются
+type sendAttempts struct {
+	mu    sync.Mutex
+	chats map[int64]int
+	err   map[int64]error
+}
+
+func (a *sendAttempts) hook(c tgbotapi.Chattable) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	chatID := chattableChatID(c)
+	a.chats[chatID]++
+	return a.err[chatID]
+}
+
+func (a *sendAttempts) count(chatID int64) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.chats[chatID]
+}
+
+func (a *sendAttempts) fail(chatID int64, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err[chatID] = err
+}
+
+func deliveryStatus(t *testing.T, chatID int64) string {
+	t.Helper()
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.DeliveryStatus
+}
+
+func TestScenarioDeliveryBlocked(t *testing.T) {
+	fm := newScenario(t)
+	attempts := &sendAttempts{chats: make(map[int64]int), err: make(map[int64]error)}
+	fm.SendError = attempts.hook
+	tracker, err := NewDeliveryTracker(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messenger = tracker
+
+	// Ученик заблокировал бота: 403 отмечает его недоступным
+	attempts.fail(scenarioStudent, errBotBlocked)
+	if _, err := messenger.Send(tgbotapi.NewMessage(scenarioStudent, "напоминание")); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("ошибка %v, ожидалась ErrUnreachable", err)
+	}
+	if status := deliveryStatus(t, scenarioStudent); status != deliveryBlocked {
+		t.Errorf("статус в базе %q, ожидался %q", status, deliveryBlocked)
+	}
+
+	// Следующие сообщения в Telegram не уходят, в том числе срочные
+	sendMessage(scenarioStudent, "меню")
+	if _, err := sendUrgent(tgbotapi.NewMessage(scenarioStudent, "срочно")); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("срочное сообщение: %v, ожидалась ErrUnreachable", err)
+	}
+	if n := attempts.count(scenarioStudent); n != 1 {
+		t.Errorf("попыток отправки недоступному ученику %d, ожидалась 1", n)
+	}
+	// Отметка переживает перезапуск: новый трекер читает ее из базы
+	restarted, err := NewDeliveryTracker(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Send(tgbotapi.NewMessage(scenarioStudent, "после перезапуска")); !errors.Is(err, ErrUnreachable) || attempts.count(scenarioStudent) != 1 {
+		t.Errorf("после перезапуска: %v, попыток %d", err, attempts.count(scenarioStudent))
+	}
+	// Учителю сообщения уходят как обычно
+	sendMessage(scenarioTeacher, "меню")
+	if len(fm.SentTo(scenarioTeacher)) != 1 {
+		t.Error("недоступность ученика затронула учителя")
+	}
+
+	// Ученик разблокировал бота и прислал /start: отметка снята
+	attempts.fail(scenarioStudent, nil)
+	sendCommand(scenarioStudent, "/start")
+	if status := deliveryStatus(t, scenarioStudent); status != deliveryActive {
+		t.Errorf("после /start статус %q", status)
+	}
+	if len(fm.SentTo(scenarioStudent)) == 0 {
+		t.Error("после /start ученику ничего не отправлено")
+	}
+	if _, err := messenger.Send(tgbotapi.NewMessage(scenarioStudent, "снова доступен")); err != nil {
+		t.Errorf("отправка после /start: %v", err)
+	}
+}
+
+func TestDeliveryStatusFromError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"бот заблокирован", errBotBlocked, deliveryBlocked},
+		{"аккаунт удален", errUserDeactivated, deliveryDeactivated},
+		{"бот исключен из группы", tgbotapi.Error{Message: "Forbidden: bot was kicked from the group chat"}, deliveryBlocked},
+		{"временная ошибка", tgbotapi.Error{Message: "Too Many Requests: retry after 5"}, ""},
+		{"чат не найден", tgbotapi.Error{Message: "Bad Request: chat not found"}, ""},
+		{"не ошибка Telegram", errors.New("Forbidden"), ""},
+	}
+	for _, c := range cases {
+		if got := deliveryStatusFromError(c.err); got != c.want {
+			t.Errorf("%s: %q, ожидалось %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Диалоги: обработчик просит пользователя ввести текст (askInput), и следующее
+// сообщение чата уходит зарегистрированному шагу. Состояние хранится в базе и
+// переживает перезапуск бота; без ответа шаг истекает, /cancel его отменяет.
+
+const (
+	defaultDialogTimeout = 10 * time.Minute
+	confirmTimeout       = time.Hour // Сколько ждать подтверждения слотов и импорта
+)
+
+// Имена шагов диалога
+const (
+	dialogSlotTime         = "slot_time"         // Время нового слота: data — дата слота
+	dialogProfileName      = "profile_name"      // Анкета ученика: имя
+	dialogProfilePhone     = "profile_phone"     // Анкета ученика: телефон (контакт Telegram)
+	dialogProfileLevel     = "profile_level"     // Анкета ученика: уровень английского
+	dialogProfileGoals     = "profile_goals"     // Анкета ученика: цели
+	dialogProfileDirection = "profile_direction" // Анкета ученика: направление
+	dialogStudentSearch    = "student_search"    // Поиск в справочнике учеников
+	dialogStudentMessage   = "student_message"   // Сообщение ученику: data — ID ученика
+	dialogStudentNote      = "student_note"      // Заметка об ученике: data — ID ученика
+	dialogLessonNote       = "lesson_note"       // Заметка к занятию: data — ID слота
+	dialogHomeworkTask     = "homework_task"     // Текст и файлы задания: data — ID задания
+	dialogHomeworkDue      = "homework_due"      // Срок сдачи задания: data — ID задания
+	dialogHomeworkAnswer   = "homework_answer"   // Ответ ученика: data — ID задания
+	dialogHomeworkComment  = "homework_comment"  // Комментарий к оценке: data — "ID задания:оценка"
+	dialogFeedbackComment  = "feedback_comment"  // Комментарий ученика к оценке занятия: data — ID слота
+	dialogLessonSummary    = "lesson_summary"    // Итог занятия от учителя: data — ID слота
+	dialogAgendaTime       = "agenda_time"       // Свое время плана на день
+	dialogCancelReason     = "cancel_reason"     // Причина отмены записи учеником: data — ID слота
+	dialogSlotTextConfirm  = "slot_text_confirm" // Подтверждение слотов из текста: data — слоты "начало/конец;…"
+	dialogImportConfirm    = "import_confirm"    // Подтверждение импорта CSV: data — file_id файла
+)
+
+// Шаг диалога
+type DialogStep struct {
+	Name    string
+	Role    string        // Требуемая роль; пустая строка — любой зарегистрированный пользователь
+	Timeout time.Duration // Сколько ждать ответа; 0 — defaultDialogTimeout
+	Handle  func(c *DialogContext) error
+}
+
+// DialogContext — ответ пользователя на шаг диалога
+type DialogContext struct {
+	Msg    *tgbotapi.Message
+	ChatID int64
+	User   *User
+	Data   string
+	step   DialogStep
+}
+
+var dialogSteps = make(map[string]DialogStep)
+
+func registerDialogStep(step DialogStep) {
+	if _, exists := dialogSteps[step.Name]; exists {
+		panic("шаг диалога зарегистрирован дважды: " + step.Name)
+	}
+	dialogSteps[step.Name] = step
+}
+
+// Текст ответа без лишних пробелов
+func (c *DialogContext) Text() string {
+	return strings.TrimSpace(c.Msg.Text)
+}
+
+// Повторный запрос того же шага, например после неверного ввода
+func (c *DialogContext) Retry(prompt string) error {
+	return askInput(c.ChatID, c.step.Name, c.Data, prompt)
+}
+
+// Запрос ввода: следующее сообщение чата получит шаг step вместе с data
+func askInput(chatID int64, step, data, prompt string) error {
+	return askInputWithButtons(chatID, step, data, prompt, nil)
+}
+
+// Запрос ввода с кнопками готовых ответов над кнопкой отмены
+func askInputWithButtons(chatID int64, step, data, prompt string, rows [][]tgbotapi.InlineKeyboardButton) error {
+	if err := waitInput(chatID, step, data); err != nil {
+		return err
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(tr(chatID, "btn.cancel"), cbDialogCancel),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return sendMessageWithKeyboard(chatID, prompt, &keyboard)
+}
+
+// Ожидание ответа на шаг step; вопрос отправляет вызывающий
+func waitInput(chatID int64, step, data string) error {
+	s, ok := dialogSteps[step]
+	if !ok {
+		return fmt.Errorf("неизвестный шаг диалога: %s", step)
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = defaultDialogTimeout
+	}
+	return store.SetDialogState(DialogState{
+		ChatID:    chatID,
+		Step:      step,
+		Data:      data,
+		ExpiresAt: time.Now().Add(timeout).UTC().Format(time.RFC3339),
+	})
+}
+
+// Передача сообщения ожидающему шагу диалога. Возвращает false, если чат
+// ничего не ждет и сообщение нужно обработать обычным образом.
+func handleDialogMessage(msg *tgbotapi.Message) bool {
+	chatID := msg.Chat.ID
+	state, err := store.TakeDialogState(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения состояния диалога:", err, "chatID:", chatID)
+		return false
+	}
+	if state == nil {
+		return false
+	}
+
+	if expiresAt, err := time.Parse(time.RFC3339, state.ExpiresAt); err != nil || time.Now().After(expiresAt) {
+		sendDialogExpired(chatID)
+		return true
+	}
+
+	step, ok := dialogSteps[state.Step]
+	if !ok {
+		fmt.Println("Неизвестный шаг диалога:", state.Step, "chatID:", chatID)
+		return false
+	}
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "error.user"))
+		return true
+	}
+	if step.Role != "" && user.Role != step.Role {
+		fmt.Println("Отказано в доступе к шагу диалога:", step.Name, "chatID:", chatID, "роль:", user.Role)
+		return false
+	}
+
+	c := &DialogContext{Msg: msg, ChatID: chatID, User: user, Data: state.Data, step: step}
+	if err := step.Handle(c); err != nil {
+		fmt.Println("Ошибка обработки ответа:", err, "шаг:", step.Name, "chatID:", chatID)
+		sendMessage(chatID, tr(chatID, "error.generic"))
+	}
+	return true
+}
+
+// Данные шага, который ждет нажатия кнопки (подтверждения); false — чат ждет
+// другого шага или время ожидания вышло
+func takeDialogData(chatID int64, step string) (string, bool) {
+	state, err := store.TakeDialogState(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения состояния диалога:", err, "chatID:", chatID)
+		return "", false
+	}
+	if state == nil {
+		return "", false
+	}
+	if state.Step != step {
+		// Кнопка от старого сообщения: текущий шаг остается как был
+		if err := store.SetDialogState(*state); err != nil {
+			fmt.Println("Ошибка сохранения состояния диалога:", err, "chatID:", chatID)
+		}
+		return "", false
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, state.ExpiresAt); err != nil || time.Now().After(expiresAt) {
+		return "", false
+	}
+	return state.Data, true
+}
+
+// Сообщение во время ожидания подтверждения кнопкой: ожидание снимается,
+// а сообщение обрабатывается как обычно
+func handleUnconfirmedMessage(c *DialogContext) error {
+	if c.Msg.Document != nil {
+		handleDocument(c.Msg)
+	} else {
+		handleMessage(c.Msg)
+	}
+	return nil
+}
+
+// Отмена ожидания ввода; false — чат ничего не ждал
+func cancelDialog(chatID int64) bool {
+	state, err := store.TakeDialogState(chatID)
+	if err != nil {
+		fmt.Println("Ошибка отмены диалога:", err, "chatID:", chatID)
+		return false
+	}
+	return state != nil
+}
+
+func showDialogCancelled(chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, tr(chatID, "dialog.cancelled"), &keyboard)
+}
+
+func sendDialogExpired(chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, tr(chatID, "dialog.expired"), &keyboard)
+}
+
+// Фоновая отмена шагов, на которые не ответили вовремя
+func dialogTimeouts(ctx context.Context) {
+	for sleepContext(ctx, 1*time.Minute) {
+		states, err := store.TakeExpiredDialogStates(time.Now())
+		if err != nil {
+			fmt.Println("Ошибка получения истекших диалогов:", err)
+			continue
+		}
+		for _, s := range states {
+			sendDialogExpired(s.ChatID)
+		}
+	}
+}
+
+// Время слота, введенное вручную: "18:30", "18.30" или "18"
+func parseClock(text string) (int, int, error) {
+	text = strings.ReplaceAll(strings.TrimSpace(text), ".", ":")
+	for _, layout := range []string{"15:04", "15"} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.Hour(), t.Minute(), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("неверное время: %s", text)
+}
+
+// Запрос произвольного времени слота на выбранную дату
+func askSlotTime(chatID int64, date time.Time) error {
+	lang := userLang(chatID)
+	return askInput(chatID, dialogSlotTime, date.Format("2006-01-02"), T(lang, "slot.ask_time", formatDate(lang, date)))
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogSlotTime, Role: "teacher", Handle: func(c *DialogContext) error {
+		date, err := time.Parse("2006-01-02", c.Data)
+		if err != nil {
+			return err
+		}
+		hour, minute, err := parseClock(c.Text())
+		if err != nil {
+			return c.Retry(tr(c.ChatID, "slot.bad_time"))
+		}
+		handleAddSlot(c.ChatID, time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.UTC), defaultSlotLength)
+		return nil
+	}})
+	registerDialogStep(DialogStep{Name: dialogCancelReason, Role: "student", Handle: func(c *DialogContext) error {
+		slotID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		if c.Text() == "" {
+			return c.Retry(tr(c.ChatID, "cancel.reason_prompt"))
+		}
+		return saveCancelReason(c.ChatID, slotID, c.Text())
+	}})
+	registerDialogStep(DialogStep{Name: dialogSlotTextConfirm, Role: "teacher", Timeout: confirmTimeout, Handle: handleUnconfirmedMessage})
+	registerDialogStep(DialogStep{Name: dialogImportConfirm, Role: "teacher", Timeout: confirmTimeout, Handle: handleUnconfirmedMessage})
+}
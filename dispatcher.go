@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const maxChatQueue = 20 // Сколько необработанных обновлений одного чата держать в очереди
+
+var dispatcher *Dispatcher
+
+// Dispatcher распределяет обновления по пулу обработчиков: обновления одного
+// чата выполняются строго по очереди, разные чаты — параллельно.
+type Dispatcher struct {
+	handle func(tgbotapi.Update)
+
+	mu     sync.Mutex
+	queues map[int64][]tgbotapi.Update // Ожидающие обновления по чатам
+	active map[int64]bool              // Чат ждет обработчика или уже обрабатывается
+
+	ready   chan int64     // Чаты, готовые к обработке
+	workers sync.WaitGroup // Горутины пула
+	pending sync.WaitGroup // Принятые, но еще не обработанные обновления
+}
+
+// Запуск пула из workers обработчиков
+func NewDispatcher(workers int, handle func(tgbotapi.Update)) *Dispatcher {
+	d := &Dispatcher{
+		handle: handle,
+		queues: make(map[int64][]tgbotapi.Update),
+		active: make(map[int64]bool),
+		ready:  make(chan int64, workers),
+	}
+	for i := 0; i < workers; i++ {
+		d.workers.Add(1)
+		go d.worker()
+	}
+	return d
+}
+
+// Постановка обновления в очередь его чата. Блокируется, если все
+// обработчики заняты, чтобы не копить обновления в памяти без ограничений.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) {
+	chatID := updateChatID(update)
+
+	d.mu.Lock()
+	if len(d.queues[chatID]) >= maxChatQueue {
+		d.mu.Unlock()
+		fmt.Println("Очередь чата переполнена, обновление пропущено. chatID:", chatID)
+		return
+	}
+	d.pending.Add(1)
+	d.queues[chatID] = append(d.queues[chatID], update)
+	start := !d.active[chatID]
+	d.active[chatID] = true
+	d.mu.Unlock()
+
+	if start {
+		d.ready <- chatID
+	}
+}
+
+// Обработчик пула: забирает чат и выполняет все его обновления по порядку
+func (d *Dispatcher) worker() {
+	defer d.workers.Done()
+	for chatID := range d.ready {
+		for {
+			d.mu.Lock()
+			queue := d.queues[chatID]
+			if len(queue) == 0 {
+				delete(d.queues, chatID)
+				delete(d.active, chatID)
+				d.mu.Unlock()
+				break
+			}
+			update := queue[0]
+			d.queues[chatID] = queue[1:]
+			d.mu.Unlock()
+
+			d.process(update)
+		}
+	}
+}
+
+func (d *Dispatcher) process(update tgbotapi.Update) {
+	defer d.pending.Done()
+	defer recoverPanic(fmt.Sprintf("обработка обновления %d", update.UpdateID))
+	d.handle(update)
+}
+
+// Остановка: дожидаемся принятых обновлений (не дольше timeout) и завершаем пул
+func (d *Dispatcher) Stop(timeout time.Duration) bool {
+	done := waitTimeout(&d.pending, timeout)
+	close(d.ready)
+	if done {
+		d.workers.Wait()
+	}
+	return done
+}
+
+// Чат, к которому относится обновление
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return int64(update.CallbackQuery.From.ID)
+	}
+	return 0
+}
+
+// Перехват паники в обработчике: ошибка записывается в лог, процесс продолжает работу
+func recoverPanic(where string) {
+	if r := recover(); r != nil {
+		fmt.Printf("Паника (%s): %v\n%s", where, r, debug.Stack())
+	}
+}
+
+// Последние сообщения бота по чатам; используется из разных горутин
+type messageIDs struct {
+	mu  sync.Mutex
+	ids map[int64]int
+}
+
+func (m *messageIDs) Get(chatID int64) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.ids[chatID]
+	return id, ok
+}
+
+func (m *messageIDs) Set(chatID int64, messageID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ids == nil {
+		m.ids = make(map[int64]int)
+	}
+	m.ids[chatID] = messageID
+}
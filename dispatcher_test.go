@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Обновление-сообщение из чата chatID
+func chatUpdate(chatID int64, updateID int) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: updateID, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}}
+}
+
+// Ожидание сигнала не дольше секунды
+func waitSignal(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("не дождались:", what)
+	}
+}
+
+func TestDispatcherChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+	running := make(map[int64]int)
+	d := NewDispatcher(4, func(u tgbotapi.Update) {
+		chatID := u.Message.Chat.ID
+		mu.Lock()
+		running[chatID]++
+		if running[chatID] > 1 {
+			t.Errorf("чат %d: обновления выполняются одновременно", chatID)
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running[chatID]--
+		handled[chatID] = append(handled[chatID], u.UpdateID)
+		mu.Unlock()
+	})
+
+	const perChat = 10
+	for i := 0; i < perChat; i++ {
+		for chatID := int64(1); chatID <= 3; chatID++ {
+			d.Dispatch(chatUpdate(chatID, i))
+		}
+	}
+	if !d.Stop(5 * time.Second) {
+		t.Fatal("Stop: обновления не обработаны")
+	}
+
+	for chatID := int64(1); chatID <= 3; chatID++ {
+		got := handled[chatID]
+		if len(got) != perChat {
+			t.Fatalf("чат %d: обработано %v", chatID, got)
+		}
+		for i, id := range got {
+			if id != i {
+				t.Errorf("чат %d: порядок нарушен: %v", chatID, got)
+				break
+			}
+		}
+	}
+}
+
+func TestDispatcherParallelChats(t *testing.T) {
+	release := make(chan struct{})
+	slowStarted := make(chan struct{})
+	fastDone := make(chan struct{})
+	d := NewDispatcher(2, func(u tgbotapi.Update) {
+		switch u.Message.Chat.ID {
+		case 1:
+			close(slowStarted)
+			<-release
+		case 2:
+			close(fastDone)
+		}
+	})
+
+	// Долгая обработка в одном чате не задерживает другой чат
+	d.Dispatch(chatUpdate(1, 1))
+	waitSignal(t, slowStarted, "начало обработки чата 1")
+	d.Dispatch(chatUpdate(2, 2))
+	waitSignal(t, fastDone, "обработка чата 2, пока занят чат 1")
+
+	close(release)
+	if !d.Stop(time.Second) {
+		t.Error("Stop: обработчики не завершились")
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var handled []int
+	d := NewDispatcher(1, func(u tgbotapi.Update) {
+		if u.UpdateID == 0 {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		handled = append(handled, u.UpdateID)
+		mu.Unlock()
+	})
+
+	d.Dispatch(chatUpdate(1, 0))
+	waitSignal(t, started, "начало обработки")
+
+	// Первое обновление уже у обработчика: очередь чата вмещает еще maxChatQueue,
+	// следующее пропускается, а другой чат принимается как обычно
+	for i := 1; i <= maxChatQueue+1; i++ {
+		d.Dispatch(chatUpdate(1, i))
+	}
+	d.Dispatch(chatUpdate(2, 100))
+	close(release)
+	if !d.Stop(time.Second) {
+		t.Fatal("Stop: обновления не обработаны")
+	}
+
+	if len(handled) != maxChatQueue+2 {
+		t.Fatalf("обработано %d обновлений, ожидалось %d: %v", len(handled), maxChatQueue+2, handled)
+	}
+	for _, id := range handled {
+		if id == maxChatQueue+1 {
+			t.Errorf("обновление сверх очереди обработано: %v", handled)
+		}
+	}
+}
+
+func TestDispatcherRecoversPanic(t *testing.T) {
+	var handled []int
+	d := NewDispatcher(1, func(u tgbotapi.Update) {
+		if u.UpdateID == 1 {
+			panic("сбой обработчика")
+		}
+		handled = append(handled, u.UpdateID)
+	})
+	d.Dispatch(chatUpdate(1, 1))
+	d.Dispatch(chatUpdate(1, 2))
+	if !d.Stop(time.Second) {
+		t.Fatal("Stop: после паники обработчик не завершился")
+	}
+	if len(handled) != 1 || handled[0] != 2 {
+		t.Errorf("после паники обработано %v, ожидалось [2]", handled)
+	}
+}
+
+func TestDispatcherStop(t *testing.T) {
+	// Без обновлений пул останавливается сразу
+	if !NewDispatcher(2, func(tgbotapi.Update) {}).Stop(time.Second) {
+		t.Error("Stop пустого пула")
+	}
+
+	// Зависший обработчик: Stop не ждет дольше timeout
+	release := make(chan struct{})
+	started := make(chan struct{})
+	d := NewDispatcher(1, func(tgbotapi.Update) {
+		close(started)
+		<-release
+	})
+	d.Dispatch(chatUpdate(1, 1))
+	waitSignal(t, started, "начало обработки")
+	begin := time.Now()
+	if d.Stop(50 * time.Millisecond) {
+		t.Error("Stop сообщил о завершении, пока обработчик занят")
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Stop ждал %v", elapsed)
+	}
+	close(release)
+}
+
+func TestRunWorkerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rootCtx = ctx
+	defer func() { rootCtx = context.Background() }()
+
+	// Фоновая задача спит между итерациями и завершается при отмене контекста
+	started := make(chan struct{})
+	runWorker(func(ctx context.Context) {
+		close(started)
+		for sleepContext(ctx, time.Hour) {
+		}
+	})
+	waitSignal(t, started, "запуск фоновой задачи")
+	cancel()
+	if !waitTimeout(&workersWG, time.Second) {
+		t.Error("фоновая задача не завершилась после отмены контекста")
+	}
+}
+
+func TestUpdateChatID(t *testing.T) {
+	chat := &tgbotapi.Chat{ID: -100}
+	cases := []struct {
+		name   string
+		update tgbotapi.Update
+		want   int64
+	}{
+		{"сообщение", tgbotapi.Update{Message: &tgbotapi.Message{Chat: chat}}, -100},
+		{"кнопка под сообщением", tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 5}, Message: &tgbotapi.Message{Chat: chat}}}, -100},
+		{"inline-кнопка без сообщения", tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 5}}}, 5},
+		{"другое обновление", tgbotapi.Update{UpdateID: 1}, 0},
+	}
+	for _, c := range cases {
+		if got := updateChatID(c.update); got != c.want {
+			t.Errorf("%s: %d, ожидалось %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	externalSyncInterval = 15 * time.Minute
+	externalSyncHorizon  = 90 * 24 * time.Hour // Насколько вперед загружается занятость
+	maxICSSize           = 5 << 20             // Ограничение размера ICS-файла
+	maxRecurrences       = 1000                // Ограничение числа повторений одного события
+)
+
+var externalHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Периодическая загрузка занятости из внешних календарей
+func externalCalendarSync(ctx context.Context) {
+	for {
+		calendars, err := store.GetExternalCalendars(0)
+		if err != nil {
+			fmt.Println("Ошибка получения внешних календарей:", err)
+		}
+		for _, c := range calendars {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := syncExternalCalendar(ctx, c); err != nil {
+				fmt.Println("Ошибка синхронизации календаря", c.ID, ":", err)
+			}
+		}
+		if !sleepContext(ctx, externalSyncInterval) {
+			return
+		}
+	}
+}
+
+// Загрузка одного календаря и сохранение его занятости
+func syncExternalCalendar(ctx context.Context, c ExternalCalendar) error {
+	now := time.Now()
+	intervals, err := fetchBusyIntervals(ctx, externalHTTPClient, c.URL, now.Add(-24*time.Hour), now.Add(externalSyncHorizon), config.Location())
+	if err != nil {
+		if setErr := store.SetExternalCalendarError(c.ID, err); setErr != nil {
+			fmt.Println("Ошибка сохранения статуса календаря:", setErr)
+		}
+		return err
+	}
+	return store.ReplaceExternalBusy(c, intervals)
+}
+
+// Загрузка ICS по ссылке и выделение занятых интервалов в окне [from, to)
+func fetchBusyIntervals(ctx context.Context, client *http.Client, rawURL string, from, to time.Time, loc *time.Location) ([]BusyInterval, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("неверная ссылка на календарь: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки календаря: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("календарь вернул статус %d", resp.StatusCode)
+	}
+
+	return parseICSBusy(io.LimitReader(resp.Body, maxICSSize), from, to, loc)
+}
+
+// Разбор ICS: занятые интервалы событий в окне [from, to).
+// Время приводится к настенному времени расписания (loc), как хранятся слоты.
+func parseICSBusy(r io.Reader, from, to time.Time, loc *time.Location) ([]BusyInterval, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения календаря: %v", err)
+	}
+
+	from, to = toScheduleTime(from, loc), toScheduleTime(to, loc)
+
+	var (
+		events  []icsEvent
+		event   icsEvent
+		inEvent bool
+		sawCal  bool
+	)
+	for _, line := range lines {
+		prop, ok := parseICSProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.Name == "BEGIN" && prop.Value == "VCALENDAR":
+			sawCal = true
+		case prop.Name == "BEGIN" && prop.Value == "VEVENT":
+			inEvent = true
+			event = icsEvent{Props: map[string]icsProperty{}}
+		case prop.Name == "END" && prop.Value == "VEVENT":
+			inEvent = false
+			events = append(events, event)
+		case inEvent && prop.Name == "EXDATE":
+			event.ExDates = append(event.ExDates, prop)
+		case inEvent:
+			if _, exists := event.Props[prop.Name]; !exists {
+				event.Props[prop.Name] = prop
+			}
+		}
+	}
+	if !sawCal {
+		return nil, fmt.Errorf("файл не является календарем iCalendar")
+	}
+
+	// Измененные и отмененные повторения (RECURRENCE-ID) заменяют исходные
+	overridden := map[string][]time.Time{}
+	for _, e := range events {
+		if rid, ok := e.Props["RECURRENCE-ID"]; ok {
+			if t, _, err := parseICSTime(rid, loc); err == nil {
+				uid := e.Props["UID"].Value
+				overridden[uid] = append(overridden[uid], t)
+			}
+		}
+	}
+
+	var intervals []BusyInterval
+	for _, e := range events {
+		var replaced []time.Time
+		if _, ok := e.Props["RECURRENCE-ID"]; !ok {
+			replaced = overridden[e.Props["UID"].Value]
+		}
+		intervals = append(intervals, eventBusyIntervals(e, replaced, from, to, loc)...)
+	}
+	return intervals, nil
+}
+
+// Событие ICS: первое значение каждого свойства и все исключенные даты (EXDATE)
+type icsEvent struct {
+	Props   map[string]icsProperty
+	ExDates []icsProperty
+}
+
+// Свойство ICS: имя, параметры и значение
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Склейка перенесенных строк (RFC 5545, раздел 3.1)
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxICSSize)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseICSProperty(line string) (icsProperty, bool) {
+	colon := strings.Index(line, ":")
+	if colon <= 0 {
+		return icsProperty{}, false
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+
+	prop := icsProperty{Name: strings.ToUpper(parts[0]), Params: map[string]string{}, Value: value}
+	for _, p := range parts[1:] {
+		if eq := strings.Index(p, "="); eq > 0 {
+			prop.Params[strings.ToUpper(p[:eq])] = strings.Trim(p[eq+1:], `"`)
+		}
+	}
+	return prop, true
+}
+
+// Интервалы занятости одного события с учетом простых повторений (RRULE) и
+// исключенных дат (EXDATE); replaced — начала повторений, измененных отдельными
+// событиями с RECURRENCE-ID
+func eventBusyIntervals(ev icsEvent, replaced []time.Time, from, to time.Time, loc *time.Location) []BusyInterval {
+	event := ev.Props
+	if status, ok := event["STATUS"]; ok && strings.EqualFold(status.Value, "CANCELLED") {
+		return nil
+	}
+	if transp, ok := event["TRANSP"]; ok && strings.EqualFold(transp.Value, "TRANSPARENT") {
+		return nil
+	}
+
+	startProp, ok := event["DTSTART"]
+	if !ok {
+		return nil
+	}
+	start, allDay, err := parseICSTime(startProp, loc)
+	if err != nil {
+		return nil
+	}
+
+	var end time.Time
+	if endProp, ok := event["DTEND"]; ok {
+		end, _, err = parseICSTime(endProp, loc)
+		if err != nil {
+			return nil
+		}
+	} else if durProp, ok := event["DURATION"]; ok {
+		d, err := parseICSDuration(durProp.Value)
+		if err != nil {
+			return nil
+		}
+		end = start.Add(d)
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	} else {
+		end = start
+	}
+	if !end.After(start) {
+		return nil
+	}
+	length := end.Sub(start)
+
+	starts := []time.Time{start}
+	if rrule, ok := event["RRULE"]; ok {
+		starts = expandRRule(rrule.Value, start, from.Add(-length), to, loc)
+	}
+
+	excluded := icsExcludedStarts(ev.ExDates, replaced, loc)
+
+	var intervals []BusyInterval
+	for _, s := range starts {
+		e := s.Add(length)
+		if excluded.contains(s) {
+			continue
+		}
+		if s.Before(to) && e.After(from) {
+			intervals = append(intervals, BusyInterval{Start: s, End: e})
+		}
+	}
+	return intervals
+}
+
+// Исключенные повторения: точное время или целый день (EXDATE;VALUE=DATE)
+type icsExclusions struct {
+	times map[int64]bool // Unix-время начала
+	days  map[string]bool
+}
+
+func icsExcludedStarts(exdates []icsProperty, replaced []time.Time, loc *time.Location) icsExclusions {
+	ex := icsExclusions{times: map[int64]bool{}, days: map[string]bool{}}
+	for _, t := range replaced {
+		ex.times[t.Unix()] = true
+	}
+	for _, prop := range exdates {
+		for _, value := range strings.Split(prop.Value, ",") {
+			t, allDay, err := parseICSTime(icsProperty{Name: prop.Name, Params: prop.Params, Value: value}, loc)
+			if err != nil {
+				continue
+			}
+			if allDay {
+				ex.days[t.Format("2006-01-02")] = true
+			} else {
+				ex.times[t.Unix()] = true
+			}
+		}
+	}
+	return ex
+}
+
+func (ex icsExclusions) contains(start time.Time) bool {
+	return ex.times[start.Unix()] || ex.days[start.Format("2006-01-02")]
+}
+
+// Разбор DATE-TIME или DATE в настенное время расписания
+func parseICSTime(prop icsProperty, loc *time.Location) (time.Time, bool, error) {
+	value := prop.Value
+	if prop.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return toScheduleTime(t, loc), false, nil
+	}
+
+	eventLoc := loc
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			eventLoc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, eventLoc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return toScheduleTime(t, loc), false, nil
+}
+
+// Слоты хранятся как настенное время часового пояса расписания с пометкой UTC
+func toScheduleTime(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+}
+
+// Обратное преобразование: настенное время слота — реальный момент в часовом поясе loc
+func fromScheduleTime(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// Разбор длительности вида P1DT2H30M
+func parseICSDuration(value string) (time.Duration, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if v == value || v == "" {
+		return 0, fmt.Errorf("неверная длительность: %s", value)
+	}
+
+	var total time.Duration
+	inTime := false
+	num := ""
+	for _, r := range v {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("неверная длительность: %s", value)
+			}
+			num = ""
+			switch {
+			case r == 'W':
+				total += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				total += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				total += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				total += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				total += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("неверная длительность: %s", value)
+			}
+		}
+	}
+	return total, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Развертывание повторений: поддерживаются FREQ=DAILY и FREQ=WEEKLY
+// с INTERVAL, COUNT, UNTIL и BYDAY. Прочие правила дают одно событие.
+func expandRRule(rule string, start, from, to time.Time, loc *time.Location) []time.Time {
+	params := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		if eq := strings.Index(part, "="); eq > 0 {
+			params[strings.ToUpper(part[:eq])] = strings.ToUpper(part[eq+1:])
+		}
+	}
+
+	freq := params["FREQ"]
+	if freq != "DAILY" && freq != "WEEKLY" {
+		return []time.Time{start}
+	}
+
+	interval := 1
+	if v, err := strconv.Atoi(params["INTERVAL"]); err == nil && v > 0 {
+		interval = v
+	}
+	count := -1
+	if v, err := strconv.Atoi(params["COUNT"]); err == nil && v > 0 {
+		count = v
+	}
+	until := to
+	if v := params["UNTIL"]; v != "" {
+		if u, allDay, err := parseICSTime(icsProperty{Value: v, Params: map[string]string{}}, loc); err == nil {
+			if allDay {
+				u = u.Add(24*time.Hour - time.Second)
+			}
+			if u.Before(until) {
+				until = u
+			}
+		}
+	}
+
+	days := map[time.Weekday]bool{}
+	if freq == "WEEKLY" {
+		for _, d := range strings.Split(params["BYDAY"], ",") {
+			if wd, ok := icsWeekdays[d]; ok {
+				days[wd] = true
+			}
+		}
+		if len(days) == 0 {
+			days[start.Weekday()] = true
+		}
+	}
+
+	var starts []time.Time
+	for day, n := start, 0; !day.After(until) && len(starts) < maxRecurrences; day = day.AddDate(0, 0, 1) {
+		diff := int(day.Sub(start).Hours() / 24)
+		switch freq {
+		case "DAILY":
+			if diff%interval != 0 {
+				continue
+			}
+		case "WEEKLY":
+			weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+			week := int(day.Sub(weekStart).Hours()/24) / 7
+			if week%interval != 0 || !days[day.Weekday()] {
+				continue
+			}
+		}
+		if count >= 0 && n >= count {
+			break
+		}
+		n++
+		if day.After(from) {
+			starts = append(starts, day)
+		}
+	}
+	return starts
+}
+
+// Команда /add_calendar <url>: подключение внешнего календаря
+func handleAddExternalCalendar(chatID int64, arg string) {
+	if err := authorizeTeacher(chatID, "подключение календаря"); err != nil {
+		sendMessage(chatID, tr(chatID, "teacher.only"))
+		return
+	}
+
+	rawURL := strings.TrimSpace(arg)
+	if strings.HasPrefix(rawURL, "webcal://") {
+		rawURL = "https://" + strings.TrimPrefix(rawURL, "webcal://")
+	}
+	u, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		sendMessage(chatID, tr(chatID, "ext.usage"))
+		return
+	}
+
+	id, err := store.AddExternalCalendar(chatID, rawURL)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "ext.add_error"))
+		return
+	}
+
+	calendar := ExternalCalendar{ID: id, TeacherID: chatID, URL: rawURL}
+	if err := syncExternalCalendar(rootCtx, calendar); err != nil {
+		fmt.Println("Ошибка первой синхронизации календаря:", err)
+		sendMessage(chatID, tr(chatID, "ext.sync_failed"))
+		return
+	}
+	handleExternalCalendars(chatID)
+}
+
+// Список подключенных внешних календарей с кнопками удаления
+func handleExternalCalendars(chatID int64) {
+	calendars, err := store.GetExternalCalendars(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "ext.list_error"))
+		return
+	}
+
+	lang := userLang(chatID)
+	var builder strings.Builder
+	builder.WriteString(T(lang, "ext.title"))
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if len(calendars) == 0 {
+		builder.WriteString(T(lang, "ext.empty"))
+	}
+	for i, c := range calendars {
+		status := T(lang, "ext.pending")
+		if c.LastError.Valid {
+			status = "⚠️ " + c.LastError.String
+		} else if c.LastSyncedAt.Valid {
+			status = T(lang, "ext.synced", formatTime(lang, c.LastSyncedAt.String))
+		}
+		builder.WriteString(fmt.Sprintf("\n%d. %s\n%s\n", i+1, c.URL, status))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "ext.delete", i+1), cbExtCalendarDelete, cbID(c.ID)),
+		))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendPlainMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Отключение внешнего календаря
+func handleDeleteExternalCalendar(chatID int64, calendarID int64) {
+	if err := authorizeTeacher(chatID, "удаление календаря"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	if err := store.DeleteExternalCalendar(chatID, calendarID); err != nil {
+		sendMessage(chatID, tr(chatID, "ext.delete_error"))
+		return
+	}
+	handleExternalCalendars(chatID)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Календарь с разовым событием, еженедельным повтором, исключенной датой,
+// перенесенным и отмененным повторениями, а также событиями, не занимающими время
+const icsFixture = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:single\r\n" +
+	"DTSTART:20261021T090000Z\r\n" +
+	"DTEND:20261021T100000Z\r\n" +
+	"SUMMARY:Разовая встреча с длинным названием\\, которое переносится на\r\n" +
+	"  следующую строку\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly\r\n" +
+	"DTSTART;TZID=Europe/Moscow:20261019T180000\r\n" +
+	"DTEND;TZID=Europe/Moscow:20261019T190000\r\n" +
+	"RRULE:FREQ=WEEKLY;COUNT=4\r\n" +
+	"EXDATE;TZID=Europe/Moscow:20261026T180000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Moscow:20261102T180000\r\n" +
+	"DTSTART;TZID=Europe/Moscow:20261103T100000\r\n" +
+	"DTEND;TZID=Europe/Moscow:20261103T110000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly\r\n" +
+	"RECURRENCE-ID;TZID=Europe/Moscow:20261109T180000\r\n" +
+	"DTSTART;TZID=Europe/Moscow:20261109T180000\r\n" +
+	"DTEND;TZID=Europe/Moscow:20261109T190000\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:free\r\n" +
+	"DTSTART:20261022T090000Z\r\n" +
+	"DTEND:20261022T100000Z\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled\r\n" +
+	"DTSTART:20261023T090000Z\r\n" +
+	"DTEND:20261023T100000Z\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestFetchBusyIntervals(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/calendar.ics":
+			w.Header().Set("Content-Type", "text/calendar")
+			w.Write([]byte(icsFixture))
+		case "/page.html":
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	intervals, err := fetchBusyIntervals(context.Background(), srv.Client(), srv.URL+"/calendar.ics", from, to, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Время — настенное время Москвы с пометкой UTC, как у слотов
+	want := []BusyInterval{
+		{Start: time.Date(2026, 10, 21, 12, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 21, 13, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2026, 11, 3, 10, 0, 0, 0, time.UTC), End: time.Date(2026, 11, 3, 11, 0, 0, 0, time.UTC)},
+	}
+	if len(intervals) != len(want) {
+		t.Fatalf("получено %d интервалов, ожидалось %d: %v", len(intervals), len(want), intervals)
+	}
+	for i := range want {
+		if !intervals[i].Start.Equal(want[i].Start) || !intervals[i].End.Equal(want[i].End) {
+			t.Errorf("интервал %d: %v–%v, ожидалось %v–%v", i, intervals[i].Start, intervals[i].End, want[i].Start, want[i].End)
+		}
+	}
+
+	for _, path := range []string{"/missing.ics", "/page.html"} {
+		if _, err := fetchBusyIntervals(context.Background(), srv.Client(), srv.URL+path, from, to, loc); err == nil {
+			t.Errorf("%s: ожидалась ошибка", path)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Отзывы после занятий: когда занятие заканчивается, ученик получает опрос
+// (1–5 звезд и необязательный комментарий), а учитель — предложение написать
+// итог занятия. Результаты привязаны к строке schedules и собираются
+// в статистике учителя.
+
+const (
+	feedbackWindow   = 24 * time.Hour // Опрашиваем только по занятиям, закончившимся за последние сутки
+	feedbackTimeout  = time.Hour      // Сколько ждать комментария или итога
+	maxSummaryLength = 2000
+)
+
+// Опрос ученика и учителя по закончившемуся занятию
+func sendLessonSurvey(b BookingNotification) {
+	created, err := store.CreateLessonFeedback(LessonFeedback{
+		SlotID:    int64(b.ID),
+		TeacherID: b.TeacherID,
+		StudentID: b.StudentID,
+		Direction: b.Direction,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Println("Ошибка создания опроса:", err)
+		return
+	}
+	if !created {
+		return
+	}
+	id := cbID(int64(b.ID))
+
+	studentLang := userLang(b.StudentID)
+	var stars []tgbotapi.InlineKeyboardButton
+	for rating := 1; rating <= maxGrade; rating++ {
+		stars = append(stars, callbackButton(strconv.Itoa(rating)+"⭐", cbFeedbackRate, id, strconv.Itoa(rating)))
+	}
+	studentKeyboard := tgbotapi.NewInlineKeyboardMarkup(stars)
+	studentMsg := htmlf(T(studentLang, "feedback.survey"), formatTime(studentLang, b.StartTime), b.Direction)
+	sendMessageWithKeyboard(b.StudentID, studentMsg, &studentKeyboard)
+
+	teacherLang := userLang(b.TeacherID)
+	teacherKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(teacherLang, "feedback.write_summary"), cbLessonSummary, id),
+			callbackButton(T(teacherLang, "homework.lesson_button"), cbLessonHomework, id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(teacherLang, "btn.menu"), cbMenu),
+		),
+	)
+	teacherMsg := htmlf(T(teacherLang, "feedback.summary_offer"), formatTime(teacherLang, b.StartTime), studentName(b.StudentID))
+	sendMessageWithKeyboard(b.TeacherID, teacherMsg, &teacherKeyboard)
+}
+
+// Опрос ученика по занятию
+func studentFeedback(chatID, slotID int64, action string) (*LessonFeedback, error) {
+	f, err := store.GetLessonFeedback(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || f.StudentID != chatID {
+		return nil, deny(chatID, action, fmt.Sprintf("опрос по слоту %d не для ученика", slotID))
+	}
+	return f, nil
+}
+
+// Опрос по занятию учителя
+func teacherFeedback(chatID, slotID int64, action string) (*LessonFeedback, error) {
+	if _, err := authorizeSlotOwner(chatID, slotID, action); err != nil {
+		return nil, err
+	}
+	f, err := store.GetLessonFeedback(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || f.TeacherID != chatID {
+		return nil, deny(chatID, action, fmt.Sprintf("по слоту %d не было опроса", slotID))
+	}
+	return f, nil
+}
+
+// Оценка ученика: сохраняем, сообщаем учителю и предлагаем комментарий
+func rateLesson(chatID, slotID int64, rating int) error {
+	f, err := studentFeedback(chatID, slotID, "оценка занятия")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	if rating < 1 || rating > maxGrade {
+		return fmt.Errorf("неверная оценка: %d", rating)
+	}
+	if err := store.SetLessonRating(slotID, rating); err != nil {
+		return err
+	}
+
+	slot, err := store.GetScheduleByID(slotID)
+	if err == nil {
+		teacherLang := userLang(f.TeacherID)
+		sendTemporaryNotification(f.TeacherID, T(teacherLang, "feedback.notify_rated",
+			studentName(chatID), formatTime(teacherLang, slot.StartTime), formatGrade(int64(rating))))
+	}
+
+	lang := userLang(chatID)
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "feedback.skip_comment"), cbFeedbackSkip, cbID(slotID)),
+	)}
+	return askInputWithButtons(chatID, dialogFeedbackComment, strconv.FormatInt(slotID, 10), T(lang, "feedback.comment_prompt", formatGrade(int64(rating))), rows)
+}
+
+// Благодарность за отзыв
+func showFeedbackThanks(chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, tr(chatID, "feedback.thanks"), &keyboard)
+}
+
+// Запрос итога занятия у учителя
+func askLessonSummary(chatID, slotID int64) error {
+	f, err := teacherFeedback(chatID, slotID, "итог занятия")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	lang := userLang(chatID)
+	prompt := htmlf(T(lang, "feedback.summary_prompt"), studentName(f.StudentID))
+	if f.Summary != "" {
+		prompt += htmlf(T(lang, "feedback.summary_current"), f.Summary)
+	}
+	return askInput(chatID, dialogLessonSummary, strconv.FormatInt(slotID, 10), prompt)
+}
+
+// Оценка, комментарий и итог для карточки занятия
+func formatLessonFeedback(lang string, slotID int64) string {
+	f, err := store.GetLessonFeedback(slotID)
+	if err != nil {
+		fmt.Println("Ошибка получения опроса:", err)
+		return ""
+	}
+	if f == nil {
+		return ""
+	}
+	var builder strings.Builder
+	if f.Rating.Valid {
+		builder.WriteString(T(lang, "feedback.rating", formatGrade(f.Rating.Int64)))
+		if f.Comment != "" {
+			builder.WriteString(htmlf("💬 %s\n", f.Comment))
+		}
+	} else {
+		builder.WriteString(T(lang, "feedback.no_rating"))
+	}
+	if f.Summary != "" {
+		builder.WriteString(htmlf(T(lang, "feedback.summary"), f.Summary))
+	}
+	return builder.String()
+}
+
+// Статистика учителя: слоты, отмены и оценки по направлениям
+func showTeacherStats(chatID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "статистика"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	stats, err := store.GetTeacherStats(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения статистики:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	ratings, err := store.GetRatingStats(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения статистики:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "stats.title"))
+	builder.WriteString(T(lang, "stats.slots", stats.TotalSlots, stats.BookedSlots, stats.FreeSlots))
+	builder.WriteString(T(lang, "stats.cancellations", stats.Cancellations))
+	if stats.Rated == 0 {
+		builder.WriteString(T(lang, "stats.no_ratings", stats.Surveyed))
+	} else {
+		builder.WriteString(T(lang, "stats.rating", stats.AverageRating, stats.Rated, stats.Surveyed))
+		builder.WriteString(T(lang, "stats.by_direction"))
+		for _, r := range ratings {
+			direction := r.Direction
+			if direction == "" {
+				direction = "—"
+			}
+			builder.WriteString(htmlf(T(lang, "stats.direction"), direction, fmt.Sprintf("%.1f", r.Average), r.Count))
+		}
+	}
+	builder.WriteString(T(lang, "stats.summaries", stats.Summaries, stats.Surveyed))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogFeedbackComment, Role: "student", Timeout: feedbackTimeout, Handle: func(c *DialogContext) error {
+		slotID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		if _, err := studentFeedback(c.ChatID, slotID, "комментарий к занятию"); err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		comment := c.Text()
+		if comment == "" || utf8.RuneCountInString(comment) > maxNoteLength {
+			return c.Retry(tr(c.ChatID, "feedback.comment_bad"))
+		}
+		if err := store.SetLessonComment(slotID, comment); err != nil {
+			return err
+		}
+		showFeedbackThanks(c.ChatID)
+		return nil
+	}})
+	registerDialogStep(DialogStep{Name: dialogLessonSummary, Role: "teacher", Timeout: feedbackTimeout, Handle: func(c *DialogContext) error {
+		slotID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		if _, err := teacherFeedback(c.ChatID, slotID, "итог занятия"); err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		summary := c.Text()
+		if summary == "" || utf8.RuneCountInString(summary) > maxSummaryLength {
+			return c.Retry(tr(c.ChatID, "feedback.summary_bad"))
+		}
+		if err := store.SetLessonSummary(slotID, summary); err != nil {
+			return err
+		}
+		showLesson(c.ChatID, slotID)
+		return nil
+	}})
+}
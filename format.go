@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Все сообщения с разметкой отправляются в HTML: экранировать нужно только
+// &, < и >, поэтому подчеркивания и звездочки в именах ничего не ломают.
+const parseMode = tgbotapi.ModeHTML
+
+// HTML — готовая разметка, которую htmlf вставляет без экранирования
+type HTML string
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Экранирование произвольного текста для parse_mode=HTML
+func escapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// Жирный текст
+func bold(s string) HTML {
+	return HTML("<b>" + escapeHTML(s) + "</b>")
+}
+
+// Моноширинный текст
+func code(s string) HTML {
+	return HTML("<code>" + escapeHTML(s) + "</code>")
+}
+
+// Аналог fmt.Sprintf для HTML: шаблон считается разметкой, а строковые
+// аргументы (кроме HTML) экранируются
+func htmlf(format string, args ...interface{}) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case HTML:
+			escaped[i] = string(v)
+		case string:
+			escaped[i] = escapeHTML(v)
+		case fmt.Stringer:
+			escaped[i] = escapeHTML(v.String())
+		case error:
+			escaped[i] = escapeHTML(v.Error())
+		default:
+			escaped[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, escaped...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeHTML(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"подчеркивание", "john_doe", "john_doe"},
+		{"тег", "<b>", "&lt;b&gt;"},
+		{"готовая сущность", "&amp;", "&amp;amp;"},
+		{"звездочка", "*", "*"},
+		{"markdown-разметка", "*bold* _italic_ `code` [link](url)", "*bold* _italic_ `code` [link](url)"},
+		{"кавычки", `Say "hi"`, "Say &quot;hi&quot;"},
+		{"эмодзи", "Анна 🇬🇧 ✨", "Анна 🇬🇧 ✨"},
+		{"эмодзи и тег", "🔥<i>Speaking</i>🔥", "🔥&lt;i&gt;Speaking&lt;/i&gt;🔥"},
+		{"пустая строка", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := escapeHTML(c.in); got != c.want {
+				t.Errorf("escapeHTML(%q) = %q, ожидалось %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+func TestHTMLF(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"имя с подчеркиванием", "👤 <b>%s</b>", []interface{}{"john_doe"}, "👤 <b>john_doe</b>"},
+		{"имя с тегом", "👤 <b>%s</b>", []interface{}{"<b>"}, "👤 <b>&lt;b&gt;</b>"},
+		{"имя с сущностью", "👤 <b>%s</b>", []interface{}{"&amp;"}, "👤 <b>&amp;amp;</b>"},
+		{"имя со звездочкой", "👤 <b>%s</b>", []interface{}{"*star*"}, "👤 <b>*star*</b>"},
+		{"имя с эмодзи", "👤 <b>%s</b>", []interface{}{"Маша 🌸"}, "👤 <b>Маша 🌸</b>"},
+		{"направление", "🕒 %s (%s)\n", []interface{}{"10:00", "Business <English> & IT"},
+			"🕒 10:00 (Business &lt;English&gt; &amp; IT)\n"},
+		{"направление с эмодзи", "🕒 %s (%s)\n", []interface{}{"10:00", "🗣 Speaking_club*"}, "🕒 10:00 (🗣 Speaking_club*)\n"},
+		{"готовая разметка", "%s: %s", []interface{}{bold("a<b"), code("x&y")}, "<b>a&lt;b</b>: <code>x&amp;y</code>"},
+		{"Stringer", "%s", []interface{}{testStringer("<tag>")}, "&lt;tag&gt;"},
+		{"ошибка", "%v", []interface{}{errors.New("a < b")}, "a &lt; b"},
+		{"числа", "%d из %d", []interface{}{3, 5}, "3 из 5"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := htmlf(c.format, c.args...); got != c.want {
+				t.Errorf("htmlf(%q) = %q, ожидалось %q", c.format, got, c.want)
+			}
+		})
+	}
+}
+
+// Имена и направления учеников в настоящих шаблонах сообщений
+func TestHTMLFTemplates(t *testing.T) {
+	lesson := time.Date(2099, 1, 10, 12, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	cases := []struct {
+		name  string
+		key   string
+		args  []interface{}
+		value string
+	}{
+		{"опрос: направление с тегом", "feedback.survey", []interface{}{formatTime("ru", lesson), "<b>Grammar</b>"}, "&lt;b&gt;Grammar&lt;/b&gt;"},
+		{"опрос: направление с эмодзи", "feedback.survey", []interface{}{formatTime("ru", lesson), "🗣 Speaking & *Listening*"}, "🗣 Speaking &amp; *Listening*"},
+		{"итог: имя с подчеркиванием", "feedback.summary_prompt", []interface{}{"john_doe"}, "john_doe"},
+		{"итог: имя с сущностью", "feedback.summary_prompt", []interface{}{"Tom &amp; Jerry"}, "Tom &amp;amp; Jerry"},
+		{"сообщение ученику: имя с эмодзи", "students.message_prompt", []interface{}{"Аня 🐱 <3"}, "Аня 🐱 &lt;3"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := htmlf(T("ru", c.key), c.args...)
+			if !strings.Contains(got, c.value) {
+				t.Errorf("%s: в %q нет %q", c.key, got, c.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Группы: учитель добавляет бота в группу и отправляет там /linkgroup (канал —
+// командой /linkgroup @канал в личном чате), а в личном чате выбирает, какие
+// события туда публикуются. Сообщения в группах не удаляются и не заменяют
+// друг друга — меню живут только в личных чатах.
+
+// События, которые можно публиковать в группу (совпадают со столбцами group_chats)
+const (
+	groupEventReminders = "reminders"  // Напоминание за 10 минут до занятия
+	groupEventAgenda    = "agenda"     // Ежедневная сводка занятий
+	groupEventFreeSlots = "free_slots" // Новые свободные слоты
+	groupEventBoard     = "board"      // Закрепленная доска свободных слотов
+	groupEventPinAgenda = "pin_agenda" // Закреплять сводку занятий (настройка события agenda)
+)
+
+var groupEvents = []string{groupEventReminders, groupEventAgenda, groupEventPinAgenda, groupEventFreeSlots, groupEventBoard}
+
+// Сообщение из группы или супергруппы: только команды привязки и служебные события
+func handleGroupMessage(msg *tgbotapi.Message) {
+	// Группа стала супергруппой — переносим привязки на новый ID
+	if msg.MigrateToChatID != 0 {
+		if err := store.MigrateGroupChat(msg.Chat.ID, msg.MigrateToChatID); err != nil {
+			fmt.Println("Ошибка переноса группы:", err, "chatID:", msg.Chat.ID)
+		}
+		return
+	}
+	// Бота удалили из группы
+	if msg.LeftChatMember != nil && msg.LeftChatMember.ID == messenger.Self().ID {
+		removeGroup(msg.Chat.ID)
+		return
+	}
+	if !msg.IsCommand() || msg.From == nil {
+		return
+	}
+	// Команда адресована другому боту группы
+	if at := strings.Index(msg.CommandWithAt(), "@"); at >= 0 &&
+		!strings.EqualFold(msg.CommandWithAt()[at+1:], messenger.Self().UserName) {
+		return
+	}
+
+	switch msg.Command() {
+	case "linkgroup":
+		linkGroup(msg)
+	case "unlinkgroup":
+		unlinkGroupCommand(msg)
+	}
+}
+
+// /linkgroup в группе: привязка к учителю, отправившему команду
+func linkGroup(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	teacherID := int64(msg.From.ID)
+	if err := authorizeTeacher(teacherID, "привязка группы"); err != nil {
+		sendGroupMessage(chatID, T(defaultLang, "groups.teacher_only"), nil, PriorityNormal)
+		return
+	}
+	created, err := store.LinkGroupChat(GroupChat{
+		ChatID:    chatID,
+		TeacherID: teacherID,
+		Title:     msg.Chat.Title,
+		Reminders: true,
+		LinkedAt:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Println("Ошибка привязки группы:", err, "chatID:", chatID)
+		sendGroupMessage(chatID, T(defaultLang, "error.generic"), nil, PriorityNormal)
+		return
+	}
+	if created {
+		sendGroupMessage(chatID, T(defaultLang, "groups.linked"), nil, PriorityNormal)
+	} else {
+		sendGroupMessage(chatID, T(defaultLang, "groups.already_linked"), nil, PriorityNormal)
+	}
+	showGroupSettings(teacherID, chatID)
+}
+
+// /linkgroup @канал в личном чате: привязка канала, где бот — администратор
+func linkChannel(chatID int64, arg string) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "привязка канала"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	chat, err := resolveChat(arg)
+	if err != nil || chat.ID >= 0 {
+		fmt.Println("Ошибка поиска канала:", err, "arg:", arg)
+		sendMessage(chatID, T(lang, "groups.channel_not_found"))
+		return
+	}
+	// Привязать канал может только его администратор
+	if err := authorizeChatAdmin(chatID, chat.ID, "привязка канала"); err != nil {
+		if !errors.Is(err, ErrForbidden) {
+			fmt.Println("Ошибка проверки прав:", err, "chatID:", chatID)
+		}
+		sendMessage(chatID, T(lang, "groups.channel_not_admin"))
+		return
+	}
+	// Проверяем, что бот может писать в канал
+	if _, err := sendGroupMessage(chat.ID, T(defaultLang, "groups.linked_channel"), nil, PriorityNormal); err != nil {
+		sendMessage(chatID, T(lang, "groups.channel_failed"))
+		return
+	}
+	_, err = store.LinkGroupChat(GroupChat{
+		ChatID:    chat.ID,
+		TeacherID: chatID,
+		Title:     chat.Title,
+		Reminders: true,
+		LinkedAt:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Println("Ошибка привязки канала:", err, "chatID:", chat.ID)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	showGroupSettings(chatID, chat.ID)
+}
+
+// Чат по ID или @username
+func resolveChat(arg string) (tgbotapi.Chat, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return messenger.GetChat(tgbotapi.ChatConfig{ChatID: id})
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(arg, "https://t.me/"), "@")
+	return messenger.GetChat(tgbotapi.ChatConfig{SuperGroupUsername: "@" + name})
+}
+
+// /unlinkgroup в группе
+func unlinkGroupCommand(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	teacherID := int64(msg.From.ID)
+	if _, err := authorizeGroup(teacherID, chatID, "отвязка группы"); err != nil {
+		sendGroupMessage(chatID, T(defaultLang, "groups.not_linked"), nil, PriorityNormal)
+		return
+	}
+	removeSlotBoard(chatID, teacherID)
+	if err := store.UnlinkGroupChat(chatID, teacherID); err != nil {
+		fmt.Println("Ошибка отвязки группы:", err, "chatID:", chatID)
+		sendGroupMessage(chatID, T(defaultLang, "error.generic"), nil, PriorityNormal)
+		return
+	}
+	sendGroupMessage(chatID, T(defaultLang, "groups.unlinked"), nil, PriorityNormal)
+}
+
+// Бот больше не может писать в группу — забываем ее у всех учителей
+func removeGroup(chatID int64) {
+	fmt.Println("Бот удален из группы:", chatID)
+	if err := store.RemoveGroupChat(chatID); err != nil {
+		fmt.Println("Ошибка удаления группы:", err)
+	}
+}
+
+// Отправка в группу: без удаления предыдущего сообщения и без lastMessageID
+func sendGroupMessage(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup, priority Priority) (tgbotapi.Message, error) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = parseMode
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	send := messenger.Send
+	if priority == PriorityHigh {
+		send = sendUrgent
+	}
+	newMsg, err := send(msg)
+	if err != nil {
+		fmt.Println("Ошибка отправки сообщения в группу:", err, "chatID:", chatID)
+		if deliveryStatusFromError(err) != "" {
+			removeGroup(chatID)
+		}
+	}
+	return newMsg, err
+}
+
+// Публикация события во все группы учителя, где оно включено
+func postGroupEvent(teacherID int64, event, text string, keyboard *tgbotapi.InlineKeyboardMarkup, priority Priority) {
+	groups, err := store.GetGroupsForEvent(teacherID, event)
+	if err != nil {
+		fmt.Println("Ошибка получения групп:", err, "teacherID:", teacherID)
+		return
+	}
+	for _, g := range groups {
+		sendGroupMessage(g.ChatID, text, keyboard, priority)
+	}
+}
+
+// Объявление в группах о новых свободных слотах
+func announceFreeSlots(teacherID int64, slots []ProposedSlot) {
+	if len(slots) == 0 {
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString(T(defaultLang, "groups.free_slots"))
+	for _, s := range slots {
+		builder.WriteString("• " + formatSlotRange(defaultLang, s) + "\n")
+	}
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	if link := botLink(""); link != "" {
+		markup := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL(T(defaultLang, "groups.book"), link),
+			),
+		)
+		keyboard = &markup
+	}
+	postGroupEvent(teacherID, groupEventFreeSlots, builder.String(), keyboard, PriorityNormal)
+}
+
+// Группа из GROUP_CHAT_ID привязывается к учителю из настроек при запуске
+func linkConfiguredGroup() {
+	if config.GroupChatID == 0 || config.TeacherID == 0 {
+		return
+	}
+	_, err := store.LinkGroupChat(GroupChat{
+		ChatID:    config.GroupChatID,
+		TeacherID: config.TeacherID,
+		Reminders: true,
+		LinkedAt:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Println("Ошибка привязки группы из настроек:", err)
+	}
+}
+
+// Название группы для списков и кнопок
+func groupTitle(lang string, g GroupChat) string {
+	if g.Title == "" {
+		return T(lang, "groups.untitled", g.ChatID)
+	}
+	return g.Title
+}
+
+func groupEventEnabled(g GroupChat, event string) bool {
+	switch event {
+	case groupEventReminders:
+		return g.Reminders
+	case groupEventAgenda:
+		return g.Agenda
+	case groupEventFreeSlots:
+		return g.FreeSlots
+	case groupEventBoard:
+		return g.Board
+	case groupEventPinAgenda:
+		return g.PinAgenda
+	}
+	return false
+}
+
+func isGroupEvent(event string) bool {
+	for _, e := range groupEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Список групп учителя
+func showGroups(chatID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "группы"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	groups, err := store.GetTeacherGroups(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения групп:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	text := T(lang, "groups.title")
+	if len(groups) == 0 {
+		text += T(lang, "groups.empty")
+	} else {
+		text += T(lang, "groups.list", len(groups))
+	}
+	text += T(lang, "groups.howto")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, g := range groups {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton("👥 "+groupTitle(lang, g), cbGroup, cbID(g.ChatID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sendMessageWithKeyboard(chatID, text, &keyboard)
+}
+
+// Настройки событий группы
+func showGroupSettings(chatID, groupChatID int64) {
+	g, err := authorizeGroup(chatID, groupChatID, "настройки группы")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	lang := userLang(chatID)
+	id := cbID(g.ChatID)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, event := range groupEvents {
+		mark := "⬜ "
+		if groupEventEnabled(*g, event) {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(mark+T(lang, "groups.event."+event), cbGroupToggle, id, event),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "groups.unlink"), cbGroupUnlink, id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "groups.back"), cbGroups),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "groups.settings"), groupTitle(lang, *g)), &keyboard)
+}
+
+// Включение или отключение события в группе
+func toggleGroupEvent(chatID, groupChatID int64, event string) error {
+	if !isGroupEvent(event) {
+		return fmt.Errorf("неизвестное событие группы: %s", event)
+	}
+	g, err := authorizeGroup(chatID, groupChatID, "настройки группы")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	enabled := !groupEventEnabled(*g, event)
+	if err := store.SetGroupEvent(g.ChatID, chatID, event, enabled); err != nil {
+		return err
+	}
+	// Доска появляется сразу после включения и удаляется из чата после отключения
+	if event == groupEventBoard {
+		if enabled {
+			refreshSlotBoards(chatID)
+		} else {
+			removeSlotBoard(g.ChatID, chatID)
+		}
+	}
+	showGroupSettings(chatID, groupChatID)
+	return nil
+}
+
+// Отвязка группы из личного чата
+func unlinkGroup(chatID, groupChatID int64) error {
+	if _, err := authorizeGroup(chatID, groupChatID, "отвязка группы"); err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	removeSlotBoard(groupChatID, chatID)
+	if err := store.UnlinkGroupChat(groupChatID, chatID); err != nil {
+		return err
+	}
+	sendGroupMessage(groupChatID, T(defaultLang, "groups.unlinked"), nil, PriorityNormal)
+	showGroups(chatID)
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,69 +13,108 @@ import (
 
 // Обработка команды /start
 func handleStart(msg *tgbotapi.Message) {
-	const teacherID = тут // Ваш Telegram ID
+	// Пользователь, заблокировавший бота, снова получает сообщения
+	markReachable(msg.Chat.ID)
 
 	// Удаляем предыдущее сообщение бота, если оно есть
-	if lastID, exists := lastMessageID[msg.Chat.ID]; exists {
+	if lastID, exists := lastMessageID.Get(msg.Chat.ID); exists {
 		deleteMessage(msg.Chat.ID, lastID)
 	}
 
 	// Проверяем, зарегистрирован ли пользователь
-	exists, err := userExists(msg.Chat.ID)
+	exists, err := store.UserExists(msg.Chat.ID)
 	if err != nil {
-		sendMessage(msg.Chat.ID, "Произошла ошибка. Попробуйте позже.")
+		sendMessage(msg.Chat.ID, tr(msg.Chat.ID, "error.generic"))
 		return
 	}
 
 	if !exists {
 		var role string
-		if msg.Chat.ID == teacherID {
+		if msg.Chat.ID == config.TeacherID {
 			role = "teacher"
 		} else {
 			role = "student"
 		}
-		err := registerUser(msg.Chat.ID, role, msg.From.UserName)
+		err := store.RegisterUser(msg.Chat.ID, role, msg.From.UserName)
 		if err != nil {
-			sendMessage(msg.Chat.ID, "Ошибка регистрации. Попробуйте снова.")
+			sendMessage(msg.Chat.ID, tr(msg.Chat.ID, "error.register"))
 			return
 		}
 	}
 
+	// Язык интерфейса по умолчанию берем из настроек Telegram
+	if msg.From != nil {
+		if err := store.SetUserLanguageCode(msg.Chat.ID, msg.From.LanguageCode); err != nil {
+			fmt.Println("Ошибка сохранения языка:", err)
+		}
+		forgetUserLang(msg.Chat.ID)
+	}
+	// Новому учителю — его список команд в подсказках
+	if !exists && msg.Chat.ID == config.TeacherID {
+		updateChatCommands(msg.Chat.ID)
+	}
+
 	// Отправляем меню в зависимости от роли
-	user, err := getUser(msg.Chat.ID)
+	user, err := store.GetUser(msg.Chat.ID)
 	if err != nil {
-		sendMessage(msg.Chat.ID, "Ошибка получения данных пользователя.")
+		sendMessage(msg.Chat.ID, tr(msg.Chat.ID, "error.user"))
 		return
 	}
 
 	if user.Role == "teacher" {
 		showTeacherMenu(msg.Chat.ID)
-	} else {
-		showStudentMenu(msg.Chat.ID)
+		return
+	}
+	// Новый ученик сначала заполняет анкету; слот с доски предлагается после нее
+	if !exists {
+		if err := startProfile(msg.Chat.ID, true, msg.CommandArguments()); err != nil {
+			fmt.Println("Ошибка запуска анкеты:", err)
+			showStudentMenu(msg.Chat.ID)
+		}
+		return
 	}
+	// Кнопка «Записаться» с доски свободных слотов
+	if slotID, ok := slotFromStartPayload(msg.CommandArguments()); ok {
+		showSlotOffer(msg.Chat.ID, slotID, "")
+		return
+	}
+	showStudentMenu(msg.Chat.ID)
 }
 
 // Меню для учителя
 func showTeacherMenu(chatID int64) {
+	lang := userLang(chatID)
 	// Получаем количество непрочитанных уведомлений
-	unreadCount, err := countUnreadNotifications(chatID)
+	unreadCount, err := store.CountUnreadNotifications(chatID)
 	if err != nil {
 		unreadCount = 0
 	}
 
-	text := fmt.Sprintf("👨‍🏫 *Меню учителя*\nВыберите действие:\n📬 Уведомления: %d", unreadCount)
+	text := T(lang, "menu.teacher", unreadCount)
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📅 Управление расписанием", "teacher_schedule"),
+			callbackButton(T(lang, "menu.teacher.schedule"), cbTeacherSchedule),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.teacher.students"), cbTeacherStudents),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("👥 Просмотр учеников", "teacher_students"),
+			callbackButton(T(lang, "menu.teacher.add_slot"), cbSlotCalendar),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить слот", "add_slot"),
+			callbackButton(T(lang, "menu.homework"), cbHomeworkList),
+			callbackButton(T(lang, "menu.teacher.stats"), cbTeacherStats),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📬 Уведомления (%d)", unreadCount), "notifications"),
+			callbackButton(T(lang, "menu.teacher.notifications", unreadCount), cbNotifications),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.ical"), cbICal),
+			callbackButton(T(lang, "menu.ext_calendars"), cbExtCalendars),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.teacher.groups"), cbGroups),
+			callbackButton(T(lang, "btn.language"), cbLanguage),
 		),
 	)
 	sendMessageWithKeyboard(chatID, text, &buttons)
@@ -81,375 +122,237 @@ func showTeacherMenu(chatID int64) {
 
 // Меню для ученика
 func showStudentMenu(chatID int64) {
-	text := "👨‍🎓 *Меню ученика*\nВыберите действие:"
+	lang := userLang(chatID)
+	text := T(lang, "menu.student")
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📅 Записаться на занятие", "student_book"),
+			callbackButton(T(lang, "menu.student.book"), cbBookCalendar),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.student.bookings"), cbMyBookings),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.student.cancel"), cbCancelList),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.homework"), cbHomeworkList),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🗓 Мои записи", "student_bookings"),
+			callbackButton(T(lang, "menu.ical"), cbICal),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить запись", "student_cancel"),
+			callbackButton(T(lang, "menu.student.profile"), cbProfile),
+			callbackButton(T(lang, "btn.language"), cbLanguage),
 		),
 	)
 	sendMessageWithKeyboard(chatID, text, &buttons)
 }
 
 func sendTemporaryNotification(teacherID int64, message string) {
-	err := addNotification(teacherID, message)
+	err := store.AddNotification(teacherID, message)
 	if err != nil {
 		fmt.Println("Ошибка добавления уведомления:", err)
 		return
 	}
 
 	msg := tgbotapi.NewMessage(teacherID, message) // Отправляем только уведомление без текста "У вас новое уведомление"
-	newMsg, err := bot.Send(msg)
+	newMsg, err := sendUrgent(msg)
 	if err != nil {
 		fmt.Println("Ошибка отправки уведомления:", err)
 		return
 	}
 
-	// Удаляем сообщение через 5 секунд без вызова меню
-	go func(chatID int64, msgID int) {
-		time.Sleep(5 * time.Second)
-		deleteMessage(chatID, msgID)
-	}(teacherID, newMsg.MessageID)
+	// Удаляем сообщение через 5 секунд без вызова меню (сразу — при остановке бота)
+	msgID := newMsg.MessageID
+	runWorker(func(ctx context.Context) {
+		sleepContext(ctx, 5*time.Second)
+		deleteMessage(teacherID, msgID)
+	})
 }
 
-func handleCallback(query *tgbotapi.CallbackQuery) {
-	chatID := query.Message.Chat.ID
-	messageID := query.Message.MessageID
-	data := query.Data
-
-	user, err := getUser(chatID)
+// Список уведомлений учителя
+func showNotifications(chatID int64) {
+	lang := userLang(chatID)
+	notifications, err := store.GetTeacherNotifications(chatID)
 	if err != nil {
-		sendMessage(chatID, "Ошибка получения данных пользователя.")
+		sendMessage(chatID, T(lang, "notifications.error"))
 		return
 	}
-
-	if user.Role == "teacher" && data != "notifications" && data != "delete_schedule" && !strings.HasPrefix(data, "mark_read_") && data != "clear_notifications" {
-		deleteMessage(chatID, messageID)
+	if len(notifications) == 0 {
+		buttons := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "settings.open"), cbNotifySettings),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "btn.menu"), cbMenu),
+			),
+		)
+		sendMessageWithKeyboard(chatID, T(lang, "notifications.empty"), &buttons)
+		return
 	}
-
-	switch data {
-	case "back_to_menu":
-		if user.Role == "teacher" {
-			showTeacherMenu(chatID)
-		} else {
-			showStudentMenu(chatID)
+	var builder strings.Builder
+	builder.WriteString(T(lang, "notifications.title"))
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, n := range notifications {
+		status := "🔔"
+		if n.IsRead {
+			status = "✅"
 		}
-	case "teacher_schedule":
-		go handleTeacherSchedule(chatID)
-	case "teacher_students":
-		go handleTeacherStudents(chatID)
-	case "add_slot":
-		fmt.Println("Handling 'add_slot' for chatID:", chatID) // Отладка
-		showMonthCalendar(chatID, time.Now().Year(), time.Now().Month())
-	case "student_book":
-		showStudentMonthCalendar(chatID, time.Now().Year(), time.Now().Month())
-	case "student_bookings":
-		go handleStudentBookings(chatID)
-	case "student_cancel":
-		go handleStudentCancel(chatID)
-	case "back_to_calendar":
-		showMonthCalendar(chatID, time.Now().Year(), time.Now().Month())
-	case "delete_schedule":
-		handleDeleteSchedule(chatID, messageID)
-	case "notifications":
-		go func() {
-			notifications, err := getTeacherNotifications(chatID)
-			if err != nil {
-				sendMessage(chatID, "Ошибка получения уведомлений.")
-				return
-			}
-			if len(notifications) == 0 {
-				buttons := tgbotapi.NewInlineKeyboardMarkup(
-					tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
-					),
-				)
-				sendMessageWithKeyboard(chatID, "📬 У вас нет уведомлений.", &buttons)
-				return
-			}
-			var builder strings.Builder
-			builder.WriteString("📬 *Ваши уведомления:*\n")
-			var buttons [][]tgbotapi.InlineKeyboardButton
-			for _, n := range notifications {
-				status := "🔔"
-				if n.IsRead {
-					status = "✅"
-				}
-				builder.WriteString(fmt.Sprintf("%s %s\n", status, n.Message))
-				if !n.IsRead {
-					buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData(
-							fmt.Sprintf("Отметить прочитанным: %s", formatTime(n.CreatedAt)),
-							fmt.Sprintf("mark_read_%d", n.ID),
-						),
-					))
-				}
-			}
-			buttons = append(buttons,
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("🗑️ Очистить все", "clear_notifications"),
-					tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
+		builder.WriteString(htmlf("%s %s\n", status, n.Message))
+		if !n.IsRead {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				callbackButton(
+					T(lang, "notifications.mark_read", formatTime(lang, n.CreatedAt)),
+					cbNotificationRead, cbID(int64(n.ID)),
 				),
-			)
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-			sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
-		}()
-	default:
-		if data == "ignore" {
-			return
+			))
 		}
-		if strings.HasPrefix(data, "confirm_reminder_") {
-			scheduleIDStr := strings.TrimPrefix(data, "confirm_reminder_")
-			scheduleID, err := strconv.ParseInt(scheduleIDStr, 10, 64)
-			if err != nil {
-				sendMessage(chatID, "Ошибка обработки подтверждения.")
-				fmt.Println("Ошибка парсинга scheduleID:", err, "scheduleIDStr:", scheduleIDStr)
-				return
-			}
+	}
+	buttons = append(buttons,
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "settings.open"), cbNotifySettings),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "notifications.clear"), cbNotificationsClear),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
 
-			fmt.Println("Confirmed reminder for schedule ID:", scheduleID, "chatID:", chatID)
-			if lastID, exists := lastMessageID[chatID]; exists {
-				deleteMessage(chatID, lastID)
-				fmt.Println("Deleted previous message ID:", lastID)
-			} else {
-				fmt.Println("No previous message found in lastMessageID for chatID:", chatID)
-			}
-			showStudentMenu(chatID)
-			return
-		}
+func handleMarkNotificationRead(chatID int64, notificationID int) {
+	if err := authorizeNotification(chatID, notificationID); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	err := store.MarkNotificationAsRead(notificationID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "notifications.mark_error"))
+		return
+	}
+	showNotifications(chatID)
+}
 
-		if strings.HasPrefix(data, "add_slot_") {
-			slotStr := strings.TrimPrefix(data, "add_slot_")
-			go handleAddSlot(chatID, slotStr)
-		} else if strings.HasPrefix(data, "calendar_") {
-			dateStr := strings.TrimPrefix(data, "calendar_")
-			fmt.Println("Teacher selected date:", dateStr)
-			if strings.HasPrefix(dateStr, "prev_") || strings.HasPrefix(dateStr, "next_") {
-				year, month, err := parseYearMonth(strings.TrimPrefix(dateStr, "prev_"))
-				if err != nil {
-					year, month, err = parseYearMonth(strings.TrimPrefix(dateStr, "next_"))
-				}
-				if err == nil {
-					showMonthCalendar(chatID, year, month)
-					return
-				}
-			}
-			date, err := time.Parse("2006-01-02", dateStr)
-			if err != nil {
-				fmt.Println("Ошибка парсинга даты в handleCallback:", err)
-				return
-			}
-			if date.Before(time.Now().Truncate(24 * time.Hour)) {
-				return
-			}
-			showTimeSlots(chatID, dateStr)
-		} else if strings.HasPrefix(data, "student_calendar_") {
-			dateStr := strings.TrimPrefix(data, "student_calendar_")
-			fmt.Println("Student selected date:", dateStr)
-			if strings.HasPrefix(dateStr, "prev_") || strings.HasPrefix(dateStr, "next_") {
-				year, month, err := parseYearMonth(strings.TrimPrefix(dateStr, "prev_"))
-				if err != nil {
-					year, month, err = parseYearMonth(strings.TrimPrefix(dateStr, "next_"))
-				}
-				if err == nil {
-					showStudentMonthCalendar(chatID, year, month)
-					return
-				}
-			}
-			date, err := time.Parse("2006-01-02", dateStr)
-			if err != nil {
-				fmt.Println("Ошибка парсинга даты в handleCallback:", err)
-				return
-			}
-			if date.Before(time.Now().Truncate(24 * time.Hour)) {
-				return
-			}
-			showStudentTimeSlots(chatID, dateStr)
-		} else if strings.HasPrefix(data, "book_") {
-			slotIDStr := strings.TrimPrefix(data, "book_")
-			slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
-			if err != nil {
-				sendMessage(chatID, "Ошибка: неверный ID слота.")
-			} else {
-				go handleBooking(chatID, slotID)
-			}
-		} else if strings.HasPrefix(data, "cancel_") {
-			slotIDStr := strings.TrimPrefix(data, "cancel_")
-			slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
-			if err != nil {
-				sendMessage(chatID, "Ошибка: неверный ID слота.")
-			} else {
-				go handleCancelBooking(chatID, slotID)
-			}
-		} else if strings.HasPrefix(data, "select_delete_") {
-			slotIDStr := strings.TrimPrefix(data, "select_delete_")
-			slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
-			if err != nil {
-				sendMessage(chatID, "Ошибка: неверный ID слота.")
-				return
-			}
-			handleSelectDeleteSlot(chatID, messageID, slotID)
-		} else if strings.HasPrefix(data, "delete_slot_") {
-			slotIDStr := strings.TrimPrefix(data, "delete_slot_")
-			slotID, err := strconv.ParseInt(slotIDStr, 10, 64)
-			if err != nil {
-				sendMessage(chatID, "Ошибка: неверный ID слота.")
-				return
-			}
-			err = DeleteScheduleSlot(slotID)
-			if err != nil {
-				sendMessage(chatID, "Ошибка удаления слота.")
-				return
-			}
-			buttons := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("📅 Вернуться к календарю", "back_to_calendar"),
-					tgbotapi.NewInlineKeyboardButtonData("↩️ Вернуться в меню", "back_to_menu"),
-				),
-			)
-			sendMessageWithKeyboard(chatID, "✅ Слот успешно удален", &buttons)
-		} else if strings.HasPrefix(data, "mark_read_") {
-			notificationIDStr := strings.TrimPrefix(data, "mark_read_")
-			notificationID, err := strconv.Atoi(notificationIDStr)
-			if err != nil {
-				sendMessage(chatID, "Ошибка: неверный ID уведомления.")
-				return
-			}
-			err = markNotificationAsRead(notificationID)
-			if err != nil {
-				sendMessage(chatID, "Ошибка отметки уведомления как прочитанного.")
-				return
-			}
-			notifications, err := getTeacherNotifications(chatID)
-			if err != nil {
-				sendMessage(chatID, "Ошибка получения уведомлений.")
-				return
-			}
-			var builder strings.Builder
-			builder.WriteString("📬 *Ваши уведомления:*\n")
-			var buttons [][]tgbotapi.InlineKeyboardButton
-			for _, n := range notifications {
-				status := "🔔"
-				if n.IsRead {
-					status = "✅"
-				}
-				builder.WriteString(fmt.Sprintf("%s %s\n", status, n.Message))
-				if !n.IsRead {
-					buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData(
-							fmt.Sprintf("Отметить прочитанным: %s", formatTime(n.CreatedAt)),
-							fmt.Sprintf("mark_read_%d", n.ID),
-						),
-					))
-				}
-			}
-			buttons = append(buttons,
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("🗑️ Очистить все", "clear_notifications"),
-					tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
-				),
-			)
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-			sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
-		} else if data == "clear_notifications" {
-			err := clearTeacherNotifications(chatID)
-			if err != nil {
-				sendMessage(chatID, "Ошибка очистки уведомлений.")
-				return
-			}
-			buttons := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
-				),
-			)
-			sendMessageWithKeyboard(chatID, "📬 Уведомления очищены.", &buttons)
-		} else {
-			if user.Role == "teacher" {
-				showTeacherMenu(chatID)
-			} else {
-				showStudentMenu(chatID)
-			}
-		}
+func handleClearNotifications(chatID int64) {
+	if err := authorizeTeacher(chatID, "очистка уведомлений"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	err := store.ClearTeacherNotifications(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "notifications.clear_error"))
+		return
 	}
+	buttons := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, tr(chatID, "notifications.cleared"), &buttons)
+}
 
-	if _, err := bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "")); err != nil {
-		fmt.Println("Ошибка ответа на callback:", err)
+// Удаление слота из экрана добавления
+func handleDeleteSlot(chatID int64, slotID int64) {
+	if _, err := authorizeSlotOwner(chatID, slotID, "удаление слота"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	err := store.DeleteScheduleSlot(slotID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "slot.delete_error"))
+		return
 	}
+	buttons := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.calendar"), cbSlotCalendar),
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, tr(chatID, "slot.deleted"), &buttons)
 }
 
 func handleSelectDeleteSlot(chatID int64, messageID int, slotID int64) {
-	err := DeleteScheduleSlot(slotID)
+	if _, err := authorizeSlotOwner(chatID, slotID, "удаление слота"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	err := store.DeleteScheduleSlot(slotID)
 	if err != nil {
-		editMessage(chatID, messageID, "❌ Ошибка удаления слота")
+		editMessage(chatID, messageID, tr(chatID, "slot.delete_error"))
 		return
 	}
 
 	// Уведомляем об успешном удалении
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
 		),
 	)
-	editMessageWithKeyboard(chatID, messageID, "✅ Слот успешно удален", &buttons)
+	editMessageWithKeyboard(chatID, messageID, tr(chatID, "slot.deleted"), &buttons)
 
 	// Возвращаем пользователя в главное меню
 	showTeacherMenu(chatID)
 }
 
 func handleDeleteSchedule(chatID int64, messageID int) {
-	schedules, err := getTeacherSchedule(chatID)
+	lang := userLang(chatID)
+	schedules, err := store.GetTeacherSchedule(chatID)
 	if err != nil {
-		editMessage(chatID, messageID, "❌ Ошибка загрузки расписания")
+		editMessage(chatID, messageID, T(lang, "schedule.error"))
 		return
 	}
 
 	if len(schedules) == 0 {
-		editMessage(chatID, messageID, "📭 Расписание пусто")
+		editMessage(chatID, messageID, T(lang, "schedule.empty"))
 		return
 	}
 
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	for _, s := range schedules {
-		buttonText := fmt.Sprintf("%s - %s", formatTime(s.StartTime), formatTime(s.EndTime))
+		buttonText := fmt.Sprintf("%s - %s", formatTime(lang, s.StartTime), formatTime(lang, s.EndTime))
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("select_delete_%d", s.ID)),
+			callbackButton(buttonText, cbSlotDeleteSelect, cbID(int64(s.ID))),
 		))
 	}
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	editMessageWithKeyboard(chatID, messageID, "Выберите слот для удаления:", &keyboard)
+	editMessageWithKeyboard(chatID, messageID, T(lang, "slot.choose_delete"), &keyboard)
 }
 
-func editMessage(chatID int64, messageID int, text string) {
+func editMessage(chatID int64, messageID int, text string) error {
 	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
-	edit.ParseMode = "Markdown"
-	if _, err := bot.Send(edit); err != nil {
+	edit.ParseMode = parseMode
+	if _, err := messenger.Send(edit); err != nil {
+		fmt.Println("Ошибка изменения сообщения:", err, "chatID:", chatID)
+		return err
 	}
+	return nil
 }
 
-func editMessageWithKeyboard(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+func editMessageWithKeyboard(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
 	editText := tgbotapi.NewEditMessageText(chatID, messageID, text)
-	editText.ParseMode = "Markdown"
-	if _, err := bot.Send(editText); err != nil {
-		return
+	editText.ParseMode = parseMode
+	if _, err := messenger.Send(editText); err != nil {
+		fmt.Println("Ошибка изменения сообщения:", err, "chatID:", chatID)
+		return err
 	}
 
 	if keyboard != nil {
 		editMarkup := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, *keyboard)
-		if _, err := bot.Send(editMarkup); err != nil {
+		if _, err := messenger.Send(editMarkup); err != nil {
+			fmt.Println("Ошибка изменения клавиатуры:", err, "chatID:", chatID)
+			return err
 		}
 	}
+	return nil
 }
 
 func showStudentMonthCalendar(chatID int64, year int, month time.Month) {
+	lang := userLang(chatID)
 	startOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
 	endOfMonth := startOfMonth.AddDate(0, 1, -1)
 
@@ -463,20 +366,15 @@ func showStudentMonthCalendar(chatID int64, year int, month time.Month) {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
 	navRow := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("<<", fmt.Sprintf("student_calendar_prev_%d-%02d", year, int(month)-1)),
-		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %d", month.String(), year), "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData(">>", fmt.Sprintf("student_calendar_next_%d-%02d", year, int(month)+1)),
+		callbackButton("<<", cbBookCalendar, cbMonth(year, month-1)),
+		callbackButton(fmt.Sprintf("%s %d", monthName(lang, month), year), cbNoop),
+		callbackButton(">>", cbBookCalendar, cbMonth(year, month+1)),
 	}
 	buttons = append(buttons, navRow)
 
-	header := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("Пн", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Вт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Ср", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Чт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Пт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Сб", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Вс", "ignore"),
+	var header []tgbotapi.InlineKeyboardButton
+	for _, name := range weekdayHeader(lang) {
+		header = append(header, callbackButton(name, cbNoop))
 	}
 	buttons = append(buttons, header)
 
@@ -486,22 +384,20 @@ func showStudentMonthCalendar(chatID int64, year int, month time.Month) {
 			currentDay := day + d + week*7
 			if currentDay >= 1 && currentDay <= endOfMonth.Day() {
 				date := time.Date(year, month, currentDay, 0, 0, 0, 0, time.UTC)
-				dateStr := date.Format("2006-01-02")
 				buttonText := fmt.Sprintf("%2d", currentDay)
-				var callbackData string
+				var button tgbotapi.InlineKeyboardButton
 
-				if date.Before(time.Now().Truncate(24 * time.Hour)) {
+				if isPastDate(date) {
 					// Прошедшие дни некликабельны
-					callbackData = "ignore"
+					button = callbackButton(buttonText, cbNoop)
 				} else {
 					// Текущие и будущие дни кликабельны
-					callbackData = fmt.Sprintf("student_calendar_%s", dateStr)
+					button = callbackButton(buttonText, cbBookDay, cbDate(date))
 				}
 
-				button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
 				weekRow = append(weekRow, button)
 			} else {
-				weekRow = append(weekRow, tgbotapi.NewInlineKeyboardButtonData("  ", "ignore"))
+				weekRow = append(weekRow, callbackButton("  ", cbNoop))
 			}
 		}
 		buttons = append(buttons, weekRow)
@@ -511,33 +407,15 @@ func showStudentMonthCalendar(chatID int64, year int, month time.Month) {
 	}
 
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sendMessageWithKeyboard(chatID, "Выберите дату для записи на занятие:", &keyboard)
-}
-
-func parseYearMonth(dateStr string) (int, time.Month, error) {
-	// Проверяем, содержит ли строка только год и месяц
-	if len(dateStr) != 7 || dateStr[4] != '-' {
-		return 0, 0, fmt.Errorf("invalid date format: %s", dateStr)
-	}
-	year, err := strconv.Atoi(dateStr[:4])
-	if err != nil {
-		return 0, 0, err
-	}
-	month, err := strconv.Atoi(dateStr[5:])
-	if err != nil {
-		return 0, 0, err
-	}
-	if month < 1 || month > 12 {
-		return 0, 0, fmt.Errorf("invalid month: %d", month)
-	}
-	return year, time.Month(month), nil
+	sendMessageWithKeyboard(chatID, T(lang, "calendar.book"), &keyboard)
 }
 
 func showMonthCalendar(chatID int64, year int, month time.Month) {
+	lang := userLang(chatID)
 	fmt.Println("showMonthCalendar called for chatID:", chatID, "year:", year, "month:", month) // Отладка
 
 	startOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
@@ -553,20 +431,15 @@ func showMonthCalendar(chatID int64, year int, month time.Month) {
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
 	navRow := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("<<", fmt.Sprintf("calendar_prev_%d-%02d", year, int(month)-1)),
-		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %d", month.String(), year), "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData(">>", fmt.Sprintf("calendar_next_%d-%02d", year, int(month)+1)),
+		callbackButton("<<", cbSlotCalendar, cbMonth(year, month-1)),
+		callbackButton(fmt.Sprintf("%s %d", monthName(lang, month), year), cbNoop),
+		callbackButton(">>", cbSlotCalendar, cbMonth(year, month+1)),
 	}
 	buttons = append(buttons, navRow)
 
-	header := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("Пн", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Вт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Ср", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Чт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Пт", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Сб", "ignore"),
-		tgbotapi.NewInlineKeyboardButtonData("Вс", "ignore"),
+	var header []tgbotapi.InlineKeyboardButton
+	for _, name := range weekdayHeader(lang) {
+		header = append(header, callbackButton(name, cbNoop))
 	}
 	buttons = append(buttons, header)
 
@@ -576,20 +449,18 @@ func showMonthCalendar(chatID int64, year int, month time.Month) {
 			currentDay := day + d + week*7
 			if currentDay >= 1 && currentDay <= endOfMonth.Day() {
 				date := time.Date(year, month, currentDay, 0, 0, 0, 0, time.UTC)
-				dateStr := date.Format("2006-01-02")
 				buttonText := fmt.Sprintf("%2d", currentDay)
-				var callbackData string
+				var button tgbotapi.InlineKeyboardButton
 
-				if date.Before(time.Now().Truncate(24 * time.Hour)) {
-					callbackData = "ignore"
+				if isPastDate(date) {
+					button = callbackButton(buttonText, cbNoop)
 				} else {
-					callbackData = fmt.Sprintf("calendar_%s", dateStr)
+					button = callbackButton(buttonText, cbSlotDay, cbDate(date))
 				}
 
-				button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
 				weekRow = append(weekRow, button)
 			} else {
-				weekRow = append(weekRow, tgbotapi.NewInlineKeyboardButtonData("  ", "ignore"))
+				weekRow = append(weekRow, callbackButton("  ", cbNoop))
 			}
 		}
 		buttons = append(buttons, weekRow)
@@ -599,31 +470,32 @@ func showMonthCalendar(chatID int64, year int, month time.Month) {
 	}
 
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	})
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	msg := tgbotapi.NewMessage(chatID, "Выберите дату для добавления слота:")
-	msg.ParseMode = "Markdown"
+	msg := tgbotapi.NewMessage(chatID, T(lang, "calendar.slot"))
+	msg.ParseMode = parseMode
 	msg.ReplyMarkup = keyboard
 
 	// Упрощаем отправку: всегда отправляем новое сообщение, удаляя старое
-	if lastID, exists := lastMessageID[chatID]; exists {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
 		deleteMessage(chatID, lastID)
 		fmt.Println("Deleted previous message ID:", lastID) // Отладка
 	}
-	newMsg, err := bot.Send(msg)
+	newMsg, err := messenger.Send(msg)
 	if err != nil {
 		fmt.Println("Ошибка отправки календаря:", err) // Отладка
 		return
 	}
-	lastMessageID[chatID] = newMsg.MessageID
+	lastMessageID.Set(chatID, newMsg.MessageID)
 	fmt.Println("Calendar sent, new message ID:", newMsg.MessageID) // Отладка
 }
 
 // Новая функция для показа календаря
 func showCalendar(chatID int64) {
-	now := time.Now()
+	lang := userLang(chatID)
+	now := scheduleNow()
 	currentYear, currentMonth, _ := now.Date()
 	startOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, time.UTC)
 	endOfMonth := startOfMonth.AddDate(0, 1, -1)
@@ -645,7 +517,6 @@ func showCalendar(chatID int64) {
 		for col := 0; col < 7; col++ { // 7 дней недели
 			if day >= 1 && day <= endOfMonth.Day() {
 				date := time.Date(currentYear, currentMonth, day, 0, 0, 0, 0, time.UTC)
-				dateStr := date.Format("2006-01-02")
 				color := "⬜" // Белый квадрат по умолчанию
 
 				// Проверяем, текущий ли это день
@@ -654,7 +525,7 @@ func showCalendar(chatID int64) {
 				}
 
 				// Проверяем, есть ли доступные слоты для этой даты
-				slots, err := getSlotsForDate(date)
+				slots, err := store.GetSlotsForDate(chatID, date)
 				if err == nil && len(slots) > 0 {
 					hasFreeSlots := false
 					for _, slot := range slots {
@@ -668,15 +539,15 @@ func showCalendar(chatID int64) {
 					}
 				}
 
-				button := tgbotapi.NewInlineKeyboardButtonData(
+				button := callbackButton(
 					fmt.Sprintf("%s %d", color, day),
-					fmt.Sprintf("calendar_%s", dateStr),
+					cbSlotDay, cbDate(date),
 				)
 				weekRow = append(weekRow, button)
 				day++
 			} else {
 				// Пустые ячейки для начала или конца месяца
-				weekRow = append(weekRow, tgbotapi.NewInlineKeyboardButtonData("⬜", "ignore"))
+				weekRow = append(weekRow, callbackButton("⬜", cbNoop))
 			}
 		}
 		if len(weekRow) > 0 {
@@ -690,37 +561,43 @@ func showCalendar(chatID int64) {
 	// Добавляем кнопку "Следующий месяц" для навигации
 	if currentMonth < time.December {
 		nextMonth := time.Date(currentYear, currentMonth+1, 1, 0, 0, 0, 0, time.UTC)
-		nextMonthStr := nextMonth.Format("2006-01")
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➡️ Следующий месяц", fmt.Sprintf("calendar_next_%s", nextMonthStr)),
+			callbackButton(T(lang, "btn.next_month"), cbSlotCalendar, cbMonth(nextMonth.Year(), nextMonth.Month())),
 		))
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("Календарь %s %d для добавления слота:", currentMonth, currentYear), &keyboard)
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "calendar.slot_month"), monthName(lang, currentMonth), currentYear), &keyboard)
 }
 
 // Новая функция для показа временных слотов
 func showTimeSlots(chatID int64, dateStr string) {
+	lang := userLang(chatID)
 	date, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
-		sendMessage(chatID, "Ошибка обработки даты: неверный формат.")
+		sendMessage(chatID, T(lang, "date.error"))
 		fmt.Println("Ошибка парсинга даты:", err, "dateStr:", dateStr)
 		return
 	}
 
 	// Если дата прошедшая, просто игнорируем нажатие
-	if date.Before(time.Now().Truncate(24 * time.Hour)) {
+	if date.Before(scheduleNow().Truncate(24 * time.Hour)) {
 		return
 	}
 
-	slots, err := getSlotsForDate(date)
+	slots, err := store.GetSlotsForDate(chatID, date)
 	if err != nil {
-		sendMessage(chatID, "Ошибка получения слотов.")
+		sendMessage(chatID, T(lang, "slots.error"))
 		fmt.Println("Ошибка getSlotsForDate:", err)
 		return
 	}
 
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	busy, err := store.GetExternalBusy(chatID, dayStart.Format(time.RFC3339), dayStart.AddDate(0, 0, 1).Format(time.RFC3339))
+	if err != nil {
+		fmt.Println("Ошибка getExternalBusy:", err)
+	}
+
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	var row []tgbotapi.InlineKeyboardButton
 
@@ -734,21 +611,30 @@ func showTimeSlots(chatID int64, dateStr string) {
 				break
 			}
 		}
+		externallyBusy := false
+		for _, b := range busy {
+			if b.Start.Before(startTime.Add(time.Hour)) && b.End.After(startTime) {
+				externallyBusy = true
+				break
+			}
+		}
 
-		color := "⬜"                       // Белый по умолчанию
-		if !startTime.Before(time.Now()) { // Только текущее и будущее время
+		color := "⬜"                          // Белый по умолчанию
+		if !startTime.Before(scheduleNow()) { // Только текущее и будущее время
 			if slotExists {
 				color = "🟥" // Красный для занятого времени
+			} else if externallyBusy {
+				color = "⛔" // Занято во внешнем календаре
 			} else {
 				color = "🟩" // Зеленый для свободного времени
 			}
 		}
 
-		timeKey := fmt.Sprintf("%s_%02d:00", dateStr, hour)
-		btn := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%02d:00\n%s", hour, color), // Часы над цветом
-			fmt.Sprintf("add_slot_%s", timeKey),
-		)
+		text := startTime.Format("15:04") + "\n" + color // Время над цветом
+		btn := callbackButton(text, cbSlotAdd, cbDate(date), cbClock(hour, 0))
+		if externallyBusy && !slotExists {
+			btn = callbackButton(text, cbNoop)
+		}
 		row = append(row, btn)
 		if len(row) == 4 {
 			buttons = append(buttons, row)
@@ -761,190 +647,130 @@ func showTimeSlots(chatID int64, dateStr string) {
 	}
 
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к календарю", "add_slot"),
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+		callbackButton(T(lang, "slot.custom_time"), cbSlotCustomTime, cbDate(date)),
+	})
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		callbackButton(T(lang, "btn.to_calendar"), cbSlotCalendar),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	})
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	if lastID, exists := lastMessageID[chatID]; exists {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
 		deleteMessage(chatID, lastID)
 	}
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🕒 *Доступные часы на %s:*\n\n🟩 - Свободно\n🟥 - Занято", dateStr))
-	msg.ParseMode = "Markdown"
+	msg := tgbotapi.NewMessage(chatID, htmlf(T(lang, "slots.hours"), formatDate(lang, date)))
+	msg.ParseMode = parseMode
 	msg.ReplyMarkup = keyboard
-	newMsg, err := bot.Send(msg)
+	newMsg, err := messenger.Send(msg)
 	if err != nil {
 		fmt.Println("Ошибка отправки временных слотов:", err)
 		return
 	}
-	lastMessageID[chatID] = newMsg.MessageID
+	lastMessageID.Set(chatID, newMsg.MessageID)
 }
 
 // Новая функция для добавления слота
-func handleAddSlot(chatID int64, slotStr string) {
-	parts := strings.Split(slotStr, "_")
-	if len(parts) != 2 {
-		sendMessage(chatID, "Ошибка: неверный формат слота.")
-		return
-	}
-	dateStr := parts[0]
-	timeStr := parts[1]
-
-	date, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		sendMessage(chatID, "Ошибка: неверная дата.")
-		return
-	}
-	timeParts := strings.Split(timeStr, ":")
-	if len(timeParts) != 2 {
-		sendMessage(chatID, "Ошибка: неверное время.")
-		return
-	}
-	hour, err := strconv.Atoi(timeParts[0])
-	if err != nil || hour < 0 || hour > 23 {
-		sendMessage(chatID, "Ошибка: неверный час.")
+func handleAddSlot(chatID int64, startTime time.Time, length time.Duration) {
+	if err := authorizeTeacher(chatID, "добавление слота"); err != nil {
+		sendAuthError(chatID, err)
 		return
 	}
-	minute, err := strconv.Atoi(timeParts[1])
-	if err != nil || minute < 0 || minute > 59 {
-		sendMessage(chatID, "Ошибка: неверная минута.")
+	if startTime.Before(scheduleNow()) {
+		sendMessage(chatID, tr(chatID, "slot.past"))
 		return
 	}
 
-	startTime := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.UTC)
-	if startTime.Before(time.Now()) {
-		sendMessage(chatID, "Нельзя добавить слот на прошедшее время.")
-		return
-	}
-
-	endTime := startTime.Add(1 * time.Hour)
+	endTime := startTime.Add(length)
 	startTimeStr := startTime.Format(time.RFC3339)
 	endTimeStr := endTime.Format(time.RFC3339)
 
-	var slotID int64
-	err = db.QueryRow(
-		`SELECT id FROM schedules WHERE teacher_id = ? AND start_time = ?`,
-		chatID, startTimeStr).Scan(&slotID)
-
+	slotID, err := store.GetSlotIDByStart(chatID, startTimeStr)
 	if err == nil {
 		buttons := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить слот", fmt.Sprintf("delete_slot_%d", slotID)),
+				callbackButton(tr(chatID, "slot.delete"), cbSlotDelete, cbID(slotID)),
 			),
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("📅 Вернуться к календарю", "back_to_calendar"),
-				tgbotapi.NewInlineKeyboardButtonData("↩️ Вернуться в меню", "back_to_menu"),
+				callbackButton(tr(chatID, "btn.calendar"), cbSlotCalendar),
+				callbackButton(tr(chatID, "btn.menu"), cbMenu),
 			),
 		)
-		sendMessageWithKeyboard(chatID, fmt.Sprintf("Слот %s - %s уже занят. Что делать?", startTime.Format("15:04"), endTime.Format("15:04")), &buttons)
+		sendMessageWithKeyboard(chatID, tr(chatID, "slot.exists", startTime.Format("15:04"), endTime.Format("15:04")), &buttons)
 		return
 	}
 
-	err = addScheduleSlot(chatID, startTimeStr, endTimeStr)
+	err = store.AddScheduleSlot(chatID, startTimeStr, endTimeStr)
+	if errors.Is(err, ErrSlotExternallyBusy) {
+		sendMessage(chatID, tr(chatID, "slot.external_busy"))
+		return
+	}
 	if err != nil {
-		sendMessage(chatID, "Ошибка добавления слота.")
+		sendMessage(chatID, tr(chatID, "slot.add_error"))
 		return
 	}
 
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📅 Вернуться к календарю", "back_to_calendar"),
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Вернуться в меню", "back_to_menu"),
+			callbackButton(tr(chatID, "btn.calendar"), cbSlotCalendar),
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
 		),
 	)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("Слот успешно добавлен: %s - %s", startTime.Format("15:04"), endTime.Format("15:04")), &buttons)
+	sendMessageWithKeyboard(chatID, tr(chatID, "slot.added", startTime.Format("15:04"), endTime.Format("15:04")), &buttons)
+	announceFreeSlots(chatID, []ProposedSlot{{Start: startTime, End: endTime}})
 }
 
 // Управление расписанием (для учителя)
 func handleTeacherSchedule(chatID int64) {
+	lang := userLang(chatID)
 	// Проверка прав учителя
-	user, err := getUser(chatID)
-	if err != nil || user.Role != "teacher" {
-		sendMessage(chatID, "❌ Эта команда доступна только учителям")
+	if err := authorizeTeacher(chatID, "просмотр расписания"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
 		return
 	}
 
-	schedules, err := getTeacherSchedule(chatID)
+	schedules, err := store.GetTeacherSchedule(chatID)
 	if err != nil {
-		sendMessage(chatID, "❌ Ошибка загрузки расписания")
+		sendMessage(chatID, T(lang, "schedule.error"))
 		return
 	}
 
 	if len(schedules) == 0 {
-		sendMessage(chatID, "📭 Расписание пусто")
+		sendMessage(chatID, T(lang, "schedule.empty"))
 		return
 	}
 
 	var builder strings.Builder
-	builder.WriteString("📅 *Ваше расписание:*\n\n")
+	builder.WriteString(T(lang, "schedule.title"))
 
 	for _, s := range schedules {
-		builder.WriteString(fmt.Sprintf(
-			"⏰ %s - %s\n🔄 Статус: %s\n",
-			formatTime(s.StartTime),
-			formatTime(s.EndTime),
-			statusToEmoji(s.Status),
+		builder.WriteString(T(lang, "schedule.item",
+			s.ID,
+			formatTime(lang, s.StartTime),
+			formatTime(lang, s.EndTime),
+			statusText(lang, s.Status),
 		))
 	}
 
-	// Кнопки управления (только удаление и назад)
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	// Кнопки занятий с заметками, удаление и назад
+	buttons := lessonButtons(lang, schedules)
+	buttons = append(buttons,
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить", "delete_schedule"),
+			callbackButton(T(lang, "btn.delete"), cbSlotDeleteList),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
 		),
 	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 
 	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
 }
 
-func statusToEmoji(status string) string {
+func statusText(lang, status string) string {
 	if status == "booked" {
-		return "✅ Занят"
+		return T(lang, "status.booked")
 	}
-	return "🆓 Свободен"
-}
-
-// Просмотр учеников (для учителя)
-func handleTeacherStudents(chatID int64) {
-	students, err := getTeacherStudents(chatID)
-	if err != nil {
-		sendMessage(chatID, "Ошибка получения списка учеников.")
-		return
-	}
-
-	if len(students) == 0 {
-		// Создаем клавиатуру с кнопкой "Назад"
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
-			),
-		)
-		sendMessageWithKeyboard(chatID, "У вас пока нет учеников.", &keyboard)
-		return
-	}
-
-	var builder strings.Builder
-	builder.WriteString("👥 *Ваши ученики:*\n")
-	for _, s := range students {
-		builder.WriteString(fmt.Sprintf(
-			"👤 @%s - %s (%s)\n",
-			s.StudentUsername,
-			formatTime(s.StartTime),
-			s.Direction,
-		))
-	}
-
-	// Кнопка возврата для случая, когда ученики есть
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
-		),
-	)
-
-	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+	return T(lang, "status.free")
 }
 
 // Запись на занятие (для ученика)
@@ -953,7 +779,8 @@ func handleStudentBook(chatID int64) {
 }
 
 func showStudentCalendar(chatID int64) {
-	now := time.Now()
+	lang := userLang(chatID)
+	now := scheduleNow()
 	currentYear, currentMonth, _ := now.Date()
 	startOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, time.UTC)
 	endOfMonth := startOfMonth.AddDate(0, 1, -1)
@@ -975,7 +802,6 @@ func showStudentCalendar(chatID int64) {
 		for col := 0; col < 7; col++ { // 7 дней недели
 			if day >= 1 && day <= endOfMonth.Day() {
 				date := time.Date(currentYear, currentMonth, day, 0, 0, 0, 0, time.UTC)
-				dateStr := date.Format("2006-01-02")
 				color := "⬜" // Белый квадрат по умолчанию
 
 				// Проверяем, текущий ли это день
@@ -984,22 +810,22 @@ func showStudentCalendar(chatID int64) {
 				}
 
 				// Проверяем, есть ли доступные слоты для этой даты
-				slots, err := getAvailableSlotsForDate(date)
+				slots, err := store.GetAvailableSlotsForDate(date)
 				if err == nil && len(slots) > 0 {
 					color = "🟩" // Зелёный для дней с доступными слотами
 				} else if err != nil || len(slots) == 0 {
 					color = "🟥" // Красный для дней без свободных слотов
 				}
 
-				button := tgbotapi.NewInlineKeyboardButtonData(
+				button := callbackButton(
 					fmt.Sprintf("%s %d", color, day),
-					fmt.Sprintf("student_calendar_%s", dateStr),
+					cbBookDay, cbDate(date),
 				)
 				weekRow = append(weekRow, button)
 				day++
 			} else {
 				// Пустые ячейки для начала или конца месяца
-				weekRow = append(weekRow, tgbotapi.NewInlineKeyboardButtonData("⬜", "ignore"))
+				weekRow = append(weekRow, callbackButton("⬜", cbNoop))
 			}
 		}
 		if len(weekRow) > 0 {
@@ -1014,33 +840,32 @@ func showStudentCalendar(chatID int64) {
 	var navRow []tgbotapi.InlineKeyboardButton
 	if currentMonth > time.January {
 		prevMonth := time.Date(currentYear, currentMonth-1, 1, 0, 0, 0, 0, time.UTC)
-		prevMonthStr := prevMonth.Format("2006-01")
-		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Предыдущий месяц", fmt.Sprintf("student_calendar_next_%s", prevMonthStr)))
+		navRow = append(navRow, callbackButton(T(lang, "btn.prev_month"), cbBookCalendar, cbMonth(prevMonth.Year(), prevMonth.Month())))
 	}
 	if currentMonth < time.December {
 		nextMonth := time.Date(currentYear, currentMonth+1, 1, 0, 0, 0, 0, time.UTC)
-		nextMonthStr := nextMonth.Format("2006-01")
-		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("➡️ Следующий месяц", fmt.Sprintf("student_calendar_next_%s", nextMonthStr)))
+		navRow = append(navRow, callbackButton(T(lang, "btn.next_month"), cbBookCalendar, cbMonth(nextMonth.Year(), nextMonth.Month())))
 	}
 	if len(navRow) > 0 {
 		buttons = append(buttons, navRow)
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("Календарь %s %d для записи на занятие:", currentMonth, currentYear), &keyboard)
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "calendar.book_month"), monthName(lang, currentMonth), currentYear), &keyboard)
 }
 
 // Новая функция для показа слотов ученику
 func showStudentTimeSlots(chatID int64, dateStr string) {
+	lang := userLang(chatID)
 	date, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
-		sendMessage(chatID, "Ошибка обработки даты.")
+		sendMessage(chatID, T(lang, "date.error"))
 		return
 	}
 
-	slots, err := getAvailableSlotsForDate(date)
+	slots, err := store.GetAvailableSlotsForDate(date)
 	if err != nil {
-		sendMessage(chatID, "Ошибка получения слотов.")
+		sendMessage(chatID, T(lang, "slots.error"))
 		return
 	}
 
@@ -1054,14 +879,13 @@ func showStudentTimeSlots(chatID int64, dateStr string) {
 			continue
 		}
 		color := "🟩" // Зеленый для свободных слотов
-		if startTime.Before(time.Now()) {
+		if startTime.Before(scheduleNow()) {
 			color = "🔴" // Красный для прошедшего времени
 		}
 
-		hour := startTime.Hour()
-		btn := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%02d:00\n%s", hour, color), // Часы над цветом
-			fmt.Sprintf("book_%d", slot.ID),
+		btn := callbackButton(
+			startTime.Format("15:04")+"\n"+color, // Время начала (слот может начинаться не в начале часа) над цветом
+			cbBook, cbID(int64(slot.ID)),
 		)
 		row = append(row, btn)
 		if len(row) == 3 {
@@ -1075,67 +899,41 @@ func showStudentTimeSlots(chatID int64, dateStr string) {
 	}
 
 	navRow := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("↩️ К календарю", "student_book"),
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+		callbackButton(T(lang, "btn.to_calendar"), cbBookCalendar),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	}
 	buttons = append(buttons, navRow)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("✅ *Свободные слоты на %s:*\n\nВыберите удобное время:", dateStr), &keyboard)
-}
-
-// Новая функция для получения доступных слотов по дате
-func getAvailableSlotsForDate(date time.Time) ([]Schedule, error) {
-	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
-	endOfDay := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, time.UTC).Format(time.RFC3339)
-
-	query := `SELECT id, start_time, end_time, status 
-        FROM schedules 
-        WHERE status = 'free' 
-        AND start_time BETWEEN ? AND ? 
-        ORDER BY start_time`
-	rows, err := db.Query(query, startOfDay, endOfDay)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка запроса свободных слотов: %v", err)
-	}
-	defer rows.Close()
-
-	var slots []Schedule
-	for rows.Next() {
-		var s Schedule
-		if err := rows.Scan(&s.ID, &s.StartTime, &s.EndTime, &s.Status); err != nil {
-			return nil, fmt.Errorf("ошибка сканирования слотов: %v", err)
-		}
-		slots = append(slots, s)
-	}
-	return slots, nil
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "slots.free"), formatDate(lang, date)), &keyboard)
 }
 
 // Просмотр записей (для ученика)
 func handleStudentBookings(chatID int64) {
-	bookings, err := getStudentBookings(chatID)
+	lang := userLang(chatID)
+	bookings, err := store.GetStudentBookings(chatID)
 	if err != nil {
-		sendMessage(chatID, "Ошибка получения записей.")
+		sendMessage(chatID, T(lang, "bookings.error"))
 		return
 	}
 
 	if len(bookings) == 0 {
 		buttons := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
+				callbackButton(T(lang, "btn.menu"), cbMenu),
 			),
 		)
-		sendMessageWithKeyboard(chatID, "У вас нет активных записей.", &buttons)
+		sendMessageWithKeyboard(chatID, T(lang, "bookings.empty"), &buttons)
 		return
 	}
 
 	var builder strings.Builder
-	builder.WriteString("🗓 *Ваши записи:*\n")
+	builder.WriteString(T(lang, "bookings.title"))
 	for _, b := range bookings {
-		builder.WriteString(fmt.Sprintf(
+		builder.WriteString(htmlf(
 			"🕒 %s - %s (%s)\n",
-			formatTime(b.StartTime),
-			formatTime(b.EndTime),
+			formatTime(lang, b.StartTime),
+			formatTime(lang, b.EndTime),
 			b.Direction.String,
 		))
 	}
@@ -1143,7 +941,7 @@ func handleStudentBookings(chatID int64) {
 	// Добавляем кнопку "Назад в меню"
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
 		),
 	)
 	sendMessageWithKeyboard(chatID, builder.String(), &buttons)
@@ -1151,105 +949,118 @@ func handleStudentBookings(chatID int64) {
 
 // Отмена записи (для ученика)
 func handleStudentCancel(chatID int64) {
-	bookings, err := getStudentBookings(chatID)
+	lang := userLang(chatID)
+	bookings, err := store.GetStudentBookings(chatID)
 	if err != nil {
-		sendMessage(chatID, "Ошибка получения записей.")
+		sendMessage(chatID, T(lang, "bookings.error"))
 		return
 	}
 
 	if len(bookings) == 0 {
 		buttons := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
+				callbackButton(T(lang, "btn.menu"), cbMenu),
 			),
 		)
-		sendMessageWithKeyboard(chatID, "У вас нет активных записей.", &buttons)
+		sendMessageWithKeyboard(chatID, T(lang, "bookings.empty"), &buttons)
 		return
 	}
 
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	for _, b := range bookings {
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("🕒 %s", formatTime(b.StartTime)),
-				fmt.Sprintf("cancel_%d", b.ID),
+			callbackButton(
+				"🕒 "+formatTime(lang, b.StartTime),
+				cbCancel, cbID(int64(b.ID)),
 			),
 		))
 	}
 
 	// Добавляем кнопку "Назад в меню"
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("↩️ Назад в меню", "back_to_menu"),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
 	))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sendMessageWithKeyboard(chatID, "Выберите запись для отмены:", &keyboard)
+	sendMessageWithKeyboard(chatID, T(lang, "bookings.cancel"), &keyboard)
 }
 
 func getUsername(chatID int64) string {
-	var username string
-	err := db.QueryRow("SELECT username FROM users WHERE telegram_id = ?", chatID).Scan(&username)
+	user, err := store.GetUser(chatID)
 	if err != nil {
 		// Если пользователь не найден или username пустой, возвращаем ID в качестве запасного варианта
 		fmt.Println("Ошибка получения username:", err)
 		return fmt.Sprintf("ID%d", chatID)
 	}
-	if username == "" {
+	if user.Username.String == "" {
 		return fmt.Sprintf("ID%d", chatID)
 	}
-	return username
+	return user.Username.String
 }
 
 // Обработка бронирования слота
 func handleBooking(chatID int64, slotID int64) {
-	slot, err := getScheduleByID(slotID)
+	slot, err := authorizeBooking(chatID, slotID)
+	if errors.Is(err, ErrForbidden) {
+		sendMessage(chatID, tr(chatID, "book.blocked"))
+		return
+	}
 	if err != nil {
-		sendMessage(chatID, "Ошибка: слот не найден.")
+		sendAuthError(chatID, err)
 		return
 	}
 
 	if slot.Status != "free" {
-		sendMessage(chatID, "Этот слот уже занят.")
+		sendMessage(chatID, tr(chatID, "book.taken"))
 		return
 	}
 
 	// Проверка на прошлые даты и время
 	startTime, err := time.Parse(time.RFC3339, slot.StartTime)
 	if err != nil {
-		sendMessage(chatID, "Ошибка обработки времени слота.")
+		sendMessage(chatID, tr(chatID, "book.time_error"))
 		return
 	}
-	if startTime.Before(time.Now()) {
-		sendMessage(chatID, "Нельзя записаться на прошедшее время.")
+	if startTime.Before(scheduleNow()) {
+		sendMessage(chatID, tr(chatID, "book.past"))
 		return
 	}
 
 	direction := "Общее"
 	if slot.Direction.Valid {
 		direction = slot.Direction.String
+	} else if p, err := store.GetStudentProfile(chatID); err == nil && p != nil && p.Direction.Valid {
+		// Направление, выбранное в анкете ученика
+		direction = directionName(defaultLang, p.Direction.String)
 	}
 	studentID := chatID
 
-	err = updateScheduleStatus(slotID, "booked", studentID, direction)
+	err = store.BookSlot(slotID, studentID, direction)
+	if errors.Is(err, ErrSlotTaken) {
+		// Другой ученик успел записаться между проверкой и записью
+		sendMessage(chatID, tr(chatID, "book.taken"))
+		return
+	}
 	if err != nil {
-		sendMessage(chatID, "Ошибка при записи на занятие.")
+		sendMessage(chatID, tr(chatID, "book.error"))
 		return
 	}
 
 	buttons := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
 		),
 	)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("Вы успешно записаны на занятие: %s", formatTime(slot.StartTime)), &buttons)
+	sendMessageWithKeyboard(chatID, tr(chatID, "book.done", formatTime(userLang(chatID), slot.StartTime)), &buttons)
 
 	teacherID := slot.TeacherID
-	teacherMsg := fmt.Sprintf("Новая запись:\n%s - %s\nУченик: @%s\nНаправление: %s",
-		formatTime(slot.StartTime),
-		formatTime(slot.EndTime),
+	teacherLang := userLang(teacherID)
+	teacherMsg := T(teacherLang, "notify.booked",
+		formatTime(teacherLang, slot.StartTime),
+		formatTime(teacherLang, slot.EndTime),
 		getUsername(chatID),
 		direction)
-	err = addNotification(teacherID, teacherMsg)
+	err = store.AddNotification(teacherID, teacherMsg)
 	if err != nil {
 		fmt.Println("Ошибка добавления уведомления о записи:", err)
 	}
@@ -1259,38 +1070,51 @@ func handleBooking(chatID int64, slotID int64) {
 
 // handlers.go
 func handleCancelBooking(chatID int64, slotID int64) {
-	slot, err := getScheduleByID(slotID)
-	if err != nil {
-		sendMessage(chatID, "Ошибка: слот не найден.")
+	slot, err := authorizeCancel(chatID, slotID)
+	if errors.Is(err, ErrForbidden) {
+		sendMessage(chatID, tr(chatID, "cancel.foreign"))
 		return
 	}
-
-	if !slot.StudentID.Valid || slot.StudentID.Int64 != chatID {
-		sendMessage(chatID, "Вы не можете отменить чужую запись.")
+	if err != nil {
+		sendAuthError(chatID, err)
 		return
 	}
 
-	err = updateScheduleStatus(slotID, "free", 0, "")
+	err = store.UpdateScheduleStatus(slotID, "free", 0, "")
 	if err != nil {
-		sendMessage(chatID, "Ошибка при отмене записи.")
+		sendMessage(chatID, tr(chatID, "cancel.error"))
 		return
 	}
+	err = store.RecordCancellation(Cancellation{
+		SlotID:      slotID,
+		TeacherID:   slot.TeacherID,
+		StudentID:   chatID,
+		StartTime:   slot.StartTime,
+		EndTime:     slot.EndTime,
+		CancelledAt: time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Println("Ошибка сохранения отмены:", err)
+	}
 
-	// Уведомляем ученика с кнопкой "Назад"
-	buttons := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("↩️ Назад к меню", "back_to_menu"),
-		),
-	)
-	sendMessageWithKeyboard(chatID, fmt.Sprintf("Запись на %s успешно отменена.", formatTime(slot.StartTime)), &buttons)
+	// Подтверждаем отмену и спрашиваем причину; ее можно не указывать
+	lang := userLang(chatID)
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "cancel.reason_skip"), cbCancelReasonSkip),
+	)}
+	prompt := T(lang, "cancel.done", formatTime(lang, slot.StartTime)) + "\n\n" + T(lang, "cancel.reason_prompt")
+	if err := askInputWithButtons(chatID, dialogCancelReason, strconv.FormatInt(slotID, 10), prompt, rows); err != nil {
+		fmt.Println("Ошибка запроса причины отмены:", err, "chatID:", chatID)
+	}
 
 	// Уведомляем учителя через систему уведомлений и обновляем меню
 	teacherID := slot.TeacherID
-	teacherMsg := fmt.Sprintf("Ученик отменил занятие:\n%s - %s\nУченик: @%s",
-		formatTime(slot.StartTime),
-		formatTime(slot.EndTime),
+	teacherLang := userLang(teacherID)
+	teacherMsg := T(teacherLang, "notify.student_cancelled",
+		formatTime(teacherLang, slot.StartTime),
+		formatTime(teacherLang, slot.EndTime),
 		getUsername(chatID))
-	err = addNotification(teacherID, teacherMsg)
+	err = store.AddNotification(teacherID, teacherMsg)
 	if err != nil {
 		fmt.Println("Ошибка добавления уведомления об отмене:", err)
 	}
@@ -1298,3 +1122,62 @@ func handleCancelBooking(chatID int64, slotID int64) {
 	// Обновляем меню учителя
 	showTeacherMenu(teacherID)
 }
+
+// Причина отмены: сохраняется в истории ученика и приходит учителю
+func saveCancelReason(chatID, slotID int64, reason string) error {
+	if err := store.SetCancellationReason(slotID, chatID, reason); err != nil {
+		return err
+	}
+	if slot, err := store.GetScheduleByID(slotID); err == nil {
+		teacherLang := userLang(slot.TeacherID)
+		sendTemporaryNotification(slot.TeacherID, T(teacherLang, "notify.cancel_reason",
+			formatTime(teacherLang, slot.StartTime), getUsername(chatID), reason))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(tr(chatID, "btn.menu"), cbMenu),
+		),
+	)
+	return sendMessageWithKeyboard(chatID, tr(chatID, "cancel.reason_saved"), &keyboard)
+}
+
+// Выбор языка интерфейса
+func showLanguageSettings(chatID int64) {
+	lang := userLang(chatID)
+	current := languageNames[lang]
+	if user, err := store.GetUser(chatID); err == nil && !user.Language.Valid {
+		current = T(lang, "language.auto") + " (" + current + ")"
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(languageNames[langRU], cbSetLanguage, langRU),
+			callbackButton(languageNames[langEN], cbSetLanguage, langEN),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "language.auto"), cbSetLanguage, languageAuto),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "language.title"), current), &keyboard)
+}
+
+// Смена языка; пустая строка — снова как в Telegram
+func handleSetLanguage(chatID int64, lang string) {
+	if err := store.SetUserLanguage(chatID, lang); err != nil {
+		fmt.Println("Ошибка смены языка:", err)
+		sendMessage(chatID, tr(chatID, "error.generic"))
+		return
+	}
+	forgetUserLang(chatID)
+	updateChatCommands(chatID)
+
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "error.user"))
+		return
+	}
+	showMenu(chatID, user)
+}
@@ -0,0 +1,791 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Домашние задания: учитель выдает задание к занятию (текст, файлы, срок),
+// ученик получает его после окончания занятия и сдает ответ текстом или файлами,
+// учитель проверяет ответ, ставит оценку и пишет комментарий.
+// Отправку и напоминания о сроке выполняет homeworkNotifications.
+
+// Статусы задания
+const (
+	homeworkDraft     = "draft"     // Учитель еще собирает задание
+	homeworkAssigned  = "assigned"  // Выдано, ждем ответа
+	homeworkSubmitted = "submitted" // Ответ отправлен на проверку
+	homeworkReviewed  = "reviewed"  // Проверено
+)
+
+const (
+	homeworkTimeout      = time.Hour      // Сколько ждать следующей части задания или ответа
+	homeworkRemindBefore = 24 * time.Hour // За сколько до срока напомнить ученику
+	homeworkListSize     = 15             // Заданий в списке
+	maxHomeworkText      = 3000
+	maxGrade             = 5
+)
+
+// Файл из сообщения: документ или фото
+func homeworkFileFromMessage(msg *tgbotapi.Message) (HomeworkFile, bool) {
+	switch {
+	case msg.Document != nil:
+		return HomeworkFile{Kind: "document", FileID: msg.Document.FileID, FileName: msg.Document.FileName}, true
+	case msg.Photo != nil && len(*msg.Photo) > 0:
+		photos := *msg.Photo
+		return HomeworkFile{Kind: "photo", FileID: photos[len(photos)-1].FileID}, true
+	}
+	return HomeworkFile{}, false
+}
+
+// Часть задания (owner = teacher) или ответа (owner = student) из сообщения:
+// текст или файл с подписью. false — сообщение не подходит
+func addHomeworkPart(hw *Homework, owner string, msg *tgbotapi.Message) (bool, error) {
+	text := msg.Text
+	file, hasFile := homeworkFileFromMessage(msg)
+	if hasFile {
+		text = msg.Caption
+	}
+	text = strings.TrimSpace(text)
+	if !hasFile && text == "" {
+		return false, nil
+	}
+
+	target := &hw.Text
+	if owner == "student" {
+		target = &hw.Answer
+	}
+	if text != "" {
+		joined := text
+		if *target != "" {
+			joined = *target + "\n\n" + text
+		}
+		if utf8.RuneCountInString(joined) > maxHomeworkText {
+			return false, nil
+		}
+		*target = joined
+	}
+	if hasFile {
+		file.HomeworkID = hw.ID
+		file.Owner = owner
+		if err := store.AddHomeworkFile(file); err != nil {
+			return false, err
+		}
+	}
+	return true, store.UpdateHomework(*hw)
+}
+
+// Количество файлов задания или ответа
+func countHomeworkFiles(files []HomeworkFile, owner string) int {
+	count := 0
+	for _, f := range files {
+		if f.Owner == owner {
+			count++
+		}
+	}
+	return count
+}
+
+// Срок сдачи прошел, а ответа нет
+func homeworkOverdue(hw *Homework) bool {
+	if hw.Status != homeworkAssigned || !hw.DueAt.Valid {
+		return false
+	}
+	due, err := time.Parse(time.RFC3339, hw.DueAt.String)
+	return err == nil && due.Before(scheduleNow())
+}
+
+func homeworkStatusText(lang string, hw *Homework) string {
+	switch {
+	case hw.Status == homeworkDraft:
+		return T(lang, "homework.status.draft")
+	case hw.Status == homeworkAssigned && !hw.SentAt.Valid:
+		return T(lang, "homework.status.waiting")
+	case homeworkOverdue(hw):
+		return T(lang, "homework.status.overdue")
+	case hw.Status == homeworkAssigned:
+		return T(lang, "homework.status.assigned")
+	case hw.Status == homeworkSubmitted:
+		return T(lang, "homework.status.submitted")
+	}
+	return T(lang, "homework.status.reviewed")
+}
+
+func homeworkIcon(hw *Homework) string {
+	switch {
+	case hw.Status == homeworkAssigned && !hw.SentAt.Valid:
+		return "⏳"
+	case homeworkOverdue(hw):
+		return "⏰"
+	case hw.Status == homeworkAssigned:
+		return "📌"
+	case hw.Status == homeworkSubmitted:
+		return "📥"
+	}
+	return "✅"
+}
+
+// Оценка звездами: ⭐⭐⭐⭐ (4/5)
+func formatGrade(grade int64) string {
+	return fmt.Sprintf("%s (%d/%d)", strings.Repeat("⭐", int(grade)), grade, maxGrade)
+}
+
+// Задание к занятию: просмотр готового или сбор нового
+func openLessonHomework(chatID, slotID int64) error {
+	slot, err := authorizeSlotOwner(chatID, slotID, "домашнее задание")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	if slot.Status != "booked" || !slot.StudentID.Valid {
+		sendMessage(chatID, tr(chatID, "homework.lesson_free"))
+		return nil
+	}
+
+	hw, err := store.GetLessonHomework(slotID)
+	if err != nil {
+		return err
+	}
+	if hw != nil && hw.Status != homeworkDraft {
+		showHomework(chatID, hw.ID)
+		return nil
+	}
+	if hw == nil {
+		id, err := store.CreateHomework(Homework{
+			SlotID:    slotID,
+			TeacherID: chatID,
+			StudentID: slot.StudentID.Int64,
+			Status:    homeworkDraft,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if hw, err = store.GetHomework(id); err != nil {
+			return err
+		}
+	} else if hw.StudentID != slot.StudentID.Int64 {
+		// Черновик остался от ученика, который отменил запись
+		hw.StudentID = slot.StudentID.Int64
+		if err := store.UpdateHomework(*hw); err != nil {
+			return err
+		}
+	}
+	return askHomeworkTask(chatID, hw, "")
+}
+
+// Запрос следующей части задания; notice — сообщение над вопросом
+func askHomeworkTask(chatID int64, hw *Homework, notice string) error {
+	lang := userLang(chatID)
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		return err
+	}
+	prompt := notice + htmlf(T(lang, "homework.task_prompt"), formatTime(lang, hw.LessonStart.String), studentName(hw.StudentID))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if n := countHomeworkFiles(files, "teacher"); hw.Text != "" || n > 0 {
+		prompt += T(lang, "homework.collected", utf8.RuneCountInString(hw.Text), n)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.to_due"), cbHomeworkDue, cbID(hw.ID)),
+		))
+	}
+	return askInputWithButtons(chatID, dialogHomeworkTask, strconv.FormatInt(hw.ID, 10), prompt, rows)
+}
+
+// Черновик задания учителя из данных шага диалога или кнопки
+func teacherDraft(chatID, homeworkID int64, action string) (*Homework, error) {
+	hw, err := authorizeHomework(chatID, homeworkID, action)
+	if err != nil {
+		return nil, err
+	}
+	if hw.TeacherID != chatID || hw.Status != homeworkDraft {
+		return nil, deny(chatID, action, fmt.Sprintf("задание %d не черновик учителя", homeworkID))
+	}
+	return hw, nil
+}
+
+// Запрос срока сдачи: кнопки с готовыми сроками или ввод даты
+func askHomeworkDue(chatID, homeworkID int64, notice string) error {
+	lang := userLang(chatID)
+	hw, err := teacherDraft(chatID, homeworkID, "срок домашнего задания")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	id := cbID(hw.ID)
+	base := homeworkDueBase(hw)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if next := nextLessonStart(hw); !next.IsZero() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.due_next", formatTime(lang, next.Format(time.RFC3339))), cbHomeworkSetDue, id, cbID(next.Unix())),
+		))
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	for _, days := range []int{1, 3, 7} {
+		due := endOfDay(base.AddDate(0, 0, days))
+		row = append(row, callbackButton(formatDate(lang, due), cbHomeworkSetDue, id, cbID(due.Unix())))
+	}
+	rows = append(rows, row)
+	return askInputWithButtons(chatID, dialogHomeworkDue, strconv.FormatInt(hw.ID, 10), notice+T(lang, "homework.due_prompt"), rows)
+}
+
+// Срок не раньше окончания занятия и текущего момента
+func homeworkDueBase(hw *Homework) time.Time {
+	base := scheduleNow()
+	if end, err := time.Parse(time.RFC3339, hw.LessonEnd.String); err == nil && end.After(base) {
+		base = end
+	}
+	return base
+}
+
+// Начало следующего занятия ученика у этого учителя; нулевое время — занятий нет
+func nextLessonStart(hw *Homework) time.Time {
+	lessons, err := store.GetStudentLessons(hw.TeacherID, hw.StudentID)
+	if err != nil {
+		fmt.Println("Ошибка получения занятий ученика:", err)
+		return time.Time{}
+	}
+	base := homeworkDueBase(hw)
+	var next time.Time
+	for _, l := range lessons {
+		start, err := time.Parse(time.RFC3339, l.StartTime)
+		if err != nil || !start.After(base) {
+			continue
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// Конец дня (время хранится как в слотах — в UTC)
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 0, 0, time.UTC)
+}
+
+// Срок, введенный текстом: "завтра", "25.10", "пт 18:00", "2025-03-10 20:00"
+func parseHomeworkDue(text string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, fmt.Errorf("неверный срок: %s", text)
+	}
+	day, err := parseCommandDay(fields[0], now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(fields) == 1 {
+		return endOfDay(day), nil
+	}
+	hour, minute, err := parseClock(fields[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.UTC), nil
+}
+
+// Выдача задания с выбранным сроком
+func setHomeworkDue(chatID, homeworkID int64, due time.Time) error {
+	hw, err := teacherDraft(chatID, homeworkID, "срок домашнего задания")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	if !due.After(homeworkDueBase(hw)) {
+		return askHomeworkDue(chatID, homeworkID, tr(chatID, "homework.due_past"))
+	}
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		return err
+	}
+	if hw.Text == "" && countHomeworkFiles(files, "teacher") == 0 {
+		return askHomeworkTask(chatID, hw, tr(chatID, "homework.task_empty"))
+	}
+
+	hw.DueAt = sql.NullString{String: due.UTC().Format(time.RFC3339), Valid: true}
+	hw.Status = homeworkAssigned
+	if err := store.UpdateHomework(*hw); err != nil {
+		return err
+	}
+	showHomework(chatID, hw.ID)
+	return nil
+}
+
+// Задание для учителя или ученика: текст, срок, ответ и проверка
+func showHomework(chatID, homeworkID int64) {
+	lang := userLang(chatID)
+	hw, err := authorizeHomework(chatID, homeworkID, "просмотр домашнего задания")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		fmt.Println("Ошибка получения файлов задания:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	teacher := hw.TeacherID == chatID
+	taskFiles := countHomeworkFiles(files, "teacher")
+	answerFiles := countHomeworkFiles(files, "student")
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "homework.title"))
+	builder.WriteString(T(lang, "homework.lesson", formatTime(lang, hw.LessonStart.String)))
+	if teacher {
+		builder.WriteString(htmlf(T(lang, "homework.student"), studentName(hw.StudentID)))
+	}
+	builder.WriteString(T(lang, "homework.status", homeworkStatusText(lang, hw)))
+	if hw.DueAt.Valid {
+		builder.WriteString(T(lang, "homework.due", formatTime(lang, hw.DueAt.String)))
+	}
+	builder.WriteString(T(lang, "homework.task"))
+	if hw.Text != "" {
+		builder.WriteString(escapeHTML(hw.Text) + "\n")
+	}
+	if taskFiles > 0 {
+		builder.WriteString(T(lang, "homework.files", taskFiles))
+	}
+
+	if hw.SubmittedAt.Valid {
+		builder.WriteString(T(lang, "homework.answer", formatTime(lang, hw.SubmittedAt.String)))
+		if hw.Answer != "" {
+			builder.WriteString(escapeHTML(hw.Answer) + "\n")
+		}
+		if answerFiles > 0 {
+			builder.WriteString(T(lang, "homework.files", answerFiles))
+		}
+	}
+	if hw.Status == homeworkReviewed && hw.Grade.Valid {
+		builder.WriteString(T(lang, "homework.grade", formatGrade(hw.Grade.Int64)))
+		if hw.Comment != "" {
+			builder.WriteString(htmlf(T(lang, "homework.comment"), hw.Comment))
+		}
+	}
+
+	id := cbID(hw.ID)
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var fileRow []tgbotapi.InlineKeyboardButton
+	if taskFiles > 0 {
+		fileRow = append(fileRow, callbackButton(T(lang, "homework.task_files", taskFiles), cbHomeworkFiles, id, "teacher"))
+	}
+	if answerFiles > 0 && hw.SubmittedAt.Valid {
+		fileRow = append(fileRow, callbackButton(T(lang, "homework.answer_files", answerFiles), cbHomeworkFiles, id, "student"))
+	}
+	if len(fileRow) > 0 {
+		buttons = append(buttons, fileRow)
+	}
+
+	if teacher {
+		switch hw.Status {
+		case homeworkDraft:
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "homework.continue"), cbLessonHomework, cbID(hw.SlotID)),
+			))
+		case homeworkSubmitted, homeworkReviewed:
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "homework.review"), cbHomeworkReview, id),
+			))
+		}
+		if hw.Status == homeworkDraft || hw.Status == homeworkAssigned {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "homework.delete"), cbHomeworkDelete, id),
+			))
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.back_lesson"), cbLesson, cbID(hw.SlotID)),
+			callbackButton(T(lang, "homework.all"), cbHomeworkList),
+		))
+	} else {
+		if hw.Status == homeworkAssigned {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "homework.submit"), cbHomeworkSubmit, id),
+			))
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.all"), cbHomeworkList),
+		))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Файлы задания (owner = teacher) или ответа (owner = student)
+func sendHomeworkFiles(chatID, homeworkID int64, owner string) {
+	hw, err := authorizeHomework(chatID, homeworkID, "файлы домашнего задания")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		fmt.Println("Ошибка получения файлов задания:", err)
+		sendMessage(chatID, tr(chatID, "error.generic"))
+		return
+	}
+	for _, f := range files {
+		if f.Owner != owner {
+			continue
+		}
+		// Файлы не удаляем следующим сообщением бота, как и медиа заметок
+		var media tgbotapi.Chattable = tgbotapi.NewDocumentShare(chatID, f.FileID)
+		if f.Kind == "photo" {
+			media = tgbotapi.NewPhotoShare(chatID, f.FileID)
+		}
+		if _, err := messenger.Send(media); err != nil {
+			fmt.Println("Ошибка отправки файла задания:", err, "chatID:", chatID)
+		}
+	}
+}
+
+// Удаление невыполненного задания
+func deleteHomework(chatID, homeworkID int64) error {
+	const action = "удаление домашнего задания"
+	hw, err := authorizeHomework(chatID, homeworkID, action)
+	if err == nil && (hw.TeacherID != chatID || (hw.Status != homeworkDraft && hw.Status != homeworkAssigned)) {
+		err = deny(chatID, action, fmt.Sprintf("задание %d нельзя удалить", homeworkID))
+	}
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	if err := store.DeleteHomework(hw.ID); err != nil {
+		return err
+	}
+	showLesson(chatID, hw.SlotID)
+	return nil
+}
+
+// Список заданий: у учителя сначала ждущие проверки, у ученика — несданные
+func showHomeworkList(chatID int64, user *User) {
+	lang := userLang(chatID)
+	teacher := user.Role == "teacher"
+	var list []Homework
+	var err error
+	if teacher {
+		list, err = store.GetTeacherHomework(chatID)
+	} else {
+		list, err = store.GetStudentHomework(chatID)
+	}
+	if err != nil {
+		fmt.Println("Ошибка получения домашних заданий:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	// Порядок статусов в списке
+	first := homeworkSubmitted
+	if !teacher {
+		first = homeworkAssigned
+	}
+	rank := func(hw *Homework) int {
+		switch hw.Status {
+		case first:
+			return 0
+		case homeworkReviewed:
+			return 2
+		}
+		return 1
+	}
+	sort.SliceStable(list, func(i, j int) bool { return rank(&list[i]) < rank(&list[j]) })
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "homework.list_title", len(list)))
+	if len(list) == 0 {
+		builder.WriteString(T(lang, "homework.list_empty"))
+	}
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for i := range list {
+		if i == homeworkListSize {
+			break
+		}
+		hw := &list[i]
+		label := homeworkIcon(hw) + " " + formatTime(lang, hw.LessonStart.String)
+		if teacher {
+			label += " — " + studentName(hw.StudentID)
+		} else if hw.DueAt.Valid && hw.Status == homeworkAssigned {
+			label += " — " + T(lang, "homework.until", formatTime(lang, hw.DueAt.String))
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(label, cbHomework, cbID(hw.ID)),
+		))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// /homework
+func handleHomeworkCommand(chatID int64) {
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "error.user"))
+		return
+	}
+	showHomeworkList(chatID, user)
+}
+
+// Задание ученика, которое можно сдавать
+func studentAssignment(chatID, homeworkID int64, action string) (*Homework, error) {
+	hw, err := authorizeHomework(chatID, homeworkID, action)
+	if err != nil {
+		return nil, err
+	}
+	if hw.StudentID != chatID || hw.Status != homeworkAssigned {
+		return nil, deny(chatID, action, fmt.Sprintf("задание %d нельзя сдать", homeworkID))
+	}
+	return hw, nil
+}
+
+// Запрос следующей части ответа
+func askHomeworkAnswer(chatID int64, hw *Homework, notice string) error {
+	lang := userLang(chatID)
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		return err
+	}
+	prompt := notice + T(lang, "homework.answer_prompt")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if n := countHomeworkFiles(files, "student"); hw.Answer != "" || n > 0 {
+		prompt += T(lang, "homework.collected", utf8.RuneCountInString(hw.Answer), n)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.send"), cbHomeworkSend, cbID(hw.ID)),
+		))
+	}
+	return askInputWithButtons(chatID, dialogHomeworkAnswer, strconv.FormatInt(hw.ID, 10), prompt, rows)
+}
+
+// Отправка ответа на проверку
+func submitHomework(chatID, homeworkID int64) error {
+	hw, err := studentAssignment(chatID, homeworkID, "сдача домашнего задания")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		return err
+	}
+	if hw.Answer == "" && countHomeworkFiles(files, "student") == 0 {
+		return askHomeworkAnswer(chatID, hw, tr(chatID, "homework.answer_empty"))
+	}
+
+	hw.Status = homeworkSubmitted
+	hw.SubmittedAt = sql.NullString{String: time.Now().Format(time.RFC3339), Valid: true}
+	if err := store.UpdateHomework(*hw); err != nil {
+		return err
+	}
+
+	teacherLang := userLang(hw.TeacherID)
+	sendTemporaryNotification(hw.TeacherID, T(teacherLang, "homework.notify_submitted",
+		studentName(hw.StudentID), formatTime(teacherLang, hw.LessonStart.String)))
+	showHomework(chatID, hw.ID)
+	return nil
+}
+
+// Выбор оценки
+func showHomeworkGrades(chatID, homeworkID int64) {
+	lang := userLang(chatID)
+	hw, err := reviewableHomework(chatID, homeworkID)
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	id := cbID(hw.ID)
+	var row []tgbotapi.InlineKeyboardButton
+	for grade := 1; grade <= maxGrade; grade++ {
+		row = append(row, callbackButton(strconv.Itoa(grade)+"⭐", cbHomeworkGrade, id, strconv.Itoa(grade)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.back"), cbHomework, id),
+		),
+	)
+	sendMessageWithKeyboard(chatID, htmlf(T(lang, "homework.grade_prompt"), studentName(hw.StudentID)), &keyboard)
+}
+
+// Сданное задание учителя; проверенное можно оценить заново
+func reviewableHomework(chatID, homeworkID int64) (*Homework, error) {
+	const action = "проверка домашнего задания"
+	hw, err := authorizeHomework(chatID, homeworkID, action)
+	if err != nil {
+		return nil, err
+	}
+	if hw.TeacherID != chatID || (hw.Status != homeworkSubmitted && hw.Status != homeworkReviewed) {
+		return nil, deny(chatID, action, fmt.Sprintf("задание %d не ждет проверки", homeworkID))
+	}
+	return hw, nil
+}
+
+// Запрос комментария к оценке; notice — сообщение над вопросом
+func askHomeworkComment(chatID, homeworkID int64, grade int, notice string) error {
+	if _, err := reviewableHomework(chatID, homeworkID); err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	lang := userLang(chatID)
+	id := cbID(homeworkID)
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "homework.no_comment"), cbHomeworkNoComment, id, strconv.Itoa(grade)),
+	)}
+	data := fmt.Sprintf("%d:%d", homeworkID, grade)
+	return askInputWithButtons(chatID, dialogHomeworkComment, data, notice+T(lang, "homework.comment_prompt", formatGrade(int64(grade))), rows)
+}
+
+// Оценка и комментарий: сохраняем и сообщаем ученику
+func reviewHomework(chatID, homeworkID int64, grade int, comment string) error {
+	hw, err := reviewableHomework(chatID, homeworkID)
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	if grade < 1 || grade > maxGrade {
+		return fmt.Errorf("неверная оценка: %d", grade)
+	}
+	hw.Status = homeworkReviewed
+	hw.Grade = sql.NullInt64{Int64: int64(grade), Valid: true}
+	hw.Comment = comment
+	hw.ReviewedAt = sql.NullString{String: time.Now().Format(time.RFC3339), Valid: true}
+	if err := store.UpdateHomework(*hw); err != nil {
+		return err
+	}
+
+	studentLang := userLang(hw.StudentID)
+	text := T(studentLang, "homework.notify_reviewed", formatTime(studentLang, hw.LessonStart.String), formatGrade(hw.Grade.Int64))
+	if comment != "" {
+		text += htmlf(T(studentLang, "homework.comment"), comment)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(studentLang, "homework.open"), cbHomework, cbID(hw.ID)),
+			callbackButton(T(studentLang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(hw.StudentID, text, &keyboard)
+
+	showHomework(chatID, hw.ID)
+	return nil
+}
+
+// Кнопки под заданием в сообщении ученику
+func homeworkStudentKeyboard(lang string, hw *Homework) *tgbotapi.InlineKeyboardMarkup {
+	id := cbID(hw.ID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.submit"), cbHomeworkSubmit, id),
+			callbackButton(T(lang, "homework.open"), cbHomework, id),
+		),
+	)
+	return &keyboard
+}
+
+// Задание ученику после окончания занятия: файлы и текст с кнопкой сдачи
+func sendHomeworkToStudent(hw Homework) {
+	lang := userLang(hw.StudentID)
+	files, err := store.GetHomeworkFiles(hw.ID)
+	if err != nil {
+		fmt.Println("Ошибка получения файлов задания:", err)
+	}
+	for _, f := range files {
+		if f.Owner != "teacher" {
+			continue
+		}
+		var media tgbotapi.Chattable = tgbotapi.NewDocumentShare(hw.StudentID, f.FileID)
+		if f.Kind == "photo" {
+			media = tgbotapi.NewPhotoShare(hw.StudentID, f.FileID)
+		}
+		if _, err := messenger.Send(media); err != nil {
+			fmt.Println("Ошибка отправки файла задания:", err, "studentID:", hw.StudentID)
+		}
+	}
+	text := T(lang, "homework.new", formatTime(lang, hw.LessonStart.String), formatTime(lang, hw.DueAt.String))
+	if hw.Text != "" {
+		text += escapeHTML(hw.Text)
+	}
+	sendMessageWithKeyboard(hw.StudentID, text, homeworkStudentKeyboard(lang, &hw))
+}
+
+// Напоминание ученику о сроке сдачи
+func remindHomework(hw Homework) {
+	lang := userLang(hw.StudentID)
+	text := T(lang, "homework.remind", formatTime(lang, hw.LessonStart.String), formatTime(lang, hw.DueAt.String))
+	sendMessageWithKeyboard(hw.StudentID, text, homeworkStudentKeyboard(lang, &hw))
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogHomeworkTask, Role: "teacher", Timeout: homeworkTimeout, Handle: func(c *DialogContext) error {
+		homeworkID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		hw, err := teacherDraft(c.ChatID, homeworkID, "домашнее задание")
+		if err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		ok, err := addHomeworkPart(hw, "teacher", c.Msg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return askHomeworkTask(c.ChatID, hw, tr(c.ChatID, "homework.part_bad"))
+		}
+		return askHomeworkTask(c.ChatID, hw, "")
+	}})
+	registerDialogStep(DialogStep{Name: dialogHomeworkDue, Role: "teacher", Timeout: homeworkTimeout, Handle: func(c *DialogContext) error {
+		homeworkID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		due, err := parseHomeworkDue(c.Text(), scheduleNow())
+		if err != nil {
+			return askHomeworkDue(c.ChatID, homeworkID, tr(c.ChatID, "homework.due_bad"))
+		}
+		return setHomeworkDue(c.ChatID, homeworkID, due)
+	}})
+	registerDialogStep(DialogStep{Name: dialogHomeworkAnswer, Role: "student", Timeout: homeworkTimeout, Handle: func(c *DialogContext) error {
+		homeworkID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		hw, err := studentAssignment(c.ChatID, homeworkID, "сдача домашнего задания")
+		if err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		ok, err := addHomeworkPart(hw, "student", c.Msg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return askHomeworkAnswer(c.ChatID, hw, tr(c.ChatID, "homework.part_bad"))
+		}
+		return askHomeworkAnswer(c.ChatID, hw, "")
+	}})
+	registerDialogStep(DialogStep{Name: dialogHomeworkComment, Role: "teacher", Timeout: homeworkTimeout, Handle: func(c *DialogContext) error {
+		var homeworkID int64
+		var grade int
+		if _, err := fmt.Sscanf(c.Data, "%d:%d", &homeworkID, &grade); err != nil {
+			return fmt.Errorf("неверные данные шага: %s", c.Data)
+		}
+		comment := c.Text()
+		if comment == "" || utf8.RuneCountInString(comment) > maxNoteLength {
+			return askHomeworkComment(c.ChatID, homeworkID, grade, tr(c.ChatID, "homework.comment_bad"))
+		}
+		return reviewHomework(c.ChatID, homeworkID, grade, comment)
+	}})
+}
@@ -0,0 +1,1101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Языки интерфейса
+const (
+	langRU      = "ru"
+	langEN      = "en"
+	defaultLang = langRU
+)
+
+// Выбор языка "как в Telegram" в настройках
+const languageAuto = "auto"
+
+// Названия языков в настройках (каждый на своем языке)
+var languageNames = map[string]string{
+	langRU: "Русский",
+	langEN: "English",
+}
+
+// Каталог сообщений: язык -> ключ -> текст (формат для fmt.Sprintf или htmlf)
+var catalog = map[string]map[string]string{
+	langRU: {
+		// Общее
+		"error.generic":     "Произошла ошибка. Попробуйте позже.",
+		"error.register":    "Ошибка регистрации. Попробуйте снова.",
+		"error.user":        "Ошибка получения данных пользователя.",
+		"btn.menu":          "↩️ Назад в меню",
+		"btn.calendar":      "📅 Вернуться к календарю",
+		"btn.to_calendar":   "↩️ К календарю",
+		"btn.prev_month":    "⬅️ Предыдущий месяц",
+		"btn.next_month":    "➡️ Следующий месяц",
+		"btn.delete":        "🗑️ Удалить",
+		"btn.cancel":        "❌ Отмена",
+		"btn.language":      "🌐 Язык",
+		"teacher.only":      "❌ Эта команда доступна только учителям",
+		"auth.forbidden":    "⛔ Недостаточно прав для этого действия.",
+		"auth.failed":       "Не удалось выполнить действие: запись не найдена или уже изменена.",
+		"callback.stale":    "Кнопка устарела, открываю меню.",
+		"callback.denied":   "Действие недоступно.",
+		"callback.bad_data": "Ошибка: неверные данные кнопки.",
+
+		// Меню
+		"menu.teacher":               "👨‍🏫 <b>Меню учителя</b>\nВыберите действие:\n📬 Уведомления: %d",
+		"menu.teacher.schedule":      "📅 Управление расписанием",
+		"menu.teacher.students":      "👥 Просмотр учеников",
+		"menu.teacher.add_slot":      "➕ Добавить слот",
+		"menu.teacher.notifications": "📬 Уведомления (%d)",
+		"menu.ical":                  "📆 Подписка на календарь",
+		"menu.ext_calendars":         "🗓 Внешние календари",
+		"menu.student":               "👨‍🎓 <b>Меню ученика</b>\nВыберите действие:",
+		"menu.student.book":          "📅 Записаться на занятие",
+		"menu.student.bookings":      "🗓 Мои записи",
+		"menu.student.cancel":        "❌ Отменить запись",
+
+		// Язык
+		"language.title": "🌐 Язык интерфейса: %s\nВыберите язык:",
+		"language.auto":  "Как в Telegram",
+
+		// Диалоги
+		"dialog.cancelled": "Ввод отменен.",
+		"dialog.expired":   "⌛ Время ожидания ответа истекло. Начните заново из меню.",
+
+		// Уведомления учителя
+		"notifications.error":       "Ошибка получения уведомлений.",
+		"notifications.empty":       "📬 У вас нет уведомлений.",
+		"notifications.title":       "📬 <b>Ваши уведомления:</b>\n",
+		"notifications.mark_read":   "Отметить прочитанным: %s",
+		"notifications.mark_error":  "Ошибка отметки уведомления как прочитанного.",
+		"notifications.clear":       "🗑️ Очистить все",
+		"notifications.clear_error": "Ошибка очистки уведомлений.",
+		"notifications.cleared":     "📬 Уведомления очищены.",
+
+		// Расписание и слоты
+		"schedule.error":      "❌ Ошибка загрузки расписания",
+		"schedule.empty":      "📭 Расписание пусто",
+		"schedule.title":      "📅 <b>Ваше расписание:</b>\n\n",
+		"schedule.item":       "⏰ #%d %s - %s\n🔄 Статус: %s\n",
+		"status.booked":       "✅ Занят",
+		"status.free":         "🆓 Свободен",
+		"slot.delete":         "🗑️ Удалить слот",
+		"slot.delete_error":   "Ошибка удаления слота.",
+		"slot.deleted":        "✅ Слот успешно удален",
+		"slot.choose_delete":  "Выберите слот для удаления:",
+		"slot.past":           "Нельзя добавить слот на прошедшее время.",
+		"slot.exists":         "Слот %s - %s уже занят. Что делать?",
+		"slot.external_busy":  "⛔ Это время занято в вашем внешнем календаре.",
+		"slot.add_error":      "Ошибка добавления слота.",
+		"slot.added":          "Слот успешно добавлен: %s - %s",
+		"slot.custom_time":    "🕒 Другое время",
+		"slot.ask_time":       "Введите время начала слота на %s в формате ЧЧ:ММ, например 18:30.\n/cancel — отмена.",
+		"slot.bad_time":       "Не удалось разобрать время. Введите его в формате ЧЧ:ММ, например 18:30.",
+		"calendar.slot":       "Выберите дату для добавления слота:",
+		"calendar.slot_month": "Календарь %s %d для добавления слота:",
+		"calendar.book":       "Выберите дату для записи на занятие:",
+		"calendar.book_month": "Календарь %s %d для записи на занятие:",
+		"date.error":          "Ошибка обработки даты.",
+		"slots.error":         "Ошибка получения слотов.",
+		"slots.hours":         "🕒 <b>Доступные часы на %s:</b>\n\n🟩 - Свободно\n🟥 - Занято\n⛔ - Занято во внешнем календаре",
+		"slots.free":          "✅ <b>Свободные слоты на %s:</b>\n\nВыберите удобное время:",
+
+		// Слоты текстом
+		"slottext.help":       "Не удалось разобрать слоты: %s\n\nПримеры:\n• пн 18:00-19:30\n• завтра 10-12 по 45 мин\n• каждую среду 17:00\n• 21.10 с 9 до 11",
+		"slottext.preview":    "🗓 Слоты по запросу «%s»:\n\n",
+		"slottext.confirm":    "✅ Добавить %d",
+		"slottext.nothing":    "\nДобавить нечего.",
+		"slottext.added":      "✅ Добавлено слотов: %d из %d\n",
+		"slottext.expired":    "Нет слотов для добавления. Отправьте их текстом еще раз.",
+		"slottext.past":       "время уже прошло",
+		"slottext.exists":     "слот уже есть",
+		"slottext.busy":       "занято во внешнем календаре",
+		"slottext.failed":     "не удалось добавить",
+		"slottext.empty":      "пустое сообщение",
+		"slottext.unknown":    "непонятное слово «%s»",
+		"slottext.no_time":    "не указано время",
+		"slottext.bad_time":   "неверное время %s",
+		"slottext.bad_date":   "неверная дата %s",
+		"slottext.bad_range":  "конец раньше начала",
+		"slottext.bad_length": "неверная длина слота %s",
+		"slottext.two_days":   "указано несколько дней",
+		"slottext.no_weekday": "для повторения укажите день недели",
+		"slottext.too_many":   "слишком много слотов, не больше %s за раз",
+
+		// Команды
+		"cmd.start":           "Главное меню",
+		"cmd.book":            "Записаться: /book 2025-03-10 18:00",
+		"cmd.free":            "Свободное время: /free завтра",
+		"cmd.free_teacher":    "Слоты на день: /free завтра",
+		"cmd.today":           "Занятия на сегодня",
+		"cmd.mybookings":      "Мои записи",
+		"cmd.cancel":          "Отменить запись",
+		"cmd.cancel_input":    "Отменить ввод",
+		"cmd.calendar_link":   "Подписка на календарь",
+		"cmd.language":        "Язык интерфейса",
+		"cmd.schedule":        "Расписание",
+		"cmd.addslot":         "Добавить слот: /addslot 2025-03-10 18:00 90m",
+		"cmd.delslot":         "Удалить слот по номеру: /delslot 12",
+		"cmd.students":        "Ученики: /students [поиск]",
+		"cmd.calendars":       "Внешние календари",
+		"cmd.add_calendar":    "Подключить внешний календарь",
+		"cmd.export":          "Выгрузка расписания в CSV",
+		"cmd.export_students": "Выгрузка учеников в CSV",
+		"usage.addslot":       "Использование: /addslot ДАТА ВРЕМЯ [ДЛИТЕЛЬНОСТЬ]\nНапример: /addslot 2025-03-10 18:00 90m\n\nДата: 2025-03-10, 10.03, завтра или пн. Длительность: 90m, 1h30m или 45 (минут), по умолчанию 1 час.",
+		"usage.free":          "Использование: /free [ДАТА]\nНапример: /free завтра, /free 10.03, /free пт\n\nБез даты — на сегодня.",
+		"usage.book":          "Использование: /book ДАТА ВРЕМЯ [@учитель]\nНапример: /book 2025-03-10 18:00\n\nБез аргументов — выбор даты в календаре.",
+		"usage.delslot":       "Использование: /delslot НОМЕР\nНапример: /delslot 12\n\nНомера слотов показаны в /schedule и /today.",
+		"command.bad_date":    "❌ Не удалось разобрать дату «%s».",
+		"command.bad_time":    "❌ Не удалось разобрать время «%s».",
+		"command.bad_length":  "❌ Неверная длительность «%s»: от 5 минут до 24 часов.",
+		"command.bad_id":      "❌ Неверный номер слота «%s».",
+		"today.title":         "📅 <b>Занятия на сегодня, %s:</b>\n\n",
+		"today.empty":         "📭 На сегодня (%s) занятий нет.",
+		"today.slot":          "⏰ #%d %s - %s %s\n",
+
+		// Ученики
+		"students.error":       "Ошибка получения списка учеников.",
+		"students.empty":       "У вас пока нет учеников.",
+		"students.title":       "👥 <b>Ваши ученики (%d):</b>\n",
+		"delivery.blocked":     "⚠️ не получает сообщения: бот заблокирован",
+		"delivery.deactivated": "⚠️ не получает сообщения: аккаунт удален",
+
+		// Анкета ученика
+		"profile.welcome":          "👋 Добро пожаловать! Давайте познакомимся — это займет минуту.\n\n",
+		"profile.ask_name":         "Как к вам обращаться?",
+		"profile.bad_name":         "Напишите, пожалуйста, имя текстом (до 100 символов).",
+		"profile.ask_phone":        "📱 Поделитесь номером телефона кнопкой ниже — он нужен учителю для связи. Номер можно ввести вручную или пропустить этот шаг.",
+		"profile.share_phone":      "📱 Отправить номер",
+		"profile.skip":             "Пропустить",
+		"profile.bad_phone":        "Не похоже на номер телефона. Нажмите «📱 Отправить номер», введите номер вида +7 999 123-45-67 или пропустите шаг.",
+		"profile.foreign_contact":  "Это чужой контакт. Отправьте, пожалуйста, свой номер кнопкой «📱 Отправить номер».",
+		"profile.thanks":           "Спасибо!",
+		"profile.ask_level":        "📚 Какой у вас уровень английского?",
+		"profile.bad_level":        "Выберите уровень кнопкой ниже.",
+		"profile.ask_goals":        "🎯 Для чего вам английский? Напишите пару слов о целях: работа, переезд, экзамен, путешествия…",
+		"profile.bad_goals":        "Напишите цели текстом (до 500 символов) или нажмите «Пропустить».",
+		"profile.ask_direction":    "🧭 Какое направление занятий вам ближе? Выберите кнопкой или напишите свой вариант.",
+		"profile.bad_direction":    "Выберите направление кнопкой или напишите его текстом (до 100 символов).",
+		"profile.done":             "✅ Анкета заполнена, спасибо!\n\n",
+		"profile.title":            "👤 <b>Ваша анкета</b>\n\n",
+		"profile.empty":            "Анкета еще не заполнена.",
+		"profile.edit":             "✏️ Заполнить заново",
+		"profile.fill":             "📝 Заполнить анкету",
+		"profile.none":             "—",
+		"profile.unfinished":       "⏳ Анкета заполнена не до конца\n",
+		"profile.new_student":      "🆕 Новый ученик: %s, уровень %s",
+		"profile.field.name":       "Имя: %s\n",
+		"profile.field.username":   "Telegram: @%s\n",
+		"profile.field.phone":      "Телефон: %s\n",
+		"profile.field.level":      "Уровень: %s\n",
+		"profile.field.goals":      "Цели: %s\n",
+		"profile.field.direction":  "Направление: %s\n",
+		"level.A1":                 "A1 — Beginner",
+		"level.A2":                 "A2 — Elementary",
+		"level.B1":                 "B1 — Intermediate",
+		"level.B2":                 "B2 — Upper-Intermediate",
+		"level.C1":                 "C1 — Advanced",
+		"level.C2":                 "C2 — Proficiency",
+		"level.unknown":            "🤷 Не знаю",
+		"direction.general":        "Общий английский",
+		"direction.speaking":       "Разговорный",
+		"direction.business":       "Деловой английский",
+		"direction.exams":          "Подготовка к экзаменам",
+		"direction.travel":         "Для путешествий",
+		"menu.student.profile":     "👤 Анкета",
+		"cmd.profile":              "Моя анкета",
+		"students.open_card":       "👤 %s",
+		"students.card":            "👤 <b>Карточка ученика</b>\n\n",
+		"students.not_found":       "Ученик не найден.",
+		"students.search_query":    "🔍 Поиск: «%s»\n",
+		"students.not_found_query": "\nНикого не нашлось.",
+		"students.counts":          " · 📅 %d · ✔️ %d · ❌ %d",
+		"students.blocked":         "🚫 Запись на занятия запрещена\n",
+		"students.upcoming":        "\n<b>Предстоящие занятия (%d):</b>\n",
+		"students.past":            "\n<b>Прошедшие занятия (%d):</b>\n",
+		"students.attendance":      "\n📊 Посещаемость: %d из %d (%d%%)\n",
+		"students.cancellations":   "❌ Отмен: %d\n",
+		"students.last_cancel":     "Последняя отмена: занятие %s\n",
+		"students.cancel_reason":   "Причина: %s\n",
+		"students.notes":           "\n<b>Заметки (%d):</b>\n",
+		"students.message":         "✉️ Написать",
+		"students.book":            "📅 Записать",
+		"students.add_note":        "📝 Заметка",
+		"students.block":           "🚫 Заблокировать",
+		"students.unblock":         "✅ Разблокировать",
+		"students.search":          "🔍 Поиск",
+		"students.search_reset":    "✖️ Сбросить поиск",
+		"students.search_prompt":   "Введите имя, @username или телефон ученика.",
+		"students.message_prompt":  "Напишите сообщение для ученика %s — бот перешлет его от вашего имени.",
+		"students.message_bad":     "Напишите сообщение текстом (до 2000 символов).",
+		"students.message_from":    "✉️ <b>Сообщение от учителя:</b>\n\n",
+		"students.message_failed":  "Не удалось доставить сообщение ученику.",
+		"students.timeline":        "🕓 История",
+		"students.book_choose":     "Выберите свободный слот для ученика %s:",
+		"students.book_no_slots":   "Нет свободных слотов. Сначала добавьте слот в расписание.",
+		"students.back_to_card":    "↩️ К карточке",
+		"students.booked":          "📅 Учитель записал вас на занятие %s.",
+		"btn.students":             "↩️ К ученикам",
+
+		// Заметки и занятия
+		"notes.student_prompt":   "Пришлите заметку об ученике %s: текст, голосовое или фото. Ее видите только вы.",
+		"notes.lesson_prompt":    "Пришлите заметку к занятию %s (%s): текст, голосовое или фото. Ее видите только вы.",
+		"notes.bad":              "Пришлите текст (до 1000 символов), голосовое или фото.",
+		"notes.lesson_free":      "На этот слот никто не записан — заметку к нему не добавить.",
+		"notes.lesson_title":     "📖 <b>Занятие</b>\n\n",
+		"notes.lesson_status":    "Статус: %s\n",
+		"notes.lesson_student":   "Ученик: %s\n",
+		"notes.lesson_direction": "Направление: %s\n",
+		"notes.none":             "\nЗаметок к занятию нет.",
+		"notes.title":            "\n<b>Заметки (%d):</b>\n",
+		"notes.lesson_suffix":    " (занятие %s)",
+		"notes.kind.text":        "заметка",
+		"notes.kind.voice":       "голосовое",
+		"notes.kind.photo":       "фото",
+		"notes.show_media":       "%s %s",
+		"notes.media_error":      "Не удалось отправить файл заметки.",
+		"notes.add_lesson":       "📝 Заметка",
+		"notes.student_card":     "👤 Ученик",
+		"notes.back_schedule":    "↩️ К расписанию",
+		"notes.timeline_title":   "🕓 <b>История ученика %s</b>\n\n",
+		"notes.timeline_empty":   "Пока ничего нет.",
+		"notes.timeline_lesson":  "%s Занятие %s (%s)\n",
+		"notes.timeline_cancel":  "❌ %s — отмена занятия %s\n",
+
+		// Домашние задания
+		"menu.homework":             "📚 Домашние задания",
+		"cmd.homework":              "Домашние задания",
+		"cmd.homework_teacher":      "Домашние задания учеников",
+		"homework.status.draft":     "черновик",
+		"homework.status.waiting":   "ученик получит после занятия",
+		"homework.status.assigned":  "нужно сдать",
+		"homework.status.overdue":   "срок прошел",
+		"homework.status.submitted": "сдано, ждет проверки",
+		"homework.status.reviewed":  "проверено",
+		"homework.lesson_button":    "📚 Домашнее задание",
+		"homework.lesson_status":    "%s Домашнее задание: %s\n",
+		"homework.lesson_free":      "На этот слот никто не записан — задание к нему не выдать.",
+		"homework.task_prompt":      "📚 Домашнее задание к занятию %s (%s).\n\nПришлите текст задания, документы или фото — можно несколькими сообщениями. Затем нажмите «Дальше» и выберите срок.",
+		"homework.collected":        "\n\nДобавлено: текст — %d симв., файлов — %d.",
+		"homework.to_due":           "➡️ Дальше: срок сдачи",
+		"homework.task_empty":       "Задание пустое — добавьте текст или файл.\n\n",
+		"homework.part_bad":         "Пришлите текст, документ или фото (всего до 3000 символов).\n\n",
+		"homework.due_prompt":       "До какого срока сдать задание? Выберите кнопкой или напишите дату: «завтра», «25.10», «пт 18:00».",
+		"homework.due_next":         "📅 К следующему занятию (%s)",
+		"homework.due_past":         "Срок должен быть позже окончания занятия.\n\n",
+		"homework.due_bad":          "Не удалось разобрать срок.\n\n",
+		"homework.title":            "📚 <b>Домашнее задание</b>\n\n",
+		"homework.lesson":           "Занятие: %s\n",
+		"homework.student":          "Ученик: %s\n",
+		"homework.status":           "Статус: %s\n",
+		"homework.due":              "Срок: %s\n",
+		"homework.task":             "\n<b>Задание:</b>\n",
+		"homework.files":            "📎 Файлов: %d\n",
+		"homework.answer":           "\n<b>Ответ (%s):</b>\n",
+		"homework.grade":            "\n<b>Оценка:</b> %s\n",
+		"homework.comment":          "💬 %s\n",
+		"homework.task_files":       "📎 Файлы задания (%d)",
+		"homework.answer_files":     "📎 Файлы ответа (%d)",
+		"homework.continue":         "✏️ Продолжить",
+		"homework.review":           "✍️ Оценить",
+		"homework.delete":           "🗑 Удалить задание",
+		"homework.back_lesson":      "↩️ К занятию",
+		"homework.back":             "↩️ Назад",
+		"homework.all":              "📚 Все задания",
+		"homework.open":             "📚 Открыть",
+		"homework.list_title":       "📚 <b>Домашние задания (%d)</b>\n\n⏳ ждет окончания занятия · 📌 выдано · ⏰ срок прошел · 📥 сдано · ✅ проверено\n",
+		"homework.list_empty":       "\nЗаданий пока нет.",
+		"homework.until":            "до %s",
+		"homework.submit":           "📤 Сдать",
+		"homework.answer_prompt":    "Пришлите ответ: текст, документы или фото — можно несколькими сообщениями. Затем нажмите «Отправить на проверку».",
+		"homework.send":             "✅ Отправить на проверку",
+		"homework.answer_empty":     "Ответ пустой — добавьте текст или файл.\n\n",
+		"homework.notify_submitted": "📥 %s сдал(а) домашнее задание к занятию %s.",
+		"homework.grade_prompt":     "Оцените ответ ученика %s:",
+		"homework.comment_prompt":   "Оценка: %s. Напишите комментарий для ученика или нажмите «Без комментария».",
+		"homework.no_comment":       "Без комментария",
+		"homework.comment_bad":      "Напишите комментарий текстом (до 1000 символов).\n\n",
+		"homework.notify_reviewed":  "✅ Учитель проверил домашнее задание к занятию %s.\nОценка: %s\n",
+		"homework.new":              "📚 <b>Домашнее задание</b> к занятию %s\nСрок: %s\n\n",
+		"homework.remind":           "⏰ Напоминание: домашнее задание к занятию %s нужно сдать до %s.",
+
+		// Отзывы после занятий и статистика
+		"menu.teacher.stats":       "📊 Статистика",
+		"cmd.stats":                "Статистика и оценки занятий",
+		"feedback.survey":          "🎓 Как прошло занятие %s (%s)? Оцените его от 1 до 5:",
+		"feedback.skip_comment":    "Без комментария",
+		"feedback.comment_prompt":  "Спасибо за оценку %s! Если хотите, напишите пару слов о занятии.",
+		"feedback.comment_bad":     "Напишите комментарий текстом (до 1000 символов).",
+		"feedback.thanks":          "🙏 Спасибо за отзыв!",
+		"feedback.notify_rated":    "⭐ %s оценил(а) занятие %s: %s",
+		"feedback.summary_offer":   "🏁 Занятие %s с учеником %s закончилось. Напишите краткий итог или выдайте домашнее задание.",
+		"feedback.write_summary":   "✍️ Итог занятия",
+		"feedback.summary_prompt":  "Напишите итог занятия с учеником %s: что прошли, что получилось, над чем работать.",
+		"feedback.summary_current": "\n\nТекущий итог:\n%s",
+		"feedback.summary_bad":     "Напишите итог текстом (до 2000 символов).",
+		"feedback.rating":          "⭐ Оценка ученика: %s\n",
+		"feedback.no_rating":       "⭐ Ученик еще не оценил занятие\n",
+		"feedback.summary":         "🏁 Итог: %s\n",
+		"stats.title":              "📊 <b>Статистика</b>\n\n",
+		"stats.slots":              "Слоты: всего %d, занято %d, свободно %d\n",
+		"stats.cancellations":      "Отмен учениками: %d\n",
+		"stats.no_ratings":         "\nОценок пока нет (опросов отправлено: %d).\n",
+		"stats.rating":             "\n⭐ <b>Средняя оценка: %.1f</b> (оценок: %d из %d опросов)\n",
+		"stats.by_direction":       "\n<b>По направлениям:</b>\n",
+		"stats.direction":          "• %s — %s (%d)\n",
+		"stats.summaries":          "\n🏁 Итоги занятий: %d из %d\n",
+
+		// Группы
+		"menu.teacher.groups":     "👥 Группы",
+		"cmd.groups":              "Группы и события в них",
+		"cmd.linkgroup":           "Привязать группу к учителю",
+		"cmd.unlinkgroup":         "Отвязать группу",
+		"groups.title":            "👥 <b>Группы</b>\n\n",
+		"groups.empty":            "Привязанных групп пока нет.\n",
+		"groups.list":             "Привязано групп: %d. Выберите группу, чтобы настроить события.\n",
+		"groups.howto":            "\nЧтобы привязать группу, добавьте в нее бота и отправьте там команду /linkgroup. Канал подключается командой /linkgroup @канал здесь, в личном чате, — и вы, и бот должны быть администраторами канала.",
+		"groups.untitled":         "Группа %d",
+		"groups.settings":         "👥 <b>%s</b>\n\nВыберите, что бот публикует в группу:",
+		"groups.event.reminders":  "Напоминания за 10 минут до занятия",
+		"groups.event.agenda":     "Сводка занятий на день",
+		"groups.event.pin_agenda": "Закреплять сводку занятий",
+		"groups.event.free_slots": "Новые свободные слоты",
+		"groups.unlink":           "🔌 Отвязать группу",
+		"groups.back":             "↩️ К списку групп",
+		"groups.teacher_only":     "❌ Привязать группу может только учитель.",
+		"groups.not_linked":       "Группа не привязана к вам.",
+		"groups.linked":           "✅ Группа привязана. События для публикации учитель выбирает в личном чате с ботом, отвязать группу — /unlinkgroup.",
+		"groups.already_linked":   "Группа уже привязана. События для публикации настраиваются в личном чате с ботом.",
+		"groups.unlinked":         "🔌 Группа отвязана: события расписания сюда больше не публикуются.",
+		"groups.free_slots":       "🆕 <b>Новые свободные слоты</b>\n",
+		"groups.book":             "📅 Записаться",
+
+		// Настройки уведомлений и план на день
+		"cmd.agenda":                   "План на сегодня",
+		"settings.open":                "⚙️ Настройки уведомлений",
+		"settings.title":               "⚙️ <b>Настройки уведомлений</b>\n\nПлан на день приходит в %s по времени расписания и обновляется до конца дня.",
+		"settings.reminders":           "Напоминание за 10 минут до занятия",
+		"settings.bookings":            "Уведомления о новых записях",
+		"settings.cancellations":       "Уведомления об отменах",
+		"settings.agenda":              "План на день",
+		"settings.pin":                 "Закреплять план на день",
+		"settings.agenda_time":         "🕗 Время плана: %s",
+		"settings.agenda_time_prompt":  "Выберите время плана на день или введите свое в формате ЧЧ:ММ, например 07:30.",
+		"settings.back":                "↩️ К уведомлениям",
+		"agenda.show":                  "☀️ План на сегодня",
+		"agenda.title":                 "☀️ <b>План на %s</b>\n\n",
+		"agenda.lessons":               "<b>Занятия:</b>\n",
+		"agenda.no_lessons":            "Занятий сегодня нет.\n",
+		"agenda.free":                  "\n🟢 Свободно: %s\n",
+		"agenda.no_free":               "\nСвободных слотов на сегодня не осталось.\n",
+		"agenda.pending":               "\n📌 <b>Ждут действий:</b>\n",
+		"agenda.pending_homework":      "• домашние задания на проверку: %d\n",
+		"agenda.pending_notifications": "• непрочитанные уведомления: %d\n",
+		"agenda.no_pending":            "\n✅ Незавершенных дел нет.\n",
+
+		// Доска свободных слотов
+		"groups.event.board":       "Закрепленная доска свободных слотов",
+		"groups.linked_channel":    "✅ Канал подключен: бот будет публиковать здесь расписание.",
+		"groups.channel_not_found": "Канал не найден. Отправьте /linkgroup @канал или /linkgroup -100…, предварительно добавив бота в администраторы канала.",
+		"groups.channel_failed":    "Не удалось написать в канал. Сделайте бота администратором с правом публикации сообщений и повторите команду.",
+		"groups.channel_not_admin": "⛔ Привязать канал может только его создатель или администратор.",
+		"board.title":              "🗓 <b>Свободное время для записи</b>\n\n",
+		"board.empty":              "Свободных слотов пока нет — доска обновится, когда они появятся.\n",
+		"board.more":               "… и еще %d\n",
+		"board.book":               "📅 Записаться: %s",
+		"board.offer":              "📅 Записаться на занятие %s?",
+		"board.confirm":            "✅ Записаться",
+		"board.other_time":         "🗓 Другое время",
+		"board.slot_gone":          "Это время уже недоступно. Выберите другое:",
+
+		// Записи ученика
+		"bookings.error":       "Ошибка получения записей.",
+		"bookings.empty":       "У вас нет активных записей.",
+		"bookings.title":       "🗓 <b>Ваши записи:</b>\n",
+		"bookings.cancel":      "Выберите запись для отмены:",
+		"book.taken":           "Этот слот уже занят.",
+		"book.blocked":         "⛔ Запись на занятия недоступна. Свяжитесь с учителем.",
+		"book.no_slot":         "На %s свободного слота нет.",
+		"book.ambiguous":       "На %s свободны слоты у нескольких учителей: %s. Укажите учителя, например: /book %s %s @имя",
+		"book.time_error":      "Ошибка обработки времени слота.",
+		"book.past":            "Нельзя записаться на прошедшее время.",
+		"book.error":           "Ошибка при записи на занятие.",
+		"book.done":            "Вы успешно записаны на занятие: %s",
+		"cancel.foreign":       "Вы не можете отменить чужую запись.",
+		"cancel.error":         "Ошибка при отмене записи.",
+		"cancel.done":          "Запись на %s успешно отменена.",
+		"cancel.reason_prompt": "Если хотите, напишите причину отмены — учитель ее увидит.",
+		"cancel.reason_skip":   "Без причины",
+		"cancel.reason_saved":  "Спасибо, причина передана учителю.",
+
+		// Оповещения и напоминания
+		"notify.booked":            "Новая запись:\n%s - %s\nУченик: @%s\nНаправление: %s",
+		"notify.student_cancelled": "Ученик отменил занятие:\n%s - %s\nУченик: @%s",
+		"notify.cancel_reason":     "Причина отмены занятия %s (@%s): %s",
+		"notify.cancelled":         "Запись отменена:\n%s - %s\nУченик: @%s",
+		"notify.you_booked":        "Вы записаны на занятие:\n%s - %s\nНаправление: %s",
+		"notify.you_cancelled":     "Ваша запись отменена:\n%s - %s",
+		"reminder.teacher":         "Напоминание: урок через 10 минут!\n%s - %s\nУченик: @%s\nНаправление: %s",
+		"reminder.group":           "Напоминание: урок начнется через 10 минут!\n%s - %s\nУченик: @%s\nНаправление: %s",
+		"reminder.student":         "Напоминание: занятие через 30 минут!\n%s - %s\nНаправление: %s",
+		"reminder.ok":              "Хорошо, я уведомлен",
+		"reminder.weekly":          "Пора заполнить расписание на следующую неделю!",
+
+		// Подписка на календарь
+		"ical.link_error":   "Ошибка получения ссылки на календарь.",
+		"ical.rotate_error": "Ошибка смены ссылки на календарь.",
+		"ical.rotated":      "🔄 Ссылка обновлена, старая больше не работает.\n\n",
+		"ical.link":         "📆 Ссылка для подписки на календарь:\n",
+		"ical.hint":         "\n\nДобавьте ее в Google Календарь, Apple Календарь или Outlook как календарь по URL. Не передавайте ссылку другим.",
+		"ical.rotate":       "🔄 Сменить ссылку",
+		"ical.name":         "Занятия по английскому",
+		"ical.lesson":       "Занятие по английскому",
+		"ical.lesson_with":  "Занятие: %s",
+		"ical.free":         "Свободный слот",
+		"ical.student":      "ученик",
+		"ical.direction":    "Направление: %s",
+
+		// Внешние календари
+		"ext.usage":        "Укажите ссылку на ICS-календарь: /add_calendar https://example.com/calendar.ics",
+		"ext.add_error":    "Ошибка добавления календаря. Возможно, он уже подключен.",
+		"ext.sync_failed":  "Календарь добавлен, но загрузить его не удалось. Бот повторит попытку позже.",
+		"ext.list_error":   "Ошибка получения списка календарей.",
+		"ext.title":        "🗓 Внешние календари\n\nЗанятое в них время недоступно для новых слотов.\n",
+		"ext.empty":        "\nКалендари не подключены. Добавьте: /add_calendar <ссылка на .ics>",
+		"ext.pending":      "⏳ еще не загружен",
+		"ext.synced":       "✅ обновлен %s",
+		"ext.delete":       "🗑️ Удалить %d",
+		"ext.delete_error": "Ошибка удаления календаря.",
+
+		// Импорт и экспорт
+		"export.usage":          "Использование: /export [ГГГГ-ММ-ДД] [ГГГГ-ММ-ДД] [free|booked]",
+		"export.schedule_error": "Ошибка выгрузки расписания.",
+		"export.students_error": "Ошибка выгрузки учеников.",
+		"export.send_error":     "Ошибка отправки файла.",
+		"import.need_csv":       "Для импорта слотов отправьте файл .csv со столбцами start_time, end_time.",
+		"import.too_large":      "Файл слишком большой.",
+		"import.download_error": "Ошибка загрузки файла.",
+		"import.read_error":     "Ошибка чтения CSV: %s",
+		"import.error":          "Ошибка импорта: %s",
+		"import.confirm":        "✅ Добавить %d",
+		"import.nothing":        "Нет файла для импорта. Отправьте CSV еще раз.",
+		"import.dry_run":        "📋 Проверка файла (пробный запуск)\n",
+		"import.done":           "📥 Импорт завершен\n",
+		"import.summary":        "Строк: %d, принято: %d, отклонено: %d\n",
+		"import.more":           "… и еще %d\n",
+		"import.row":            "строка %d: %s\n",
+	},
+	langEN: {
+		// Общее
+		"error.generic":     "Something went wrong. Please try again later.",
+		"error.register":    "Registration failed. Please try again.",
+		"error.user":        "Could not load your profile.",
+		"btn.menu":          "↩️ Back to menu",
+		"btn.calendar":      "📅 Back to calendar",
+		"btn.to_calendar":   "↩️ To calendar",
+		"btn.prev_month":    "⬅️ Previous month",
+		"btn.next_month":    "➡️ Next month",
+		"btn.delete":        "🗑️ Delete",
+		"btn.cancel":        "❌ Cancel",
+		"btn.language":      "🌐 Language",
+		"teacher.only":      "❌ This command is only available to teachers",
+		"auth.forbidden":    "⛔ You are not allowed to do this.",
+		"auth.failed":       "Could not complete the action: the item was not found or has changed.",
+		"callback.stale":    "This button is outdated, opening the menu.",
+		"callback.denied":   "This action is not available.",
+		"callback.bad_data": "Error: invalid button data.",
+
+		// Меню
+		"menu.teacher":               "👨‍🏫 <b>Teacher menu</b>\nChoose an action:\n📬 Notifications: %d",
+		"menu.teacher.schedule":      "📅 Manage schedule",
+		"menu.teacher.students":      "👥 My students",
+		"menu.teacher.add_slot":      "➕ Add a slot",
+		"menu.teacher.notifications": "📬 Notifications (%d)",
+		"menu.ical":                  "📆 Calendar subscription",
+		"menu.ext_calendars":         "🗓 External calendars",
+		"menu.student":               "👨‍🎓 <b>Student menu</b>\nChoose an action:",
+		"menu.student.book":          "📅 Book a lesson",
+		"menu.student.bookings":      "🗓 My bookings",
+		"menu.student.cancel":        "❌ Cancel a booking",
+
+		// Язык
+		"language.title": "🌐 Interface language: %s\nChoose a language:",
+		"language.auto":  "Same as Telegram",
+
+		// Диалоги
+		"dialog.cancelled": "Input cancelled.",
+		"dialog.expired":   "⌛ The time to reply has run out. Please start again from the menu.",
+
+		// Уведомления учителя
+		"notifications.error":       "Could not load notifications.",
+		"notifications.empty":       "📬 You have no notifications.",
+		"notifications.title":       "📬 <b>Your notifications:</b>\n",
+		"notifications.mark_read":   "Mark as read: %s",
+		"notifications.mark_error":  "Could not mark the notification as read.",
+		"notifications.clear":       "🗑️ Clear all",
+		"notifications.clear_error": "Could not clear notifications.",
+		"notifications.cleared":     "📬 Notifications cleared.",
+
+		// Расписание и слоты
+		"schedule.error":      "❌ Could not load the schedule",
+		"schedule.empty":      "📭 The schedule is empty",
+		"schedule.title":      "📅 <b>Your schedule:</b>\n\n",
+		"schedule.item":       "⏰ #%d %s - %s\n🔄 Status: %s\n",
+		"status.booked":       "✅ Booked",
+		"status.free":         "🆓 Free",
+		"slot.delete":         "🗑️ Delete slot",
+		"slot.delete_error":   "Could not delete the slot.",
+		"slot.deleted":        "✅ Slot deleted",
+		"slot.choose_delete":  "Choose a slot to delete:",
+		"slot.past":           "You can't add a slot in the past.",
+		"slot.exists":         "Slot %s - %s already exists. What would you like to do?",
+		"slot.external_busy":  "⛔ This time is busy in your external calendar.",
+		"slot.add_error":      "Could not add the slot.",
+		"slot.added":          "Slot added: %s - %s",
+		"slot.custom_time":    "🕒 Other time",
+		"slot.ask_time":       "Enter the start time of the slot on %s as HH:MM, for example 18:30.\n/cancel to cancel.",
+		"slot.bad_time":       "Could not read the time. Enter it as HH:MM, for example 18:30.",
+		"calendar.slot":       "Choose a date to add a slot:",
+		"calendar.slot_month": "%s %d: choose a date to add a slot:",
+		"calendar.book":       "Choose a date to book a lesson:",
+		"calendar.book_month": "%s %d: choose a date to book a lesson:",
+		"date.error":          "Invalid date.",
+		"slots.error":         "Could not load slots.",
+		"slots.hours":         "🕒 <b>Available hours on %s:</b>\n\n🟩 - Free\n🟥 - Taken\n⛔ - Busy in an external calendar",
+		"slots.free":          "✅ <b>Free slots on %s:</b>\n\nChoose a time:",
+
+		// Слоты текстом
+		"slottext.help":       "Could not read the slots: %s\n\nExamples:\n• mon 18:00-19:30\n• tomorrow 10-12 by 45 min\n• every wednesday 17:00\n• 21.10 from 9 to 11",
+		"slottext.preview":    "🗓 Slots for “%s”:\n\n",
+		"slottext.confirm":    "✅ Add %d",
+		"slottext.nothing":    "\nNothing to add.",
+		"slottext.added":      "✅ Slots added: %d of %d\n",
+		"slottext.expired":    "No slots to add. Please send them again.",
+		"slottext.past":       "already in the past",
+		"slottext.exists":     "slot already exists",
+		"slottext.busy":       "busy in an external calendar",
+		"slottext.failed":     "could not be added",
+		"slottext.empty":      "the message is empty",
+		"slottext.unknown":    "unknown word “%s”",
+		"slottext.no_time":    "no time given",
+		"slottext.bad_time":   "invalid time %s",
+		"slottext.bad_date":   "invalid date %s",
+		"slottext.bad_range":  "the end is before the start",
+		"slottext.bad_length": "invalid slot length %s",
+		"slottext.two_days":   "more than one day is given",
+		"slottext.no_weekday": "give a weekday to repeat",
+		"slottext.too_many":   "too many slots, at most %s at once",
+
+		// Команды
+		"cmd.start":           "Main menu",
+		"cmd.book":            "Book a lesson: /book 2025-03-10 18:00",
+		"cmd.free":            "Free time: /free tomorrow",
+		"cmd.free_teacher":    "Slots for a day: /free tomorrow",
+		"cmd.today":           "Today's lessons",
+		"cmd.mybookings":      "My bookings",
+		"cmd.cancel":          "Cancel a booking",
+		"cmd.cancel_input":    "Cancel input",
+		"cmd.calendar_link":   "Calendar subscription",
+		"cmd.language":        "Interface language",
+		"cmd.schedule":        "Schedule",
+		"cmd.addslot":         "Add a slot: /addslot 2025-03-10 18:00 90m",
+		"cmd.delslot":         "Delete a slot by number: /delslot 12",
+		"cmd.students":        "Students: /students [search]",
+		"cmd.calendars":       "External calendars",
+		"cmd.add_calendar":    "Connect an external calendar",
+		"cmd.export":          "Export schedule to CSV",
+		"cmd.export_students": "Export students to CSV",
+		"usage.addslot":       "Usage: /addslot DATE TIME [LENGTH]\nExample: /addslot 2025-03-10 18:00 90m\n\nDate: 2025-03-10, 10.03, tomorrow or mon. Length: 90m, 1h30m or 45 (minutes), 1 hour by default.",
+		"usage.free":          "Usage: /free [DATE]\nExample: /free tomorrow, /free 10.03, /free fri\n\nWithout a date — today.",
+		"usage.book":          "Usage: /book DATE TIME [@teacher]\nExample: /book 2025-03-10 18:00\n\nWithout arguments — pick a date in the calendar.",
+		"usage.delslot":       "Usage: /delslot NUMBER\nExample: /delslot 12\n\nSlot numbers are shown in /schedule and /today.",
+		"command.bad_date":    "❌ Could not read the date \"%s\".",
+		"command.bad_time":    "❌ Could not read the time \"%s\".",
+		"command.bad_length":  "❌ Invalid length \"%s\": from 5 minutes to 24 hours.",
+		"command.bad_id":      "❌ Invalid slot number \"%s\".",
+		"today.title":         "📅 <b>Today's lessons, %s:</b>\n\n",
+		"today.empty":         "📭 No lessons today (%s).",
+		"today.slot":          "⏰ #%d %s - %s %s\n",
+
+		// Ученики
+		"students.error":       "Could not load your students.",
+		"students.empty":       "You have no students yet.",
+		"students.title":       "👥 <b>Your students (%d):</b>\n",
+		"delivery.blocked":     "⚠️ not receiving messages: the bot is blocked",
+		"delivery.deactivated": "⚠️ not receiving messages: the account is deleted",
+
+		// Анкета ученика
+		"profile.welcome":          "👋 Welcome! Let's get to know each other — it takes a minute.\n\n",
+		"profile.ask_name":         "What should we call you?",
+		"profile.bad_name":         "Please type your name as text (up to 100 characters).",
+		"profile.ask_phone":        "📱 Share your phone number with the button below — the teacher needs it to reach you. You can also type it or skip this step.",
+		"profile.share_phone":      "📱 Share number",
+		"profile.skip":             "Skip",
+		"profile.bad_phone":        "That doesn't look like a phone number. Tap \"📱 Share number\", type a number like +44 20 7946 0958 or skip this step.",
+		"profile.foreign_contact":  "That is someone else's contact. Please share your own number with \"📱 Share number\".",
+		"profile.thanks":           "Thank you!",
+		"profile.ask_level":        "📚 What is your English level?",
+		"profile.bad_level":        "Please choose a level with the buttons below.",
+		"profile.ask_goals":        "🎯 Why are you learning English? A few words about your goals: work, relocation, an exam, travel…",
+		"profile.bad_goals":        "Please type your goals (up to 500 characters) or tap \"Skip\".",
+		"profile.ask_direction":    "🧭 Which kind of lessons suits you best? Choose a button or type your own.",
+		"profile.bad_direction":    "Choose a button or type it as text (up to 100 characters).",
+		"profile.done":             "✅ Profile completed, thank you!\n\n",
+		"profile.title":            "👤 <b>Your profile</b>\n\n",
+		"profile.empty":            "Your profile is not filled in yet.",
+		"profile.edit":             "✏️ Fill in again",
+		"profile.fill":             "📝 Fill in profile",
+		"profile.none":             "—",
+		"profile.unfinished":       "⏳ Profile is not complete\n",
+		"profile.new_student":      "🆕 New student: %s, level %s",
+		"profile.field.name":       "Name: %s\n",
+		"profile.field.username":   "Telegram: @%s\n",
+		"profile.field.phone":      "Phone: %s\n",
+		"profile.field.level":      "Level: %s\n",
+		"profile.field.goals":      "Goals: %s\n",
+		"profile.field.direction":  "Lessons: %s\n",
+		"level.A1":                 "A1 — Beginner",
+		"level.A2":                 "A2 — Elementary",
+		"level.B1":                 "B1 — Intermediate",
+		"level.B2":                 "B2 — Upper-Intermediate",
+		"level.C1":                 "C1 — Advanced",
+		"level.C2":                 "C2 — Proficiency",
+		"level.unknown":            "🤷 Not sure",
+		"direction.general":        "General English",
+		"direction.speaking":       "Speaking",
+		"direction.business":       "Business English",
+		"direction.exams":          "Exam preparation",
+		"direction.travel":         "Travel",
+		"menu.student.profile":     "👤 Profile",
+		"cmd.profile":              "My profile",
+		"students.open_card":       "👤 %s",
+		"students.card":            "👤 <b>Student card</b>\n\n",
+		"students.not_found":       "Student not found.",
+		"students.search_query":    "🔍 Search: \"%s\"\n",
+		"students.not_found_query": "\nNobody found.",
+		"students.counts":          " · 📅 %d · ✔️ %d · ❌ %d",
+		"students.blocked":         "🚫 Not allowed to book lessons\n",
+		"students.upcoming":        "\n<b>Upcoming lessons (%d):</b>\n",
+		"students.past":            "\n<b>Past lessons (%d):</b>\n",
+		"students.attendance":      "\n📊 Attendance: %d of %d (%d%%)\n",
+		"students.cancellations":   "❌ Cancellations: %d\n",
+		"students.last_cancel":     "Last cancelled lesson: %s\n",
+		"students.cancel_reason":   "Reason: %s\n",
+		"students.notes":           "\n<b>Notes (%d):</b>\n",
+		"students.message":         "✉️ Message",
+		"students.book":            "📅 Book",
+		"students.add_note":        "📝 Note",
+		"students.block":           "🚫 Block",
+		"students.unblock":         "✅ Unblock",
+		"students.search":          "🔍 Search",
+		"students.search_reset":    "✖️ Clear search",
+		"students.search_prompt":   "Type the student's name, @username or phone number.",
+		"students.message_prompt":  "Write a message for %s — the bot will forward it on your behalf.",
+		"students.message_bad":     "Please type the message as text (up to 2000 characters).",
+		"students.message_from":    "✉️ <b>Message from your teacher:</b>\n\n",
+		"students.message_failed":  "Could not deliver the message to the student.",
+		"students.timeline":        "🕓 History",
+		"students.book_choose":     "Choose a free slot for %s:",
+		"students.book_no_slots":   "There are no free slots. Add a slot to your schedule first.",
+		"students.back_to_card":    "↩️ Back to card",
+		"students.booked":          "📅 Your teacher booked you for a lesson on %s.",
+		"btn.students":             "↩️ Back to students",
+
+		// Notes and lessons
+		"notes.student_prompt":   "Send a note about %s: text, a voice message or a photo. Only you can see it.",
+		"notes.lesson_prompt":    "Send a note for the lesson on %s (%s): text, a voice message or a photo. Only you can see it.",
+		"notes.bad":              "Please send text (up to 1000 characters), a voice message or a photo.",
+		"notes.lesson_free":      "Nobody is booked for this slot, so it can't have notes.",
+		"notes.lesson_title":     "📖 <b>Lesson</b>\n\n",
+		"notes.lesson_status":    "Status: %s\n",
+		"notes.lesson_student":   "Student: %s\n",
+		"notes.lesson_direction": "Direction: %s\n",
+		"notes.none":             "\nNo notes for this lesson.",
+		"notes.title":            "\n<b>Notes (%d):</b>\n",
+		"notes.lesson_suffix":    " (lesson %s)",
+		"notes.kind.text":        "note",
+		"notes.kind.voice":       "voice message",
+		"notes.kind.photo":       "photo",
+		"notes.show_media":       "%s %s",
+		"notes.media_error":      "Could not send the note's file.",
+		"notes.add_lesson":       "📝 Note",
+		"notes.student_card":     "👤 Student",
+		"notes.back_schedule":    "↩️ Back to schedule",
+		"notes.timeline_title":   "🕓 <b>History of %s</b>\n\n",
+		"notes.timeline_empty":   "Nothing here yet.",
+		"notes.timeline_lesson":  "%s Lesson %s (%s)\n",
+		"notes.timeline_cancel":  "❌ %s — cancelled the lesson on %s\n",
+
+		// Homework
+		"menu.homework":             "📚 Homework",
+		"cmd.homework":              "Homework",
+		"cmd.homework_teacher":      "Students' homework",
+		"homework.status.draft":     "draft",
+		"homework.status.waiting":   "the student gets it after the lesson",
+		"homework.status.assigned":  "to be submitted",
+		"homework.status.overdue":   "overdue",
+		"homework.status.submitted": "submitted, awaiting review",
+		"homework.status.reviewed":  "reviewed",
+		"homework.lesson_button":    "📚 Homework",
+		"homework.lesson_status":    "%s Homework: %s\n",
+		"homework.lesson_free":      "Nobody is booked for this slot, so it can't have homework.",
+		"homework.task_prompt":      "📚 Homework for the lesson on %s (%s).\n\nSend the task text, documents or photos — several messages are fine. Then tap \"Next\" and choose a due date.",
+		"homework.collected":        "\n\nAdded: text — %d chars, files — %d.",
+		"homework.to_due":           "➡️ Next: due date",
+		"homework.task_empty":       "The task is empty — add some text or a file.\n\n",
+		"homework.part_bad":         "Please send text, a document or a photo (up to 3000 characters in total).\n\n",
+		"homework.due_prompt":       "When is it due? Pick a button or type a date: \"tomorrow\", \"25.10\", \"fri 18:00\".",
+		"homework.due_next":         "📅 By the next lesson (%s)",
+		"homework.due_past":         "The due date must be after the lesson ends.\n\n",
+		"homework.due_bad":          "Could not understand the due date.\n\n",
+		"homework.title":            "📚 <b>Homework</b>\n\n",
+		"homework.lesson":           "Lesson: %s\n",
+		"homework.student":          "Student: %s\n",
+		"homework.status":           "Status: %s\n",
+		"homework.due":              "Due: %s\n",
+		"homework.task":             "\n<b>Task:</b>\n",
+		"homework.files":            "📎 Files: %d\n",
+		"homework.answer":           "\n<b>Answer (%s):</b>\n",
+		"homework.grade":            "\n<b>Grade:</b> %s\n",
+		"homework.comment":          "💬 %s\n",
+		"homework.task_files":       "📎 Task files (%d)",
+		"homework.answer_files":     "📎 Answer files (%d)",
+		"homework.continue":         "✏️ Continue",
+		"homework.review":           "✍️ Grade",
+		"homework.delete":           "🗑 Delete homework",
+		"homework.back_lesson":      "↩️ Back to lesson",
+		"homework.back":             "↩️ Back",
+		"homework.all":              "📚 All homework",
+		"homework.open":             "📚 Open",
+		"homework.list_title":       "📚 <b>Homework (%d)</b>\n\n⏳ waiting for the lesson to end · 📌 assigned · ⏰ overdue · 📥 submitted · ✅ reviewed\n",
+		"homework.list_empty":       "\nNo homework yet.",
+		"homework.until":            "due %s",
+		"homework.submit":           "📤 Submit",
+		"homework.answer_prompt":    "Send your answer: text, documents or photos — several messages are fine. Then tap \"Submit for review\".",
+		"homework.send":             "✅ Submit for review",
+		"homework.answer_empty":     "The answer is empty — add some text or a file.\n\n",
+		"homework.notify_submitted": "📥 %s submitted the homework for the lesson on %s.",
+		"homework.grade_prompt":     "Grade the answer from %s:",
+		"homework.comment_prompt":   "Grade: %s. Write a comment for the student or tap \"No comment\".",
+		"homework.no_comment":       "No comment",
+		"homework.comment_bad":      "Please type the comment as text (up to 1000 characters).\n\n",
+		"homework.notify_reviewed":  "✅ Your teacher reviewed the homework for the lesson on %s.\nGrade: %s\n",
+		"homework.new":              "📚 <b>Homework</b> for the lesson on %s\nDue: %s\n\n",
+		"homework.remind":           "⏰ Reminder: the homework for the lesson on %s is due by %s.",
+
+		// Post-lesson feedback and stats
+		"menu.teacher.stats":       "📊 Stats",
+		"cmd.stats":                "Stats and lesson ratings",
+		"feedback.survey":          "🎓 How was the lesson on %s (%s)? Rate it from 1 to 5:",
+		"feedback.skip_comment":    "No comment",
+		"feedback.comment_prompt":  "Thanks for rating it %s! Feel free to add a few words about the lesson.",
+		"feedback.comment_bad":     "Please type the comment as text (up to 1000 characters).",
+		"feedback.thanks":          "🙏 Thank you for the feedback!",
+		"feedback.notify_rated":    "⭐ %s rated the lesson on %s: %s",
+		"feedback.summary_offer":   "🏁 The lesson on %s with %s has ended. Write a short summary or assign homework.",
+		"feedback.write_summary":   "✍️ Lesson summary",
+		"feedback.summary_prompt":  "Write a summary of the lesson with %s: what you covered, what went well, what to work on.",
+		"feedback.summary_current": "\n\nCurrent summary:\n%s",
+		"feedback.summary_bad":     "Please type the summary as text (up to 2000 characters).",
+		"feedback.rating":          "⭐ Student's rating: %s\n",
+		"feedback.no_rating":       "⭐ The student hasn't rated the lesson yet\n",
+		"feedback.summary":         "🏁 Summary: %s\n",
+		"stats.title":              "📊 <b>Stats</b>\n\n",
+		"stats.slots":              "Slots: %d total, %d booked, %d free\n",
+		"stats.cancellations":      "Cancelled by students: %d\n",
+		"stats.no_ratings":         "\nNo ratings yet (surveys sent: %d).\n",
+		"stats.rating":             "\n⭐ <b>Average rating: %.1f</b> (%d ratings from %d surveys)\n",
+		"stats.by_direction":       "\n<b>By direction:</b>\n",
+		"stats.direction":          "• %s — %s (%d)\n",
+		"stats.summaries":          "\n🏁 Lesson summaries: %d of %d\n",
+
+		// Groups
+		"menu.teacher.groups":     "👥 Groups",
+		"cmd.groups":              "Groups and their events",
+		"cmd.linkgroup":           "Link this group to a teacher",
+		"cmd.unlinkgroup":         "Unlink this group",
+		"groups.title":            "👥 <b>Groups</b>\n\n",
+		"groups.empty":            "No groups linked yet.\n",
+		"groups.list":             "Linked groups: %d. Choose a group to set up its events.\n",
+		"groups.howto":            "\nTo link a group, add the bot to it and send /linkgroup there. To connect a channel you administer, make the bot its administrator too and send /linkgroup @channel here in the private chat.",
+		"groups.untitled":         "Group %d",
+		"groups.settings":         "👥 <b>%s</b>\n\nChoose what the bot posts to the group:",
+		"groups.event.reminders":  "Reminders 10 minutes before a lesson",
+		"groups.event.agenda":     "Daily lesson agenda",
+		"groups.event.pin_agenda": "Pin the lesson agenda",
+		"groups.event.free_slots": "New free slots",
+		"groups.unlink":           "🔌 Unlink group",
+		"groups.back":             "↩️ Back to groups",
+		"groups.teacher_only":     "❌ Only a teacher can link a group.",
+		"groups.not_linked":       "This group isn't linked to you.",
+		"groups.linked":           "✅ Group linked. The teacher chooses which events are posted here in a private chat with the bot; to unlink, send /unlinkgroup.",
+		"groups.already_linked":   "This group is already linked. Events are set up in a private chat with the bot.",
+		"groups.unlinked":         "🔌 Group unlinked: schedule events will no longer be posted here.",
+		"groups.free_slots":       "🆕 <b>New free slots</b>\n",
+		"groups.book":             "📅 Book a lesson",
+
+		// Notification settings and daily agenda
+		"cmd.agenda":                   "Today's agenda",
+		"settings.open":                "⚙️ Notification settings",
+		"settings.title":               "⚙️ <b>Notification settings</b>\n\nThe daily agenda arrives at %s schedule time and is updated until the end of the day.",
+		"settings.reminders":           "Reminder 10 minutes before a lesson",
+		"settings.bookings":            "New booking notifications",
+		"settings.cancellations":       "Cancellation notifications",
+		"settings.agenda":              "Daily agenda",
+		"settings.pin":                 "Pin the daily agenda",
+		"settings.agenda_time":         "🕗 Agenda time: %s",
+		"settings.agenda_time_prompt":  "Choose when the daily agenda arrives or type your own time as HH:MM, for example 07:30.",
+		"settings.back":                "↩️ Back to notifications",
+		"agenda.show":                  "☀️ Today's agenda",
+		"agenda.title":                 "☀️ <b>Agenda for %s</b>\n\n",
+		"agenda.lessons":               "<b>Lessons:</b>\n",
+		"agenda.no_lessons":            "No lessons today.\n",
+		"agenda.free":                  "\n🟢 Free: %s\n",
+		"agenda.no_free":               "\nNo free slots left today.\n",
+		"agenda.pending":               "\n📌 <b>Pending:</b>\n",
+		"agenda.pending_homework":      "• homework to review: %d\n",
+		"agenda.pending_notifications": "• unread notifications: %d\n",
+		"agenda.no_pending":            "\n✅ Nothing pending.\n",
+
+		// Free slots board
+		"groups.event.board":       "Pinned free slots board",
+		"groups.linked_channel":    "✅ Channel connected: the bot will post the schedule here.",
+		"groups.channel_not_found": "Channel not found. Send /linkgroup @channel or /linkgroup -100… after making the bot a channel administrator.",
+		"groups.channel_failed":    "Could not post to the channel. Make the bot an administrator allowed to post messages and try again.",
+		"groups.channel_not_admin": "⛔ Only the channel's creator or an administrator can link it.",
+		"board.title":              "🗓 <b>Free times to book</b>\n\n",
+		"board.empty":              "No free slots yet — the board will update when they appear.\n",
+		"board.more":               "… and %d more\n",
+		"board.book":               "📅 Book: %s",
+		"board.offer":              "📅 Book a lesson %s?",
+		"board.confirm":            "✅ Book",
+		"board.other_time":         "🗓 Another time",
+		"board.slot_gone":          "This time is no longer available. Please choose another:",
+
+		// Записи ученика
+		"bookings.error":       "Could not load your bookings.",
+		"bookings.empty":       "You have no upcoming bookings.",
+		"bookings.title":       "🗓 <b>Your bookings:</b>\n",
+		"bookings.cancel":      "Choose a booking to cancel:",
+		"book.taken":           "This slot is already taken.",
+		"book.blocked":         "⛔ Booking is not available. Please contact your teacher.",
+		"book.no_slot":         "There is no free slot at %s.",
+		"book.ambiguous":       "Several teachers are free at %s: %s. Name the teacher, for example: /book %s %s @name",
+		"book.time_error":      "Invalid slot time.",
+		"book.past":            "You can't book a time in the past.",
+		"book.error":           "Could not book the lesson.",
+		"book.done":            "You're booked for a lesson: %s",
+		"cancel.foreign":       "You can't cancel someone else's booking.",
+		"cancel.error":         "Could not cancel the booking.",
+		"cancel.done":          "Your booking for %s has been cancelled.",
+		"cancel.reason_prompt": "If you like, write the reason for cancelling — your teacher will see it.",
+		"cancel.reason_skip":   "No reason",
+		"cancel.reason_saved":  "Thanks, your teacher has been told the reason.",
+
+		// Оповещения и напоминания
+		"notify.booked":            "New booking:\n%s - %s\nStudent: @%s\nTopic: %s",
+		"notify.student_cancelled": "A student cancelled a lesson:\n%s - %s\nStudent: @%s",
+		"notify.cancel_reason":     "Reason for cancelling the lesson on %s (@%s): %s",
+		"notify.cancelled":         "Booking cancelled:\n%s - %s\nStudent: @%s",
+		"notify.you_booked":        "You're booked for a lesson:\n%s - %s\nTopic: %s",
+		"notify.you_cancelled":     "Your booking has been cancelled:\n%s - %s",
+		"reminder.teacher":         "Reminder: lesson in 10 minutes!\n%s - %s\nStudent: @%s\nTopic: %s",
+		"reminder.group":           "Reminder: a lesson starts in 10 minutes!\n%s - %s\nStudent: @%s\nTopic: %s",
+		"reminder.student":         "Reminder: lesson in 30 minutes!\n%s - %s\nTopic: %s",
+		"reminder.ok":              "OK, got it",
+		"reminder.weekly":          "Time to fill in next week's schedule!",
+
+		// Подписка на календарь
+		"ical.link_error":   "Could not get the calendar link.",
+		"ical.rotate_error": "Could not change the calendar link.",
+		"ical.rotated":      "🔄 The link has been changed, the old one no longer works.\n\n",
+		"ical.link":         "📆 Calendar subscription link:\n",
+		"ical.hint":         "\n\nAdd it to Google Calendar, Apple Calendar or Outlook as a calendar from URL. Do not share the link.",
+		"ical.rotate":       "🔄 Change link",
+		"ical.name":         "English lessons",
+		"ical.lesson":       "English lesson",
+		"ical.lesson_with":  "Lesson: %s",
+		"ical.free":         "Free slot",
+		"ical.student":      "student",
+		"ical.direction":    "Topic: %s",
+
+		// Внешние календари
+		"ext.usage":        "Send the ICS calendar link: /add_calendar https://example.com/calendar.ics",
+		"ext.add_error":    "Could not add the calendar. It may already be connected.",
+		"ext.sync_failed":  "The calendar was added but could not be loaded. The bot will retry later.",
+		"ext.list_error":   "Could not load your calendars.",
+		"ext.title":        "🗓 External calendars\n\nTime that is busy in them is not available for new slots.\n",
+		"ext.empty":        "\nNo calendars connected. Add one: /add_calendar <link to .ics>",
+		"ext.pending":      "⏳ not loaded yet",
+		"ext.synced":       "✅ updated %s",
+		"ext.delete":       "🗑️ Delete %d",
+		"ext.delete_error": "Could not delete the calendar.",
+
+		// Импорт и экспорт
+		"export.usage":          "Usage: /export [YYYY-MM-DD] [YYYY-MM-DD] [free|booked]",
+		"export.schedule_error": "Could not export the schedule.",
+		"export.students_error": "Could not export students.",
+		"export.send_error":     "Could not send the file.",
+		"import.need_csv":       "To import slots, send a .csv file with start_time, end_time columns.",
+		"import.too_large":      "The file is too large.",
+		"import.download_error": "Could not download the file.",
+		"import.read_error":     "Could not read the CSV: %s",
+		"import.error":          "Import failed: %s",
+		"import.confirm":        "✅ Add %d",
+		"import.nothing":        "No file to import. Please send the CSV again.",
+		"import.dry_run":        "📋 File check (dry run)\n",
+		"import.done":           "📥 Import finished\n",
+		"import.summary":        "Rows: %d, accepted: %d, rejected: %d\n",
+		"import.more":           "… and %d more\n",
+		"import.row":            "line %d: %s\n",
+	},
+}
+
+// Названия месяцев и дней недели (с понедельника)
+var (
+	monthNames = map[string][12]string{
+		langRU: {"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"},
+		langEN: {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	}
+	weekdayNames = map[string][7]string{
+		langRU: {"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"},
+		langEN: {"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"},
+	}
+	// Формат даты и времени в списках и на кнопках
+	timeLayouts = map[string]string{
+		langRU: "02.01 15:04",
+		langEN: "Jan 2, 15:04",
+	}
+	dateLayouts = map[string]string{
+		langRU: "02.01.2006",
+		langEN: "Jan 2, 2006",
+	}
+)
+
+// Текст по ключу на языке lang; если перевода нет — на языке по умолчанию
+func T(lang, key string, args ...interface{}) string {
+	text, ok := catalog[lang][key]
+	if !ok {
+		text, ok = catalog[defaultLang][key]
+	}
+	if !ok {
+		fmt.Println("Нет перевода:", key, "язык:", lang)
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Текст по ключу на языке пользователя
+func tr(chatID int64, key string, args ...interface{}) string {
+	return T(userLang(chatID), key, args...)
+}
+
+// Язык по language_code из Telegram ("en-US", "ru" и т.п.)
+func langFromCode(code string) string {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	switch code {
+	case "":
+		return defaultLang
+	case "ru", "uk", "be", "kk":
+		return langRU
+	}
+	if _, ok := catalog[code]; ok {
+		return code
+	}
+	return langEN
+}
+
+// Язык пользователя: выбранный в настройках, иначе из Telegram
+func (u *User) Lang() string {
+	if _, ok := catalog[u.Language.String]; ok {
+		return u.Language.String
+	}
+	return langFromCode(u.LanguageCode.String)
+}
+
+// Кэш языков пользователей, чтобы не читать базу на каждую строку
+type userLanguages struct {
+	mu    sync.Mutex
+	langs map[int64]string
+}
+
+var languages userLanguages
+
+func userLang(chatID int64) string {
+	// Группы и каналы — на языке по умолчанию
+	if chatID <= 0 {
+		return defaultLang
+	}
+	languages.mu.Lock()
+	lang, ok := languages.langs[chatID]
+	languages.mu.Unlock()
+	if ok {
+		return lang
+	}
+
+	lang = defaultLang
+	if user, err := store.GetUser(chatID); err == nil {
+		lang = user.Lang()
+	}
+	languages.mu.Lock()
+	if languages.langs == nil {
+		languages.langs = make(map[int64]string)
+	}
+	languages.langs[chatID] = lang
+	languages.mu.Unlock()
+	return lang
+}
+
+// Сброс кэша после смены языка
+func forgetUserLang(chatID int64) {
+	languages.mu.Lock()
+	delete(languages.langs, chatID)
+	languages.mu.Unlock()
+}
+
+func monthName(lang string, month time.Month) string {
+	names, ok := monthNames[lang]
+	if !ok {
+		names = monthNames[defaultLang]
+	}
+	return names[(int(month)+11)%12]
+}
+
+func weekdayHeader(lang string) [7]string {
+	if names, ok := weekdayNames[lang]; ok {
+		return names
+	}
+	return weekdayNames[defaultLang]
+}
+
+// Форматирование времени слота
+func formatTime(lang, timeStr string) string {
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return timeStr
+	}
+	layout, ok := timeLayouts[lang]
+	if !ok {
+		layout = timeLayouts[defaultLang]
+	}
+	return t.Format(layout)
+}
+
+// Форматирование даты
+func formatDate(lang string, date time.Time) string {
+	layout, ok := dateLayouts[lang]
+	if !ok {
+		layout = dateLayouts[defaultLang]
+	}
+	return date.Format(layout)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	icalPathPrefix = "/ical/"
+	icalDateFormat = "20060102T150405Z"
+)
+
+var (
+	httpMux    = http.NewServeMux()
+	httpServer *http.Server
+)
+
+// Запуск встроенного HTTP(S)-сервера (подписка на календарь, вебхук)
+func StartHTTPServer(addr string) {
+	httpMux.HandleFunc(icalPathPrefix, handleICalFeed)
+	httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           httpMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if config.TLSCertFile != "" {
+			err = httpServer.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Println("Ошибка HTTP-сервера:", err)
+		}
+	}()
+}
+
+// Остановка HTTP-сервера с ожиданием текущих запросов
+func StopHTTPServer(ctx context.Context) {
+	if httpServer == nil {
+		return
+	}
+	if err := httpServer.Shutdown(ctx); err != nil {
+		fmt.Println("Ошибка остановки HTTP-сервера:", err)
+	}
+}
+
+// Ссылка на календарную ленту пользователя
+func calendarFeedURL(token string) string {
+	return strings.TrimRight(config.PublicURL, "/") + icalPathPrefix + token + ".ics"
+}
+
+// Отдача календарной ленты по секретному токену: /ical/<token>.ics
+func handleICalFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, icalPathPrefix)
+	token := strings.TrimSuffix(name, ".ics")
+	if token == name || token == "" || !IsAlphanumeric(token) {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := store.GetUserByCalendarToken(token)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	events, err := store.GetCalendarEvents(user.TelegramID)
+	if err != nil {
+		fmt.Println("Ошибка получения занятий для календаря:", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	body := buildICal(user, events, time.Now())
+	// ETag не зависит от времени формирования ленты (DTSTAMP)
+	sum := sha256.Sum256([]byte(buildICal(user, events, time.Time{})))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="schedule.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300, must-revalidate")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprint(w, body)
+}
+
+// Формирование календаря в формате iCalendar (RFC 5545); generated — время
+// формирования ленты (DTSTAMP)
+func buildICal(user *User, events []CalendarEvent, generated time.Time) string {
+	loc := config.Location()
+	stamp := generated.UTC().Format(icalDateFormat)
+	var b strings.Builder
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//Tutor Scheduler Bot//RU")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(T(user.Lang(), "ical.name")))
+	writeICalLine(&b, "REFRESH-INTERVAL;VALUE=DURATION:PT15M")
+	writeICalLine(&b, "X-PUBLISHED-TTL:PT15M")
+
+	for _, e := range events {
+		start, err := time.Parse(time.RFC3339, e.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, e.EndTime)
+		if err != nil {
+			end = start.Add(time.Hour)
+		}
+
+		summary, description := icalEventText(user, e)
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, fmt.Sprintf("UID:schedule-%d@tutor-scheduler-bot", e.ID))
+		// Время слотов — настенное время часового пояса расписания, в ленте — UTC
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART:"+fromScheduleTime(start, loc).UTC().Format(icalDateFormat))
+		writeICalLine(&b, "DTEND:"+fromScheduleTime(end, loc).UTC().Format(icalDateFormat))
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(summary))
+		if description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(description))
+		}
+		if e.Status == "free" {
+			writeICalLine(&b, "TRANSP:TRANSPARENT")
+			writeICalLine(&b, "STATUS:TENTATIVE")
+		} else {
+			writeICalLine(&b, "TRANSP:OPAQUE")
+			writeICalLine(&b, "STATUS:CONFIRMED")
+		}
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// Заголовок и описание события в зависимости от роли владельца ленты
+func icalEventText(user *User, e CalendarEvent) (string, string) {
+	direction := "Общее"
+	if e.Direction.Valid && e.Direction.String != "" {
+		direction = e.Direction.String
+	}
+
+	lang := user.Lang()
+	if e.TeacherID != user.TelegramID {
+		return T(lang, "ical.lesson"), T(lang, "ical.direction", direction)
+	}
+	if e.Status == "free" {
+		return T(lang, "ical.free"), ""
+	}
+
+	student := T(lang, "ical.student")
+	if e.StudentUsername.Valid && e.StudentUsername.String != "" {
+		student = "@" + e.StudentUsername.String
+	}
+	return T(lang, "ical.lesson_with", student), T(lang, "ical.direction", direction)
+}
+
+// Экранирование текстовых значений iCalendar
+func escapeICalText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// Запись строки с переносом по 75 октетов и окончанием CRLF
+func writeICalLine(b *strings.Builder, line string) {
+	const limit = 75
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+}
+
+// Команда /calendar_link: ссылка на подписку и кнопка смены ссылки
+func handleCalendarLink(chatID int64) {
+	token, err := store.GetCalendarToken(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения токена календаря:", err)
+		sendMessage(chatID, tr(chatID, "ical.link_error"))
+		return
+	}
+	showCalendarLink(chatID, token, false)
+}
+
+// Смена токена: старая ссылка перестает работать
+func handleCalendarRotate(chatID int64) {
+	token, err := store.RotateCalendarToken(chatID)
+	if err != nil {
+		fmt.Println("Ошибка смены токена календаря:", err)
+		sendMessage(chatID, tr(chatID, "ical.rotate_error"))
+		return
+	}
+	showCalendarLink(chatID, token, true)
+}
+
+func showCalendarLink(chatID int64, token string, rotated bool) {
+	lang := userLang(chatID)
+	var builder strings.Builder
+	if rotated {
+		builder.WriteString(T(lang, "ical.rotated"))
+	}
+	builder.WriteString(T(lang, "ical.link"))
+	builder.WriteString(calendarFeedURL(token))
+	builder.WriteString(T(lang, "ical.hint"))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "ical.rotate"), cbICalRotate),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendPlainMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Лента учителя: экранирование, перенос длинных строк по 75 октетов (не
+// разрывая символы) и перевод настенного времени Москвы в UTC
+const icalGolden = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Tutor Scheduler Bot//RU\r\n" +
+	"CALSCALE:GREGORIAN\r\n" +
+	"METHOD:PUBLISH\r\n" +
+	"X-WR-CALNAME:Занятия по английскому\r\n" +
+	"REFRESH-INTERVAL;VALUE=DURATION:PT15M\r\n" +
+	"X-PUBLISHED-TTL:PT15M\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:schedule-1@tutor-scheduler-bot\r\n" +
+	"DTSTAMP:20261019T090000Z\r\n" +
+	"DTSTART:20261021T153000Z\r\n" +
+	"DTEND:20261021T170000Z\r\n" +
+	"SUMMARY:Занятие: @john_doe\r\n" +
+	"DESCRIPTION:Направление: Business\\, IT\\; exams\\\\prep\\nи раз\r\n" +
+	" говорная практика для путешествий\r\n" +
+	"TRANSP:OPAQUE\r\n" +
+	"STATUS:CONFIRMED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:schedule-2@tutor-scheduler-bot\r\n" +
+	"DTSTAMP:20261019T090000Z\r\n" +
+	"DTSTART:20260328T223000Z\r\n" +
+	"DTEND:20260328T233000Z\r\n" +
+	"SUMMARY:Свободный слот\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"STATUS:TENTATIVE\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestBuildICal(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Moscow"); err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	config.Timezone = "Europe/Moscow"
+	user := &User{TelegramID: 10, Role: "teacher", Language: sql.NullString{String: "ru", Valid: true}}
+	events := []CalendarEvent{
+		{ID: 1, TeacherID: 10, StartTime: "2026-10-21T18:30:00Z", EndTime: "2026-10-21T20:00:00Z", Status: "booked",
+			Direction:       sql.NullString{String: "Business, IT; exams\\prep\nи разговорная практика для путешествий", Valid: true},
+			StudentUsername: sql.NullString{String: "john_doe", Valid: true}},
+		// Время окончания не разобрано — занятие длится час; слот в 01:30 по Москве — еще 28 марта по UTC
+		{ID: 2, TeacherID: 10, StartTime: "2026-03-29T01:30:00Z", EndTime: "bad", Status: "free"},
+		{ID: 3, TeacherID: 10, StartTime: "bad", EndTime: "bad", Status: "free"},
+	}
+
+	got := buildICal(user, events, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	if got != icalGolden {
+		t.Errorf("лента отличается от ожидаемой:\n%s\nожидалось:\n%s", got, icalGolden)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("строка %d длиннее 75 октетов: %q", i+1, line)
+		}
+	}
+}
+
+func TestICalFeed(t *testing.T) {
+	st := newTestStore(t)
+	store = st
+	config.Timezone = "Europe/Moscow"
+	mustExec(t, st.RegisterUser(10, "teacher", "teacher"))
+	mustExec(t, st.AddScheduleSlot(10, "2099-01-10T12:00:00Z", "2099-01-10T13:00:00Z"))
+	token, err := st.GetCalendarToken(10)
+	mustExec(t, err)
+
+	get := func(method, path, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handleICalFeed(w, r)
+		return w
+	}
+
+	w := get(http.MethodGet, icalPathPrefix+token+".ics", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "UID:schedule-") {
+		t.Fatalf("лента: %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Content-Type: %q", ct)
+	}
+	etag := w.Header().Get("ETag")
+	if w := get(http.MethodGet, icalPathPrefix+token+".ics", etag); w.Code != http.StatusNotModified {
+		t.Errorf("повторный запрос с ETag: %d", w.Code)
+	}
+	if w := get(http.MethodHead, icalPathPrefix+token+".ics", ""); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("HEAD: %d, тело %d байт", w.Code, w.Body.Len())
+	}
+
+	for _, path := range []string{icalPathPrefix + "wrong.ics", icalPathPrefix + token, icalPathPrefix + "../" + token + ".ics"} {
+		if w := get(http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: %d, ожидалось 404", path, w.Code)
+		}
+	}
+	if w := get(http.MethodPost, icalPathPrefix+token+".ics", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", w.Code)
+	}
+}
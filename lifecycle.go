@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const shutdownTimeout = 15 * time.Second // Сколько ждать завершения обработчиков при остановке
+
+var (
+	rootCtx   = context.Background() // Корневой контекст, отменяется при остановке бота
+	workersWG sync.WaitGroup         // Фоновые задачи (уведомления, синхронизация)
+)
+
+// Запуск фоновой задачи, которая завершается при отмене контекста
+func runWorker(fn func(ctx context.Context)) {
+	workersWG.Add(1)
+	go func() {
+		defer workersWG.Done()
+		defer recoverPanic("фоновая задача")
+		fn(rootCtx)
+	}()
+}
+
+// Пауза, прерываемая отменой контекста. Возвращает false, если контекст отменен.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Ожидание группы горутин не дольше timeout
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Остановка: прием обновлений уже прекращен, ждем обработчики и фоновые задачи
+func shutdown() {
+	fmt.Println("Остановка бота...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	StopHTTPServer(ctx)
+
+	if dispatcher != nil && !dispatcher.Stop(shutdownTimeout) {
+		fmt.Println("Не все обработчики завершились за", shutdownTimeout)
+	}
+	if !waitTimeout(&workersWG, shutdownTimeout) {
+		fmt.Println("Не все фоновые задачи завершились за", shutdownTimeout)
+	}
+}
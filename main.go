@@ -1,77 +1,110 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 var (
 	bot           *tgbotapi.BotAPI
-	db            *sql.DB
-	lastMessageID map[int64]int // Карта: ChatID -> MessageID
+	store         Store
+	lastMessageID messageIDs // Карта: ChatID -> MessageID
 )
 
 func main() {
-	lastMessageID = make(map[int64]int)
-
 	var err error
-	db, err = InitDB()
+	config, err = LoadConfig()
+	if err != nil {
+		panic("Ошибка загрузки настроек: " + err.Error())
+	}
+
+	sqliteStore, err := NewSQLiteStore(config.DatabaseDSN)
 	if err != nil {
 		panic("Ошибка инициализации базы данных: " + err.Error())
 	}
-	defer db.Close()
+	defer sqliteStore.Close()
+	// Изменения слотов обновляют доски свободных слотов в группах
+	store = NewBoardTracker(sqliteStore)
+
+	// Подкоманды командной строки (импорт/экспорт) работают без Telegram
+	if len(os.Args) > 1 {
+		if err := runCLI(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Ошибка:", err)
+			sqliteStore.Close()
+			os.Exit(1)
+		}
+		return
+	}
 
-	botToken := "ВашТокенБота"
-	if botToken == "" {
+	if config.BotToken == "" {
 		panic("Токен бота не указан. Установите переменную окружения TELEGRAM_BOT_TOKEN")
 	}
 
-	bot, err = tgbotapi.NewBotAPI(botToken)
+	bot, err = tgbotapi.NewBotAPI(config.BotToken)
 	if err != nil {
 		panic("Ошибка инициализации бота: " + err.Error())
 	}
 
 	bot.Debug = true
-
-	StartNotificationScheduler()
-
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
-
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	_, err = bot.RemoveWebhook()
+	tracker, err := NewDeliveryTracker(NewSendQueue(telegramBot{bot}))
 	if err != nil {
-		panic("Ошибка при снятии вебхука: " + err.Error())
+		panic("Ошибка загрузки статусов доставки: " + err.Error())
 	}
+	messenger = tracker
+	registerBotCommands()
 
-	updates, err := bot.GetUpdatesChan(u)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	rootCtx = ctx
+
+	dispatcher = NewDispatcher(config.Workers, handleUpdate)
+	linkConfiguredGroup()
+	StartNotificationScheduler()
+	StartHTTPServer(config.HTTPAddr)
+
+	updates, err := startUpdates()
 	if err != nil {
 		panic("Ошибка получения обновлений: " + err.Error())
 	}
 
-	for update := range updates {
-		if update.UpdateID != 0 {
-			// Лог удален
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case update := <-updates:
+			dispatcher.Dispatch(update)
 		}
-		handleUpdate(update)
 	}
 
-	select {
-	case <-signalChan:
-		// Лог удален
-		return
+	// Прекращаем прием обновлений, дожидаемся обработчиков и фоновых задач,
+	// и только после этого закрываем базу (defer sqliteStore.Close)
+	if config.UpdateMode == updateModePolling {
+		bot.StopReceivingUpdates()
+	}
+	// Обновления, уже подтвержденные Telegram, обрабатываем до остановки
+	for len(updates) > 0 {
+		dispatcher.Dispatch(<-updates)
 	}
+	shutdown()
 }
 
 func handleUpdate(update tgbotapi.Update) {
+	// Группы обрабатываются отдельно: сообщения участников не удаляются
+	if update.Message != nil && !update.Message.Chat.IsPrivate() {
+		handleGroupMessage(update.Message)
+		return
+	}
+	if update.CallbackQuery != nil && update.CallbackQuery.Message != nil && !update.CallbackQuery.Message.Chat.IsPrivate() {
+		answerCallback(update.CallbackQuery, "")
+		return
+	}
+
 	// Лог удален
 	if update.Message != nil {
 		deleteMessage(update.Message.Chat.ID, update.Message.MessageID)
@@ -88,78 +121,94 @@ func handleUpdate(update tgbotapi.Update) {
 
 	if update.Message.IsCommand() {
 		handleCommand(update.Message)
+		return
 	}
+
+	// Ответ на вопрос бота (шаг диалога)
+	if handleDialogMessage(update.Message) {
+		return
+	}
+
+	if update.Message.Document != nil {
+		handleDocument(update.Message)
+		return
+	}
+
+	// Обычный текст: учитель добавляет слоты ("пн 18:00-19:30")
+	handleMessage(update.Message)
 }
 
 func handleCommand(msg *tgbotapi.Message) {
+	// Любая команда прерывает ожидание ввода, а /cancel только отменяет его
+	if cancelDialog(msg.Chat.ID) && msg.Command() == "cancel" {
+		showDialogCancelled(msg.Chat.ID)
+		return
+	}
+
 	switch msg.Command() {
 	case "start":
 		handleStart(msg)
 	case "schedule":
 		handleTeacherSchedule(msg.Chat.ID)
 	case "students":
-		handleTeacherStudents(msg.Chat.ID)
+		handleStudentsCommand(msg.Chat.ID, msg.CommandArguments())
 	case "book":
-		handleStudentBook(msg.Chat.ID)
+		handleBookCommand(msg.Chat.ID, msg.CommandArguments())
+	case "addslot":
+		handleAddSlotCommand(msg.Chat.ID, msg.CommandArguments())
+	case "free":
+		handleFreeCommand(msg.Chat.ID, msg.CommandArguments())
+	case "delslot":
+		handleDeleteSlotCommand(msg.Chat.ID, msg.CommandArguments())
+	case "today":
+		handleToday(msg.Chat.ID)
 	case "mybookings":
 		handleStudentBookings(msg.Chat.ID)
 	case "cancel":
 		handleStudentCancel(msg.Chat.ID)
+	case "calendar_link":
+		handleCalendarLink(msg.Chat.ID)
+	case "add_calendar":
+		handleAddExternalCalendar(msg.Chat.ID, msg.CommandArguments())
+	case "calendars":
+		handleExternalCalendars(msg.Chat.ID)
+	case "export":
+		handleExportSchedules(msg.Chat.ID, msg.CommandArguments())
+	case "export_students":
+		handleExportStudents(msg.Chat.ID)
+	case "language":
+		showLanguageSettings(msg.Chat.ID)
+	case "profile":
+		showProfile(msg.Chat.ID)
+	case "homework":
+		handleHomeworkCommand(msg.Chat.ID)
+	case "stats":
+		showTeacherStats(msg.Chat.ID)
+	case "agenda":
+		showAgenda(msg.Chat.ID)
+	case "groups", "unlinkgroup":
+		showGroups(msg.Chat.ID)
+	case "linkgroup":
+		// С @каналом — привязка канала, без аргумента — список групп, как /groups
+		if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+			linkChannel(msg.Chat.ID, arg)
+		} else {
+			showGroups(msg.Chat.ID)
+		}
 	default:
 		// Неизвестная команда — показываем меню
-		user, err := getUser(msg.Chat.ID)
+		user, err := store.GetUser(msg.Chat.ID)
 		if err != nil {
-			sendMessage(msg.Chat.ID, "Ошибка получения данных пользователя.")
+			sendMessage(msg.Chat.ID, tr(msg.Chat.ID, "error.user"))
 			return
 		}
-		if user.Role == "teacher" {
-			showTeacherMenu(msg.Chat.ID)
-		} else {
-			showStudentMenu(msg.Chat.ID)
-		}
+		showMenu(msg.Chat.ID, user)
 	}
 }
 
 func deleteMessage(chatID int64, messageID int) {
 	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
-	if _, err := bot.Send(deleteMsg); err != nil {
+	if _, err := messenger.Send(deleteMsg); err != nil {
 		fmt.Println("Ошибка удаления сообщения:", err) // Логирование
 	}
 }
-
-// main.go
-func handleMessage(msg *tgbotapi.Message) {
-	// Обработка текстовых сообщений (например, добавление слота)
-	if isValidDateTimeFormat(msg.Text) {
-		parts := strings.Split(msg.Text, " ")
-		if len(parts) == 2 {
-			startTime := parts[0]
-			endTime := parts[1]
-			err := addScheduleSlot(msg.Chat.ID, startTime, endTime)
-			if err != nil {
-				sendMessage(msg.Chat.ID, "Ошибка добавления слота.")
-			} else {
-				sendMessage(msg.Chat.ID, "Слот успешно добавлен!")
-			}
-		} else {
-			sendMessage(msg.Chat.ID, "Неверный формат. Используйте: ГГГГ-ММ-ДДTЧЧ:ММ:ССZ ГГГГ-ММ-ДДTЧЧ:ММ:ССZ")
-		}
-	} else {
-		sendMessage(msg.Chat.ID, "Неверный формат даты и времени. Используйте: ГГГГ-ММ-ДДTЧЧ:ММ:ССZ ГГГГ-ММ-ДДTЧЧ:ММ:ССZ")
-	}
-}
-
-// Новая функция для проверки формата даты и времени
-func isValidDateTimeFormat(text string) bool {
-	parts := strings.Split(text, " ")
-	if len(parts) != 2 {
-		return false
-	}
-	for _, part := range parts {
-		if !strings.HasPrefix(part, strings.Join(strings.Split(time.Now().Format("2006-01-02"), "-"), "-")) &&
-			!isValidTime(part) {
-			return false
-		}
-	}
-	return true
-}
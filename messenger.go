@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Messenger — узкий интерфейс Telegram-клиента, от которого зависят обработчики
+// и уведомления. В работе это telegramBot, в сценарных проверках — FakeMessenger.
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error)
+	GetFileDirectURL(fileID string) (string, error)
+	// Метод Bot API, для которого в библиотеке нет обертки
+	MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error)
+	// Сам бот: ID и имя пользователя
+	Self() tgbotapi.User
+	GetChat(config tgbotapi.ChatConfig) (tgbotapi.Chat, error)
+	PinChatMessage(config tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error)
+	GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error)
+}
+
+var messenger Messenger
+
+// telegramBot — *tgbotapi.BotAPI как Messenger: в библиотеке Self — поле, а не метод
+type telegramBot struct {
+	*tgbotapi.BotAPI
+}
+
+func (b telegramBot) Self() tgbotapi.User {
+	return b.BotAPI.Self
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// FakeMessenger — Messenger в памяти процесса: ничего не отправляет в Telegram,
+// а записывает отправленные, измененные и удаленные сообщения вместе с клавиатурами.
+type FakeMessenger struct {
+	mu        sync.Mutex
+	nextID    int
+	Sent      []FakeMessage                  // Отправленные сообщения и документы
+	Edited    []FakeMessage                  // Изменения текста и клавиатуры
+	Deleted   []FakeMessageRef               // Удаленные сообщения
+	Pinned    []FakeMessageRef               // Закрепленные сообщения
+	Answered  []string                       // ID отвеченных callback-запросов
+	Files     map[string]string              // FileID -> прямая ссылка для GetFileDirectURL
+	Requests  []FakeRequest                  // Прямые вызовы Bot API
+	BotUser   tgbotapi.User                  // Пользователь бота для Self
+	Chats     []tgbotapi.Chat                // Чаты, которые находит GetChat
+	Members   map[[2]int64]string            // {чат, пользователь} -> статус участника для GetChatMember
+	SendError func(tgbotapi.Chattable) error // Ошибка, которую нужно вернуть из Send (если задана)
+}
+
+// FakeMessage представляет записанное сообщение
+type FakeMessage struct {
+	ChatID    int64
+	MessageID int
+	Text      string
+	ParseMode string
+	Keyboard  *tgbotapi.InlineKeyboardMarkup
+	Document  string // Имя отправленного файла
+	FileID    string // file_id отправленного фото или голосового сообщения
+}
+
+// FakeRequest — вызов метода Bot API через MakeRequest
+type FakeRequest struct {
+	Method string
+	Params url.Values
+}
+
+// FakeMessageRef указывает на сообщение в чате
+type FakeMessageRef struct {
+	ChatID    int64
+	MessageID int
+}
+
+func NewFakeMessenger() *FakeMessenger {
+	return &FakeMessenger{
+		Files:   make(map[string]string),
+		BotUser: tgbotapi.User{ID: 777, UserName: "tutor_bot", IsBot: true},
+		Members: make(map[[2]int64]string),
+	}
+}
+
+func (f *FakeMessenger) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.SendError != nil {
+		if err := f.SendError(c); err != nil {
+			return tgbotapi.Message{}, err
+		}
+	}
+
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		f.nextID++
+		f.Sent = append(f.Sent, FakeMessage{
+			ChatID:    m.ChatID,
+			MessageID: f.nextID,
+			Text:      m.Text,
+			ParseMode: m.ParseMode,
+			Keyboard:  inlineKeyboard(m.ReplyMarkup),
+		})
+		return tgbotapi.Message{MessageID: f.nextID, Chat: &tgbotapi.Chat{ID: m.ChatID}, Text: m.Text}, nil
+	case tgbotapi.DocumentConfig:
+		f.nextID++
+		name := ""
+		if file, ok := m.File.(tgbotapi.FileBytes); ok {
+			name = file.Name
+		}
+		f.Sent = append(f.Sent, FakeMessage{ChatID: m.ChatID, MessageID: f.nextID, Text: m.Caption, Document: name, FileID: m.FileID})
+		return tgbotapi.Message{MessageID: f.nextID, Chat: &tgbotapi.Chat{ID: m.ChatID}}, nil
+	case tgbotapi.PhotoConfig:
+		f.nextID++
+		f.Sent = append(f.Sent, FakeMessage{ChatID: m.ChatID, MessageID: f.nextID, Text: m.Caption, FileID: m.FileID})
+		return tgbotapi.Message{MessageID: f.nextID, Chat: &tgbotapi.Chat{ID: m.ChatID}}, nil
+	case tgbotapi.VoiceConfig:
+		f.nextID++
+		f.Sent = append(f.Sent, FakeMessage{ChatID: m.ChatID, MessageID: f.nextID, Text: m.Caption, FileID: m.FileID})
+		return tgbotapi.Message{MessageID: f.nextID, Chat: &tgbotapi.Chat{ID: m.ChatID}}, nil
+	case tgbotapi.EditMessageTextConfig:
+		f.Edited = append(f.Edited, FakeMessage{
+			ChatID:    m.ChatID,
+			MessageID: m.MessageID,
+			Text:      m.Text,
+			ParseMode: m.ParseMode,
+			Keyboard:  m.ReplyMarkup,
+		})
+		return tgbotapi.Message{MessageID: m.MessageID, Chat: &tgbotapi.Chat{ID: m.ChatID}, Text: m.Text}, nil
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		f.Edited = append(f.Edited, FakeMessage{ChatID: m.ChatID, MessageID: m.MessageID, Keyboard: m.ReplyMarkup})
+		return tgbotapi.Message{MessageID: m.MessageID, Chat: &tgbotapi.Chat{ID: m.ChatID}}, nil
+	case tgbotapi.DeleteMessageConfig:
+		f.Deleted = append(f.Deleted, FakeMessageRef{ChatID: m.ChatID, MessageID: m.MessageID})
+		return tgbotapi.Message{}, nil
+	default:
+		return tgbotapi.Message{}, fmt.Errorf("FakeMessenger: неподдерживаемый тип %T", c)
+	}
+}
+
+func (f *FakeMessenger) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Answered = append(f.Answered, config.CallbackQueryID)
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *FakeMessenger) GetFileDirectURL(fileID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, ok := f.Files[fileID]; ok {
+		return u, nil
+	}
+	return "", fmt.Errorf("FakeMessenger: файл %s не найден", fileID)
+}
+
+func (f *FakeMessenger) MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Requests = append(f.Requests, FakeRequest{Method: endpoint, Params: params})
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *FakeMessenger) Self() tgbotapi.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.BotUser
+}
+
+// Чат по ID или @username среди Chats; как и Telegram, неизвестный чат — ошибка
+func (f *FakeMessenger) GetChat(config tgbotapi.ChatConfig) (tgbotapi.Chat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.Chats {
+		if config.SuperGroupUsername == "" && c.ID == config.ChatID ||
+			config.SuperGroupUsername != "" && strings.EqualFold(config.SuperGroupUsername, "@"+c.UserName) {
+			return c, nil
+		}
+	}
+	return tgbotapi.Chat{}, tgbotapi.Error{Message: "Bad Request: chat not found"}
+}
+
+func (f *FakeMessenger) PinChatMessage(config tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Pinned = append(f.Pinned, FakeMessageRef{ChatID: config.ChatID, MessageID: config.MessageID})
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// Участник чата; кого нет в Members, тот не состоит в чате
+func (f *FakeMessenger) GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.Members[[2]int64{config.ChatID, int64(config.UserID)}]
+	if !ok {
+		status = "left"
+	}
+	return tgbotapi.ChatMember{User: &tgbotapi.User{ID: config.UserID}, Status: status}, nil
+}
+
+// Сообщения, отправленные в чат
+func (f *FakeMessenger) SentTo(chatID int64) []FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result []FakeMessage
+	for _, m := range f.Sent {
+		if m.ChatID == chatID {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Последнее сообщение, отправленное в чат
+func (f *FakeMessenger) LastSent(chatID int64) (FakeMessage, bool) {
+	sent := f.SentTo(chatID)
+	if len(sent) == 0 {
+		return FakeMessage{}, false
+	}
+	return sent[len(sent)-1], true
+}
+
+// Очистка записанной истории
+func (f *FakeMessenger) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Sent, f.Edited, f.Deleted, f.Pinned, f.Answered, f.Requests = nil, nil, nil, nil, nil, nil
+}
+
+// Данные кнопок клавиатуры в порядке отображения
+func (m FakeMessage) ButtonData() []string {
+	if m.Keyboard == nil {
+		return nil
+	}
+	var data []string
+	for _, row := range m.Keyboard.InlineKeyboard {
+		for _, b := range row {
+			if b.CallbackData != nil {
+				data = append(data, *b.CallbackData)
+			}
+		}
+	}
+	return data
+}
+
+func inlineKeyboard(markup interface{}) *tgbotapi.InlineKeyboardMarkup {
+	switch k := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return &k
+	case *tgbotapi.InlineKeyboardMarkup:
+		return k
+	}
+	return nil
+}
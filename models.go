@@ -12,6 +12,10 @@ type User struct {
 	Role       string         // Роль: "teacher" или "student"
 	Username   sql.NullString // Имя пользователя в Telegram (может быть NULL)
 	Contact    sql.NullString // Контактная информация (может быть NULL)
+	// Статус доставки: active, blocked (бот заблокирован) или deactivated (аккаунт удален)
+	DeliveryStatus string
+	Language       sql.NullString // Язык, выбранный в настройках (NULL — как в Telegram)
+	LanguageCode   sql.NullString // language_code из Telegram
 }
 
 // Schedule представляет слот в расписании
@@ -63,10 +67,21 @@ type Slot struct {
 
 // TeacherStats представляет статистику учителя
 type TeacherStats struct {
-	TotalSlots    int // Общее количество слотов
-	BookedSlots   int // Количество забронированных слотов
-	FreeSlots     int // Количество свободных слотов
-	Cancellations int // Количество отмен
+	TotalSlots    int     // Общее количество слотов
+	BookedSlots   int     // Количество забронированных слотов
+	FreeSlots     int     // Количество свободных слотов
+	Cancellations int     // Количество отмен
+	Surveyed      int     // Занятий, после которых ученику отправлен опрос
+	Rated         int     // Занятий с оценкой ученика
+	AverageRating float64 // Средняя оценка учеников
+	Summaries     int     // Занятий с итогом учителя
+}
+
+// RatingStat — средняя оценка занятий одного направления
+type RatingStat struct {
+	Direction string
+	Count     int
+	Average   float64
 }
 
 // StudentStats представляет статистику ученика
@@ -78,10 +93,13 @@ type StudentStats struct {
 
 // NotificationSettings представляет настройки уведомлений
 type NotificationSettings struct {
-	UserID              int64 // ID пользователя
-	EnableReminders     bool  // Включены ли напоминания
-	EnableNewBookings   bool  // Включены ли уведомления о новых записях
-	EnableCancellations bool  // Включены ли уведомления об отменах
+	UserID              int64  // ID пользователя
+	EnableReminders     bool   // Включены ли напоминания
+	EnableNewBookings   bool   // Включены ли уведомления о новых записях
+	EnableCancellations bool   // Включены ли уведомления об отменах
+	EnableAgenda        bool   // Присылать ли план на день
+	AgendaTime          string // Время плана на день в часовом поясе расписания, "08:00"
+	PinAgenda           bool   // Закреплять ли план на день в личном чате
 }
 
 // ErrorLog представляет запись об ошибке
@@ -99,3 +117,203 @@ type Notification struct {
 	CreatedAt string // Время создания (RFC3339)
 	IsRead    bool   // Прочитано или нет
 }
+
+// CalendarEvent представляет занятие в календарной ленте iCal
+type CalendarEvent struct {
+	ID              int            // Идентификатор слота
+	TeacherID       int64          // ID учителя
+	StartTime       string         // Время начала занятия (RFC3339)
+	EndTime         string         // Время окончания занятия (RFC3339)
+	Status          string         // Статус: "free" или "booked"
+	Direction       sql.NullString // Направление (может быть NULL)
+	StudentUsername sql.NullString // Имя пользователя ученика (NULL для свободного слота)
+}
+
+// ExternalCalendar представляет внешний календарь учителя (ICS-ссылка)
+type ExternalCalendar struct {
+	ID           int64          // Уникальный идентификатор календаря
+	TeacherID    int64          // ID учителя
+	URL          string         // Адрес ICS-файла
+	LastSyncedAt sql.NullString // Время последней успешной загрузки (RFC3339)
+	LastError    sql.NullString // Ошибка последней загрузки
+}
+
+// BusyInterval представляет занятый интервал времени
+type BusyInterval struct {
+	Start time.Time // Начало
+	End   time.Time // Окончание
+}
+
+// ScheduleFilter задает отбор слотов для выгрузки
+type ScheduleFilter struct {
+	TeacherID int64  // ID учителя (0 — все учителя)
+	From      string // Начало периода включительно (RFC3339, пусто — без ограничения)
+	To        string // Конец периода не включительно (RFC3339, пусто — без ограничения)
+	Status    string // Статус слота (пусто — любой)
+}
+
+// ScheduleExportRow представляет строку выгрузки расписания
+type ScheduleExportRow struct {
+	ID              int
+	TeacherID       int64
+	StartTime       string
+	EndTime         string
+	Status          string
+	StudentID       sql.NullInt64
+	StudentUsername sql.NullString
+	StudentContact  sql.NullString
+	Direction       sql.NullString
+}
+
+// StudentExportRow представляет строку выгрузки учеников
+type StudentExportRow struct {
+	TelegramID int64
+	Username   sql.NullString
+	Contact    sql.NullString
+	Bookings   int // Количество активных записей
+}
+
+// ImportReport содержит результат импорта слотов из CSV
+type ImportReport struct {
+	Total    int              // Количество строк с данными
+	Accepted int              // Количество принятых (или добавленных) слотов
+	Rejected []ImportRejected // Отклоненные строки
+	DryRun   bool             // Пробный запуск без записи в базу
+}
+
+// ImportRejected описывает отклоненную строку CSV
+type ImportRejected struct {
+	Line   int    // Номер строки в файле
+	Reason string // Причина отказа
+}
+
+// DialogState — чат ждет текстового ответа для шага диалога
+type DialogState struct {
+	ChatID    int64
+	Step      string // Имя шага, которому уйдет следующее сообщение
+	Data      string // Данные шага (например, выбранная дата)
+	ExpiresAt string // После этого времени ответ не принимается
+}
+
+// StudentProfile — анкета ученика, заполняемая при первом /start
+type StudentProfile struct {
+	TelegramID  int64
+	Name        sql.NullString // Как обращаться к ученику
+	Phone       sql.NullString // Телефон из контакта Telegram
+	Level       sql.NullString // Уровень английского: A1…C2 или unknown
+	Goals       sql.NullString // Цели обучения
+	Direction   sql.NullString // Предпочитаемое направление занятий
+	CompletedAt sql.NullString // Время завершения анкеты (RFC3339); NULL — анкета не заполнена
+}
+
+// StudentSummary — строка справочника учеников учителя
+type StudentSummary struct {
+	TelegramID    int64
+	Username      sql.NullString
+	Name          sql.NullString // Имя из анкеты
+	Phone         sql.NullString // Телефон из анкеты или контакт пользователя
+	Level         sql.NullString
+	Blocked       bool
+	Upcoming      int // Предстоящие занятия с учителем
+	Past          int // Прошедшие занятия с учителем
+	Cancellations int // Отмененные учеником записи
+}
+
+// Cancellation — отмена записи учеником
+type Cancellation struct {
+	SlotID      int64
+	TeacherID   int64
+	StudentID   int64
+	StartTime   string
+	EndTime     string
+	CancelledAt string
+	Reason      string // Причина от ученика; пустая строка — не указана
+}
+
+// StudentNote — заметка учителя об ученике или занятии, видна только учителю
+type StudentNote struct {
+	ID          int64
+	TeacherID   int64
+	StudentID   int64
+	SlotID      sql.NullInt64  // Занятие, к которому относится заметка (NULL — заметка об ученике)
+	Kind        string         // Вид заметки: text, voice или photo
+	Text        string         // Текст заметки или подпись к голосовому/фото
+	FileID      sql.NullString // file_id голосового сообщения или фото в Telegram
+	CreatedAt   string
+	LessonStart sql.NullString // Время начала занятия (только при чтении)
+}
+
+// Homework — домашнее задание к занятию
+type Homework struct {
+	ID          int64
+	SlotID      int64
+	TeacherID   int64
+	StudentID   int64
+	Status      string // draft, assigned, submitted или reviewed
+	Text        string // Текст задания
+	DueAt       sql.NullString
+	CreatedAt   string
+	SentAt      sql.NullString // Когда задание отправлено ученику (после занятия)
+	RemindedAt  sql.NullString // Когда ученику напомнили о сроке
+	Answer      string         // Текст ответа ученика
+	SubmittedAt sql.NullString
+	Grade       sql.NullInt64 // Оценка от 1 до 5
+	Comment     string        // Комментарий учителя к ответу
+	ReviewedAt  sql.NullString
+	LessonStart sql.NullString // Время начала занятия (только при чтении)
+	LessonEnd   sql.NullString // Время окончания занятия (только при чтении)
+}
+
+// HomeworkFile — файл задания (от учителя) или ответа (от ученика)
+type HomeworkFile struct {
+	ID         int64
+	HomeworkID int64
+	Owner      string // teacher или student
+	Kind       string // document или photo
+	FileID     string // file_id в Telegram
+	FileName   string
+}
+
+// LessonFeedback — отзыв ученика и итог учителя по прошедшему занятию
+type LessonFeedback struct {
+	SlotID       int64
+	TeacherID    int64
+	StudentID    int64
+	Direction    string // Направление занятия на момент опроса
+	Rating       sql.NullInt64
+	Comment      string
+	RatedAt      sql.NullString
+	Summary      string // Итог занятия от учителя
+	SummarizedAt sql.NullString
+	CreatedAt    string // Когда отправлен опрос
+}
+
+// GroupChat — группа, привязанная учителем, и события, которые туда публикуются
+type GroupChat struct {
+	ChatID    int64
+	TeacherID int64
+	Title     string
+	Reminders bool // Напоминания за 10 минут до занятия
+	Agenda    bool // Ежедневная сводка занятий
+	FreeSlots bool // Новые свободные слоты
+	Board     bool // Закрепленная доска свободных слотов
+	PinAgenda bool // Закреплять ли сводку занятий в группе
+	LinkedAt  string
+}
+
+// DailyAgenda — отправленный план на день, который обновляется до конца дня
+type DailyAgenda struct {
+	ChatID    int64 // Личный чат учителя или привязанная группа
+	TeacherID int64
+	Day       string // День плана, YYYY-MM-DD
+	MessageID int
+	Text      string // Текст последней версии сообщения
+}
+
+// SlotBoard — закрепленное сообщение со свободными слотами учителя в группе или канале
+type SlotBoard struct {
+	ChatID    int64
+	TeacherID int64
+	MessageID int
+	Text      string // Текст последней версии сообщения
+}
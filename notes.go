@@ -0,0 +1,413 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Заметки учителя: об ученике или о конкретном занятии. Заметка — текст,
+// голосовое сообщение или фото; файлы остаются в Telegram, в базе хранится
+// только file_id. Ученик заметок не видит.
+
+// Виды заметок
+const (
+	noteText  = "text"
+	noteVoice = "voice"
+	notePhoto = "photo"
+)
+
+const (
+	timelinePerPage  = 10 // Событий на странице истории ученика
+	scheduleLessons  = 20 // Кнопок занятий в расписании
+	scheduleLookback = 30 * 24 * time.Hour
+)
+
+// Заметка из сообщения учителя; false — в сообщении нет ни текста, ни голосового, ни фото
+func noteFromMessage(msg *tgbotapi.Message) (StudentNote, bool) {
+	note := StudentNote{CreatedAt: time.Now().Format(time.RFC3339)}
+	switch {
+	case msg.Voice != nil:
+		note.Kind = noteVoice
+		note.Text = strings.TrimSpace(msg.Caption)
+		note.FileID = sql.NullString{String: msg.Voice.FileID, Valid: true}
+	case msg.Photo != nil && len(*msg.Photo) > 0:
+		// Telegram присылает несколько размеров, последний — самый большой
+		photos := *msg.Photo
+		note.Kind = notePhoto
+		note.Text = strings.TrimSpace(msg.Caption)
+		note.FileID = sql.NullString{String: photos[len(photos)-1].FileID, Valid: true}
+	case strings.TrimSpace(msg.Text) != "":
+		note.Kind = noteText
+		note.Text = strings.TrimSpace(msg.Text)
+	default:
+		return note, false
+	}
+	return note, utf8.RuneCountInString(note.Text) <= maxNoteLength
+}
+
+// Значок вида заметки
+func noteIcon(kind string) string {
+	switch kind {
+	case noteVoice:
+		return "🎤"
+	case notePhoto:
+		return "🖼"
+	}
+	return "📝"
+}
+
+// Строка заметки; withLesson — указать занятие, к которому она относится
+func formatNote(lang string, n StudentNote, withLesson bool) string {
+	text := n.Text
+	if text == "" {
+		text = T(lang, "notes.kind."+n.Kind)
+	}
+	line := htmlf("%s %s: %s", noteIcon(n.Kind), formatTime(lang, n.CreatedAt), text)
+	if withLesson && n.LessonStart.Valid {
+		line += htmlf(T(lang, "notes.lesson_suffix"), formatTime(lang, n.LessonStart.String))
+	}
+	return line + "\n"
+}
+
+// Кнопка просмотра голосового или фото
+func noteMediaButton(lang string, n StudentNote) tgbotapi.InlineKeyboardButton {
+	return callbackButton(T(lang, "notes.show_media", noteIcon(n.Kind), formatTime(lang, n.CreatedAt)), cbNoteShow, cbID(n.ID))
+}
+
+// Запрос заметки об ученике
+func askStudentNote(chatID, studentID int64) error {
+	prompt := htmlf(tr(chatID, "notes.student_prompt"), studentName(studentID))
+	return askInput(chatID, dialogStudentNote, strconv.FormatInt(studentID, 10), prompt)
+}
+
+// Запрос заметки к занятию
+func askLessonNote(chatID, slotID int64) error {
+	slot, err := authorizeSlotOwner(chatID, slotID, "заметка к занятию")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return nil
+	}
+	lang := userLang(chatID)
+	if slot.Status != "booked" || !slot.StudentID.Valid {
+		sendMessage(chatID, T(lang, "notes.lesson_free"))
+		return nil
+	}
+	prompt := htmlf(T(lang, "notes.lesson_prompt"), formatTime(lang, slot.StartTime), studentName(slot.StudentID.Int64))
+	return askInput(chatID, dialogLessonNote, strconv.FormatInt(slotID, 10), prompt)
+}
+
+// Кнопки занятий в расписании: записи за последний месяц и предстоящие
+func lessonButtons(lang string, schedules []Schedule) [][]tgbotapi.InlineKeyboardButton {
+	from := scheduleNow().Add(-scheduleLookback)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	count := 0
+	for _, s := range schedules {
+		start, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil || s.Status != "booked" || start.Before(from) {
+			continue
+		}
+		row = append(row, callbackButton("📖 "+formatTime(lang, s.StartTime), cbLesson, cbID(int64(s.ID))))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+		if count++; count == scheduleLessons {
+			break
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Занятие: время, ученик и заметки к нему
+func showLesson(chatID, slotID int64) {
+	lang := userLang(chatID)
+	slot, err := authorizeSlotOwner(chatID, slotID, "просмотр занятия")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	notes, err := store.GetLessonNotes(slotID)
+	if err != nil {
+		fmt.Println("Ошибка получения заметок занятия:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "notes.lesson_title"))
+	builder.WriteString(htmlf("🕒 %s - %s\n", formatTime(lang, slot.StartTime), formatTime(lang, slot.EndTime)))
+	builder.WriteString(T(lang, "notes.lesson_status", statusText(lang, slot.Status)))
+	if slot.StudentID.Valid {
+		builder.WriteString(htmlf(T(lang, "notes.lesson_student"), studentName(slot.StudentID.Int64)))
+	}
+	if slot.Direction.Valid && slot.Direction.String != "" {
+		builder.WriteString(htmlf(T(lang, "notes.lesson_direction"), slot.Direction.String))
+	}
+	if hw, err := store.GetLessonHomework(slotID); err != nil {
+		fmt.Println("Ошибка получения домашнего задания:", err)
+	} else if hw != nil {
+		builder.WriteString(T(lang, "homework.lesson_status", homeworkIcon(hw), homeworkStatusText(lang, hw)))
+	}
+	builder.WriteString(formatLessonFeedback(lang, slotID))
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if len(notes) == 0 {
+		builder.WriteString(T(lang, "notes.none"))
+	} else {
+		builder.WriteString(T(lang, "notes.title", len(notes)))
+	}
+	for _, n := range notes {
+		builder.WriteString(formatNote(lang, n, false))
+		if n.FileID.Valid {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(noteMediaButton(lang, n)))
+		}
+	}
+
+	id := cbID(slotID)
+	if slot.Status == "booked" && slot.StudentID.Valid {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "notes.add_lesson"), cbLessonNote, id),
+			callbackButton(T(lang, "notes.student_card"), cbStudentCard, cbID(slot.StudentID.Int64)),
+		))
+		row := tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "homework.lesson_button"), cbLessonHomework, id),
+		)
+		if f, err := store.GetLessonFeedback(slotID); err == nil && f != nil {
+			row = append(row, callbackButton(T(lang, "feedback.write_summary"), cbLessonSummary, id))
+		}
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "notes.back_schedule"), cbTeacherSchedule),
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Голосовое или фото из заметки
+func showNoteMedia(chatID, noteID int64) {
+	const action = "просмотр заметки"
+	if err := authorizeTeacher(chatID, action); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	note, err := store.GetStudentNote(noteID)
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	if note.TeacherID != chatID {
+		sendAuthError(chatID, deny(chatID, action, fmt.Sprintf("заметка %d принадлежит учителю %d", noteID, note.TeacherID)))
+		return
+	}
+	if !note.FileID.Valid {
+		return
+	}
+
+	// Медиа не удаляем следующим сообщением бота, чтобы его можно было дослушать
+	var media tgbotapi.Chattable
+	switch note.Kind {
+	case noteVoice:
+		voice := tgbotapi.NewVoiceShare(chatID, note.FileID.String)
+		voice.Caption = note.Text
+		media = voice
+	case notePhoto:
+		photo := tgbotapi.NewPhotoShare(chatID, note.FileID.String)
+		photo.Caption = note.Text
+		media = photo
+	default:
+		return
+	}
+	if _, err := messenger.Send(media); err != nil {
+		fmt.Println("Ошибка отправки медиа заметки:", err, "chatID:", chatID)
+		sendMessage(chatID, tr(chatID, "notes.media_error"))
+	}
+}
+
+// Событие в истории ученика
+type timelineEvent struct {
+	At     time.Time
+	Text   string
+	Button *tgbotapi.InlineKeyboardButton // Кнопка события (занятие или медиа заметки)
+}
+
+// История ученика: занятия, отмены и заметки по времени, сначала последние
+func showStudentTimeline(chatID, studentID int64, page int) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "история ученика"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	lessons, err := store.GetStudentLessons(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения занятий ученика:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	cancellations, err := store.GetStudentCancellations(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения отмен ученика:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	notes, err := store.GetStudentNotes(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения заметок:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	var events []timelineEvent
+	now := scheduleNow() // Время занятий — настенное время расписания
+	for _, l := range lessons {
+		at, err := time.Parse(time.RFC3339, l.StartTime)
+		if err != nil {
+			continue
+		}
+		icon := "✔️"
+		if at.After(now) {
+			icon = "🕒"
+		}
+		button := callbackButton("📖 "+formatTime(lang, l.StartTime), cbLesson, cbID(int64(l.ID)))
+		events = append(events, timelineEvent{
+			At:     at,
+			Text:   htmlf(T(lang, "notes.timeline_lesson"), icon, formatTime(lang, l.StartTime), l.Direction.String),
+			Button: &button,
+		})
+	}
+	for _, c := range cancellations {
+		at, err := time.Parse(time.RFC3339, c.CancelledAt)
+		if err != nil {
+			continue
+		}
+		events = append(events, timelineEvent{
+			At:   at,
+			Text: T(lang, "notes.timeline_cancel", formatTime(lang, c.CancelledAt), formatTime(lang, c.StartTime)),
+		})
+	}
+	for _, n := range notes {
+		at, err := time.Parse(time.RFC3339, n.CreatedAt)
+		if err != nil {
+			continue
+		}
+		e := timelineEvent{At: at, Text: formatNote(lang, n, true)}
+		if n.FileID.Valid {
+			button := noteMediaButton(lang, n)
+			e.Button = &button
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+
+	pages := (len(events) + timelinePerPage - 1) / timelinePerPage
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	from := page * timelinePerPage
+	to := from + timelinePerPage
+	if to > len(events) {
+		to = len(events)
+	}
+
+	var builder strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	builder.WriteString(htmlf(T(lang, "notes.timeline_title"), studentName(studentID)))
+	if len(events) == 0 {
+		builder.WriteString(T(lang, "notes.timeline_empty"))
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	for _, e := range events[from:to] {
+		builder.WriteString(e.Text)
+		if e.Button != nil {
+			row = append(row, *e.Button)
+			if len(row) == 2 {
+				buttons = append(buttons, row)
+				row = nil
+			}
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+
+	id := cbID(studentID)
+	if pages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, callbackButton("⬅️", cbStudentTimeline, id, cbID(int64(page-1))))
+		}
+		navRow = append(navRow, callbackButton(fmt.Sprintf("%d/%d", page+1, pages), cbNoop))
+		if page < pages-1 {
+			navRow = append(navRow, callbackButton("➡️", cbStudentTimeline, id, cbID(int64(page+1))))
+		}
+		buttons = append(buttons, navRow)
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "students.add_note"), cbStudentNote, id),
+		callbackButton(T(lang, "students.back_to_card"), cbStudentCard, id),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogStudentNote, Role: "teacher", Handle: func(c *DialogContext) error {
+		studentID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		note, ok := noteFromMessage(c.Msg)
+		if !ok {
+			return c.Retry(tr(c.ChatID, "notes.bad"))
+		}
+		note.TeacherID = c.ChatID
+		note.StudentID = studentID
+		if err := store.AddStudentNote(note); err != nil {
+			return err
+		}
+		showStudentCard(c.ChatID, studentID)
+		return nil
+	}})
+	registerDialogStep(DialogStep{Name: dialogLessonNote, Role: "teacher", Handle: func(c *DialogContext) error {
+		slotID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		slot, err := authorizeSlotOwner(c.ChatID, slotID, "заметка к занятию")
+		if err != nil {
+			sendAuthError(c.ChatID, err)
+			return nil
+		}
+		if !slot.StudentID.Valid {
+			sendMessage(c.ChatID, tr(c.ChatID, "notes.lesson_free"))
+			return nil
+		}
+		note, ok := noteFromMessage(c.Msg)
+		if !ok {
+			return c.Retry(tr(c.ChatID, "notes.bad"))
+		}
+		note.TeacherID = c.ChatID
+		note.StudentID = slot.StudentID.Int64
+		note.SlotID = sql.NullInt64{Int64: slotID, Valid: true}
+		if err := store.AddStudentNote(note); err != nil {
+			return err
+		}
+		showLesson(c.ChatID, slotID)
+		return nil
+	}})
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Анкета ученика: при первом /start бот спрашивает имя, телефон (контактом
+// Telegram), уровень английского, цели и направление. Ответы сохраняются
+// после каждого шага, учитель видит анкету в карточке ученика. Шаги анкеты
+// хранят параметр /start (data), чтобы после анкеты, например, предложить
+// слот, выбранный на доске.
+
+const (
+	profileTimeout    = 24 * time.Hour // Анкету можно заполнять с перерывами
+	maxProfileName    = 100
+	maxProfileText    = 500
+	profileLevelUnset = "unknown"
+)
+
+// Уровни английского
+var englishLevels = []string{"A1", "A2", "B1", "B2", "C1", "C2", profileLevelUnset}
+
+// Направления занятий; свой вариант ученик может написать текстом
+var lessonDirections = []string{"general", "speaking", "business", "exams", "travel"}
+
+var rePhone = regexp.MustCompile(`^\+?\d[\d ()-]{5,19}$`)
+
+// Начало анкеты; welcome — приветствие нового ученика, payload — параметр /start
+func startProfile(chatID int64, welcome bool, payload string) error {
+	lang := userLang(chatID)
+	prompt := T(lang, "profile.ask_name")
+	if welcome {
+		prompt = T(lang, "profile.welcome") + prompt
+	}
+	return askInput(chatID, dialogProfileName, payload, prompt)
+}
+
+// Снятие ожидания ответа анкеты (ответ дан кнопкой); возвращает параметр /start
+func takeProfilePayload(chatID int64) string {
+	state, err := store.TakeDialogState(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения состояния диалога:", err, "chatID:", chatID)
+		return ""
+	}
+	if state == nil {
+		return ""
+	}
+	switch state.Step {
+	case dialogProfileName, dialogProfilePhone, dialogProfileLevel, dialogProfileGoals, dialogProfileDirection:
+		return state.Data
+	}
+	return ""
+}
+
+// Запрос телефона: кнопка отправки контакта и пропуск на обычной клавиатуре
+func askProfilePhone(chatID int64, payload, prompt string) error {
+	if err := waitInput(chatID, dialogProfilePhone, payload); err != nil {
+		return err
+	}
+	lang := userLang(chatID)
+	keyboard := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonContact(T(lang, "profile.share_phone"))),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(T(lang, "profile.skip"))),
+	)
+	keyboard.ResizeKeyboard = true
+	keyboard.OneTimeKeyboard = true
+	return sendMessageWithReplyMarkup(chatID, prompt, keyboard)
+}
+
+func askProfileLevel(chatID int64, payload, prompt string) error {
+	lang := userLang(chatID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, level := range englishLevels {
+		row = append(row, callbackButton(levelName(lang, level), cbProfileLevel, level))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return askInputWithButtons(chatID, dialogProfileLevel, payload, prompt, rows)
+}
+
+func askProfileGoals(chatID int64, payload, prompt string) error {
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(callbackButton(tr(chatID, "profile.skip"), cbProfileSkipGoals)),
+	}
+	return askInputWithButtons(chatID, dialogProfileGoals, payload, prompt, rows)
+}
+
+func askProfileDirection(chatID int64, payload, prompt string) error {
+	lang := userLang(chatID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range lessonDirections {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(directionName(lang, d), cbProfileDirection, d),
+		))
+	}
+	return askInputWithButtons(chatID, dialogProfileDirection, payload, prompt, rows)
+}
+
+// Изменение анкеты с сохранением; анкета создается при первом ответе
+func updateProfile(chatID int64, apply func(p *StudentProfile)) (*StudentProfile, error) {
+	p, err := store.GetStudentProfile(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &StudentProfile{TelegramID: chatID}
+	}
+	apply(p)
+	if err := store.SaveStudentProfile(*p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Уровень выбран кнопкой или введен текстом
+func setProfileLevel(chatID int64, level, payload string) error {
+	if _, err := updateProfile(chatID, func(p *StudentProfile) {
+		p.Level = sql.NullString{String: level, Valid: true}
+	}); err != nil {
+		return err
+	}
+	return askProfileGoals(chatID, payload, tr(chatID, "profile.ask_goals"))
+}
+
+// Цели введены или пропущены (пустая строка)
+func setProfileGoals(chatID int64, goals, payload string) error {
+	if _, err := updateProfile(chatID, func(p *StudentProfile) {
+		p.Goals = sql.NullString{String: goals, Valid: goals != ""}
+	}); err != nil {
+		return err
+	}
+	return askProfileDirection(chatID, payload, tr(chatID, "profile.ask_direction"))
+}
+
+// Последний шаг: направление, после него анкета считается заполненной
+func setProfileDirection(chatID int64, direction, payload string) error {
+	firstTime := false
+	p, err := updateProfile(chatID, func(p *StudentProfile) {
+		p.Direction = sql.NullString{String: direction, Valid: true}
+		firstTime = !p.CompletedAt.Valid
+		p.CompletedAt = sql.NullString{String: time.Now().UTC().Format(time.RFC3339), Valid: true}
+	})
+	if err != nil {
+		return err
+	}
+
+	if firstTime && config.TeacherID != 0 {
+		teacherLang := userLang(config.TeacherID)
+		message := T(teacherLang, "profile.new_student", p.Name.String, levelName(teacherLang, p.Level.String))
+		if err := store.AddNotification(config.TeacherID, message); err != nil {
+			fmt.Println("Ошибка добавления уведомления о новом ученике:", err)
+		}
+	}
+
+	lang := userLang(chatID)
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		return err
+	}
+	text := T(lang, "profile.done") + formatProfile(lang, user, p)
+	// Ученик пришел по кнопке «Записаться» с доски — предлагаем выбранный слот
+	if slotID, ok := slotFromStartPayload(payload); ok {
+		showSlotOffer(chatID, slotID, text+"\n")
+		return nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "menu.student.book"), cbBookCalendar),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	return sendMessageWithKeyboard(chatID, text, &keyboard)
+}
+
+// Название уровня; неизвестное значение показывается как есть
+func levelName(lang, level string) string {
+	for _, l := range englishLevels {
+		if l == level {
+			return T(lang, "level."+level)
+		}
+	}
+	return level
+}
+
+// Название направления; свой вариант ученика показывается как есть
+func directionName(lang, direction string) string {
+	for _, d := range lessonDirections {
+		if d == direction {
+			return T(lang, "direction."+direction)
+		}
+	}
+	return direction
+}
+
+// Поля анкеты для показа ученику или учителю
+func formatProfile(lang string, user *User, p *StudentProfile) string {
+	if p == nil {
+		p = &StudentProfile{}
+	}
+	value := func(s sql.NullString) string {
+		if !s.Valid || s.String == "" {
+			return T(lang, "profile.none")
+		}
+		return s.String
+	}
+
+	var builder strings.Builder
+	builder.WriteString(htmlf(T(lang, "profile.field.name"), value(p.Name)))
+	if user != nil && user.Username.Valid && user.Username.String != "" {
+		builder.WriteString(htmlf(T(lang, "profile.field.username"), user.Username.String))
+	}
+	phone := p.Phone
+	if !phone.Valid && user != nil {
+		phone = user.Contact
+	}
+	builder.WriteString(htmlf(T(lang, "profile.field.phone"), value(phone)))
+	level := p.Level
+	level.String = levelName(lang, level.String)
+	builder.WriteString(htmlf(T(lang, "profile.field.level"), value(level)))
+	builder.WriteString(htmlf(T(lang, "profile.field.goals"), value(p.Goals)))
+	direction := p.Direction
+	direction.String = directionName(lang, direction.String)
+	builder.WriteString(htmlf(T(lang, "profile.field.direction"), value(direction)))
+	if !p.CompletedAt.Valid {
+		builder.WriteString(T(lang, "profile.unfinished"))
+	}
+	return builder.String()
+}
+
+// Имя ученика для списков: из анкеты, иначе @username, иначе ID
+func studentDisplayName(user *User, p *StudentProfile) string {
+	if p != nil && p.Name.Valid && p.Name.String != "" {
+		return p.Name.String
+	}
+	if user != nil && user.Username.Valid && user.Username.String != "" {
+		return "@" + user.Username.String
+	}
+	if user != nil {
+		return fmt.Sprintf("ID%d", user.TelegramID)
+	}
+	return ""
+}
+
+// Анкета ученика (для самого ученика)
+func showProfile(chatID int64) {
+	lang := userLang(chatID)
+	user, err := store.GetUser(chatID)
+	if err != nil {
+		sendMessage(chatID, T(lang, "error.user"))
+		return
+	}
+	p, err := store.GetStudentProfile(chatID)
+	if err != nil {
+		fmt.Println("Ошибка получения анкеты:", err, "chatID:", chatID)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	text := T(lang, "profile.title") + formatProfile(lang, user, p)
+	button := T(lang, "profile.edit")
+	if p == nil {
+		text = T(lang, "profile.empty")
+		button = T(lang, "profile.fill")
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(button, cbProfileEdit),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, text, &keyboard)
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogProfileName, Role: "student", Timeout: profileTimeout, Handle: func(c *DialogContext) error {
+		name := c.Text()
+		if name == "" || utf8.RuneCountInString(name) > maxProfileName {
+			return c.Retry(tr(c.ChatID, "profile.bad_name"))
+		}
+		if _, err := updateProfile(c.ChatID, func(p *StudentProfile) {
+			p.Name = sql.NullString{String: name, Valid: true}
+		}); err != nil {
+			return err
+		}
+		return askProfilePhone(c.ChatID, c.Data, tr(c.ChatID, "profile.ask_phone"))
+	}})
+	registerDialogStep(DialogStep{Name: dialogProfilePhone, Role: "student", Timeout: profileTimeout, Handle: func(c *DialogContext) error {
+		lang := userLang(c.ChatID)
+		var phone string
+		switch contact := c.Msg.Contact; {
+		case contact != nil && contact.UserID != 0 && int64(contact.UserID) != c.ChatID:
+			return askProfilePhone(c.ChatID, c.Data, T(lang, "profile.foreign_contact"))
+		case contact != nil:
+			phone = contact.PhoneNumber
+		case c.Text() == T(lang, "profile.skip"):
+		case rePhone.MatchString(c.Text()):
+			phone = c.Text()
+		default:
+			return askProfilePhone(c.ChatID, c.Data, T(lang, "profile.bad_phone"))
+		}
+
+		if phone != "" {
+			if _, err := updateProfile(c.ChatID, func(p *StudentProfile) {
+				p.Phone = sql.NullString{String: phone, Valid: true}
+			}); err != nil {
+				return err
+			}
+			if err := store.SetUserContact(c.ChatID, phone); err != nil {
+				return err
+			}
+		}
+		// Убираем клавиатуру с кнопкой контакта
+		sendMessageWithReplyMarkup(c.ChatID, T(lang, "profile.thanks"), tgbotapi.NewRemoveKeyboard(false))
+		return askProfileLevel(c.ChatID, c.Data, T(lang, "profile.ask_level"))
+	}})
+	registerDialogStep(DialogStep{Name: dialogProfileLevel, Role: "student", Timeout: profileTimeout, Handle: func(c *DialogContext) error {
+		level := strings.ToUpper(c.Text())
+		for _, l := range englishLevels {
+			if l == level {
+				return setProfileLevel(c.ChatID, level, c.Data)
+			}
+		}
+		return askProfileLevel(c.ChatID, c.Data, tr(c.ChatID, "profile.bad_level"))
+	}})
+	registerDialogStep(DialogStep{Name: dialogProfileGoals, Role: "student", Timeout: profileTimeout, Handle: func(c *DialogContext) error {
+		goals := c.Text()
+		if goals == "" || utf8.RuneCountInString(goals) > maxProfileText {
+			return askProfileGoals(c.ChatID, c.Data, tr(c.ChatID, "profile.bad_goals"))
+		}
+		return setProfileGoals(c.ChatID, goals, c.Data)
+	}})
+	registerDialogStep(DialogStep{Name: dialogProfileDirection, Role: "student", Timeout: profileTimeout, Handle: func(c *DialogContext) error {
+		direction := c.Text()
+		if direction == "" || utf8.RuneCountInString(direction) > maxProfileName {
+			return askProfileDirection(c.ChatID, c.Data, tr(c.ChatID, "profile.bad_direction"))
+		}
+		return setProfileDirection(c.ChatID, direction, c.Data)
+	}})
+}
@@ -0,0 +1,697 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Сценарные проверки: база в памяти, FakeMessenger вместо Telegram и
+// обновления, которые проходят через handleUpdate, как от настоящего бота
+
+const (
+	scenarioTeacher int64 = 1
+	scenarioStudent int64 = 2
+)
+
+// Окружение сценария: учитель и ученик зарегистрированы, интерфейс на русском
+func newScenario(t *testing.T) *FakeMessenger {
+	t.Helper()
+	st, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	store = st
+	fm := NewFakeMessenger()
+	messenger = fm
+	bot = nil
+	config.TeacherID = scenarioTeacher
+	config.Timezone = "Europe/Moscow"
+
+	if err := store.RegisterUser(scenarioTeacher, "teacher", "teacher"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RegisterUser(scenarioStudent, "student", "student"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{scenarioTeacher, scenarioStudent} {
+		if err := store.SetUserLanguageCode(id, "ru"); err != nil {
+			t.Fatal(err)
+		}
+		forgetUserLang(id)
+	}
+	return fm
+}
+
+// Свободный слот учителя через days дней в 12:00 по времени расписания
+func addScenarioSlot(t *testing.T, days int) (int64, time.Time) {
+	t.Helper()
+	now := scheduleNow()
+	start := time.Date(now.Year(), now.Month(), now.Day()+days, 12, 0, 0, 0, time.UTC)
+	if err := store.AddScheduleSlot(scenarioTeacher, start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	schedules, err := store.GetTeacherSchedule(scenarioTeacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range schedules {
+		if s.StartTime == start.Format(time.RFC3339) {
+			return int64(s.ID), start
+		}
+	}
+	t.Fatal("слот не найден после добавления")
+	return 0, start
+}
+
+// Нажатие кнопки в личном чате
+func pressButton(chatID int64, data string) {
+	handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "query",
+		From:    &tgbotapi.User{ID: int(chatID)},
+		Data:    data,
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID, Type: "private"}},
+	}})
+}
+
+// Текстовое сообщение в личном чате
+func sendText(chatID int64, text string) {
+	handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 2,
+		From:      &tgbotapi.User{ID: int(chatID)},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Text:      text,
+	}})
+}
+
+// Команда в личном чате: "/book 2026-10-21 12:00"
+func sendCommand(chatID int64, text string) {
+	command := strings.Fields(text)[0]
+	handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 2,
+		From:      &tgbotapi.User{ID: int(chatID)},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Text:      text,
+		Entities:  &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}})
+}
+
+// Последнее сообщение в чат, которое должно существовать
+func lastSent(t *testing.T, fm *FakeMessenger, chatID int64) FakeMessage {
+	t.Helper()
+	m, ok := fm.LastSent(chatID)
+	if !ok {
+		t.Fatalf("в чат %d ничего не отправлено", chatID)
+	}
+	return m
+}
+
+func hasButton(m FakeMessage, data string) bool {
+	for _, d := range m.ButtonData() {
+		if d == data {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScenarioBooking(t *testing.T) {
+	fm := newScenario(t)
+	slotID, start := addScenarioSlot(t, 2)
+
+	// Ученик открывает день и видит кнопку записи на слот
+	pressButton(scenarioStudent, callbackData(cbBookDay, cbDate(start)))
+	book := callbackData(cbBook, cbID(slotID))
+	if m := lastSent(t, fm, scenarioStudent); !hasButton(m, book) {
+		t.Fatalf("нет кнопки записи %q: %v", book, m.ButtonData())
+	}
+
+	fm.Reset()
+	pressButton(scenarioStudent, book)
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.Status != "booked" || !slot.StudentID.Valid || slot.StudentID.Int64 != scenarioStudent {
+		t.Fatalf("слот не забронирован: %+v", slot)
+	}
+	if m := lastSent(t, fm, scenarioStudent); !strings.Contains(m.Text, "записаны") {
+		t.Errorf("ученику не подтверждена запись: %q", m.Text)
+	}
+	if len(fm.Answered) == 0 {
+		t.Error("нет ответа на callback-запрос")
+	}
+
+	// Повторная запись на занятый слот отклоняется
+	fm.Reset()
+	pressButton(scenarioStudent, book)
+	if m := lastSent(t, fm, scenarioStudent); m.Text != T("ru", "book.taken") {
+		t.Errorf("повторная запись: %q", m.Text)
+	}
+}
+
+// Хранилище, которое один раз вызывает between после чтения слота: так
+// другой ученик записывается между проверкой слота и записью на него
+type interleavedStore struct {
+	Store
+	between func()
+}
+
+func (s *interleavedStore) GetScheduleByID(scheduleID int64) (*Schedule, error) {
+	slot, err := s.Store.GetScheduleByID(scheduleID)
+	if f := s.between; f != nil {
+		s.between = nil
+		f()
+	}
+	return slot, err
+}
+
+func TestScenarioBookingRace(t *testing.T) {
+	fm := newScenario(t)
+	slotID, _ := addScenarioSlot(t, 2)
+	const secondStudent int64 = 3
+	if err := store.RegisterUser(secondStudent, "student", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetUserLanguageCode(secondStudent, "ru"); err != nil {
+		t.Fatal(err)
+	}
+	forgetUserLang(secondStudent)
+
+	// Первый ученик увидел свободный слот, а второй записался раньше него
+	book := callbackData(cbBook, cbID(slotID))
+	store = &interleavedStore{Store: store, between: func() { pressButton(secondStudent, book) }}
+	pressButton(scenarioStudent, book)
+
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.Status != "booked" || slot.StudentID.Int64 != secondStudent {
+		t.Fatalf("слот должен остаться за вторым учеником: %+v", slot)
+	}
+	if m := lastSent(t, fm, secondStudent); !strings.Contains(m.Text, "записаны") {
+		t.Errorf("второму ученику не подтверждена запись: %q", m.Text)
+	}
+	if m := lastSent(t, fm, scenarioStudent); m.Text != T("ru", "book.taken") {
+		t.Errorf("первому ученику: %q, ожидалось %q", m.Text, T("ru", "book.taken"))
+	}
+	if bookings, _ := store.GetStudentBookings(scenarioStudent); len(bookings) != 0 {
+		t.Errorf("первый ученик тоже записан: %+v", bookings)
+	}
+}
+
+func TestScenarioBookCommand(t *testing.T) {
+	fm := newScenario(t)
+	slotID, start := addScenarioSlot(t, 2)
+
+	// В то же время свободен другой учитель: без имени учителя запись не выбирается наугад
+	const otherTeacher int64 = 3
+	if err := store.RegisterUser(otherTeacher, "teacher", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddScheduleSlot(otherTeacher, start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	args := start.Format("2006-01-02") + " 12:00"
+	sendCommand(scenarioStudent, "/book "+args)
+	if bookings, _ := store.GetStudentBookings(scenarioStudent); len(bookings) != 0 {
+		t.Fatalf("записан без выбора учителя: %+v", bookings)
+	}
+	if m := fm.SentTo(scenarioStudent); len(m) == 0 || !strings.Contains(m[0].Text, "@second") {
+		t.Errorf("нет списка учителей: %+v", m)
+	}
+
+	sendCommand(scenarioStudent, "/book "+args+" @Teacher")
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.Status != "booked" || slot.StudentID.Int64 != scenarioStudent {
+		t.Errorf("запись не к тому учителю: %+v", slot)
+	}
+}
+
+func TestScenarioCancel(t *testing.T) {
+	fm := newScenario(t)
+	slotID, _ := addScenarioSlot(t, 3)
+	pressButton(scenarioStudent, callbackData(cbBook, cbID(slotID)))
+
+	// Чужой ученик не может отменить запись
+	if err := store.RegisterUser(3, "student", "other"); err != nil {
+		t.Fatal(err)
+	}
+	pressButton(3, callbackData(cbCancel, cbID(slotID)))
+	if slot, _ := store.GetScheduleByID(slotID); slot.Status != "booked" {
+		t.Fatalf("запись отменена чужим учеником: %+v", slot)
+	}
+
+	fm.Reset()
+	pressButton(scenarioStudent, callbackData(cbCancelList))
+	cancel := callbackData(cbCancel, cbID(slotID))
+	if m := lastSent(t, fm, scenarioStudent); !hasButton(m, cancel) {
+		t.Fatalf("нет кнопки отмены %q: %v", cancel, m.ButtonData())
+	}
+	pressButton(scenarioStudent, cancel)
+
+	slot, err := store.GetScheduleByID(slotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot.Status != "free" || slot.StudentID.Valid {
+		t.Fatalf("слот не освобожден: %+v", slot)
+	}
+	m := lastSent(t, fm, scenarioStudent)
+	if !strings.Contains(m.Text, "отменена") {
+		t.Errorf("ученику не подтверждена отмена: %q", m.Text)
+	}
+	if !hasButton(m, callbackData(cbCancelReasonSkip)) {
+		t.Errorf("нет вопроса о причине отмены: %v", m.ButtonData())
+	}
+	stats, err := store.GetTeacherStats(scenarioTeacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Cancellations != 1 {
+		t.Errorf("отмена не записана: %+v", stats)
+	}
+
+	// Причина отмены сохраняется и приходит учителю
+	fm.Reset()
+	sendText(scenarioStudent, "Заболел")
+	cancellations, err := store.GetStudentCancellations(scenarioTeacher, scenarioStudent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancellations) != 1 || cancellations[0].Reason != "Заболел" {
+		t.Errorf("причина отмены не сохранена: %+v", cancellations)
+	}
+	if m := lastSent(t, fm, scenarioTeacher); !strings.Contains(m.Text, "Заболел") {
+		t.Errorf("учителю не пришла причина отмены: %q", m.Text)
+	}
+}
+
+func TestScenarioSlotText(t *testing.T) {
+	fm := newScenario(t)
+
+	// Слоты из текста добавляются только после подтверждения
+	sendText(scenarioTeacher, "завтра 10:00-11:00")
+	confirm := callbackData(cbSlotTextConfirm)
+	if m := lastSent(t, fm, scenarioTeacher); !hasButton(m, confirm) {
+		t.Fatalf("нет кнопки подтверждения: %q %v", m.Text, m.ButtonData())
+	}
+	if schedule, _ := store.GetTeacherSchedule(scenarioTeacher); len(schedule) != 0 {
+		t.Fatalf("слоты добавлены до подтверждения: %+v", schedule)
+	}
+
+	pressButton(scenarioTeacher, confirm)
+	schedule, err := store.GetTeacherSchedule(scenarioTeacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 || !strings.HasSuffix(schedule[0].StartTime, "T10:00:00Z") {
+		t.Fatalf("слот не добавлен: %+v", schedule)
+	}
+
+	// Повторное нажатие ничего не добавляет
+	fm.Reset()
+	pressButton(scenarioTeacher, confirm)
+	if m := lastSent(t, fm, scenarioTeacher); m.Text != T("ru", "slottext.expired") {
+		t.Errorf("повторное подтверждение: %q", m.Text)
+	}
+}
+
+func TestScenarioSlotLabels(t *testing.T) {
+	fm := newScenario(t)
+	_, start := addScenarioSlot(t, 2)
+	halfPast := start.Add(6*time.Hour + 30*time.Minute)
+	if err := store.AddScheduleSlot(scenarioTeacher, halfPast.Format(time.RFC3339), halfPast.Add(time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+
+	// На кнопке время начала слота, а не только час
+	pressButton(scenarioStudent, callbackData(cbBookDay, cbDate(start)))
+	var labels []string
+	for _, row := range lastSent(t, fm, scenarioStudent).Keyboard.InlineKeyboard {
+		for _, b := range row {
+			labels = append(labels, b.Text)
+		}
+	}
+	for _, want := range []string{"12:00\n🟩", "18:30\n🟩"} {
+		found := false
+		for _, l := range labels {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("нет кнопки %q: %q", want, labels)
+		}
+	}
+}
+
+// Прошедшие занятия определяются по времени расписания, а не по часам сервера
+func TestScenarioLessonTimes(t *testing.T) {
+	fm := newScenario(t)
+	now := scheduleNow().Truncate(time.Minute)
+	book := func(start time.Time) int64 {
+		t.Helper()
+		startStr := start.Format(time.RFC3339)
+		if err := store.AddScheduleSlot(scenarioTeacher, startStr, start.Add(time.Hour).Format(time.RFC3339)); err != nil {
+			t.Fatal(err)
+		}
+		id, err := store.GetSlotIDByStart(scenarioTeacher, startStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateScheduleStatus(id, "booked", scenarioStudent, "Grammar"); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	// Час назад по Москве: на сервере в UTC это еще "будущее"
+	recent := book(now.Add(-time.Hour))
+	old := book(now.Add(-scheduleLookback - time.Hour))
+
+	showStudentTimeline(scenarioTeacher, scenarioStudent, 0)
+	m := lastSent(t, fm, scenarioTeacher)
+	if !strings.Contains(m.Text, "✔️ Занятие "+formatTime("ru", now.Add(-time.Hour).Format(time.RFC3339))) || strings.Contains(m.Text, "🕒") {
+		t.Errorf("прошедшее занятие в истории не отмечено:\n%s", m.Text)
+	}
+
+	schedules, err := store.GetTeacherSchedule(scenarioTeacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []string
+	for _, row := range lessonButtons("ru", schedules) {
+		for _, b := range row {
+			data = append(data, *b.CallbackData)
+		}
+	}
+	if len(data) != 1 || data[0] != callbackData(cbLesson, cbID(recent)) {
+		t.Errorf("кнопки занятий %v: ожидалось только занятие %d, без %d", data, recent, old)
+	}
+}
+
+func TestScenarioNotifications(t *testing.T) {
+	fm := newScenario(t)
+	slotID, _ := addScenarioSlot(t, 1)
+	pressButton(scenarioStudent, callbackData(cbBook, cbID(slotID)))
+
+	// Уведомления о записи приходят один раз
+	fm.Reset()
+	notifyNewBookings()
+	if m := lastSent(t, fm, scenarioTeacher); !strings.Contains(m.Text, "@student") {
+		t.Errorf("учителю не пришло уведомление о записи: %q", m.Text)
+	}
+	lastSent(t, fm, scenarioStudent)
+	fm.Reset()
+	notifyNewBookings()
+	if len(fm.Sent) != 0 {
+		t.Errorf("повторные уведомления о записи: %+v", fm.Sent)
+	}
+
+	// Напоминание ученику за 30 минут с кнопкой подтверждения
+	slotID, start := addScenarioSlot(t, 2)
+	pressButton(scenarioStudent, callbackData(cbBook, cbID(slotID)))
+	fm.Reset()
+	remindLessons(start.Add(-30 * time.Minute).Add(time.Second))
+	m := lastSent(t, fm, scenarioStudent)
+	if !hasButton(m, callbackData(cbReminderOK, cbID(slotID))) {
+		t.Errorf("нет напоминания ученику: %q %v", m.Text, m.ButtonData())
+	}
+	if len(fm.SentTo(scenarioTeacher)) != 0 {
+		t.Errorf("учителю напоминание раньше срока: %+v", fm.SentTo(scenarioTeacher))
+	}
+
+	// Учителю — за 10 минут
+	fm.Reset()
+	remindLessons(start.Add(-10 * time.Minute).Add(time.Second))
+	if len(fm.SentTo(scenarioTeacher)) != 1 || len(fm.SentTo(scenarioStudent)) != 0 {
+		t.Errorf("напоминание за 10 минут: учителю %+v, ученику %+v", fm.SentTo(scenarioTeacher), fm.SentTo(scenarioStudent))
+	}
+}
+
+func TestScenarioAgenda(t *testing.T) {
+	fm := newScenario(t)
+	addScenarioSlot(t, 0)
+	day := scheduleNow()
+	at := func(hour, minute int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.UTC)
+	}
+
+	// После перезапуска днем утренний план не отправляется
+	refreshAgendas(at(11, 0))
+	if len(fm.SentTo(scenarioTeacher)) != 0 {
+		t.Fatalf("план отправлен позже заданного времени: %+v", fm.SentTo(scenarioTeacher))
+	}
+
+	// В течение получаса после 08:00 — отправляется один раз
+	refreshAgendas(at(7, 59))
+	if len(fm.SentTo(scenarioTeacher)) != 0 {
+		t.Fatalf("план отправлен раньше заданного времени: %+v", fm.SentTo(scenarioTeacher))
+	}
+	refreshAgendas(at(8, 10))
+	refreshAgendas(at(8, 11))
+	if sent := fm.SentTo(scenarioTeacher); len(sent) != 1 || !strings.Contains(sent[0].Text, "12:00") {
+		t.Fatalf("план на день: %+v", sent)
+	}
+
+	// Отправленный план обновляется на месте и после окна отправки
+	if err := store.AddScheduleSlot(scenarioTeacher, at(15, 0).Format(time.RFC3339), at(16, 0).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	refreshAgendas(at(11, 0))
+	if len(fm.SentTo(scenarioTeacher)) != 1 || len(fm.Edited) != 1 || !strings.Contains(fm.Edited[0].Text, "15:00") {
+		t.Errorf("обновление плана: отправлено %+v, изменено %+v", fm.SentTo(scenarioTeacher), fm.Edited)
+	}
+}
+
+// План закрепляется только в группах, где это включено, и только при отправке
+func TestScenarioAgendaPin(t *testing.T) {
+	fm := newScenario(t)
+	addScenarioSlot(t, 0)
+	const pinned, plain int64 = -100801, -100802
+	for _, g := range []GroupChat{
+		{ChatID: pinned, TeacherID: scenarioTeacher, Title: "С закреплением", Agenda: true, PinAgenda: true, LinkedAt: "2026-01-01T00:00:00Z"},
+		{ChatID: plain, TeacherID: scenarioTeacher, Title: "Без закрепления", Agenda: true, LinkedAt: "2026-01-02T00:00:00Z"},
+	} {
+		if _, err := store.LinkGroupChat(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := scheduleNow()
+	at := func(hour int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, 10, 0, 0, time.UTC)
+	}
+
+	refreshAgendas(at(8))
+	sent := fm.SentTo(pinned)
+	if len(sent) != 1 || len(fm.SentTo(plain)) != 1 {
+		t.Fatalf("план в группах: %+v, %+v", sent, fm.SentTo(plain))
+	}
+	want := []FakeMessageRef{{ChatID: pinned, MessageID: sent[0].MessageID}}
+	if !reflect.DeepEqual(fm.Pinned, want) {
+		t.Errorf("закреплено %+v, ожидалось %+v", fm.Pinned, want)
+	}
+
+	// Обновление плана на месте не закрепляет его повторно
+	if err := store.AddScheduleSlot(scenarioTeacher, at(15).Format(time.RFC3339), at(16).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	refreshAgendas(at(11))
+	if len(fm.Edited) == 0 || len(fm.Pinned) != 1 {
+		t.Errorf("после обновления: изменено %+v, закреплено %+v", fm.Edited, fm.Pinned)
+	}
+}
+
+func TestScenarioBoardLinkNewStudent(t *testing.T) {
+	fm := newScenario(t)
+	slotID, _ := addScenarioSlot(t, 2)
+	const visitor int64 = 3
+
+	// Новый ученик пришел по кнопке «Записаться» с доски: сначала анкета
+	sendCommand(visitor, "/start "+boardSlotPayload+cbID(slotID))
+	if m := lastSent(t, fm, visitor); !strings.Contains(m.Text, T("ru", "profile.ask_name")) {
+		t.Fatalf("анкета не начата: %q", m.Text)
+	}
+	sendText(visitor, "Аня")
+	sendText(visitor, T("ru", "profile.skip"))
+	sendText(visitor, "B1")
+	pressButton(visitor, callbackData(cbProfileSkipGoals))
+	sendText(visitor, "speaking")
+
+	// После анкеты — предложение записаться на выбранный слот
+	m := lastSent(t, fm, visitor)
+	if !strings.Contains(m.Text, T("ru", "profile.done")) || !hasButton(m, callbackData(cbBook, cbID(slotID))) {
+		t.Errorf("после анкеты нет предложения слота: %q %v", m.Text, m.ButtonData())
+	}
+	if p, _ := store.GetStudentProfile(visitor); p == nil || !p.CompletedAt.Valid || p.Level.String != "B1" {
+		t.Errorf("анкета не сохранена: %+v", p)
+	}
+}
+
+func TestScenarioLinkChannelRequiresAdmin(t *testing.T) {
+	fm := newScenario(t)
+	const channel int64 = -100500
+
+	// Без аргумента в личном чате — список групп, как /groups
+	sendCommand(scenarioTeacher, "/linkgroup")
+	if m := lastSent(t, fm, scenarioTeacher); !strings.HasPrefix(m.Text, T("ru", "groups.title")) {
+		t.Errorf("/linkgroup без аргумента: %q", m.Text)
+	}
+
+	// Канал, которого Telegram не знает
+	sendCommand(scenarioTeacher, "/linkgroup @nowhere")
+	if m := lastSent(t, fm, scenarioTeacher); m.Text != T("ru", "groups.channel_not_found") {
+		t.Errorf("привязка неизвестного канала: %q", m.Text)
+	}
+
+	// Без подтверждения Telegram, что учитель — администратор канала, канал не привязывается
+	fm.Chats = append(fm.Chats, tgbotapi.Chat{ID: channel, Type: "channel", Title: "Канал", UserName: "english_news"})
+	sendCommand(scenarioTeacher, "/linkgroup "+strconv.FormatInt(channel, 10))
+	if m := lastSent(t, fm, scenarioTeacher); m.Text != T("ru", "groups.channel_not_admin") {
+		t.Errorf("привязка чужого канала: %q", m.Text)
+	}
+	if len(fm.SentTo(channel)) != 0 {
+		t.Errorf("бот написал в непроверенный канал: %+v", fm.SentTo(channel))
+	}
+	if g, err := store.GetGroupChat(channel, scenarioTeacher); err != nil || g != nil {
+		t.Errorf("канал привязан: %+v, %v", g, err)
+	}
+	// Обычный участник канала — тоже нет
+	fm.Members[[2]int64{channel, scenarioTeacher}] = "member"
+	sendCommand(scenarioTeacher, "/linkgroup @english_news")
+	if m := lastSent(t, fm, scenarioTeacher); m.Text != T("ru", "groups.channel_not_admin") {
+		t.Errorf("привязка канала участником: %q", m.Text)
+	}
+
+	// Администратор канала привязывает его по @username
+	fm.Members[[2]int64{channel, scenarioTeacher}] = "administrator"
+	sendCommand(scenarioTeacher, "/linkgroup @english_news")
+	if m := lastSent(t, fm, channel); m.Text != T(defaultLang, "groups.linked_channel") {
+		t.Errorf("в канал: %q", m.Text)
+	}
+	if g, err := store.GetGroupChat(channel, scenarioTeacher); err != nil || g == nil || g.Title != "Канал" {
+		t.Errorf("канал не привязан администратором: %+v, %v", g, err)
+	}
+}
+
+// Сообщение в группе от пользователя from
+func sendGroupMessageUpdate(chatID, from int64, text string, left *tgbotapi.User) {
+	msg := &tgbotapi.Message{
+		MessageID:      3,
+		From:           &tgbotapi.User{ID: int(from)},
+		Chat:           &tgbotapi.Chat{ID: chatID, Type: "supergroup", Title: "Группа"},
+		Text:           text,
+		LeftChatMember: left,
+	}
+	if strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		msg.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	handleUpdate(tgbotapi.Update{Message: msg})
+}
+
+func TestScenarioGroupLinking(t *testing.T) {
+	fm := newScenario(t)
+	const group int64 = -100700
+
+	// Команда другому боту группы не обрабатывается
+	sendGroupMessageUpdate(group, scenarioTeacher, "/linkgroup@other_bot", nil)
+	if g, _ := store.GetGroupChat(group, scenarioTeacher); g != nil {
+		t.Fatalf("группа привязана командой другому боту: %+v", g)
+	}
+
+	// Команда с именем этого бота (регистр не важен)
+	sendGroupMessageUpdate(group, scenarioTeacher, "/linkgroup@Tutor_Bot", nil)
+	if g, err := store.GetGroupChat(group, scenarioTeacher); err != nil || g == nil {
+		t.Fatalf("группа не привязана: %+v, %v", g, err)
+	}
+	if m := lastSent(t, fm, group); m.Text != T(defaultLang, "groups.linked") {
+		t.Errorf("в группе: %q", m.Text)
+	}
+
+	// Из группы вышел другой участник — привязка остается
+	sendGroupMessageUpdate(group, scenarioStudent, "", &tgbotapi.User{ID: int(scenarioStudent)})
+	if g, _ := store.GetGroupChat(group, scenarioTeacher); g == nil {
+		t.Fatal("группа отвязана после выхода участника")
+	}
+	// Бота удалили из группы — привязка снимается
+	self := fm.Self()
+	sendGroupMessageUpdate(group, scenarioTeacher, "", &self)
+	if g, _ := store.GetGroupChat(group, scenarioTeacher); g != nil {
+		t.Errorf("группа осталась привязанной после удаления бота: %+v", g)
+	}
+}
+
+// Кнопки доски ведут в личный чат с ботом по его имени из Telegram
+func TestScenarioBoardLinks(t *testing.T) {
+	fm := newScenario(t)
+	slotID, _ := addScenarioSlot(t, 1)
+	const group int64 = -100900
+	if _, err := store.LinkGroupChat(GroupChat{ChatID: group, TeacherID: scenarioTeacher, Board: true}); err != nil {
+		t.Fatal(err)
+	}
+	refreshSlotBoards(scenarioTeacher)
+	m := lastSent(t, fm, group)
+	want := "https://t.me/tutor_bot?start=" + boardSlotPayload + cbID(slotID)
+	if m.Keyboard == nil || len(m.Keyboard.InlineKeyboard) != 1 || m.Keyboard.InlineKeyboard[0][0].URL == nil ||
+		*m.Keyboard.InlineKeyboard[0][0].URL != want {
+		t.Fatalf("кнопка записи на доске: %+v, ожидалась ссылка %s", m.Keyboard, want)
+	}
+
+	// Имя бота неизвестно — доска без кнопок
+	fm.BotUser.UserName = ""
+	if _, keyboard, err := buildSlotBoard("ru", scenarioTeacher, scheduleNow()); err != nil || len(keyboard.InlineKeyboard) != 0 {
+		t.Errorf("кнопки без имени бота: %+v, %v", keyboard, err)
+	}
+}
+
+func TestScenarioBoardEditErrors(t *testing.T) {
+	fm := newScenario(t)
+	const group int64 = -100600
+	if _, err := store.LinkGroupChat(GroupChat{ChatID: group, TeacherID: scenarioTeacher, Board: true}); err != nil {
+		t.Fatal(err)
+	}
+	refreshSlotBoards(scenarioTeacher)
+	if len(fm.SentTo(group)) != 1 {
+		t.Fatalf("доска не опубликована: %+v", fm.SentTo(group))
+	}
+	editError := func(text string) {
+		fm.SendError = func(c tgbotapi.Chattable) error {
+			if _, ok := c.(tgbotapi.EditMessageTextConfig); ok {
+				return errors.New(text)
+			}
+			return nil
+		}
+	}
+
+	// Лимит запросов: новой доски нет, изменение повторяется при следующем обновлении
+	addScenarioSlot(t, 1)
+	changedBoards.Take()
+	editError("Too Many Requests: retry after 5")
+	refreshSlotBoards(scenarioTeacher)
+	if len(fm.SentTo(group)) != 1 {
+		t.Errorf("доска опубликована повторно после ошибки лимита: %+v", fm.SentTo(group))
+	}
+	if !changedBoards.Take()[scenarioTeacher] {
+		t.Error("повтор изменения доски не запланирован")
+	}
+
+	// Сообщение доски удалено из чата: публикуется новая доска
+	editError("Bad Request: message to edit not found")
+	refreshSlotBoards(scenarioTeacher)
+	if len(fm.SentTo(group)) != 2 {
+		t.Errorf("удаленная доска не опубликована заново: %+v", fm.SentTo(group))
+	}
+	b, err := store.GetSlotBoard(group, scenarioTeacher)
+	if err != nil || b == nil || b.MessageID != fm.SentTo(group)[1].MessageID {
+		t.Errorf("новая доска не сохранена: %+v, %v", b, err)
+	}
+}
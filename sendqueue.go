@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Ограничения Telegram на отправку сообщений
+const (
+	globalSendRate    = 30              // Сообщений в секунду на бота
+	privateChatBurst  = 3               // Сообщений подряд в личный чат
+	privateChatPeriod = time.Second     // Далее не чаще одного сообщения за период
+	groupChatBurst    = 3               // Сообщений подряд в группу
+	groupChatPeriod   = 3 * time.Second // 20 сообщений в минуту
+	maxSendAttempts   = 4               // Попыток на одно сообщение
+	sendBackoffBase   = 500 * time.Millisecond
+	maxSendBackoff    = 30 * time.Second // Дольше не ждем даже при retry_after
+)
+
+// Приоритет отправки: напоминания и уведомления обгоняют меню
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+)
+
+// PrioritySender — Messenger, который умеет отправлять с приоритетом
+type PrioritySender interface {
+	SendPriority(c tgbotapi.Chattable, p Priority) (tgbotapi.Message, error)
+}
+
+// Отправка с высоким приоритетом, если messenger это поддерживает
+func sendUrgent(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if ps, ok := messenger.(PrioritySender); ok {
+		return ps.SendPriority(c, PriorityHigh)
+	}
+	return messenger.Send(c)
+}
+
+// SendQueue — Messenger поверх другого Messenger с соблюдением лимитов Telegram:
+// общий лимит на бота, лимит на чат, повтор временных ошибок (в том числе 429
+// с retry_after) с нарастающей паузой. Send блокируется до результата и
+// возвращает ошибку последней попытки.
+type SendQueue struct {
+	next  Messenger
+	now   func() time.Time      // Часы; в тестах подменяются
+	sleep func(d time.Duration) // Ожидание по этим часам
+
+	high   chan *sendJob
+	normal chan *sendJob
+
+	mu    sync.Mutex
+	chats map[int64]*chatLimit
+}
+
+type sendJob struct {
+	c      tgbotapi.Chattable
+	result chan sendResult
+}
+
+type sendResult struct {
+	msg tgbotapi.Message
+	err error
+}
+
+// Лимит чата: запас сообщений и момент, до которого чат на паузе
+type chatLimit struct {
+	tokens      float64
+	updated     time.Time
+	pausedUntil time.Time
+}
+
+func NewSendQueue(next Messenger) *SendQueue {
+	q := newSendQueue(next, time.Now, time.Sleep)
+	go q.run(time.NewTicker(time.Second / globalSendRate).C)
+	return q
+}
+
+// Очередь с заданными часами; отправитель запускается отдельно с нужными тиками
+func newSendQueue(next Messenger, now func() time.Time, sleep func(time.Duration)) *SendQueue {
+	return &SendQueue{
+		next:   next,
+		now:    now,
+		sleep:  sleep,
+		high:   make(chan *sendJob),
+		normal: make(chan *sendJob),
+		chats:  make(map[int64]*chatLimit),
+	}
+}
+
+func (q *SendQueue) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return q.SendPriority(c, PriorityNormal)
+}
+
+func (q *SendQueue) SendPriority(c tgbotapi.Chattable, p Priority) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+	_, isDelete := c.(tgbotapi.DeleteMessageConfig)
+	for attempt := 1; ; attempt++ {
+		// Удаление не считается новым сообщением и не расходует лимит чата
+		if !isDelete || attempt > 1 {
+			q.waitChat(chatID)
+		}
+
+		job := &sendJob{c: c, result: make(chan sendResult, 1)}
+		if p == PriorityHigh {
+			q.high <- job
+		} else {
+			q.normal <- job
+		}
+		res := <-job.result
+		if res.err == nil {
+			return res.msg, nil
+		}
+
+		delay, retry := sendRetryDelay(res.err, attempt)
+		if !retry || attempt >= maxSendAttempts {
+			return res.msg, res.err
+		}
+		fmt.Println("Повтор отправки через", delay, "chatID:", chatID, "ошибка:", res.err)
+		q.pauseChat(chatID, delay)
+	}
+}
+
+func (q *SendQueue) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return q.next.AnswerCallbackQuery(config)
+}
+
+func (q *SendQueue) GetFileDirectURL(fileID string) (string, error) {
+	return q.next.GetFileDirectURL(fileID)
+}
+
+func (q *SendQueue) MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error) {
+	return q.next.MakeRequest(endpoint, params)
+}
+
+func (q *SendQueue) Self() tgbotapi.User {
+	return q.next.Self()
+}
+
+func (q *SendQueue) GetChat(config tgbotapi.ChatConfig) (tgbotapi.Chat, error) {
+	return q.next.GetChat(config)
+}
+
+func (q *SendQueue) PinChatMessage(config tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error) {
+	return q.next.PinChatMessage(config)
+}
+
+func (q *SendQueue) GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	return q.next.GetChatMember(config)
+}
+
+// Отправитель: один запрос на тик (globalSendRate в секунду), высокий приоритет первым
+func (q *SendQueue) run(ticks <-chan time.Time) {
+	for range ticks {
+		var job *sendJob
+		select {
+		case job = <-q.high:
+		default:
+			select {
+			case job = <-q.high:
+			case job = <-q.normal:
+			}
+		}
+		go func(job *sendJob) {
+			msg, err := q.next.Send(job.c)
+			job.result <- sendResult{msg, err}
+		}(job)
+	}
+}
+
+// Ожидание, пока чат сможет принять еще одно сообщение
+func (q *SendQueue) waitChat(chatID int64) {
+	if chatID == 0 {
+		return
+	}
+	burst, period := float64(privateChatBurst), privateChatPeriod
+	if chatID < 0 {
+		burst, period = float64(groupChatBurst), groupChatPeriod
+	}
+
+	for {
+		q.mu.Lock()
+		l, ok := q.chats[chatID]
+		now := q.now()
+		if !ok {
+			l = &chatLimit{tokens: burst, updated: now}
+			q.chats[chatID] = l
+		}
+		l.tokens += float64(now.Sub(l.updated)) / float64(period)
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+		l.updated = now
+
+		var wait time.Duration
+		switch {
+		case now.Before(l.pausedUntil):
+			wait = l.pausedUntil.Sub(now)
+		case l.tokens < 1:
+			wait = time.Duration((1 - l.tokens) * float64(period))
+		default:
+			l.tokens--
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+		q.sleep(wait)
+	}
+}
+
+// Пауза для чата перед повторной попыткой
+func (q *SendQueue) pauseChat(chatID int64, d time.Duration) {
+	if chatID == 0 {
+		q.sleep(d)
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	l, ok := q.chats[chatID]
+	if !ok {
+		l = &chatLimit{updated: q.now()}
+		q.chats[chatID] = l
+	}
+	if until := q.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Нужно ли повторить отправку и через какое время
+func sendRetryDelay(err error, attempt int) (time.Duration, bool) {
+	backoff := sendBackoffBase << (attempt - 1)
+	if backoff > maxSendBackoff {
+		backoff = maxSendBackoff
+	}
+
+	var apiErr tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			delay := time.Duration(apiErr.RetryAfter) * time.Second
+			return delay, delay <= maxSendBackoff
+		}
+		msg := strings.ToLower(apiErr.Message)
+		for _, transient := range []string{"too many requests", "internal server error", "bad gateway", "gateway timeout", "service unavailable"} {
+			if strings.Contains(msg, transient) {
+				return backoff, true
+			}
+		}
+		return 0, false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return backoff, true
+	}
+	return 0, false
+}
+
+// Чат, в который отправляется сообщение (0 — неизвестно)
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
+	case tgbotapi.PhotoConfig:
+		return m.ChatID
+	case tgbotapi.VoiceConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return m.ChatID
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Часы очереди: ожидание не спит, а сдвигает время вперед
+type fakeClock struct {
+	mu    sync.Mutex
+	t     time.Time
+	onNow func()
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onNow != nil {
+		c.onNow()
+	}
+	return c.t
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// Сколько времени прошло по часам очереди
+func (c *fakeClock) Elapsed(since time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t.Sub(since)
+}
+
+var clockStart = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// Очередь поверх FakeMessenger с поддельными часами; тики идут без пауз
+func newTestSendQueue(t *testing.T) (*SendQueue, *FakeMessenger, *fakeClock) {
+	fm := NewFakeMessenger()
+	clock := &fakeClock{t: clockStart}
+	q := newSendQueue(fm, clock.Now, clock.Sleep)
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case ticks <- time.Time{}:
+			case <-done:
+				close(ticks)
+				return
+			}
+		}
+	}()
+	go q.run(ticks)
+	return q, fm, clock
+}
+
+// Ошибка Telegram с заданным текстом и retry_after
+func apiError(message string, retryAfter int) error {
+	return tgbotapi.Error{Message: message, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: retryAfter}}
+}
+
+// Первые failures попыток отправки завершаются ошибкой err
+func failFirst(failures int, err error) (func(tgbotapi.Chattable) error, func() int) {
+	var mu sync.Mutex
+	attempts := 0
+	send := func(tgbotapi.Chattable) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts <= failures {
+			return err
+		}
+		return nil
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts
+	}
+	return send, count
+}
+
+func TestSendQueueRetryAfter(t *testing.T) {
+	q, fm, clock := newTestSendQueue(t)
+	var attempts func() int
+	fm.SendError, attempts = failFirst(1, apiError("Too Many Requests: retry after 5", 5))
+
+	if _, err := q.Send(tgbotapi.NewMessage(1, "привет")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if attempts() != 2 || len(fm.SentTo(1)) != 1 {
+		t.Errorf("попыток %d, доставлено %d; ожидалось 2 и 1", attempts(), len(fm.SentTo(1)))
+	}
+	// Повтор не раньше retry_after
+	if elapsed := clock.Elapsed(clockStart); elapsed != 5*time.Second {
+		t.Errorf("повтор через %v, ожидалось 5s", elapsed)
+	}
+}
+
+func TestSendQueueRetryLimit(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		attempts int
+		waited   time.Duration
+	}{
+		// Паузы растут: 500ms, 1s, 2s, затем попытки заканчиваются
+		{"временная ошибка", apiError("Bad Gateway", 0), maxSendAttempts, 3500 * time.Millisecond},
+		{"retry_after дольше предела", apiError("Too Many Requests: retry after 60", 60), 1, 0},
+		{"постоянная ошибка", apiError("Bad Request: chat not found", 0), 1, 0},
+		{"не ошибка Telegram", errors.New("сбой"), 1, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q, fm, clock := newTestSendQueue(t)
+			var attempts func() int
+			fm.SendError, attempts = failFirst(maxSendAttempts, c.err)
+
+			_, err := q.Send(tgbotapi.NewMessage(1, "привет"))
+			if !errors.Is(err, c.err) {
+				t.Errorf("ошибка %v, ожидалась %v", err, c.err)
+			}
+			if attempts() != c.attempts {
+				t.Errorf("попыток %d, ожидалось %d", attempts(), c.attempts)
+			}
+			if elapsed := clock.Elapsed(clockStart); elapsed != c.waited {
+				t.Errorf("ожидание %v, ожидалось %v", elapsed, c.waited)
+			}
+		})
+	}
+}
+
+func TestSendQueueChatLimit(t *testing.T) {
+	q, fm, clock := newTestSendQueue(t)
+
+	// В личный чат три сообщения подряд, четвертое — через privateChatPeriod;
+	// удаление лимит не расходует
+	for i := 0; i < privateChatBurst; i++ {
+		q.Send(tgbotapi.NewMessage(1, "сообщение"))
+	}
+	q.Send(tgbotapi.NewDeleteMessage(1, 1))
+	if elapsed := clock.Elapsed(clockStart); elapsed != 0 {
+		t.Errorf("ожидание в пределах запаса: %v", elapsed)
+	}
+	q.Send(tgbotapi.NewMessage(1, "сообщение"))
+	if elapsed := clock.Elapsed(clockStart); elapsed != privateChatPeriod {
+		t.Errorf("сверх запаса: ожидание %v, ожидалось %v", elapsed, privateChatPeriod)
+	}
+	if len(fm.SentTo(1)) != privateChatBurst+1 {
+		t.Errorf("доставлено %d сообщений", len(fm.SentTo(1)))
+	}
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	fm := NewFakeMessenger()
+	var mu sync.Mutex
+	var order []int64
+	fm.SendError = func(c tgbotapi.Chattable) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, chattableChatID(c))
+		return nil
+	}
+	sent := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order)
+	}
+	clock := &fakeClock{t: clockStart}
+	q := newSendQueue(fm, clock.Now, clock.Sleep)
+	ticks := make(chan time.Time)
+	defer close(ticks)
+	go q.run(ticks)
+
+	// Каждый отправитель проверяет лимит чата и сразу встает в очередь;
+	// onNow сообщает, что он дошел до этой проверки
+	queued := make(chan struct{}, 4)
+	clock.onNow = func() { queued <- struct{}{} }
+	var wg sync.WaitGroup
+	send := func(chatID int64, p Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.SendPriority(tgbotapi.NewMessage(chatID, "сообщение"), p)
+		}()
+		waitSignal(t, queued, "постановка в очередь")
+	}
+	send(1, PriorityNormal)
+	send(2, PriorityNormal)
+	send(3, PriorityHigh)
+	time.Sleep(50 * time.Millisecond) // Отправители ждут на каналах очереди
+
+	// Пока есть срочные сообщения, обычные ждут: первым уходит чат 3.
+	// Следующий тик — только после отправки предыдущего сообщения
+	for i := 1; i <= 3; i++ {
+		ticks <- time.Now()
+		for deadline := time.Now().Add(time.Second); sent() < i; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("сообщение не отправлено после тика")
+			}
+		}
+	}
+	wg.Wait()
+	if order[0] != 3 {
+		t.Errorf("порядок отправки %v: срочное сообщение не первое", order)
+	}
+}
+
+func TestSendRetryDelay(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		attempt int
+		delay   time.Duration
+		retry   bool
+	}{
+		{"retry_after", apiError("Too Many Requests: retry after 7", 7), 1, 7 * time.Second, true},
+		{"429 без retry_after", apiError("Too Many Requests", 0), 2, time.Second, true},
+		{"502", apiError("Bad Gateway", 0), 3, 2 * time.Second, true},
+		{"предел паузы", apiError("Service Unavailable", 0), 10, maxSendBackoff, true},
+		{"403", apiError("Forbidden: bot was blocked by the user", 0), 1, 0, false},
+	}
+	for _, c := range cases {
+		delay, retry := sendRetryDelay(c.err, c.attempt)
+		if delay != c.delay || retry != c.retry {
+			t.Errorf("%s: %v, %v; ожидалось %v, %v", c.name, delay, retry, c.delay, c.retry)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Настройки уведомлений учителя: всплывающие уведомления о записях и отменах,
+// напоминание перед занятием и план на день. Выключенные уведомления о записях
+// и отменах не всплывают, но остаются в списке «📬 Уведомления».
+
+const defaultAgendaTime = "08:00"
+
+// Готовые варианты времени плана на день
+var agendaTimes = []string{"07:00", "08:00", "09:00", "10:00"}
+
+// Переключатели на экране настроек
+const (
+	settingReminders     = "reminders"
+	settingBookings      = "bookings"
+	settingCancellations = "cancellations"
+	settingAgenda        = "agenda"
+	settingPinAgenda     = "pin"
+)
+
+var settingToggles = []string{settingReminders, settingBookings, settingCancellations, settingAgenda, settingPinAgenda}
+
+func defaultNotificationSettings(userID int64) NotificationSettings {
+	return NotificationSettings{
+		UserID:              userID,
+		EnableReminders:     true,
+		EnableNewBookings:   true,
+		EnableCancellations: true,
+		EnableAgenda:        true,
+		AgendaTime:          defaultAgendaTime,
+	}
+}
+
+// Настройки пользователя; если их не удалось прочитать — значения по умолчанию
+func notificationSettings(userID int64) NotificationSettings {
+	s, err := store.GetNotificationSettings(userID)
+	if err != nil {
+		fmt.Println("Ошибка получения настроек уведомлений:", err, "userID:", userID)
+		return defaultNotificationSettings(userID)
+	}
+	return *s
+}
+
+// Флаг настроек по имени переключателя; nil — неизвестный переключатель
+func settingFlag(s *NotificationSettings, setting string) *bool {
+	switch setting {
+	case settingReminders:
+		return &s.EnableReminders
+	case settingBookings:
+		return &s.EnableNewBookings
+	case settingCancellations:
+		return &s.EnableCancellations
+	case settingAgenda:
+		return &s.EnableAgenda
+	case settingPinAgenda:
+		return &s.PinAgenda
+	}
+	return nil
+}
+
+// Уведомление учителю: всплывающее, если событие включено, иначе только в списке
+func notifyTeacher(teacherID int64, enabled bool, message string) {
+	if enabled {
+		sendTemporaryNotification(teacherID, message)
+		return
+	}
+	if err := store.AddNotification(teacherID, message); err != nil {
+		fmt.Println("Ошибка добавления уведомления:", err)
+	}
+}
+
+// Экран настроек уведомлений
+func showNotificationSettings(chatID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "настройки уведомлений"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	s := notificationSettings(chatID)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, setting := range settingToggles {
+		mark := "⬜ "
+		if *settingFlag(&s, setting) {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(mark+T(lang, "settings."+setting), cbSettingToggle, setting),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "settings.agenda_time", s.AgendaTime), cbAgendaTime),
+			callbackButton(T(lang, "agenda.show"), cbAgenda),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "settings.back"), cbNotifications),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sendMessageWithKeyboard(chatID, T(lang, "settings.title", s.AgendaTime), &keyboard)
+}
+
+// Переключение настройки
+func toggleNotificationSetting(chatID int64, setting string) error {
+	s := notificationSettings(chatID)
+	flag := settingFlag(&s, setting)
+	if flag == nil {
+		return fmt.Errorf("неизвестная настройка: %s", setting)
+	}
+	*flag = !*flag
+	if err := store.SaveNotificationSettings(s); err != nil {
+		return err
+	}
+	showNotificationSettings(chatID)
+	return nil
+}
+
+// Выбор времени плана на день: готовые варианты или свое время сообщением;
+// notice — текст перед вопросом (например, об ошибке ввода)
+func askAgendaTime(chatID int64, notice string) error {
+	lang := userLang(chatID)
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, clock := range agendaTimes {
+		buttons = append(buttons, callbackButton(clock, cbAgendaSetTime, strings.Replace(clock, ":", "", 1)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(buttons...)}
+	return askInputWithButtons(chatID, dialogAgendaTime, "", notice+T(lang, "settings.agenda_time_prompt"), rows)
+}
+
+// Сохранение времени плана на день
+func setAgendaTime(chatID int64, hour, minute int) error {
+	s := notificationSettings(chatID)
+	s.AgendaTime = fmt.Sprintf("%02d:%02d", hour, minute)
+	if err := store.SaveNotificationSettings(s); err != nil {
+		return err
+	}
+	showNotificationSettings(chatID)
+	return nil
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogAgendaTime, Role: "teacher", Handle: func(c *DialogContext) error {
+		hour, minute, err := parseClock(c.Text())
+		if err != nil {
+			return askAgendaTime(c.ChatID, tr(c.ChatID, "slot.bad_time")+"\n\n")
+		}
+		return setAgendaTime(c.ChatID, hour, minute)
+	}})
+}
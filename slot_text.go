@@ -0,0 +1,448 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Добавление слотов текстом: "пн 18:00-19:30", "завтра 10-12 по 45 мин",
+// "каждую среду 17:00". Бот показывает получившиеся слоты и добавляет их
+// после подтверждения.
+
+const (
+	defaultSlotLength = time.Hour // Длина слота, если указано только начало
+	recurringWeeks    = 4         // На сколько недель вперед создавать "каждую среду"
+	maxProposedSlots  = 50        // Больше слотов за одно сообщение не создаем
+)
+
+// ProposedSlot — слот, разобранный из текста
+type ProposedSlot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Ошибка разбора с ключом текста для пользователя
+type slotTextError struct {
+	key string
+	arg string
+}
+
+func (e *slotTextError) Error() string {
+	return e.Text(defaultLang)
+}
+
+// Текст ошибки на языке пользователя
+func (e *slotTextError) Text(lang string) string {
+	if e.arg == "" {
+		return T(lang, e.key)
+	}
+	return T(lang, e.key, e.arg)
+}
+
+var (
+	reClockRange = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?-(\d{1,2})(?::(\d{2}))?$`)
+	reClock      = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	reHour       = regexp.MustCompile(`^\d{1,2}$`)
+	reDayMonth   = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{2}|\d{4}))?$`)
+	reISODate    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	reMinutes    = regexp.MustCompile(`^(\d{1,3})(?:мин|минут|минуты|min|mins|minutes|m)?$`)
+	reDash       = regexp.MustCompile(`\s*[-–—]\s*`)
+)
+
+// Дни недели в разных формах
+var slotTextWeekdays = map[string]time.Weekday{
+	"пн": time.Monday, "пон": time.Monday, "понедельник": time.Monday, "понедельникам": time.Monday,
+	"вт": time.Tuesday, "вторник": time.Tuesday, "вторникам": time.Tuesday,
+	"ср": time.Wednesday, "среда": time.Wednesday, "среду": time.Wednesday, "средам": time.Wednesday,
+	"чт": time.Thursday, "четверг": time.Thursday, "четвергам": time.Thursday,
+	"пт": time.Friday, "пятница": time.Friday, "пятницу": time.Friday, "пятницам": time.Friday,
+	"сб": time.Saturday, "суббота": time.Saturday, "субботу": time.Saturday, "субботам": time.Saturday,
+	"вс": time.Sunday, "воскресенье": time.Sunday, "воскресеньям": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday, "tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday, "thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday, "sat": time.Saturday, "saturday": time.Saturday,
+	"sun": time.Sunday, "sunday": time.Sunday,
+}
+
+// Относительные дни: смещение от сегодня
+var slotTextDays = map[string]int{
+	"сегодня": 0, "today": 0,
+	"завтра": 1, "tomorrow": 1,
+	"послезавтра": 2,
+}
+
+// Слова, которые ничего не меняют
+var slotTextFillers = map[string]bool{
+	"в": true, "во": true, "с": true, "со": true, "at": true, "on": true, "from": true,
+}
+
+// Разбор текста учителя в список слотов. now задает "сегодня".
+func parseSlotText(text string, now time.Time) ([]ProposedSlot, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	text = reDash.ReplaceAllString(text, "-")
+	text = strings.NewReplacer(",", " ", ";", " ").Replace(text)
+	tokens := strings.Fields(text)
+	if len(tokens) == 0 {
+		return nil, &slotTextError{key: "slottext.empty"}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var (
+		day       *time.Time
+		weekday   *time.Weekday
+		recurring bool
+		start     *time.Duration // Смещение от начала дня
+		end       *time.Duration
+		step      time.Duration
+		wantEnd   bool
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case slotTextFillers[tok]:
+		case tok == "каждый" || tok == "каждую" || tok == "каждое" || tok == "каждая" || tok == "every" ||
+			tok == "по" && i+1 < len(tokens) && isWeekdayToken(tokens[i+1]):
+			recurring = true
+		case tok == "до" || tok == "to" || tok == "till" || tok == "until":
+			wantEnd = true
+		case tok == "по" || tok == "by" || tok == "x":
+			if i+1 >= len(tokens) {
+				return nil, &slotTextError{key: "slottext.bad_length", arg: tok}
+			}
+			i++
+			m := reMinutes.FindStringSubmatch(tokens[i])
+			if m == nil {
+				return nil, &slotTextError{key: "slottext.bad_length", arg: tokens[i]}
+			}
+			minutes, _ := strconv.Atoi(m[1])
+			if minutes < 5 || minutes > 24*60 {
+				return nil, &slotTextError{key: "slottext.bad_length", arg: tokens[i]}
+			}
+			step = time.Duration(minutes) * time.Minute
+			// Единица измерения отдельным словом
+			if i+1 < len(tokens) && isMinutesUnit(tokens[i+1]) {
+				i++
+			}
+		case (isWeekdayToken(tok) || hasKey(slotTextDays, tok) || reDayMonth.MatchString(tok) || reISODate.MatchString(tok)) &&
+			(day != nil || weekday != nil):
+			return nil, &slotTextError{key: "slottext.two_days"}
+		case isWeekdayToken(tok):
+			wd := slotTextWeekdays[tok]
+			weekday = &wd
+		case hasKey(slotTextDays, tok):
+			d := today.AddDate(0, 0, slotTextDays[tok])
+			day = &d
+		case reDayMonth.MatchString(tok):
+			d, err := parseDayMonth(tok, today)
+			if err != nil {
+				return nil, err
+			}
+			day = &d
+		case reISODate.MatchString(tok):
+			d, err := time.Parse("2006-01-02", tok)
+			if err != nil {
+				return nil, &slotTextError{key: "slottext.bad_date", arg: tok}
+			}
+			day = &d
+		case reClockRange.MatchString(tok):
+			m := reClockRange.FindStringSubmatch(tok)
+			s, err := clockOffset(m[1], m[2])
+			if err != nil {
+				return nil, err
+			}
+			e, err := clockOffset(m[3], m[4])
+			if err != nil {
+				return nil, err
+			}
+			start, end = &s, &e
+		case reClock.MatchString(tok) || reHour.MatchString(tok) && (start != nil || day != nil || weekday != nil):
+			hour, minute := tok, ""
+			if m := reClock.FindStringSubmatch(tok); m != nil {
+				hour, minute = m[1], m[2]
+			}
+			t, err := clockOffset(hour, minute)
+			if err != nil {
+				return nil, err
+			}
+			if start == nil && !wantEnd {
+				start = &t
+			} else {
+				end = &t
+			}
+			wantEnd = false
+		default:
+			return nil, &slotTextError{key: "slottext.unknown", arg: tok}
+		}
+	}
+
+	if start == nil {
+		return nil, &slotTextError{key: "slottext.no_time"}
+	}
+	if end != nil && *end <= *start {
+		return nil, &slotTextError{key: "slottext.bad_range"}
+	}
+
+	// Дни, на которые создаются слоты
+	var days []time.Time
+	switch {
+	case weekday != nil:
+		first := today.AddDate(0, 0, (int(*weekday)-int(today.Weekday())+7)%7)
+		// Сегодняшний день недели, время которого уже прошло, — со следующей недели
+		if first.Equal(today) && !today.Add(*start).After(now) {
+			first = first.AddDate(0, 0, 7)
+		}
+		days = append(days, first)
+		if recurring {
+			for w := 1; w < recurringWeeks; w++ {
+				days = append(days, first.AddDate(0, 0, 7*w))
+			}
+		}
+	case recurring:
+		return nil, &slotTextError{key: "slottext.no_weekday"}
+	case day != nil:
+		days = append(days, *day)
+	default:
+		days = append(days, today)
+	}
+
+	var slots []ProposedSlot
+	for _, d := range days {
+		from := d.Add(*start)
+		switch {
+		case end == nil && step > 0:
+			slots = append(slots, ProposedSlot{Start: from, End: from.Add(step)})
+		case end == nil:
+			slots = append(slots, ProposedSlot{Start: from, End: from.Add(defaultSlotLength)})
+		case step == 0:
+			slots = append(slots, ProposedSlot{Start: from, End: d.Add(*end)})
+		default:
+			for s := from; !s.Add(step).After(d.Add(*end)); s = s.Add(step) {
+				slots = append(slots, ProposedSlot{Start: s, End: s.Add(step)})
+			}
+		}
+		if len(slots) > maxProposedSlots {
+			return nil, &slotTextError{key: "slottext.too_many", arg: strconv.Itoa(maxProposedSlots)}
+		}
+	}
+	if len(slots) == 0 {
+		return nil, &slotTextError{key: "slottext.bad_length", arg: step.String()}
+	}
+	return slots, nil
+}
+
+func isWeekdayToken(tok string) bool {
+	_, ok := slotTextWeekdays[tok]
+	return ok
+}
+
+func isMinutesUnit(tok string) bool {
+	switch tok {
+	case "мин", "минут", "минуты", "min", "mins", "minutes":
+		return true
+	}
+	return false
+}
+
+func hasKey(m map[string]int, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// Смещение от начала дня по часам и минутам
+func clockOffset(hour, minute string) (time.Duration, error) {
+	h, err := strconv.Atoi(hour)
+	if err != nil || h > 24 {
+		return 0, &slotTextError{key: "slottext.bad_time", arg: hour + ":" + minute}
+	}
+	m := 0
+	if minute != "" {
+		if m, err = strconv.Atoi(minute); err != nil || m > 59 {
+			return 0, &slotTextError{key: "slottext.bad_time", arg: hour + ":" + minute}
+		}
+	}
+	if h == 24 && m > 0 {
+		return 0, &slotTextError{key: "slottext.bad_time", arg: hour + ":" + minute}
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Дата вида "21.10" или "21.10.2026"; без года — ближайшая такая дата
+func parseDayMonth(tok string, today time.Time) (time.Time, error) {
+	m := reDayMonth.FindStringSubmatch(tok)
+	day, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	year := today.Year()
+	if m[3] != "" {
+		year, _ = strconv.Atoi(m[3])
+		if year < 100 {
+			year += 2000
+		}
+	}
+	d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if d.Day() != day || int(d.Month()) != month {
+		return time.Time{}, &slotTextError{key: "slottext.bad_date", arg: tok}
+	}
+	if m[3] == "" && d.Before(today) {
+		d = d.AddDate(1, 0, 0)
+	}
+	return d, nil
+}
+
+// Текстовое сообщение без ожидающего диалога: учитель добавляет слоты текстом
+func handleMessage(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if msg.Text == "" || !IsTeacher(chatID) {
+		return
+	}
+	lang := userLang(chatID)
+
+	now := scheduleNow()
+	slots, err := parseSlotText(msg.Text, now)
+	if err != nil {
+		reason := err.Error()
+		var textErr *slotTextError
+		if errors.As(err, &textErr) {
+			reason = textErr.Text(lang)
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(lang, "btn.menu"), cbMenu),
+			),
+		)
+		sendMessageWithKeyboard(chatID, htmlf(T(lang, "slottext.help"), reason), &keyboard)
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString(htmlf(T(lang, "slottext.preview"), msg.Text))
+	var accepted []ProposedSlot
+	for _, s := range slots {
+		line := formatSlotRange(lang, s)
+		startStr, endStr := s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339)
+		if s.Start.Before(now) {
+			builder.WriteString(htmlf("⚠️ %s — %s\n", line, T(lang, "slottext.past")))
+			continue
+		}
+		if err := store.ValidateScheduleSlot(chatID, startStr, endStr); err != nil {
+			builder.WriteString(htmlf("⚠️ %s — %s\n", line, slotRejectReason(lang, err)))
+			continue
+		}
+		builder.WriteString(htmlf("✅ %s\n", line))
+		accepted = append(accepted, s)
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if len(accepted) > 0 {
+		if err := waitInput(chatID, dialogSlotTextConfirm, encodeProposedSlots(accepted)); err != nil {
+			fmt.Println("Ошибка сохранения слотов из текста:", err, "chatID:", chatID)
+			sendMessage(chatID, T(lang, "error.generic"))
+			return
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "slottext.confirm", len(accepted)), cbSlotTextConfirm),
+			callbackButton(T(lang, "btn.cancel"), cbSlotTextCancel),
+		))
+	} else {
+		builder.WriteString(T(lang, "slottext.nothing"))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Добавление слотов после подтверждения
+func handleSlotTextConfirm(chatID int64) {
+	if err := authorizeTeacher(chatID, "добавление слотов текстом"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	lang := userLang(chatID)
+
+	data, ok := takeDialogData(chatID, dialogSlotTextConfirm)
+	slots, err := decodeProposedSlots(data)
+	if !ok || err != nil {
+		sendMessage(chatID, T(lang, "slottext.expired"))
+		return
+	}
+
+	var builder strings.Builder
+	var added []ProposedSlot
+	for _, s := range slots {
+		err := store.AddScheduleSlot(chatID, s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+		if err != nil {
+			fmt.Println("Ошибка добавления слота из текста:", err, "chatID:", chatID)
+			builder.WriteString(htmlf("⚠️ %s — %s\n", formatSlotRange(lang, s), slotRejectReason(lang, err)))
+			continue
+		}
+		added = append(added, s)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.calendar"), cbSlotCalendar),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, T(lang, "slottext.added", len(added), len(slots))+builder.String(), &keyboard)
+	announceFreeSlots(chatID, added)
+}
+
+func handleSlotTextCancel(chatID int64) {
+	takeDialogData(chatID, dialogSlotTextConfirm)
+	showTeacherMenu(chatID)
+}
+
+// Слоты в данных шага подтверждения: "начало/конец;начало/конец" в RFC3339
+func encodeProposedSlots(slots []ProposedSlot) string {
+	parts := make([]string, len(slots))
+	for i, s := range slots {
+		parts[i] = s.Start.Format(time.RFC3339) + "/" + s.End.Format(time.RFC3339)
+	}
+	return strings.Join(parts, ";")
+}
+
+func decodeProposedSlots(data string) ([]ProposedSlot, error) {
+	var slots []ProposedSlot
+	for _, part := range strings.Split(data, ";") {
+		start, end, ok := strings.Cut(part, "/")
+		if !ok {
+			return nil, fmt.Errorf("неверные данные слота: %q", part)
+		}
+		s, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, err
+		}
+		e, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, ProposedSlot{Start: s, End: e})
+	}
+	return slots, nil
+}
+
+// "Пн 21.10 18:00–19:30"
+func formatSlotRange(lang string, s ProposedSlot) string {
+	weekday := weekdayHeader(lang)[(int(s.Start.Weekday())+6)%7]
+	return fmt.Sprintf("%s %s–%s", weekday, formatTime(lang, s.Start.Format(time.RFC3339)), s.End.Format("15:04"))
+}
+
+// Причина, по которой слот не может быть добавлен
+func slotRejectReason(lang string, err error) string {
+	switch {
+	case errors.Is(err, ErrSlotExists):
+		return T(lang, "slottext.exists")
+	case errors.Is(err, ErrSlotExternallyBusy):
+		return T(lang, "slottext.busy")
+	}
+	return T(lang, "slottext.failed")
+}
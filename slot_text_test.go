@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// "Сейчас" для разбора: понедельник 19 октября 2026, 12:00 по времени расписания
+var slotTextNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestParseSlotText(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string // "2006-01-02 15:04-15:04"
+		err  string   // Ключ текста ошибки
+	}{
+		{text: "пн 18:00-19:30", want: []string{"2026-10-19 18:00-19:30"}},
+		// Сегодняшний день недели, время которого прошло, — следующая неделя
+		{text: "пн 10:00", want: []string{"2026-10-26 10:00-11:00"}},
+		{text: "Fri, 14:00—15:00", want: []string{"2026-10-23 14:00-15:00"}},
+		{text: "завтра 10-12 по 45 мин", want: []string{"2026-10-20 10:00-10:45", "2026-10-20 10:45-11:30"}},
+		{text: "послезавтра с 9 до 10:30", want: []string{"2026-10-21 09:00-10:30"}},
+		{text: "каждую среду 17:00", want: []string{
+			"2026-10-21 17:00-18:00", "2026-10-28 17:00-18:00", "2026-11-04 17:00-18:00", "2026-11-11 17:00-18:00"}},
+		{text: "21.10 18:30 по 90", want: []string{"2026-10-21 18:30-20:00"}},
+		// Прошедшая дата без года — следующий год
+		{text: "01.01 10:00", want: []string{"2027-01-01 10:00-11:00"}},
+		{text: "2026-11-05 8-9", want: []string{"2026-11-05 08:00-09:00"}},
+		// Прошедшее время сегодня разбирается; предупреждает о нем обработчик
+		{text: "сегодня 09:00", want: []string{"2026-10-19 09:00-10:00"}},
+		{text: "18:00", want: []string{"2026-10-19 18:00-19:00"}},
+
+		{text: "  ", err: "slottext.empty"},
+		{text: "завтра", err: "slottext.no_time"},
+		{text: "завтра 12-10", err: "slottext.bad_range"},
+		{text: "пн вт 10:00", err: "slottext.two_days"},
+		{text: "каждый 10:00", err: "slottext.no_weekday"},
+		{text: "завтра 25:00", err: "slottext.bad_time"},
+		{text: "завтра 10:00 по 4 мин", err: "slottext.bad_length"},
+		{text: "завтра 10-11 по 90", err: "slottext.bad_length"},
+		{text: "31.02 10:00", err: "slottext.bad_date"},
+		{text: "завтра 10:00 пожалуйста", err: "slottext.unknown"},
+		{text: "каждый пн 0-24 по 5", err: "slottext.too_many"},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			slots, err := parseSlotText(c.text, slotTextNow)
+			if c.err != "" {
+				var textErr *slotTextError
+				if !errors.As(err, &textErr) || textErr.key != c.err {
+					t.Errorf("ошибка %v, ожидалась %s", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ошибка: %v", err)
+			}
+			var got []string
+			for _, s := range slots {
+				got = append(got, s.Start.Format("2006-01-02 15:04")+"-"+s.End.Format("15:04"))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("получено %v, ожидалось %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	cases := []struct {
+		hour, minute string
+		want         time.Duration
+		ok           bool
+	}{
+		{"9", "", 9 * time.Hour, true},
+		{"18", "30", 18*time.Hour + 30*time.Minute, true},
+		{"0", "00", 0, true},
+		{"24", "", 24 * time.Hour, true},
+		{"24", "01", 0, false},
+		{"25", "", 0, false},
+		{"10", "60", 0, false},
+	}
+	for _, c := range cases {
+		got, err := clockOffset(c.hour, c.minute)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("clockOffset(%q, %q) = %v, %v", c.hour, c.minute, got, err)
+		}
+	}
+}
+
+// Время, введенное учителем в диалоге
+func TestParseClock(t *testing.T) {
+	cases := []struct {
+		text         string
+		hour, minute int
+		ok           bool
+	}{
+		{"18:30", 18, 30, true},
+		{" 7:05 ", 7, 5, true},
+		{"18.30", 18, 30, true},
+		{"9", 9, 0, true},
+		{"00:00", 0, 0, true},
+		{"24:00", 0, 0, false},
+		{"25", 0, 0, false},
+		{"18:60", 0, 0, false},
+		{"9:5", 0, 0, false},
+		{"вечером", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, c := range cases {
+		hour, minute, err := parseClock(c.text)
+		if (err == nil) != c.ok || hour != c.hour || minute != c.minute {
+			t.Errorf("parseClock(%q) = %d:%02d, %v", c.text, hour, minute, err)
+		}
+	}
+}
+
+func TestParseDayMonth(t *testing.T) {
+	today := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		tok  string
+		want string // Пусто — ошибка
+	}{
+		{"21.10", "2026-10-21"},
+		{"19.10", "2026-10-19"},
+		{"18.10", "2027-10-18"},
+		{"5.1", "2027-01-05"},
+		{"18.10.2026", "2026-10-18"},
+		{"29.02.28", "2028-02-29"},
+		{"29.02.2027", ""},
+		{"1.13", ""},
+		{"32.10", ""},
+	}
+	for _, c := range cases {
+		got, err := parseDayMonth(c.tok, today)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("parseDayMonth(%q) = %v, ожидалась ошибка", c.tok, got)
+			}
+			continue
+		}
+		if err != nil || got.Format("2006-01-02") != c.want {
+			t.Errorf("parseDayMonth(%q) = %v, %v; ожидалось %s", c.tok, got, err, c.want)
+		}
+	}
+}
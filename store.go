@@ -0,0 +1,165 @@
+package main
+
+import "time"
+
+// Store — доступ к данным бота. Обработчики работают только через него,
+// реализация по умолчанию — SQLiteStore (файл или ":memory:").
+type Store interface {
+	UserStore
+	SlotStore
+	BookingStore
+	NotificationStore
+	CalendarStore
+	DialogStore
+	ProfileStore
+	StudentStore
+	HomeworkStore
+	FeedbackStore
+	GroupStore
+	Close() error
+}
+
+// UserStore — пользователи (учителя и ученики)
+type UserStore interface {
+	RegisterUser(telegramID int64, role, username string) error
+	UserExists(telegramID int64) (bool, error)
+	GetUser(telegramID int64) (*User, error)
+	GetAllTeachers() ([]User, error)
+	GetAllStudents() ([]StudentExportRow, error)
+	SetDeliveryStatus(telegramID int64, status, reason string) error
+	GetUnreachableUsers() (map[int64]string, error)
+	SetUserLanguage(telegramID int64, lang string) error
+	SetUserLanguageCode(telegramID int64, code string) error
+	SetUserContact(telegramID int64, contact string) error
+}
+
+// SlotStore — слоты расписания
+type SlotStore interface {
+	AddScheduleSlot(teacherID int64, startTime, endTime string) error
+	ValidateScheduleSlot(teacherID int64, startTime, endTime string) error
+	DeleteScheduleSlot(scheduleID int64) error
+	GetScheduleByID(scheduleID int64) (*Schedule, error)
+	GetSlotIDByStart(teacherID int64, startTime string) (int64, error)
+	GetSlotsForDate(teacherID int64, date time.Time) ([]Schedule, error)
+	GetAvailableSlotsForDate(date time.Time) ([]Schedule, error)
+	GetAvailableSlots(now time.Time) ([]Schedule, error)
+	GetTeacherSchedule(teacherID int64) ([]Schedule, error)
+	GetSchedulesForExport(filter ScheduleFilter) ([]ScheduleExportRow, error)
+}
+
+// BookingStore — записи учеников и их уведомления
+type BookingStore interface {
+	UpdateScheduleStatus(scheduleID int64, status string, studentID int64, direction string) error
+	BookSlot(scheduleID, studentID int64, direction string) error
+	GetStudentBookings(studentID int64) ([]Schedule, error)
+	GetUpcomingBookings(after time.Time) ([]BookingNotification, error)
+	GetNewBookings() ([]BookingNotification, error)
+	GetNewCancellations() ([]CancellationNotification, error)
+	MarkBookingAsNotified(bookingID int) error
+	MarkCancellationAsNotified(cancellationID int) error
+	RecordCancellation(c Cancellation) error
+	SetCancellationReason(slotID, studentID int64, reason string) error
+}
+
+// NotificationStore — уведомления учителя
+type NotificationStore interface {
+	AddNotification(teacherID int64, message string) error
+	GetTeacherNotifications(teacherID int64) ([]Notification, error)
+	CountUnreadNotifications(teacherID int64) (int, error)
+	GetNotificationByID(notificationID int) (*Notification, error)
+	MarkNotificationAsRead(notificationID int) error
+	ClearTeacherNotifications(teacherID int64) error
+	GetNotificationSettings(userID int64) (*NotificationSettings, error)
+	SaveNotificationSettings(s NotificationSettings) error
+	GetDailyAgendas(day string) ([]DailyAgenda, error)
+	SaveDailyAgenda(a DailyAgenda) error
+	DeleteDailyAgendasBefore(day string) error
+}
+
+// CalendarStore — подписка на календарь и внешние календари
+type CalendarStore interface {
+	GetCalendarToken(telegramID int64) (string, error)
+	RotateCalendarToken(telegramID int64) (string, error)
+	GetUserByCalendarToken(token string) (*User, error)
+	GetCalendarEvents(telegramID int64) ([]CalendarEvent, error)
+	AddExternalCalendar(teacherID int64, url string) (int64, error)
+	GetExternalCalendars(teacherID int64) ([]ExternalCalendar, error)
+	DeleteExternalCalendar(teacherID, calendarID int64) error
+	ReplaceExternalBusy(calendar ExternalCalendar, intervals []BusyInterval) error
+	SetExternalCalendarError(calendarID int64, syncErr error) error
+	GetExternalBusy(teacherID int64, from, to string) ([]BusyInterval, error)
+	IsExternallyBusy(teacherID int64, startTime, endTime string) (bool, error)
+}
+
+// DialogStore — ожидание текстового ввода в чате
+type DialogStore interface {
+	SetDialogState(state DialogState) error
+	TakeDialogState(chatID int64) (*DialogState, error)
+	TakeExpiredDialogStates(now time.Time) ([]DialogState, error)
+}
+
+// ProfileStore — анкеты учеников
+type ProfileStore interface {
+	GetStudentProfile(telegramID int64) (*StudentProfile, error)
+	SaveStudentProfile(profile StudentProfile) error
+}
+
+// StudentStore — справочник учеников учителя и заметки о них
+type StudentStore interface {
+	GetStudentSummaries(teacherID int64, now time.Time) ([]StudentSummary, error)
+	SetStudentBlocked(teacherID, studentID int64, blocked bool) error
+	IsStudentBlocked(teacherID, studentID int64) (bool, error)
+	GetStudentLessons(teacherID, studentID int64) ([]Schedule, error)
+	GetStudentCancellations(teacherID, studentID int64) ([]Cancellation, error)
+	AddStudentNote(note StudentNote) error
+	GetStudentNotes(teacherID, studentID int64) ([]StudentNote, error)
+	GetLessonNotes(slotID int64) ([]StudentNote, error)
+	GetStudentNote(noteID int64) (*StudentNote, error)
+}
+
+// HomeworkStore — домашние задания к занятиям
+type HomeworkStore interface {
+	CreateHomework(hw Homework) (int64, error)
+	UpdateHomework(hw Homework) error
+	DeleteHomework(homeworkID int64) error
+	GetHomework(homeworkID int64) (*Homework, error)
+	GetLessonHomework(slotID int64) (*Homework, error)
+	GetTeacherHomework(teacherID int64) ([]Homework, error)
+	GetStudentHomework(studentID int64) ([]Homework, error)
+	GetHomeworkToSend(now time.Time) ([]Homework, error)
+	GetHomeworkToRemind(dueBefore time.Time) ([]Homework, error)
+	MarkHomeworkSent(homeworkID int64) error
+	MarkHomeworkReminded(homeworkID int64) error
+	AddHomeworkFile(file HomeworkFile) error
+	GetHomeworkFiles(homeworkID int64) ([]HomeworkFile, error)
+}
+
+// FeedbackStore — опросы после занятий и статистика учителя
+type FeedbackStore interface {
+	GetLessonsToSurvey(from, to time.Time) ([]BookingNotification, error)
+	CreateLessonFeedback(feedback LessonFeedback) (bool, error)
+	GetLessonFeedback(slotID int64) (*LessonFeedback, error)
+	SetLessonRating(slotID int64, rating int) error
+	SetLessonComment(slotID int64, comment string) error
+	SetLessonSummary(slotID int64, summary string) error
+	GetTeacherStats(teacherID int64) (*TeacherStats, error)
+	GetRatingStats(teacherID int64) ([]RatingStat, error)
+}
+
+// GroupStore — группы учителей, события для публикации в них и доски свободных слотов
+type GroupStore interface {
+	LinkGroupChat(g GroupChat) (bool, error)
+	UnlinkGroupChat(chatID, teacherID int64) error
+	RemoveGroupChat(chatID int64) error
+	MigrateGroupChat(oldChatID, newChatID int64) error
+	GetGroupChat(chatID, teacherID int64) (*GroupChat, error)
+	GetTeacherGroups(teacherID int64) ([]GroupChat, error)
+	GetGroupsForEvent(teacherID int64, event string) ([]GroupChat, error)
+	SetGroupEvent(chatID, teacherID int64, event string, enabled bool) error
+	GetSlotBoard(chatID, teacherID int64) (*SlotBoard, error)
+	SaveSlotBoard(b SlotBoard) error
+	DeleteSlotBoard(chatID, teacherID int64) error
+	GetBoardTeachers() ([]int64, error)
+}
+
+var _ Store = (*SQLiteStore)(nil)
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Справочник учеников для учителя: список без повторов с поиском и страницами,
+// карточка ученика с анкетой, занятиями, отменами и заметками
+
+const (
+	studentsPerPage  = 8  // Учеников на странице справочника
+	cardLessons      = 5  // Предстоящих и прошедших занятий в карточке
+	cardNotes        = 3  // Последних заметок в карточке
+	bookingSlotsList = 12 // Свободных слотов в выборе при записи ученика
+	maxNoteLength    = 1000
+	maxMessageLength = 2000
+)
+
+// Поисковый запрос в справочнике учеников (на время листания страниц)
+var (
+	studentSearches   = make(map[int64]string) // ChatID учителя -> запрос
+	studentSearchesMu sync.Mutex
+)
+
+func setStudentSearch(chatID int64, query string) {
+	studentSearchesMu.Lock()
+	defer studentSearchesMu.Unlock()
+	if query == "" {
+		delete(studentSearches, chatID)
+		return
+	}
+	studentSearches[chatID] = query
+}
+
+func studentSearch(chatID int64) string {
+	studentSearchesMu.Lock()
+	defer studentSearchesMu.Unlock()
+	return studentSearches[chatID]
+}
+
+// Список учеников с начала, без поиска
+func handleTeacherStudents(chatID int64) {
+	setStudentSearch(chatID, "")
+	showStudentDirectory(chatID, 0)
+}
+
+// /students [запрос]
+func handleStudentsCommand(chatID int64, query string) {
+	setStudentSearch(chatID, strings.TrimSpace(query))
+	showStudentDirectory(chatID, 0)
+}
+
+// Ученик подходит под запрос: имя, username или телефон
+func matchStudent(s StudentSummary, query string) bool {
+	query = strings.ToLower(strings.TrimPrefix(query, "@"))
+	for _, field := range []sql.NullString{s.Name, s.Username, s.Phone} {
+		if field.Valid && strings.Contains(strings.ToLower(field.String), query) {
+			return true
+		}
+	}
+	// Телефон ищем без пробелов и скобок
+	if digits := onlyDigits(query); digits != "" && s.Phone.Valid {
+		return strings.Contains(onlyDigits(s.Phone.String), digits)
+	}
+	return false
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Страница справочника учеников
+func showStudentDirectory(chatID int64, page int) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "просмотр учеников"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+
+	all, err := store.GetStudentSummaries(chatID, scheduleNow())
+	if err != nil {
+		fmt.Println("Ошибка получения учеников:", err)
+		sendMessage(chatID, T(lang, "students.error"))
+		return
+	}
+	query := studentSearch(chatID)
+	var students []StudentSummary
+	for _, s := range all {
+		if query == "" || matchStudent(s, query) {
+			students = append(students, s)
+		}
+	}
+
+	var builder strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	builder.WriteString(T(lang, "students.title", len(students)))
+	if query != "" {
+		builder.WriteString(htmlf(T(lang, "students.search_query"), query))
+	}
+	if len(students) == 0 {
+		if query != "" {
+			builder.WriteString(T(lang, "students.not_found_query"))
+		} else {
+			builder.WriteString(T(lang, "students.empty"))
+		}
+	}
+
+	pages := (len(students) + studentsPerPage - 1) / studentsPerPage
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	from := page * studentsPerPage
+	to := from + studentsPerPage
+	if to > len(students) {
+		to = len(students)
+	}
+	for _, s := range students[from:to] {
+		name := studentDisplayName(&User{TelegramID: s.TelegramID, Username: s.Username}, &StudentProfile{Name: s.Name})
+		builder.WriteString(formatStudentLine(lang, name, s))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "students.open_card", name), cbStudentCard, cbID(s.TelegramID)),
+		))
+	}
+
+	if pages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, callbackButton("⬅️", cbStudentsPage, cbID(int64(page-1))))
+		}
+		navRow = append(navRow, callbackButton(fmt.Sprintf("%d/%d", page+1, pages), cbNoop))
+		if page < pages-1 {
+			navRow = append(navRow, callbackButton("➡️", cbStudentsPage, cbID(int64(page+1))))
+		}
+		buttons = append(buttons, navRow)
+	}
+	searchRow := []tgbotapi.InlineKeyboardButton{callbackButton(T(lang, "students.search"), cbStudentsSearch)}
+	if query != "" {
+		searchRow = append(searchRow, callbackButton(T(lang, "students.search_reset"), cbTeacherStudents))
+	}
+	buttons = append(buttons, searchRow, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "btn.menu"), cbMenu),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Строка ученика в справочнике
+func formatStudentLine(lang, name string, s StudentSummary) string {
+	line := htmlf("👤 <b>%s</b>", name)
+	if s.Level.Valid {
+		line += htmlf(" · %s", s.Level.String)
+	}
+	line += T(lang, "students.counts", s.Upcoming, s.Past, s.Cancellations)
+	if s.Blocked {
+		line += " · 🚫"
+	}
+	return line + "\n"
+}
+
+// Карточка ученика для учителя
+func showStudentCard(chatID, studentID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "просмотр карточки ученика"); err != nil {
+		sendMessage(chatID, T(lang, "teacher.only"))
+		return
+	}
+	student, err := store.GetUser(studentID)
+	if err != nil || student.Role != "student" {
+		sendMessage(chatID, T(lang, "students.not_found"))
+		return
+	}
+	p, err := store.GetStudentProfile(studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения анкеты:", err, "studentID:", studentID)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	lessons, err := store.GetStudentLessons(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения занятий ученика:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	cancellations, err := store.GetStudentCancellations(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения отмен ученика:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+	notes, err := store.GetStudentNotes(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения заметок:", err)
+		sendMessage(chatID, T(lang, "error.generic"))
+		return
+	}
+
+	var upcoming, past []Schedule
+	now := scheduleNow()
+	for _, l := range lessons {
+		start, err := time.Parse(time.RFC3339, l.StartTime)
+		if err != nil {
+			continue
+		}
+		if start.Before(now) {
+			past = append(past, l)
+		} else {
+			upcoming = append(upcoming, l)
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(T(lang, "students.card"))
+	builder.WriteString(formatProfile(lang, student, p))
+	blocked, err := store.IsStudentBlocked(chatID, studentID)
+	if err != nil {
+		fmt.Println("Ошибка проверки блокировки ученика:", err)
+	}
+	if blocked {
+		builder.WriteString(T(lang, "students.blocked"))
+	}
+	if status := deliveryStatusText(lang, student.DeliveryStatus); status != "" {
+		builder.WriteString(status + "\n")
+	}
+
+	builder.WriteString(T(lang, "students.upcoming", len(upcoming)))
+	for i, l := range upcoming {
+		if i == cardLessons {
+			break
+		}
+		builder.WriteString(htmlf("🕒 %s (%s)\n", formatTime(lang, l.StartTime), l.Direction.String))
+	}
+	builder.WriteString(T(lang, "students.past", len(past)))
+	for i := len(past) - 1; i >= 0 && i >= len(past)-cardLessons; i-- {
+		builder.WriteString(htmlf("✔️ %s (%s)\n", formatTime(lang, past[i].StartTime), past[i].Direction.String))
+	}
+	// Посещаемость: проведенные занятия среди прошедших, включая отмененные
+	missed := 0
+	for _, c := range cancellations {
+		if start, err := time.Parse(time.RFC3339, c.StartTime); err == nil && start.Before(now) {
+			missed++
+		}
+	}
+	if total := len(past) + missed; total > 0 {
+		builder.WriteString(T(lang, "students.attendance", len(past), total, len(past)*100/total))
+	}
+	builder.WriteString(T(lang, "students.cancellations", len(cancellations)))
+	if len(cancellations) > 0 {
+		builder.WriteString(T(lang, "students.last_cancel", formatTime(lang, cancellations[0].StartTime)))
+		if cancellations[0].Reason != "" {
+			builder.WriteString(htmlf(T(lang, "students.cancel_reason"), cancellations[0].Reason))
+		}
+	}
+
+	if len(notes) > 0 {
+		builder.WriteString(T(lang, "students.notes", len(notes)))
+		for i, n := range notes {
+			if i == cardNotes {
+				break
+			}
+			builder.WriteString(formatNote(lang, n, true))
+		}
+	}
+
+	id := cbID(studentID)
+	blockButton := callbackButton(T(lang, "students.block"), cbStudentBlock, id, "1")
+	if blocked {
+		blockButton = callbackButton(T(lang, "students.unblock"), cbStudentBlock, id, "0")
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "students.message"), cbStudentMessage, id),
+			callbackButton(T(lang, "students.book"), cbStudentBookList, id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "students.add_note"), cbStudentNote, id),
+			callbackButton(T(lang, "students.timeline"), cbStudentTimeline, id, cbID(0)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			blockButton,
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(lang, "btn.students"), cbStudentsPage, cbID(0)),
+			callbackButton(T(lang, "btn.menu"), cbMenu),
+		),
+	)
+	sendMessageWithKeyboard(chatID, builder.String(), &keyboard)
+}
+
+// Имя ученика для сообщений учителю
+func studentName(studentID int64) string {
+	user, err := store.GetUser(studentID)
+	if err != nil {
+		return fmt.Sprintf("ID%d", studentID)
+	}
+	p, err := store.GetStudentProfile(studentID)
+	if err != nil {
+		fmt.Println("Ошибка получения анкеты:", err, "studentID:", studentID)
+	}
+	return studentDisplayName(user, p)
+}
+
+// Блокировка: заблокированный ученик не может записываться к этому учителю
+func handleBlockStudent(chatID, studentID int64, blocked bool) {
+	if err := authorizeTeacher(chatID, "блокировка ученика"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	if err := store.SetStudentBlocked(chatID, studentID, blocked); err != nil {
+		fmt.Println("Ошибка блокировки ученика:", err)
+		sendMessage(chatID, tr(chatID, "error.generic"))
+		return
+	}
+	showStudentCard(chatID, studentID)
+}
+
+// Свободные слоты учителя для записи ученика
+func showBookForStudent(chatID, studentID int64) {
+	lang := userLang(chatID)
+	if err := authorizeTeacher(chatID, "запись ученика"); err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	slots, err := store.GetTeacherSchedule(chatID)
+	if err != nil {
+		sendMessage(chatID, T(lang, "schedule.error"))
+		return
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	now := scheduleNow()
+	for _, s := range slots {
+		start, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil || s.Status != "free" || start.Before(now) {
+			continue
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			callbackButton("🕒 "+formatTime(lang, s.StartTime), cbStudentBookSlot, cbID(studentID), cbID(int64(s.ID))),
+		))
+		if len(buttons) == bookingSlotsList {
+			break
+		}
+	}
+
+	text := htmlf(T(lang, "students.book_choose"), studentName(studentID))
+	if len(buttons) == 0 {
+		text = T(lang, "students.book_no_slots")
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(T(lang, "students.back_to_card"), cbStudentCard, cbID(studentID)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sendMessageWithKeyboard(chatID, text, &keyboard)
+}
+
+// Запись ученика учителем на свой свободный слот
+func handleBookForStudent(chatID, studentID, slotID int64) {
+	slot, err := authorizeSlotOwner(chatID, slotID, "запись ученика")
+	if err != nil {
+		sendAuthError(chatID, err)
+		return
+	}
+	if slot.Status != "free" {
+		sendMessage(chatID, tr(chatID, "book.taken"))
+		return
+	}
+	start, err := time.Parse(time.RFC3339, slot.StartTime)
+	if err != nil || start.Before(scheduleNow()) {
+		sendMessage(chatID, tr(chatID, "book.past"))
+		return
+	}
+	student, err := store.GetUser(studentID)
+	if err != nil || student.Role != "student" {
+		sendMessage(chatID, tr(chatID, "students.not_found"))
+		return
+	}
+
+	direction := "Общее"
+	if slot.Direction.Valid {
+		direction = slot.Direction.String
+	} else if p, err := store.GetStudentProfile(studentID); err == nil && p != nil && p.Direction.Valid {
+		direction = directionName(defaultLang, p.Direction.String)
+	}
+	err = store.BookSlot(slotID, studentID, direction)
+	if errors.Is(err, ErrSlotTaken) {
+		sendMessage(chatID, tr(chatID, "book.taken"))
+		return
+	}
+	if err != nil {
+		sendMessage(chatID, tr(chatID, "book.error"))
+		return
+	}
+	// Учитель записал сам — уведомление о новой записи ему не нужно
+	if err := store.MarkBookingAsNotified(int(slotID)); err != nil {
+		fmt.Println("Ошибка отметки уведомления о записи:", err)
+	}
+
+	studentLang := userLang(studentID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callbackButton(T(studentLang, "menu.student.bookings"), cbMyBookings),
+		),
+	)
+	sendMessageWithKeyboard(studentID, T(studentLang, "students.booked", formatTime(studentLang, slot.StartTime)), &keyboard)
+
+	showStudentCard(chatID, studentID)
+}
+
+func init() {
+	registerDialogStep(DialogStep{Name: dialogStudentSearch, Role: "teacher", Handle: func(c *DialogContext) error {
+		query := c.Text()
+		if query == "" {
+			return c.Retry(tr(c.ChatID, "students.search_prompt"))
+		}
+		setStudentSearch(c.ChatID, query)
+		showStudentDirectory(c.ChatID, 0)
+		return nil
+	}})
+	registerDialogStep(DialogStep{Name: dialogStudentMessage, Role: "teacher", Handle: func(c *DialogContext) error {
+		studentID, err := strconv.ParseInt(c.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		text := c.Text()
+		if text == "" || utf8.RuneCountInString(text) > maxMessageLength {
+			return c.Retry(tr(c.ChatID, "students.message_bad"))
+		}
+		studentLang := userLang(studentID)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				callbackButton(T(studentLang, "btn.menu"), cbMenu),
+			),
+		)
+		if err := sendMessageWithKeyboard(studentID, T(studentLang, "students.message_from")+escapeHTML(text), &keyboard); err != nil {
+			sendMessage(c.ChatID, tr(c.ChatID, "students.message_failed"))
+			return nil
+		}
+		showStudentCard(c.ChatID, studentID)
+		return nil
+	}})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	updateModePolling = "polling"
+	updateModeWebhook = "webhook"
+
+	webhookPathPrefix   = "/telegram/"
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+	maxUpdateSize       = 1 << 20
+)
+
+// Запуск получения обновлений в режиме, выбранном в настройках (UPDATE_MODE).
+// Ожидающие обновления сохраняются при переключении между режимами:
+// вебхук снимается без drop_pending_updates, а включается только после
+// того, как обработчик уже слушает запросы.
+func startUpdates() (tgbotapi.UpdatesChannel, error) {
+	switch config.UpdateMode {
+	case updateModeWebhook:
+		return startWebhook()
+	case updateModePolling:
+		return startPolling()
+	default:
+		return nil, fmt.Errorf("неизвестный режим получения обновлений: %s", config.UpdateMode)
+	}
+}
+
+// Long polling через getUpdates
+func startPolling() (tgbotapi.UpdatesChannel, error) {
+	params := url.Values{}
+	params.Set("drop_pending_updates", "false")
+	if _, err := bot.MakeRequest("deleteWebhook", params); err != nil {
+		return nil, fmt.Errorf("ошибка при снятии вебхука: %v", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	return bot.GetUpdatesChan(u)
+}
+
+// Прием обновлений на секретный путь встроенного HTTP-сервера
+func startWebhook() (tgbotapi.UpdatesChannel, error) {
+	if config.WebhookURL == "" {
+		return nil, fmt.Errorf("не указан WEBHOOK_URL для режима вебхука")
+	}
+	if config.WebhookSecret == "" {
+		return nil, fmt.Errorf("не указан WEBHOOK_SECRET для режима вебхука")
+	}
+
+	updates := make(chan tgbotapi.Update, 100)
+	path := webhookPathPrefix + config.WebhookSecret
+	httpMux.HandleFunc(path, webhookHandler(config.WebhookSecret, updates))
+
+	params := url.Values{}
+	params.Set("url", strings.TrimRight(config.WebhookURL, "/")+path)
+	params.Set("secret_token", config.WebhookSecret)
+	params.Set("drop_pending_updates", "false")
+	params.Set("allowed_updates", `["message","callback_query"]`)
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		return nil, fmt.Errorf("ошибка установки вебхука: %v", err)
+	}
+
+	return updates, nil
+}
+
+// Обработчик вебхука: проверка секретного заголовка и передача обновления в канал
+func webhookHandler(secret string, updates chan<- tgbotapi.Update) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			fmt.Println("Отклонен запрос вебхука с неверным секретом от", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxUpdateSize))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.Unmarshal(body, &update); err != nil {
+			fmt.Println("Ошибка разбора обновления вебхука:", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-rootCtx.Done():
+			// Бот останавливается: Telegram доставит обновление повторно
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+			// Telegram повторит доставку, если не получит ответ 200
+			http.Error(w, "timeout", http.StatusServiceUnavailable)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	const secret = "s3cret"
+	const update = `{"update_id": 7, "message": {"message_id": 1, "chat": {"id": 42, "type": "private"}, "text": "/start"}}`
+	updates := make(chan tgbotapi.Update, 1)
+	handler := webhookHandler(secret, updates)
+
+	post := func(ctx context.Context, method, token, body string) int {
+		r := httptest.NewRequest(method, webhookPathPrefix+secret, strings.NewReader(body)).WithContext(ctx)
+		if token != "" {
+			r.Header.Set(webhookSecretHeader, token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	cases := []struct {
+		name, method, token, body string
+		want                      int
+	}{
+		{"нет секрета", http.MethodPost, "", update, http.StatusForbidden},
+		{"неверный секрет", http.MethodPost, "wrong", update, http.StatusForbidden},
+		{"секрет-префикс", http.MethodPost, secret[:3], update, http.StatusForbidden},
+		{"не POST", http.MethodGet, secret, update, http.StatusMethodNotAllowed},
+		{"неверный JSON", http.MethodPost, secret, `{"update_id": `, http.StatusBadRequest},
+		{"не объект", http.MethodPost, secret, `[1, 2]`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := post(context.Background(), c.method, c.token, c.body); got != c.want {
+				t.Errorf("код %d, ожидался %d", got, c.want)
+			}
+			if len(updates) != 0 {
+				t.Errorf("отклоненное обновление передано дальше: %+v", <-updates)
+			}
+		})
+	}
+
+	// Верный секрет: обновление передается обработчикам
+	if got := post(context.Background(), http.MethodPost, secret, update); got != http.StatusOK {
+		t.Fatalf("код %d, ожидался 200", got)
+	}
+	select {
+	case u := <-updates:
+		if u.UpdateID != 7 || u.Message == nil || u.Message.Chat.ID != 42 || u.Message.Text != "/start" {
+			t.Errorf("получено обновление %+v", u)
+		}
+	default:
+		t.Fatal("обновление не передано")
+	}
+
+	// Очередь заполнена и запрос прерван: не 200, чтобы Telegram доставил обновление повторно
+	updates <- tgbotapi.Update{UpdateID: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := post(ctx, http.MethodPost, secret, update); got != http.StatusServiceUnavailable {
+		t.Errorf("переполненная очередь: код %d, ожидался 503", got)
+	}
+}
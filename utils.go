@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -9,54 +11,115 @@ import (
 )
 
 // Отправка текстового сообщения
-func sendMessage(chatID int64, text string) {
+func sendMessage(chatID int64, text string) error {
+	return sendTextMessage(chatID, text, PriorityNormal)
+}
+
+// Отправка напоминания или уведомления вне очереди обычных сообщений
+func sendUrgentMessage(chatID int64, text string) error {
+	return sendTextMessage(chatID, text, PriorityHigh)
+}
+
+func sendTextMessage(chatID int64, text string, priority Priority) error {
 	// Удаляем предыдущее сообщение бота, если оно есть
-	if lastID, exists := lastMessageID[chatID]; exists {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
 		deleteMessage(chatID, lastID)
 	}
 
 	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = "Markdown"
-	newMsg, err := bot.Send(msg)
+	msg.ParseMode = parseMode
+	send := messenger.Send
+	if priority == PriorityHigh {
+		send = sendUrgent
+	}
+	newMsg, err := send(msg)
 	if err != nil {
-		return
+		fmt.Println("Ошибка отправки сообщения:", err, "chatID:", chatID)
+		return err
 	}
-	lastMessageID[chatID] = newMsg.MessageID // Сохраняем новый ID сообщения
+	lastMessageID.Set(chatID, newMsg.MessageID) // Сохраняем новый ID сообщения
+	return nil
 }
 
 // Отправка сообщения с клавиатурой
-func sendMessageWithKeyboard(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
-	if lastID, exists := lastMessageID[chatID]; exists {
+func sendMessageWithKeyboard(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
 		deleteMessage(chatID, lastID)
 		fmt.Println("Deleted previous message ID:", lastID, "for chatID:", chatID)
 	}
 
 	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = "Markdown"
+	msg.ParseMode = parseMode
 	if keyboard != nil {
 		msg.ReplyMarkup = keyboard
 	}
 
-	newMsg, err := bot.Send(msg)
+	newMsg, err := messenger.Send(msg)
 	if err != nil {
 		fmt.Println("Ошибка отправки сообщения с клавиатурой:", err, "chatID:", chatID)
-		return
+		return err
 	}
-	lastMessageID[chatID] = newMsg.MessageID
+	lastMessageID.Set(chatID, newMsg.MessageID)
 	fmt.Println("Sent new message ID:", newMsg.MessageID, "for chatID:", chatID)
+	return nil
+}
+
+// Отправка сообщения с обычной (не inline) клавиатурой или ее удалением
+func sendMessageWithReplyMarkup(chatID int64, text string, markup interface{}) error {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
+		deleteMessage(chatID, lastID)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = parseMode
+	msg.ReplyMarkup = markup
+
+	newMsg, err := messenger.Send(msg)
+	if err != nil {
+		fmt.Println("Ошибка отправки сообщения с клавиатурой:", err, "chatID:", chatID)
+		return err
+	}
+	lastMessageID.Set(chatID, newMsg.MessageID)
+	return nil
+}
+
+// Отправка сообщения без разметки (для текста с пользовательскими данными и ссылками)
+func sendPlainMessageWithKeyboard(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
+		deleteMessage(chatID, lastID)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+
+	newMsg, err := messenger.Send(msg)
+	if err != nil {
+		fmt.Println("Ошибка отправки сообщения:", err, "chatID:", chatID)
+		return err
+	}
+	lastMessageID.Set(chatID, newMsg.MessageID)
+	return nil
 }
 
 func updateMessageWithKeyboard(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
-	if lastID, exists := lastMessageID[chatID]; exists {
+	if lastID, exists := lastMessageID.Get(chatID); exists {
 		// Обновляем текст
 		editMsg := tgbotapi.NewEditMessageText(chatID, lastID, text)
-		editMsg.ParseMode = "Markdown"
-		bot.Send(editMsg)
+		editMsg.ParseMode = parseMode
+		if _, err := messenger.Send(editMsg); err != nil {
+			fmt.Println("Ошибка изменения сообщения:", err, "chatID:", chatID)
+			return
+		}
 
 		// Обновляем клавиатуру
 		if keyboard != nil {
 			editMarkup := tgbotapi.NewEditMessageReplyMarkup(chatID, lastID, *keyboard)
-			bot.Send(editMarkup)
+			if _, err := messenger.Send(editMarkup); err != nil {
+				fmt.Println("Ошибка изменения клавиатуры:", err, "chatID:", chatID)
+			}
 		}
 	} else {
 		sendMessageWithKeyboard(chatID, text, keyboard)
@@ -83,7 +146,7 @@ func FormatDuration(start, end string) string {
 
 // Проверка, является ли пользователь учителем
 func IsTeacher(telegramID int64) bool {
-	user, err := getUser(telegramID)
+	user, err := store.GetUser(telegramID)
 	if err != nil {
 		return false
 	}
@@ -92,7 +155,7 @@ func IsTeacher(telegramID int64) bool {
 
 // Проверка, является ли пользователь учеником
 func IsStudent(telegramID int64) bool {
-	user, err := getUser(telegramID)
+	user, err := store.GetUser(telegramID)
 	if err != nil {
 		return false
 	}
@@ -100,38 +163,38 @@ func IsStudent(telegramID int64) bool {
 }
 
 // Генерация текста для отображения расписания
-func FormatSchedule(schedules []Schedule) string {
+func FormatSchedule(lang string, schedules []Schedule) string {
 	if len(schedules) == 0 {
-		return "Нет доступных слотов."
+		return T(lang, "schedule.empty")
 	}
 
 	var builder strings.Builder
-	builder.WriteString("Расписание:\n")
+	builder.WriteString(T(lang, "schedule.title"))
 	for _, s := range schedules {
-		builder.WriteString(fmt.Sprintf(
+		builder.WriteString(htmlf(
 			"📅 %s - %s [%s]\n",
-			formatTime(s.StartTime),
-			formatTime(s.EndTime),
-			s.Status,
+			formatTime(lang, s.StartTime),
+			formatTime(lang, s.EndTime),
+			statusText(lang, s.Status),
 		))
 	}
 	return builder.String()
 }
 
 // Генерация текста для отображения записей ученика
-func FormatBookings(bookings []Schedule) string {
+func FormatBookings(lang string, bookings []Schedule) string {
 	if len(bookings) == 0 {
-		return "У вас нет активных записей."
+		return T(lang, "bookings.empty")
 	}
 
 	var builder strings.Builder
-	builder.WriteString("Ваши записи:\n")
+	builder.WriteString(T(lang, "bookings.title"))
 	for _, b := range bookings {
-		builder.WriteString(fmt.Sprintf(
+		builder.WriteString(htmlf(
 			"📌 %s - %s (%s)\n",
-			formatTime(b.StartTime),
-			formatTime(b.EndTime),
-			b.Direction,
+			formatTime(lang, b.StartTime),
+			formatTime(lang, b.EndTime),
+			b.Direction.String,
 		))
 	}
 	return builder.String()
@@ -165,6 +228,15 @@ func GenerateUniqueID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
+// Генерация случайного секретного токена
+func generateToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // Проверка, что строка содержит только буквы и цифры
 func IsAlphanumeric(s string) bool {
 	for _, r := range s {
@@ -190,8 +262,3 @@ func IdsValidDirection(direction string) bool {
 	}
 	return false
 }
-
-func isValidTime(timeStr string) bool {
-	_, err := time.Parse("2006-01-02T15:04", timeStr)
-	return err == nil
-}